@@ -0,0 +1,64 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ErrDenoNotFound is returned when the given Deno binary does not exist, or no
+// Deno binary path was given and none could be discovered.
+var ErrDenoNotFound = errors.New("deno binary not found")
+
+// ErrDenoNotExecutable is returned when the Deno binary exists but can not be run, eg: it is
+// missing the execute permission, is a directory or was built for another OS or architecture.
+var ErrDenoNotExecutable = errors.New("deno binary not executable")
+
+// ErrScriptNotFound is returned by Start when the Deno process died while starting up because its script does not exist.
+var ErrScriptNotFound = errors.New("deno script not found")
+
+// resolveDenoBinary checks path is an executable when given, otherwise it discovers a Deno binary
+// by searching PATH and then the conventional install location of the Deno install script.
+func resolveDenoBinary(path string) (string, error) {
+	if path != "" {
+		if _, err := exec.LookPath(path); err != nil && !errors.Is(err, exec.ErrDot) {
+			return "", classifyDenoBinaryError(path, err)
+		}
+		return path, nil
+	}
+
+	searched := []string{"PATH"}
+	if found, err := exec.LookPath(denoBinaryName()); err == nil {
+		return found, nil
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		candidate := filepath.Join(home, ".deno", "bin", denoBinaryName())
+		searched = append(searched, candidate)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"%w, searched: %s. Install Deno (see https://docs.deno.com/runtime/getting_started/installation/) or set the path to the binary explicitly",
+		ErrDenoNotFound, strings.Join(searched, ", "),
+	)
+}
+
+// classifyDenoBinaryError wraps err, a failure to find or run the Deno binary at path, in ErrDenoNotFound
+// or ErrDenoNotExecutable when it is one of them. Any other error is returned unchanged.
+func classifyDenoBinaryError(path string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, exec.ErrNotFound):
+		return fmt.Errorf("%w at %s: %w", ErrDenoNotFound, path, err)
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.ENOEXEC), errors.Is(err, syscall.EISDIR):
+		return fmt.Errorf("%w at %s: %w", ErrDenoNotExecutable, path, err)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package deno
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// writeFakeDenoBinary creates an empty executable named like the Deno binary in dir.
+func writeFakeDenoBinary(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, denoBinaryName())
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+	return path
+}
+
+func TestResolveDenoBinary_Explicit(t *testing.T) {
+	expected := writeFakeDenoBinary(t, t.TempDir())
+	path, err := resolveDenoBinary(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, path)
+}
+
+func TestResolveDenoBinary_ExplicitNotFound(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), denoBinaryName())
+	_, err := resolveDenoBinary(missing)
+	assert.IsError(t, err, ErrDenoNotFound)
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.Contains(t, err.Error(), missing)
+}
+
+func TestResolveDenoBinary_ExplicitNotExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no execute permission")
+	}
+	path := filepath.Join(t.TempDir(), denoBinaryName())
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o644))
+
+	_, err := resolveDenoBinary(path)
+	assert.IsError(t, err, ErrDenoNotExecutable)
+	assert.NotIsError(t, err, ErrDenoNotFound)
+
+	_, err = resolveDenoBinary(t.TempDir())
+	assert.IsError(t, err, ErrDenoNotExecutable)
+}
+
+func TestClassifyDenoBinaryError(t *testing.T) {
+	assert.IsError(t, classifyDenoBinaryError("deno", &fs.PathError{Op: "fork/exec", Path: "deno", Err: syscall.ENOENT}), ErrDenoNotFound)
+	assert.IsError(t, classifyDenoBinaryError("deno", &fs.PathError{Op: "fork/exec", Path: "deno", Err: syscall.EACCES}), ErrDenoNotExecutable)
+	assert.IsError(t, classifyDenoBinaryError("deno", &fs.PathError{Op: "fork/exec", Path: "deno", Err: syscall.ENOEXEC}), ErrDenoNotExecutable)
+
+	other := errors.New("boom")
+	assert.Equal(t, other, classifyDenoBinaryError("deno", other))
+}
+
+func TestResolveDenoBinary_Path(t *testing.T) {
+	binDir := t.TempDir()
+	expected := writeFakeDenoBinary(t, binDir)
+	t.Setenv("PATH", binDir)
+	t.Setenv("HOME", t.TempDir())
+
+	path, err := resolveDenoBinary("")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, path)
+}
+
+func TestResolveDenoBinary_HomeFallback(t *testing.T) {
+	home := t.TempDir()
+	expected := writeFakeDenoBinary(t, filepath.Join(home, ".deno", "bin"))
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	path, err := resolveDenoBinary("")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, path)
+}
+
+func TestResolveDenoBinary_NotFound(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	_, err := resolveDenoBinary("")
+	assert.IsError(t, err, ErrDenoNotFound)
+	assert.Contains(t, err.Error(), "PATH")
+	assert.Contains(t, err.Error(), filepath.Join(home, ".deno", "bin", denoBinaryName()))
+}
+
+func TestDenoClient_StartWithoutDenoBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	c := NewDenoClient("", "fake.ts", "/dev/null", nil, nil)
+	assert.IsError(t, c.Start(t.Context()), ErrDenoNotFound)
+}
+
+func TestDenoClient_ConfigReportsResolvedDenoBinary(t *testing.T) {
+	binDir := t.TempDir()
+	expected := writeFakeDenoBinary(t, binDir)
+	t.Setenv("PATH", binDir)
+	t.Setenv("HOME", t.TempDir())
+
+	c := NewDenoClient("", "fake.ts", "/dev/null", nil, nil)
+	c.DryRun = true
+	assert.Equal(t, "", c.Config().DenoBinaryPath)
+
+	assert.IsError(t, c.Start(t.Context()), ErrDryRun)
+	assert.Equal(t, expected, c.Config().DenoBinaryPath)
+}
+
+func TestDenoClient_StartWithNonExecutableDenoBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no execute permission")
+	}
+	path := filepath.Join(t.TempDir(), denoBinaryName())
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o644))
+
+	c := NewDenoClient(path, "fake.ts", "/dev/null", nil, nil)
+	assert.IsError(t, c.Start(t.Context()), ErrDenoNotExecutable)
+}
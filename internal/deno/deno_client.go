@@ -3,15 +3,23 @@ package deno
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/sourcegraph/jsonrpc2"
@@ -27,97 +35,452 @@ type DenoClient struct {
 	process        *exec.Cmd
 	rpcMethods     func(ctx context.Context, c *jsonrpc2.Conn) map[string]any
 	Socket         *jsocket.JSocket
+
+	// StartupTimeout bounds how long Start waits for the script to become healthy.
+	StartupTimeout time.Duration
+
+	// ShutdownGracePeriod is how long Stop waits for the process to exit after the shutdown
+	// notification, and again after SIGTERM, before killing it.
+	ShutdownGracePeriod time.Duration
+
+	// DrainTimeout is how long Stop waits for in-flight calls, eg: a Create on another goroutine,
+	// to finish before asking the process to shutdown. Zero does not wait.
+	DrainTimeout time.Duration
+
+	// ReusePolicy decides what happens to the Deno process after a call fails with a fatal error.
+	ReusePolicy ReusePolicy
+
+	// CallTimeout bounds how long any single call may take, zero means no timeout.
+	CallTimeout time.Duration
+
+	// MethodTimeouts overrides CallTimeout for specific methods, keyed by method name.
+	MethodTimeouts map[string]time.Duration
+
+	// RestartOnCrash relaunches the Deno process when it dies unexpectedly, eg: OOM
+	// or a panic in the script, and retries the in-flight call once.
+	RestartOnCrash bool
+
+	// MaxRestarts bounds how many times a crashed process is relaunched.
+	MaxRestarts int
+
+	// RestartBackoff is the delay before the first crash restart, doubled for each subsequent restart up to 30s.
+	RestartBackoff time.Duration
+
+	// PermissionResolver, when set, is called by Start to compute the effective
+	// permissions, overriding the static permissions given to NewDenoClient.
+	PermissionResolver PermissionResolver
+
+	// PermissionChangePolicy decides whether the Deno process is recycled when its permissions change.
+	PermissionChangePolicy PermissionChangePolicy
+
+	// ImportMap is an optional import map passed to Deno via --import-map, for projects whose
+	// import map is not referenced from deno.json. Relative paths resolve against WorkingDir.
+	ImportMap string
+
+	// LockFile is passed to Deno via --lock to pin the script's dependencies.
+	// Defaults to a deno.lock next to the config file, if there is one.
+	LockFile string
+
+	// FrozenLockfile passes --frozen so Deno errors rather than updating an out of date lockfile.
+	FrozenLockfile bool
+
+	// OfflineMode restricts fetching remote modules, eg: for air-gapped CI.
+	OfflineMode OfflineMode
+
+	// Reload passes --reload so Deno refetches remote modules rather than using its cache.
+	// It has no effect on local modules, so is harmless for local scripts.
+	Reload bool
+
+	// ReloadSpecifiers limits Reload to the given module specifiers, otherwise the whole cache is reloaded.
+	ReloadSpecifiers []string
+
+	// V8Flags are passed through to V8 via --v8-flags, eg: "--max-old-space-size=4096".
+	// Each flag must begin with --.
+	V8Flags []string
+
+	// MinDenoVersion, when set, fails Start if the Deno binary is older than this semver, eg: "2.1.0".
+	MinDenoVersion string
+
+	// WorkingDir is the working directory of the Deno process, so relative paths in the
+	// script and its permissions resolve predictably. Defaults to the directory of a local script.
+	WorkingDir string
+
+	// Env sets environment variables on the Deno process, overriding inherited variables of the same name.
+	Env map[string]string
+
+	// ClearEnv starts the Deno process from an empty environment instead of inheriting the provider's.
+	ClearEnv bool
+
+	// ForwardEnv, when set, only forwards the named variables from the provider's environment.
+	ForwardEnv []string
+
+	// RequireBackendHealthy fails Start when the script reports that it cannot reach the
+	// backend it manages, eg: the API is down or credentials are invalid.
+	RequireBackendHealthy bool
+
+	// HealthRetryPolicy, when set, controls how Start retries the health check, see HealthRetryPolicy.
+	HealthRetryPolicy *HealthRetryPolicy
+
+	// Contract, when set, lists the methods the provider calls on the script. Start checks it against
+	// the script's OpenRPC document, unless SkipDiscovery is set, see ScriptContract.
+	Contract *ScriptContract
+
+	// SkipDiscovery skips checking the script's OpenRPC document against the Contract.
+	SkipDiscovery bool
+
+	// ConfigResolution controls where the deno config file is looked for when none is given.
+	ConfigResolution ConfigResolution
+
+	// ConfigPrecedence controls which config file is used when a directory has both a deno.json and a deno.jsonc.
+	ConfigPrecedence ConfigPrecedence
+
+	// DryRun makes Start work out the full Deno command, log it and return ErrDryRun instead of
+	// executing it, for debugging misconfiguration without spawning anything.
+	DryRun bool
+
+	// ScriptIntegrity, when set, is the subresource integrity hash a remote script must match, eg: "sha256-<base64>".
+	// Start downloads the script and refuses to launch it if it does not match, then launches the verified copy,
+	// so relative imports of the script resolve against that copy. Local scripts are not checked.
+	ScriptIntegrity string
+
+	// ConfigSearchPaths are directories checked, in order, for a config file before walking up from the script,
+	// eg: a sibling tooling directory in a monorepo. Relative paths resolve against WorkingDir.
+	ConfigSearchPaths []string
+
+	// MaxMessageBytes limits the size of each JSON-RPC message the script may send, so a buggy script can not
+	// exhaust the provider's memory. Calls answered with a larger response fail with jsocket.ErrMessageTooLarge.
+	// Defaults to jsocket.DefaultMaxMessageBytes, zero removes the limit.
+	MaxMessageBytes int64
+
+	// StringIDs sends JSON-RPC requests with string ids, eg: "denobridge-1", instead of integers.
+	StringIDs bool
+
+	// MaxPendingAsync, when non-zero, caps the number of async operations, eg: pending creates,
+	// that may wait for completion at once. Creates and deletes reserve a slot before the script is
+	// called, as it may answer pending, so starting another blocks until one completes.
+	MaxPendingAsync int
+
+	// FlushWarningThreshold, when non-zero, logs a warning for each response that took at least
+	// this long to arrive, mostly because the script did not flush it promptly, see WithFlushWarning.
+	FlushWarningThreshold time.Duration
+
+	// WarmStandby keeps a second Deno process running alongside the primary. When the primary
+	// crashes the standby takes over at once, rather than paying for a cold restart, and a new
+	// standby is started in the background. When no standby is ready, eg: it is still starting,
+	// the crashed process is restarted as if RestartOnCrash were set.
+	//
+	// The in-flight call is retried on the standby, and later calls never reach the crashed
+	// process, so the script must be stateless or able to reattach to its work from scratch.
+	WarmStandby bool
+
+	// CPUHint is the number of CPUs the script is told it may use, via the health handshake,
+	// so it can size the concurrency of CPU bound work. Defaults to runtime.NumCPU, zero omits the hint.
+	CPUHint int
+
+	// PermissionPrompts lets Deno prompt for permissions that were not granted, for local debugging
+	// with a TTY. By default --no-prompt is passed, so an un-granted permission fails fast instead
+	// of blocking forever on a prompt nobody can answer.
+	PermissionPrompts bool
+
+	// Features limits the optional protocol features offered to the script in the health handshake,
+	// nil offers all SupportedFeatures. Only those the script answers with are used, see NegotiatedFeatures.
+	Features []Feature
+
+	// HeartbeatInterval, when non-zero, pings the running Deno process this often. A process that does not
+	// answer within HeartbeatTimeout, eg: because its event loop is blocked, is killed, so the next call
+	// restarts it as if it had crashed rather than hanging until it times out, see WithHeartbeat.
+	HeartbeatInterval time.Duration
+
+	// HeartbeatTimeout is how long a heartbeat ping may take, zero uses DefaultHeartbeatTimeout.
+	HeartbeatTimeout time.Duration
+
+	// Pool, when set, shares the Deno process with other clients of the pool that have the same
+	// configuration, see ProcessPool. Calls are then handled by the shared process, which the pool
+	// supervises, so eg: crash restarts follow the settings of the client that started it.
+	Pool *ProcessPool
+
+	startMu sync.Mutex
+	running bool
+	pooled  *pooledProcess
+
+	denoVersion          *semver.Version
+	healthWarnings       []string
+	effectivePermissions *Permissions
+	permissionsHash      string
+	features             []Feature
+	capabilities         []string
+
+	// resolvedDenoBinaryPath is the Deno executable the last Start resolved denoBinaryPath to. It is read
+	// by Config, which is called with startMu held, so it is not guarded by startMu.
+	resolvedDenoBinaryPath atomic.Pointer[string]
+
+	exit          *processExit
+	crashRestarts int
+
+	standby         atomic.Pointer[DenoClient]
+	standbyStarting atomic.Bool
+	standbyWG       sync.WaitGroup
+
+	heartbeatCancel context.CancelFunc
+	heartbeatWG     sync.WaitGroup
+
+	calls inFlightCalls
+
+	mu       sync.Mutex
+	poisoned error
+	stats    runStats
+	async    asyncLimiter
+	nextID   atomic.Uint64
+	logLevel atomic.Pointer[string]
+}
+
+// ReusePolicy controls whether a Deno process may be reused after a fatal error.
+type ReusePolicy int
+
+const (
+	// ReusePolicyError fails every call made after a fatal error with ErrProcessPoisoned.
+	ReusePolicyError ReusePolicy = iota
+	// ReusePolicyRestart recycles the Deno process before the next call after a fatal error.
+	ReusePolicyRestart
+)
+
+// PermissionResolver dynamically computes the permissions a Deno process should run with.
+// This enables policy driven permission grants, eg: per environment or per resource.
+type PermissionResolver func(ctx context.Context) (*Permissions, error)
+
+// ErrProcessPoisoned is returned when a call is attempted against a Deno process that
+// previously failed with a fatal error and the ReusePolicy does not allow a restart.
+var ErrProcessPoisoned = errors.New("deno process is in an unrecoverable state")
+
+// HealthRequest is the startup handshake sent to the Deno process.
+type HealthRequest struct {
+	// Permissions are the effective permissions the Deno process was granted.
+	Permissions Permissions `json:"permissions"`
+	// CPUHint is the number of CPUs the script may use, if known.
+	CPUHint int `json:"cpuHint,omitempty"`
+	// Features are the optional protocol features the provider supports.
+	Features []Feature `json:"features"`
+	// Encodings are the payload encodings the provider supports besides JSON, eg: "cbor".
+	Encodings []jsocket.PayloadEncoding `json:"encodings"`
+}
+
+// HealthResponse is the Deno process's answer to the startup handshake.
+type HealthResponse struct {
+	// Ok is true once the script is up and ready to serve calls.
+	Ok bool `json:"ok"`
+	// Backend optionally reports whether the script can reach the backend it manages.
+	Backend *BackendHealth `json:"backend,omitempty"`
+	// Warnings are optional advisories about a degraded but working script, eg: a soon to expire credential.
+	Warnings []string `json:"warnings,omitempty"`
+	// Features are the offered features the script supports too, only these are used.
+	Features []Feature `json:"features,omitempty"`
+	// Encodings are the offered payload encodings the script supports too, the first the provider offered is
+	// used for the params and results of every later call. Without any, payloads stay JSON.
+	Encodings []jsocket.PayloadEncoding `json:"encodings,omitempty"`
+	// Capabilities optionally lists the optional methods the script implements, eg: "import" or "renew".
+	Capabilities []string `json:"capabilities,omitempty"`
+	// ProtocolVersion optionally reports the version of the bridge protocol the script speaks, see ProtocolVersion.
+	ProtocolVersion string `json:"protocolVersion,omitempty"`
+}
+
+// BackendHealth describes the connectivity between a script and the backend it manages.
+type BackendHealth struct {
+	// Ok is true when the backend is reachable.
+	Ok bool `json:"ok"`
+	// Message optionally explains why the backend is unreachable.
+	Message string `json:"message,omitempty"`
+}
+
+// ErrBackendUnhealthy is returned by Start when RequireBackendHealthy is set and
+// the script reports that its backend is unreachable.
+var ErrBackendUnhealthy = errors.New("script is up but backend unreachable")
+
+// CodeManualIntervention is the JSON-RPC error code a script returns to halt an operation
+// because it detected a condition that needs a human to resolve, eg: ambiguous state or
+// external tampering. Such errors are never retried.
+const CodeManualIntervention int64 = -32002
+
+// ErrManualIntervention is returned by Call when the script responded with CodeManualIntervention.
+var ErrManualIntervention = errors.New("manual intervention required")
+
+// DefaultStartupTimeout is how long Start waits for the script to become healthy by default.
+const DefaultStartupTimeout = 30 * time.Second
+
+// healthPollInterval is how often the health method is polled while waiting for the script to boot.
+const healthPollInterval = 250 * time.Millisecond
+
+// DenoClientOption configures optional settings of a DenoClient.
+type DenoClientOption func(c *DenoClient)
+
+// WithStartupTimeout sets how long Start waits for the script to become healthy.
+// Useful on cold machines where Deno has to compile a large TypeScript entrypoint.
+func WithStartupTimeout(timeout time.Duration) DenoClientOption {
+	return func(c *DenoClient) {
+		c.StartupTimeout = timeout
+	}
+}
+
+// WithMaxMessageBytes limits the size of each JSON-RPC message the script may send, see MaxMessageBytes.
+func WithMaxMessageBytes(limit int64) DenoClientOption {
+	return func(c *DenoClient) {
+		c.MaxMessageBytes = limit
+	}
+}
+
+// WithImportMap sets the import map passed to Deno via --import-map.
+func WithImportMap(importMap string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ImportMap = importMap
+	}
 }
 
 // NewDenoClient creates a new Deno client for the given script.
-func NewDenoClient(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, rpcMethods func(ctx context.Context, c *jsonrpc2.Conn) map[string]any) *DenoClient {
-	return &DenoClient{
-		scriptPath:     scriptPath,
-		configPath:     configPath,
-		permissions:    permissions,
-		denoBinaryPath: denoBinaryPath,
-		rpcMethods:     rpcMethods,
+func NewDenoClient(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, rpcMethods func(ctx context.Context, c *jsonrpc2.Conn) map[string]any, opts ...DenoClientOption) *DenoClient {
+	c := &DenoClient{
+		scriptPath:          scriptPath,
+		configPath:          configPath,
+		permissions:         permissions,
+		denoBinaryPath:      denoBinaryPath,
+		rpcMethods:          rpcMethods,
+		StartupTimeout:      DefaultStartupTimeout,
+		ShutdownGracePeriod: DefaultShutdownGracePeriod,
+		DrainTimeout:        DefaultDrainTimeout,
+		MaxRestarts:         defaultMaxRestarts,
+		RestartBackoff:      defaultRestartBackoff,
+		CPUHint:             runtime.NumCPU(),
+		MaxMessageBytes:     jsocket.DefaultMaxMessageBytes,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Start launches the Deno JSON-RPC process.
+//
+// Start is safe to call concurrently and is idempotent, calls are serialized and once
+// the process is running any further calls return nil without spawning another process.
+// If starting fails, any partially started process is killed so a later call can retry.
 func (c *DenoClient) Start(ctx context.Context) error {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+
+	if c.running {
+		return nil
+	}
+	if c.Pool != nil {
+		return c.startPooled(ctx)
+	}
+
+	if err := c.start(ctx); err != nil {
+		c.kill()
+		return err
+	}
+
+	c.running = true
+	c.spawnStandby()
+	c.startHeartbeat()
+	return nil
+}
+
+// start does the actual work of launching the Deno JSON-RPC process.
+func (c *DenoClient) start(ctx context.Context) error {
 	// Store context for logging
 	c.ctx = ctx
-
-	// Build Deno command arguments
-	args := []string{"run", "-q"}
+	c.exit = nil
+	c.healthWarnings = nil
+	c.effectivePermissions = nil
+	c.features = nil
+	c.stats.started()
 
 	// Attempt to locate a deno config file if none given
 	configPath := c.configPath
 	if configPath == "" {
-		configPath = locateDenoConfigFile(c.scriptPath)
+		located, err := c.locateConfig()
+		if err != nil {
+			return fmt.Errorf("failed to locate the deno config file for deno script %s: %w", c.scriptPath, err)
+		}
+		configPath = located
 	}
-	if configPath != "" && configPath != "/dev/null" {
-		args = append(args, "-c", configPath)
+	if err := validateConfigFile(configPath); err != nil {
+		return err
 	}
 
-	// Add permissions
-	if c.permissions != nil {
-		if c.permissions.All {
-			args = append(args, "--allow-all")
-		} else {
-			for _, perm := range c.permissions.Allow {
-				args = append(args, fmt.Sprintf("--allow-%s", perm))
-			}
-			for _, perm := range c.permissions.Deny {
-				args = append(args, fmt.Sprintf("--deny-%s", perm))
-			}
-		}
+	// Resolve the effective permissions
+	permissions, err := c.resolvePermissions(ctx, c.permissions)
+	if err != nil {
+		return err
+	}
+	if err := permissions.Validate(); err != nil {
+		return fmt.Errorf("invalid permissions for deno script %s:\n%w", c.scriptPath, err)
 	}
 
-	// Handle script path - support file:// URLs and remote URLs
-	var scriptArg string
-	if strings.Contains(c.scriptPath, "://") {
-		// Parse URL
-		parsedURL, err := url.Parse(c.scriptPath)
-		if err != nil {
-			return fmt.Errorf("failed to parse script URL: %w", err)
-		}
+	scriptArg, err := resolveScriptArg(c.scriptPath)
+	if err != nil {
+		return err
+	}
+	workingDir, err := c.resolveWorkingDir(scriptArg)
+	if err != nil {
+		return err
+	}
+	scriptPath, err := c.checkScriptIntegrity(ctx, scriptArg)
+	if err != nil {
+		return err
+	}
 
-		if parsedURL.Scheme == "file" {
-			// Convert file:// URL to local path
-			path := parsedURL.Path
-			// On Windows, url.Parse for file:///C:/path gives Path="/C:/path"
-			// We need to remove the leading slash before the drive letter
-			if len(path) > 2 && path[0] == '/' && path[2] == ':' {
-				path = path[1:]
-			}
-			localPath := filepath.FromSlash(path)
-			absPath, err := filepath.Abs(localPath)
-			if err != nil {
-				return fmt.Errorf("failed to resolve script path: %w", err)
-			}
-			scriptArg = absPath
-		} else {
-			// Remote URL (http://, https://, etc.) - pass as-is
-			scriptArg = c.scriptPath
-		}
-	} else {
-		// Local file path - convert to absolute path
-		absPath, err := filepath.Abs(c.scriptPath)
+	var importMap string
+	if c.ImportMap != "" {
+		importMap, err = c.resolveImportMap(workingDir)
 		if err != nil {
-			return fmt.Errorf("failed to resolve script path: %w", err)
+			return err
 		}
-		scriptArg = absPath
 	}
-	args = append(args, scriptArg)
+
+	// Build Deno command arguments
+	args, err := buildDenoArgs(DenoLaunchOptions{
+		ScriptPath:       scriptPath,
+		ConfigPath:       configPath,
+		LockFile:         c.lockFile(configPath),
+		FrozenLockfile:   c.FrozenLockfile,
+		Permissions:      permissions,
+		ImportMap:        importMap,
+		OfflineMode:      c.OfflineMode,
+		Reload:           c.Reload,
+		ReloadSpecifiers: c.ReloadSpecifiers,
+		V8Flags:          c.V8Flags,
+		Prompt:           c.PermissionPrompts,
+	})
+	if err != nil {
+		return err
+	}
+
+	denoBinaryPath, err := resolveDenoBinary(c.denoBinaryPath)
+	if err != nil {
+		return err
+	}
+	c.resolvedDenoBinaryPath.Store(&denoBinaryPath)
+	if c.DryRun {
+		return c.dryRun(ctx, denoBinaryPath, args, workingDir)
+	}
+	if err := c.checkDenoVersion(ctx, denoBinaryPath); err != nil {
+		return err
+	}
 
 	// Create command
-	c.process = exec.CommandContext(ctx, c.denoBinaryPath, args...)
+	c.process = exec.CommandContext(ctx, denoBinaryPath, args...)
+	c.process.Dir = workingDir
+	c.process.Env = c.environ()
 
-	// Log the full command being executed
-	fullCmd := append([]string{c.denoBinaryPath}, args...)
+	// Log the full command being executed, and its environment without values
+	fullCmd := append([]string{denoBinaryPath}, args...)
 	cmdStr := strings.Join(fullCmd, " ")
+	envStr := redactEnv(c.process.Env)
 	if isTestContext() {
-		log.Printf("[DEBUG] Executing Deno command: %s", cmdStr)
+		log.Printf("[DEBUG] Executing Deno command (offline mode: %s): %s", c.OfflineMode, cmdStr)
+		log.Printf("[DEBUG] Deno command environment: %s", envStr)
 	} else {
-		tflog.Debug(ctx, fmt.Sprintf("Executing Deno command: %s", cmdStr))
+		tflog.Debug(ctx, fmt.Sprintf("Executing Deno command (offline mode: %s): %s", c.OfflineMode, cmdStr))
+		tflog.Debug(ctx, fmt.Sprintf("Deno command environment: %s", envStr))
 	}
 
 	// Get pipes to the child proc stdio
@@ -131,49 +494,457 @@ func (c *DenoClient) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	stderr, err := c.process.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
+	// Stderr is copied through an in-memory pipe rather than using StderrPipe, so
+	// that process.Wait only returns once every line has been read and logged.
+	stderr, stderrWriter := io.Pipe()
+	c.process.Stderr = stderrWriter
 
 	// Start the process
 	if err := c.process.Start(); err != nil {
-		return fmt.Errorf("failed to start Deno process: %w", err)
+		return fmt.Errorf("failed to start Deno process: %w", classifyDenoBinaryError(denoBinaryPath, err))
 	}
 
 	// Pipe stderr to tflog
-	go pipeToDebugLog(ctx, stderr, "[deno stderr] ")
+	exit := &processExit{done: make(chan struct{})}
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		c.pipeToLog(ctx, stderr, "[deno stderr] ", &exit.stderrTail)
+	}()
+
+	// Supervise the process, recording when & how it exits
+	c.exit = exit
+	go func(process *exec.Cmd) {
+		exit.err = process.Wait()
+		_ = stderrWriter.Close()
+		<-stderrDone
+		close(exit.done)
+	}(c.process)
 
 	// Create the jsocket
-	c.Socket = jsocket.New(ctx, stdout, stdin, c.rpcMethods)
+	process := c.process
+	tracked, connOpts := c.flushConnOpts(ctx, &countingReader{stdout, &c.stats.bytesReceived})
+	connOpts = append(connOpts, c.duplicateResponseConnOpt(ctx))
+	c.Socket = jsocket.New(ctx,
+		&stdoutReader{
+			ReadCloser: tracked,
+			onEOF:      func() { c.checkStdoutEOF(ctx, exit, process) },
+		},
+		&countingWriter{stdin, &c.stats.bytesSent},
+		c.rpcMethods,
+		connOpts...,
+	)
+	if c.StringIDs {
+		c.Socket.NewID = c.newStringID
+	}
+	c.Socket.SetMaxMessageBytes(c.MaxMessageBytes)
+	c.Socket.OnMessageTooLarge(func(err error) {
+		msg := fmt.Sprintf("Deno script %s sent a JSON-RPC message that was discarded: %v", c.scriptPath, err)
+		if isTestContext() {
+			log.Printf("[WARN] %s", msg)
+		} else {
+			tflog.Warn(ctx, msg)
+		}
+	})
+	// Until the script agrees to it in the health handshake
+	c.Socket.SetCancelRequests(false)
+
+	// Wait for the server to be ready, telling it what it has been granted
+	// so that it can avoid importing modules that need other permissions.
+	granted := grantedPermissions(permissions)
+	c.effectivePermissions = &granted
+	c.permissionsHash = permissionsHash(granted)
+	if err := c.waitForHealthy(ctx, &HealthRequest{Permissions: granted, CPUHint: c.CPUHint, Features: c.offeredFeatures(), Encodings: slices.Clone(SupportedEncodings)}); err != nil {
+		return err
+	}
+	return c.discover(ctx)
+}
+
+// resolveWorkingDir returns the working directory for the Deno process, defaulting to the
+// directory containing a local script. Remote scripts, and npm: or jsr: specifiers, inherit the
+// provider's working directory.
+// The directory must exist and be readable.
+func (c *DenoClient) resolveWorkingDir(scriptArg string) (string, error) {
+	workingDir := c.WorkingDir
+	if workingDir == "" {
+		if strings.Contains(scriptArg, "://") || isPackageSpecifier(scriptArg) {
+			return "", nil
+		}
+		workingDir = filepath.Dir(scriptArg)
+	}
 
-	// Wait for the server to be ready
-	var response struct {
-		Ok bool `json:"ok"`
+	info, err := os.Stat(workingDir)
+	if err != nil && c.WorkingDir == "" && errors.Is(err, fs.ErrNotExist) {
+		// The directory of the script does not exist, so neither does the script
+		return "", fmt.Errorf("%w: %s: %w", ErrScriptNotFound, scriptArg, err)
 	}
-	if err := c.Socket.Call(ctx, "health", nil, &response); err != nil {
-		return fmt.Errorf("failed to call the Deno JSON-RPC servers health method: %w", err)
+	if err != nil {
+		return "", fmt.Errorf("invalid working directory for deno script %s: %w", c.scriptPath, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid working directory for deno script %s: %s is not a directory", c.scriptPath, workingDir)
 	}
-	if !response.Ok {
-		return fmt.Errorf("deno process unhealthy: %w", err)
+	dir, err := os.Open(workingDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid working directory for deno script %s: %w", c.scriptPath, err)
 	}
+	_ = dir.Close()
+
+	return workingDir, nil
+}
 
+// resolveImportMap returns the import map to pass to Deno. Local paths & file:// URLs are resolved to
+// an absolute path, relative to the working directory, and must exist. Remote URLs are passed as-is.
+func (c *DenoClient) resolveImportMap(workingDir string) (string, error) {
+	path := c.ImportMap
+	if strings.Contains(path, "://") {
+		parsedURL, err := url.Parse(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse import map URL: %w", err)
+		}
+		if parsedURL.Scheme != "file" {
+			return path, nil
+		}
+		path = fileURLPath(parsedURL)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve import map path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid import map for deno script %s: %w", c.scriptPath, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("invalid import map for deno script %s: %s is a directory", c.scriptPath, absPath)
+	}
+
+	return absPath, nil
+}
+
+// waitForHealthy polls the health method until it returns ok, or StartupTimeout elapses.
+// Cancelling ctx aborts the poll loop early.
+func (c *DenoClient) waitForHealthy(ctx context.Context, request *HealthRequest) error {
+	startupCtx := ctx
+	if c.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		startupCtx, cancel = context.WithTimeout(ctx, c.StartupTimeout)
+		defer cancel()
+	}
+
+	policy := c.HealthRetryPolicy
+	began := time.Now()
+	for attempt := 1; ; attempt++ {
+		var response HealthResponse
+		err := c.Socket.Call(startupCtx, "health", request, &response)
+		if err == nil && response.Ok {
+			if err := checkProtocolVersion(response.ProtocolVersion); err != nil {
+				return fmt.Errorf("deno script %s: %w", c.scriptPath, err)
+			}
+			c.healthWarnings = response.Warnings
+			c.capabilities = response.Capabilities
+			c.features = negotiateFeatures(request.Features, response.Features)
+			c.Socket.SetCancelRequests(slices.Contains(c.features, FeatureCancelRequest))
+			if err := c.Socket.SetPayloadEncoding(negotiateEncoding(request.Encodings, response.Encodings)); err != nil {
+				return fmt.Errorf("deno script %s: %w", c.scriptPath, err)
+			}
+			return c.checkBackendHealth(ctx, response.Backend)
+		}
+		if err != nil && isFatalError(err) {
+			return c.explainStartupFailure(fmt.Errorf("failed to call the Deno JSON-RPC servers health method: %w", err))
+		}
+		if err == nil && policy != nil && policy.FailOnNotOk {
+			return c.exit.withStderrTail(fmt.Errorf("deno script %s: %w", c.scriptPath, ErrNotHealthy))
+		}
+		if policy.exhausted(attempt) {
+			exhausted := fmt.Errorf("deno script %s did not become healthy after %d attempts", c.scriptPath, attempt)
+			if err != nil {
+				exhausted = fmt.Errorf("%w: %w", exhausted, err)
+			}
+			return c.exit.withStderrTail(exhausted)
+		}
+
+		select {
+		case <-startupCtx.Done():
+			if ctx.Err() != nil {
+				return fmt.Errorf("aborted waiting for deno script %s to become healthy: %w", c.scriptPath, ctx.Err())
+			}
+			err := fmt.Errorf("deno script %s did not become healthy within %s", c.scriptPath, time.Since(began).Round(time.Millisecond))
+			if c.exit != nil && !c.exited() {
+				err = fmt.Errorf("%w, the process is still running but did not answer, %s", err, c.silentStartupHint())
+			}
+			return c.exit.withStderrTail(err)
+		case <-time.After(policy.delay(attempt)):
+		}
+	}
+}
+
+// HealthWarnings returns the warnings the script reported when it was last started.
+// Warnings never fail Start, only the ok flag of the health response decides that.
+func (c *DenoClient) HealthWarnings() []string {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return slices.Clone(c.healthWarnings)
+}
+
+// EffectivePermissions returns the permissions the Deno process was last started with,
+// after the PermissionResolver, rather than the permissions it was configured with.
+// Returns nil if the process has not been started.
+func (c *DenoClient) EffectivePermissions() *Permissions {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.effectivePermissions == nil {
+		return nil
+	}
+	return &Permissions{
+		All:   c.effectivePermissions.All,
+		Allow: slices.Clone(c.effectivePermissions.Allow),
+		Deny:  slices.Clone(c.effectivePermissions.Deny),
+	}
+}
+
+// checkBackendHealth validates the backend health reported by the script.
+// An unreachable backend is only an error when RequireBackendHealthy is set, otherwise it is logged.
+func (c *DenoClient) checkBackendHealth(ctx context.Context, backend *BackendHealth) error {
+	if backend != nil && backend.Ok {
+		return nil
+	}
+
+	reason := "the script does not report backend health"
+	if backend != nil {
+		reason = "no reason given"
+		if backend.Message != "" {
+			reason = backend.Message
+		}
+	}
+
+	if c.RequireBackendHealthy {
+		return fmt.Errorf("deno script %s: %w: %s", c.scriptPath, ErrBackendUnhealthy, reason)
+	}
+
+	if backend != nil {
+		msg := fmt.Sprintf("Deno script %s reports its backend is unreachable: %s", c.scriptPath, reason)
+		if isTestContext() {
+			log.Printf("[WARN] %s", msg)
+		} else {
+			tflog.Warn(ctx, msg)
+		}
+	}
+
+	return nil
+}
+
+// Call invokes a JSON-RPC method on the Deno child process.
+// This is the central call path used by all the resource specific clients.
+//
+// Each call is bounded by the timeout configured for its method, see MethodTimeouts.
+//
+// Errors are classified after each call. Ordinary method errors leave the process
+// healthy, whereas fatal errors (parse errors, protocol violations, or a process that
+// has died) mark the process as poisoned. What happens next is decided by ReusePolicy.
+// If the process crashed and RestartOnCrash is enabled, it is relaunched and the call retried once.
+// A CodeManualIntervention error is returned as ErrManualIntervention and never retried.
+// Any other error the script failed the call with is returned as a *ScriptError.
+func (c *DenoClient) Call(ctx context.Context, method string, params, result any) error {
+	if owner := c.sharedOwner(); owner != nil {
+		return owner.Call(ctx, method, params, result)
+	}
+	defer c.calls.begin(method)()
+	if err := c.recoverPoisoned(); err != nil {
+		return err
+	}
+	if err := c.recyclePermissionChange(ctx); err != nil {
+		return err
+	}
+
+	if timeout := c.callTimeout(method); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	socket, exit := c.current()
+	start := time.Now()
+	err := socket.Call(ctx, method, params, result)
+	c.stats.called(method, time.Since(start), err)
+
+	// Fail over to the warm standby, or relaunch a crashed process, and retry the call once
+	crashed := err != nil && (c.RestartOnCrash || c.WarmStandby) && c.crashed(exit, err)
+	if crashed {
+		if !c.promoteStandby(ctx, exit) {
+			if err := c.restartCrashed(ctx, exit); err != nil {
+				return err
+			}
+		}
+		socket, exit = c.current()
+		start = time.Now()
+		err = socket.Call(ctx, method, params, result)
+		c.stats.called(method, time.Since(start), err)
+		crashed = err != nil && c.crashed(exit, err)
+	}
+
+	if err != nil && isFatalError(err) && exit != nil && exit.stdoutClosed.Load() {
+		err = fmt.Errorf("%w: %w", ErrStdoutClosed, err)
+	}
+
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == CodeManualIntervention {
+		return fmt.Errorf("%w: %s", ErrManualIntervention, rpcErr.Message)
+	}
+	// Crashed processes are left to the crash supervisor rather than being poisoned
+	if err != nil && isFatalError(err) && !crashed {
+		c.mu.Lock()
+		c.poisoned = fmt.Errorf("%s: %w", method, err)
+		c.mu.Unlock()
+	}
+	return newScriptError(method, err)
+}
+
+// callTimeout returns the timeout for the given method, falling back to CallTimeout.
+func (c *DenoClient) callTimeout(method string) time.Duration {
+	if timeout, ok := c.MethodTimeouts[method]; ok {
+		return timeout
+	}
+	return c.CallTimeout
+}
+
+// recoverPoisoned checks if the process has been poisoned by a previous fatal error and
+// either restarts it or returns ErrProcessPoisoned, depending on the ReusePolicy.
+func (c *DenoClient) recoverPoisoned() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.poisoned == nil {
+		return nil
+	}
+
+	if c.ReusePolicy != ReusePolicyRestart {
+		return fmt.Errorf("%w: %v", ErrProcessPoisoned, c.poisoned)
+	}
+
+	if isTestContext() {
+		log.Printf("[DEBUG] Restarting poisoned Deno process: %v", c.poisoned)
+	} else {
+		tflog.Debug(c.ctx, fmt.Sprintf("Restarting poisoned Deno process: %v", c.poisoned))
+	}
+
+	c.startMu.Lock()
+	c.running = false
+	c.startMu.Unlock()
+
+	c.kill()
+	if err := c.Start(c.ctx); err != nil {
+		return fmt.Errorf("failed to restart poisoned deno process: %w", err)
+	}
+	c.stats.restarted()
+
+	c.poisoned = nil
 	return nil
 }
 
+// kill forcefully terminates the Deno child process without a graceful shutdown.
+func (c *DenoClient) kill() {
+	if c.Socket != nil {
+		_ = c.Socket.Close()
+	}
+	if c.process != nil && c.process.Process != nil {
+		_ = c.process.Process.Kill()
+	}
+	if c.exit != nil {
+		<-c.exit.done
+	}
+}
+
+// isFatalError returns true if the error indicates that the Deno process can no
+// longer be trusted to handle further calls. Ordinary method errors return false.
+func isFatalError(err error) bool {
+	if errors.Is(err, jsonrpc2.ErrClosed) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case jsonrpc2.CodeParseError, jsonrpc2.CodeInvalidRequest:
+			return true
+		}
+	}
+
+	return false
+}
+
 // Stop terminates the Deno child process.
+//
+// The script is first asked to shutdown gracefully, if it has not exited after ShutdownGracePeriod
+// it is sent SIGTERM and then, after another ShutdownGracePeriod, killed. ErrShutdownForced is
+// returned when the process had to be killed. A client sharing its process via a ProcessPool
+// only releases it, the process is stopped by the last client to do so.
 func (c *DenoClient) Stop() error {
+	defer c.stats.stopped()
+
+	c.startMu.Lock()
+	c.running = false
+	pooled := c.pooled
+	if pooled != nil {
+		c.pooled = nil
+		c.process = nil
+		c.exit = nil
+	}
+	c.startMu.Unlock()
+	if pooled != nil {
+		return c.Pool.release(c, pooled)
+	}
+	c.stopHeartbeat()
+	c.stopStandby()
+
+	// Let calls made on other goroutines finish, rather than truncating eg: a half done create
 	if c.Socket != nil {
-		if err := c.Socket.Notify(c.ctx, "shutdown", nil); err != nil {
-			return fmt.Errorf("failed to notify deno child proc to shutdown gracefully: %v", err)
+		if pending := c.calls.drain(c.DrainTimeout); len(pending) > 0 {
+			msg := fmt.Sprintf("Stopping deno script %s with calls still in flight after %s: %s",
+				c.scriptPath, c.DrainTimeout, strings.Join(pending, ", "))
+			if isTestContext() {
+				log.Printf("[WARN] %s", msg)
+			} else {
+				tflog.Warn(c.ctx, msg)
+			}
 		}
-		if err := c.Socket.Close(); err != nil {
+	}
+
+	// From here on the process exiting, and its stdout closing, are expected.
+	// Only an exit that happened before now is a crash.
+	crashed := false
+	if c.exit != nil {
+		c.exit.shuttingDown.Store(true)
+		crashed = c.exited()
+	}
+
+	if c.Socket != nil {
+		notifyErr := c.Socket.Notify(c.ctx, "shutdown", nil)
+		// The connection, or the pipes under it, are already closed if the process exited first
+		if err := c.Socket.Close(); err != nil && !errors.Is(err, jsonrpc2.ErrClosed) && !errors.Is(err, os.ErrClosed) {
 			return fmt.Errorf("failed to close jsocket and release resources: %w", err)
 		}
+		// The notification can not be delivered to a process that already exited, which is reported below
+		if notifyErr != nil && (c.exit == nil || !c.exited()) {
+			return fmt.Errorf("failed to notify deno child proc to shutdown gracefully: %v", notifyErr)
+		}
 	}
-	if c.process != nil {
-		if err := c.process.Wait(); err != nil {
-			return fmt.Errorf("deno child proc died: %w", err)
+	if c.exit != nil {
+		if !c.waitForExit(c.ShutdownGracePeriod) {
+			if !c.terminate() {
+				return ErrShutdownForced
+			}
+			// The process was asked to exit via SIGTERM, so how it exited is expected
+			return nil
+		}
+		// A process that exited before it was asked to is a crash, even if it exited with code 0
+		if crashed || !expectedShutdownExit(c.exit.err) {
+			return c.exit.withStderrTail(newProcessExitError(c.exit.err, crashed))
 		}
 	}
 	return nil
@@ -186,32 +957,87 @@ func isTestContext() bool {
 	return os.Getenv("DENO_TOFU_BRIDGE_TEST_MODE") == "true"
 }
 
-// pipeToDebugLog reads from a reader and logs each line as debug.
-func pipeToDebugLog(ctx context.Context, reader io.Reader, prefix string) {
+// pipeToLog reads from a reader and logs each line, at debug level by default, recording each line in tail.
+// Lines are routed according to the current log level, see SetLogLevel.
+func (c *DenoClient) pipeToLog(ctx context.Context, reader io.Reader, prefix string, tail *lineRing) {
 	scanner := bufio.NewScanner(reader)
-	if isTestContext() {
-		// In test context, write directly to stdout
-		for scanner.Scan() {
-			log.Printf("[DEBUG] %s%s", prefix, scanner.Text())
+	for scanner.Scan() {
+		tail.add(scanner.Text())
+		level := c.LogLevel()
+		if logLevelRanks[level] > logLevelRanks[LogLevelDebug] {
+			continue
 		}
-	} else {
-		// In Terraform context, use tflog
-		for scanner.Scan() {
+		if isTestContext() {
+			// In test context, write directly to stdout
+			log.Printf("[%s] %s%s", strings.ToUpper(level), prefix, scanner.Text())
+		} else if level == LogLevelTrace {
+			// In Terraform context, use tflog
+			tflog.Trace(ctx, prefix+scanner.Text())
+		} else {
 			tflog.Debug(ctx, prefix+scanner.Text())
 		}
 	}
 }
 
-// cachedConfigLookups stores config file paths to avoid repeated filesystem lookups.
-var cachedConfigLookups = make(map[string]string)
+// configLookup is the cached outcome of a config file lookup.
+type configLookup struct {
+	path string
+	err  error
+	// observed are the directories the lookup checked, as they were when it checked them
+	observed []observedDir
+}
+
+// observedDir records the state of a directory checked for a config file. Adding or removing
+// a file changes the modification time of its directory, so a change means the lookup is stale.
+type observedDir struct {
+	path    string
+	exists  bool
+	modTime time.Time
+}
+
+// observeDir records the current state of dir.
+func observeDir(dir string) observedDir {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return observedDir{path: dir}
+	}
+	return observedDir{path: dir, exists: true, modTime: info.ModTime()}
+}
+
+// stale returns true if any directory the lookup checked has changed since, eg: a deno.json was
+// added or deleted between Terraform runs of a long-lived provider process.
+func (l configLookup) stale() bool {
+	for _, observed := range l.observed {
+		current := observeDir(observed.path)
+		if current.exists != observed.exists || !current.modTime.Equal(observed.modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// configLookupKey identifies a config file lookup, by the directory it started from, the search paths
+// checked before it and its precedence.
+type configLookupKey struct {
+	dir         string
+	searchPaths string
+	precedence  ConfigPrecedence
+}
+
+// cachedConfigLookups stores config file lookups to avoid repeated filesystem lookups.
+// A cached lookup is only used while the directories it checked are unchanged.
+var (
+	cachedConfigLookups   = make(map[configLookupKey]configLookup)
+	cachedConfigLookupsMu sync.Mutex
+)
 
 // locateDenoConfigFile searches for a Deno configuration file (deno.json or deno.jsonc)
-// starting from the script file's directory and traversing upward through parent
-// directories until found or root is reached.
+// in the search paths, then starting from the script file's directory and traversing
+// upward through parent directories until found or root is reached.
 //
 // Accepts both regular file paths and file:// URLs.
 // Results are cached to avoid repeated filesystem operations for the same file paths.
-func locateDenoConfigFile(scriptPath string) string {
+func locateDenoConfigFile(scriptPath string, searchPaths []string, precedence ConfigPrecedence) (string, error) {
 	// Convert file URL to path if needed
 	if strings.HasPrefix(scriptPath, "file://") {
 		parsedURL, err := url.Parse(scriptPath)
@@ -226,35 +1052,63 @@ func locateDenoConfigFile(scriptPath string) string {
 		}
 	}
 
-	// Check if scriptPath has a protocol scheme other than file://
-	// If so, return empty string as remote script loading is not supported
-	if strings.Contains(scriptPath, "://") {
-		return ""
+	// Check if scriptPath has a protocol scheme other than file://, or is an npm: or jsr: specifier
+	// If so, only the search paths are checked as there is no local directory to walk up from
+	if strings.Contains(scriptPath, "://") || isPackageSpecifier(scriptPath) {
+		return findDenoConfigFile("", searchPaths, precedence)
 	}
 
+	// Start from the directory containing the script
+	return findDenoConfigFile(filepath.Dir(scriptPath), searchPaths, precedence)
+}
+
+// findDenoConfigFile searches for a Deno configuration file (deno.json or deno.jsonc) in each of the
+// search paths, then in the given directory and its parents, stopping at the first directory containing
+// either. When it contains both, precedence decides which is used. An empty dir only checks the search
+// paths. Results are cached until one of the directories checked changes.
+func findDenoConfigFile(dir string, searchPaths []string, precedence ConfigPrecedence) (string, error) {
+	cachedConfigLookupsMu.Lock()
+	defer cachedConfigLookupsMu.Unlock()
+
 	// Check cache first
-	if cached, ok := cachedConfigLookups[scriptPath]; ok {
-		return cached
+	key := configLookupKey{dir, strings.Join(searchPaths, string(filepath.ListSeparator)), precedence}
+	if cached, ok := cachedConfigLookups[key]; ok && !cached.stale() {
+		return cached.path, cached.err
 	}
 
-	// Start from the directory containing the script
-	currentDir := filepath.Dir(scriptPath)
+	lookup := walkDenoConfigFile(dir, searchPaths, precedence)
+	cachedConfigLookups[key] = lookup
+	return lookup.path, lookup.err
+}
+
+// walkDenoConfigFile does the filesystem work of findDenoConfigFile, recording each directory it checks.
+func walkDenoConfigFile(dir string, searchPaths []string, precedence ConfigPrecedence) configLookup {
+	var observed []observedDir
+	check := func(dir string) configLookup {
+		// Observe the directory before looking in it, so a change in between makes the lookup stale
+		observed = append(observed, observeDir(dir))
+		lookup := findDenoConfigFileIn(dir, precedence)
+		lookup.observed = observed
+		return lookup
+	}
+
+	// The search paths are checked as is, without walking up from them
+	for _, searchPath := range searchPaths {
+		if lookup := check(searchPath); lookup.path != "" || lookup.err != nil {
+			return lookup
+		}
+	}
+	if dir == "" {
+		return configLookup{observed: observed}
+	}
+
+	currentDir := dir
 	volumeName := filepath.VolumeName(currentDir)
 
 	// Walk up the directory tree
 	for {
-		// Check for deno.json
-		denoJsonPath := filepath.Join(currentDir, "deno.json")
-		if _, err := os.Stat(denoJsonPath); err == nil {
-			cachedConfigLookups[scriptPath] = denoJsonPath
-			return denoJsonPath
-		}
-
-		// Check for deno.jsonc
-		denoJsoncPath := filepath.Join(currentDir, "deno.jsonc")
-		if _, err := os.Stat(denoJsoncPath); err == nil {
-			cachedConfigLookups[scriptPath] = denoJsoncPath
-			return denoJsoncPath
+		if lookup := check(currentDir); lookup.path != "" || lookup.err != nil {
+			return lookup
 		}
 
 		// Get parent directory
@@ -270,5 +1124,24 @@ func locateDenoConfigFile(scriptPath string) string {
 	}
 
 	// No config file found
-	return ""
+	return configLookup{observed: observed}
+}
+
+// findDenoConfigFileIn looks for a deno.json or deno.jsonc in dir only, the zero configLookup means neither exists.
+func findDenoConfigFileIn(dir string, precedence ConfigPrecedence) configLookup {
+	denoJsonPath := filepath.Join(dir, "deno.json")
+	_, jsonErr := os.Stat(denoJsonPath)
+	denoJsoncPath := filepath.Join(dir, "deno.jsonc")
+	_, jsoncErr := os.Stat(denoJsoncPath)
+
+	switch {
+	case jsonErr == nil && jsoncErr == nil:
+		return precedence.choose(denoJsonPath, denoJsoncPath)
+	case jsonErr == nil:
+		return configLookup{path: denoJsonPath}
+	case jsoncErr == nil:
+		return configLookup{path: denoJsoncPath}
+	default:
+		return configLookup{}
+	}
 }
@@ -3,6 +3,7 @@ package deno
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
@@ -26,9 +27,10 @@ type DenoClientAction struct {
 //   - configPath: The path to the Deno configuration file (deno.json)
 //   - permissions: The Deno security permissions to grant the runtime
 //   - resp: The Terraform action InvokeResponse for sending progress updates
+//   - opts: Optional settings for the underlying DenoClient, eg: WithStartupTimeout
 //
 // Returns a configured DenoClientAction ready to invoke actions.
-func NewDenoClientAction(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, resp *action.InvokeResponse) *DenoClientAction {
+func NewDenoClientAction(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, resp *action.InvokeResponse, opts ...DenoClientOption) *DenoClientAction {
 	return &DenoClientAction{
 		NewDenoClient(
 			denoBinaryPath,
@@ -36,6 +38,7 @@ func NewDenoClientAction(denoBinaryPath, scriptPath, configPath string, permissi
 			configPath,
 			permissions,
 			jsocket.TypedServerMethods(&DenoClientActionServerMethods{resp}),
+			append([]DenoClientOption{withContract(actionContract)}, opts...)...,
 		),
 	}
 }
@@ -75,8 +78,8 @@ type InvokeResponse struct {
 // Returns an error if the JSON-RPC call fails or the action does not complete successfully.
 func (c *DenoClientAction) Invoke(ctx context.Context, params *InvokeRequest) (*InvokeResponse, error) {
 	var response *InvokeResponse
-	if err := c.Client.Socket.Call(ctx, "invoke", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call invoke method over JSON-RPC: %v", err)
+	if err := c.Client.Call(ctx, "invoke", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to call invoke method over JSON-RPC: %w", err)
 	}
 	return response, nil
 }
@@ -94,6 +97,31 @@ type DenoClientActionServerMethods struct {
 type InvokeProgressRequest struct {
 	// Message is the progress message to display to the user
 	Message string `json:"message"`
+	// Percent optionally reports completion between 0 and 100, values outside are clamped
+	Percent *float64 `json:"percent,omitempty"`
+	// Current optionally reports how many units of work are done, shown when Total is set
+	Current int64 `json:"current,omitempty"`
+	// Total optionally reports how many units of work there are in all
+	Total int64 `json:"total,omitempty"`
+}
+
+// String formats the progress for display, eg: "42% (21/50): uploading".
+// Only the message is returned when neither a percentage nor counts are given.
+func (p *InvokeProgressRequest) String() string {
+	var prefix []string
+	if p.Percent != nil && !math.IsNaN(*p.Percent) {
+		prefix = append(prefix, fmt.Sprintf("%.0f%%", max(0, min(100, *p.Percent))))
+	}
+	if p.Total > 0 {
+		prefix = append(prefix, fmt.Sprintf("(%d/%d)", p.Current, p.Total))
+	}
+	if len(prefix) == 0 {
+		return p.Message
+	}
+	if p.Message == "" {
+		return strings.Join(prefix, " ")
+	}
+	return strings.Join(prefix, " ") + ": " + p.Message
 }
 
 // InvokeProgress handles progress update requests from the Deno runtime during action execution.
@@ -101,9 +129,9 @@ type InvokeProgressRequest struct {
 //
 // Parameters:
 //   - ctx: The context for the operation (currently unused but required by JSON-RPC interface)
-//   - params: The progress request containing the message to display
+//   - params: The progress request containing the message, and optionally the percentage and counts, to display
 func (c *DenoClientActionServerMethods) InvokeProgress(ctx context.Context, params *InvokeProgressRequest) {
-	message := params.Message
+	message := params.String()
 
 	// ensure that the terraform cli output doesn't become misaligned.
 	if !strings.HasSuffix(message, "\r") {
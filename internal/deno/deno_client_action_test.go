@@ -0,0 +1,32 @@
+package deno
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestInvokeProgressRequest_String(t *testing.T) {
+	percent := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		progress InvokeProgressRequest
+		want     string
+	}{
+		{"message only", InvokeProgressRequest{Message: "uploading"}, "uploading"},
+		{"percent and counts", InvokeProgressRequest{Message: "uploading", Percent: percent(42), Current: 21, Total: 50}, "42% (21/50): uploading"},
+		{"percent only", InvokeProgressRequest{Message: "uploading", Percent: percent(0)}, "0%: uploading"},
+		{"counts only", InvokeProgressRequest{Message: "uploading", Current: 3, Total: 10}, "(3/10): uploading"},
+		{"no message", InvokeProgressRequest{Percent: percent(50)}, "50%"},
+		{"clamped above", InvokeProgressRequest{Message: "done", Percent: percent(150)}, "100%: done"},
+		{"clamped below", InvokeProgressRequest{Message: "starting", Percent: percent(-5)}, "0%: starting"},
+		{"not a number", InvokeProgressRequest{Message: "working", Percent: percent(math.NaN())}, "working"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.progress.String())
+		})
+	}
+}
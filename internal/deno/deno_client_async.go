@@ -0,0 +1,73 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTooManyPendingAsync is returned when an async operation could not start before its
+// context ended, because MaxPendingAsync operations were already pending.
+var ErrTooManyPendingAsync = errors.New("too many pending async operations")
+
+// asyncLimiter counts the async operations, eg: pending creates, that are waiting to complete.
+// These are tracked separately from in flight JSON-RPC calls, which are bounded by their timeouts.
+type asyncLimiter struct {
+	mu      sync.Mutex
+	pending int
+	// freed is closed, and then replaced, whenever a pending operation completes
+	freed chan struct{}
+}
+
+// WithMaxPendingAsync caps the number of async operations that may be pending at once.
+// Once the cap is reached, starting another blocks until one completes or its context ends.
+func WithMaxPendingAsync(limit int) DenoClientOption {
+	return func(c *DenoClient) {
+		c.MaxPendingAsync = limit
+	}
+}
+
+// beginAsync reserves a slot for a new async operation, blocking while MaxPendingAsync
+// operations are already pending. The returned function must be called once the
+// operation completes, it is safe to call more than once.
+func (c *DenoClient) beginAsync(ctx context.Context) (func(), error) {
+	for {
+		c.async.mu.Lock()
+		if c.MaxPendingAsync <= 0 || c.async.pending < c.MaxPendingAsync {
+			c.async.pending++
+			c.async.mu.Unlock()
+			return sync.OnceFunc(c.endAsync), nil
+		}
+		if c.async.freed == nil {
+			c.async.freed = make(chan struct{})
+		}
+		freed := c.async.freed
+		c.async.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (max %d): %w", ErrTooManyPendingAsync, c.MaxPendingAsync, ctx.Err())
+		}
+	}
+}
+
+// endAsync releases the slot of a completed async operation and wakes any waiters.
+func (c *DenoClient) endAsync() {
+	c.async.mu.Lock()
+	defer c.async.mu.Unlock()
+	c.async.pending--
+	if c.async.freed != nil {
+		close(c.async.freed)
+		c.async.freed = nil
+	}
+}
+
+// PendingAsync returns the number of async operations currently waiting to complete, including
+// creates and deletes the script has not answered yet, see MaxPendingAsync.
+func (c *DenoClient) PendingAsync() int {
+	c.async.mu.Lock()
+	defer c.async.mu.Unlock()
+	return c.async.pending
+}
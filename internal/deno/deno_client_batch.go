@@ -0,0 +1,71 @@
+package deno
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+)
+
+// CallBatch sends many calls to the Deno script as a single JSON-RPC 2.0 batch, one round trip
+// instead of one per call. Results are returned in the same order as items and each call succeeds
+// or fails on its own, see jsocket.JSocket.CallBatch. The whole batch is bounded by the longest
+// timeout of its methods.
+//
+// Unlike Call, a batch that fails because the process crashed is not retried. When the script
+// did not agree to FeatureBatch in the health handshake, the items are sent one call at a time.
+func (c *DenoClient) CallBatch(ctx context.Context, items []jsocket.BatchItem) ([]jsocket.BatchResult, error) {
+	if owner := c.sharedOwner(); owner != nil {
+		return owner.CallBatch(ctx, items)
+	}
+	if !c.hasFeature(FeatureBatch) {
+		return c.callEach(ctx, items), nil
+	}
+
+	if err := c.recoverPoisoned(); err != nil {
+		return nil, err
+	}
+	if err := c.recyclePermissionChange(ctx); err != nil {
+		return nil, err
+	}
+
+	var timeout time.Duration
+	for _, item := range items {
+		timeout = max(timeout, c.callTimeout(item.Method))
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	defer c.calls.begin("batch")()
+	socket, _ := c.current()
+	start := time.Now()
+	results, err := socket.CallBatch(ctx, items)
+	if err != nil {
+		if isFatalError(err) {
+			c.mu.Lock()
+			c.poisoned = fmt.Errorf("batch: %w", err)
+			c.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	// Share the round trip between the calls so the run summary's total call time stays honest
+	share := time.Since(start) / time.Duration(max(len(items), 1))
+	for i, result := range results {
+		c.stats.called(items[i].Method, share, result.Err)
+	}
+	return results, nil
+}
+
+// callEach sends the items of a batch one call at a time, for scripts that do not support batches.
+func (c *DenoClient) callEach(ctx context.Context, items []jsocket.BatchItem) []jsocket.BatchResult {
+	results := make([]jsocket.BatchResult, len(items))
+	for i, item := range items {
+		results[i].Err = c.Call(ctx, item.Method, item.Params, &results[i].Result)
+	}
+	return results
+}
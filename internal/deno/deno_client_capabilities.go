@@ -0,0 +1,52 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ProtocolVersion is the version of the bridge protocol this provider speaks. Scripts may report
+// the version they speak in the health handshake, only the same major version is compatible.
+const ProtocolVersion = "1.0.0"
+
+// ErrProtocolVersionMismatch is returned by Start when the script reports a protocolVersion
+// that is incompatible with ProtocolVersion.
+var ErrProtocolVersionMismatch = errors.New("incompatible bridge protocol version")
+
+// checkProtocolVersion fails when the version reported by the script is incompatible with ProtocolVersion.
+// Scripts that do not report a version are assumed to be compatible.
+func checkProtocolVersion(reported string) error {
+	if reported == "" {
+		return nil
+	}
+	version, err := semver.NewVersion(reported)
+	if err != nil {
+		return fmt.Errorf("%w: the script speaks %q, which is not a valid version, the provider speaks %s",
+			ErrProtocolVersionMismatch, reported, ProtocolVersion)
+	}
+	if version.Major() != semver.MustParse(ProtocolVersion).Major() {
+		return fmt.Errorf("%w: the script speaks %s but the provider speaks %s, upgrade whichever is older",
+			ErrProtocolVersionMismatch, version, ProtocolVersion)
+	}
+	return nil
+}
+
+// Capabilities returns the optional methods the script reported it implements when the process was
+// last started, eg: "import", "modifyPlan" or "renew". Returns nil if the script did not report any.
+func (c *DenoClient) Capabilities() []string {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return slices.Clone(c.capabilities)
+}
+
+// HasCapability returns false when the script reported its capabilities and the given method is not
+// among them, so callers can skip a call that would only fail with method not found. Scripts that do
+// not report their capabilities may implement anything, so true is returned and the call must be tried.
+func (c *DenoClient) HasCapability(method string) bool {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return c.capabilities == nil || slices.Contains(c.capabilities, method)
+}
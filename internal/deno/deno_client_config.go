@@ -0,0 +1,234 @@
+package deno
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// ClientConfig is a serializable snapshot of the effective settings of a DenoClient.
+// It lets tooling log exactly how a client was configured and lets a client be
+// reconstructed with NewDenoClientFromConfig.
+//
+// Note that PermissionResolver and any server side RPC methods are functions and
+// are therefore not part of the snapshot, nor is the Contract. Env is left out too, as its values are
+// commonly secrets that must not end up in logs.
+type ClientConfig struct {
+	// DenoBinaryPath is the path to the Deno executable, as resolved by Start once the client was started.
+	DenoBinaryPath string `json:"denoBinaryPath"`
+	// ScriptPath is the path or URL of the script to run.
+	ScriptPath string `json:"scriptPath"`
+	// ConfigPath is the path to the Deno config file, after auto discovery.
+	ConfigPath string `json:"configPath"`
+	// ConfigResolution controls where the Deno config file is looked for when none is given.
+	ConfigResolution ConfigResolution `json:"configResolution"`
+	// ConfigPrecedence controls which config file is used when a directory has both a deno.json and a deno.jsonc.
+	ConfigPrecedence ConfigPrecedence `json:"configPrecedence"`
+	// ConfigSearchPaths are directories checked for a config file before walking up from the script.
+	ConfigSearchPaths []string `json:"configSearchPaths,omitempty"`
+	// DryRun makes Start log the Deno command and return ErrDryRun instead of executing it.
+	DryRun bool `json:"dryRun"`
+	// ScriptIntegrity is the subresource integrity hash a remote script must match.
+	ScriptIntegrity string `json:"scriptIntegrity,omitempty"`
+	// Permissions are the static permissions granted to the Deno process.
+	Permissions *Permissions `json:"permissions"`
+	// ReusePolicy decides what happens to the Deno process after a fatal error.
+	ReusePolicy ReusePolicy `json:"reusePolicy"`
+	// PermissionChangePolicy decides whether the Deno process is recycled when its permissions change.
+	PermissionChangePolicy PermissionChangePolicy `json:"permissionChangePolicy"`
+	// RestartOnCrash relaunches the Deno process when it dies unexpectedly.
+	RestartOnCrash bool `json:"restartOnCrash"`
+	// MaxRestarts bounds how many times a crashed process is relaunched.
+	MaxRestarts int `json:"maxRestarts"`
+	// RestartBackoff is the delay before the first crash restart.
+	RestartBackoff time.Duration `json:"restartBackoff"`
+	// StartupTimeout bounds how long Start waits for the script to become healthy.
+	StartupTimeout time.Duration `json:"startupTimeout"`
+	// ShutdownGracePeriod is how long Stop waits for the process to exit before escalating.
+	ShutdownGracePeriod time.Duration `json:"shutdownGracePeriod"`
+	// DrainTimeout is how long Stop waits for in-flight calls to finish.
+	DrainTimeout time.Duration `json:"drainTimeout"`
+	// CallTimeout bounds how long any single call may take.
+	CallTimeout time.Duration `json:"callTimeout"`
+	// MethodTimeouts overrides CallTimeout for specific methods.
+	MethodTimeouts map[string]time.Duration `json:"methodTimeouts,omitempty"`
+	// RequireBackendHealthy fails Start when the script reports an unreachable backend.
+	RequireBackendHealthy bool `json:"requireBackendHealthy"`
+	// HealthRetryPolicy controls how Start retries the health check, nil polls until StartupTimeout.
+	HealthRetryPolicy *HealthRetryPolicy `json:"healthRetryPolicy,omitempty"`
+	// SkipDiscovery skips checking the script's OpenRPC document against its contract.
+	SkipDiscovery bool `json:"skipDiscovery"`
+	// ClearEnv starts the Deno process from an empty environment.
+	ClearEnv bool `json:"clearEnv"`
+	// ForwardEnv only forwards the named variables from the provider's environment.
+	ForwardEnv []string `json:"forwardEnv,omitempty"`
+	// WorkingDir is the working directory of the Deno process, empty means the script's directory.
+	WorkingDir string `json:"workingDir,omitempty"`
+	// MinDenoVersion is the minimum version of Deno the script may be run with.
+	MinDenoVersion string `json:"minDenoVersion,omitempty"`
+	// ImportMap is the import map passed to Deno via --import-map.
+	ImportMap string `json:"importMap,omitempty"`
+	// LockFile is passed to Deno via --lock.
+	LockFile string `json:"lockFile,omitempty"`
+	// FrozenLockfile passes --frozen to Deno.
+	FrozenLockfile bool `json:"frozenLockfile"`
+	// OfflineMode restricts fetching remote modules.
+	OfflineMode OfflineMode `json:"offlineMode"`
+	// Reload passes --reload to Deno.
+	Reload bool `json:"reload"`
+	// ReloadSpecifiers limits Reload to the given module specifiers.
+	ReloadSpecifiers []string `json:"reloadSpecifiers,omitempty"`
+	// MaxPendingAsync caps the number of pending async operations.
+	MaxPendingAsync int `json:"maxPendingAsync"`
+	// MaxMessageBytes limits the size of each JSON-RPC message the script may send, zero means no limit.
+	MaxMessageBytes int64 `json:"maxMessageBytes"`
+	// StringIDs sends JSON-RPC requests with string ids.
+	StringIDs bool `json:"stringIds"`
+	// FlushWarningThreshold logs a warning for responses delayed by the script not flushing.
+	FlushWarningThreshold time.Duration `json:"flushWarningThreshold"`
+	// WarmStandby keeps a second process warm to take over if the primary crashes.
+	WarmStandby bool `json:"warmStandby"`
+	// HeartbeatInterval is how often the running process is pinged, zero disables the heartbeat.
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"`
+	// HeartbeatTimeout is how long a heartbeat ping may take before the process is killed.
+	HeartbeatTimeout time.Duration `json:"heartbeatTimeout"`
+	// CPUHint is the number of CPUs the script is told it may use.
+	CPUHint int `json:"cpuHint"`
+	// V8Flags are passed through to V8.
+	V8Flags []string `json:"v8Flags,omitempty"`
+	// PermissionPrompts lets Deno prompt for permissions that were not granted, rather than passing --no-prompt.
+	PermissionPrompts bool `json:"permissionPrompts"`
+	// Features limits the optional protocol features offered to the script, nil offers all of them.
+	Features []Feature `json:"features,omitempty"`
+}
+
+// Config returns a snapshot of the client's effective configuration.
+func (c *DenoClient) Config() ClientConfig {
+	configPath := c.configPath
+	if configPath == "" {
+		// An ambiguous config is reported by Start
+		configPath, _ = c.locateConfig()
+	}
+
+	var healthRetryPolicy *HealthRetryPolicy
+	if c.HealthRetryPolicy != nil {
+		policy := *c.HealthRetryPolicy
+		healthRetryPolicy = &policy
+	}
+
+	var permissions *Permissions
+	if c.permissions != nil {
+		permissions = &Permissions{
+			All:          c.permissions.All,
+			Allow:        slices.Clone(c.permissions.Allow),
+			Deny:         slices.Clone(c.permissions.Deny),
+			AllowUnknown: c.permissions.AllowUnknown,
+		}
+	}
+
+	// The configured path, possibly empty or a bare name looked up on PATH, until Start resolves it
+	denoBinaryPath := c.denoBinaryPath
+	if resolved := c.resolvedDenoBinaryPath.Load(); resolved != nil {
+		denoBinaryPath = *resolved
+	}
+
+	return ClientConfig{
+		DenoBinaryPath:         denoBinaryPath,
+		ScriptPath:             c.scriptPath,
+		ConfigPath:             configPath,
+		ConfigResolution:       c.ConfigResolution,
+		ConfigPrecedence:       c.ConfigPrecedence,
+		ConfigSearchPaths:      slices.Clone(c.ConfigSearchPaths),
+		DryRun:                 c.DryRun,
+		ScriptIntegrity:        c.ScriptIntegrity,
+		Permissions:            permissions,
+		ReusePolicy:            c.ReusePolicy,
+		PermissionChangePolicy: c.PermissionChangePolicy,
+		RestartOnCrash:         c.RestartOnCrash,
+		MaxRestarts:            c.MaxRestarts,
+		RestartBackoff:         c.RestartBackoff,
+		StartupTimeout:         c.StartupTimeout,
+		ShutdownGracePeriod:    c.ShutdownGracePeriod,
+		DrainTimeout:           c.DrainTimeout,
+		CallTimeout:            c.CallTimeout,
+		MethodTimeouts:         maps.Clone(c.MethodTimeouts),
+		RequireBackendHealthy:  c.RequireBackendHealthy,
+		HealthRetryPolicy:      healthRetryPolicy,
+		SkipDiscovery:          c.SkipDiscovery,
+		ClearEnv:               c.ClearEnv,
+		ForwardEnv:             slices.Clone(c.ForwardEnv),
+		WorkingDir:             c.WorkingDir,
+		MinDenoVersion:         c.MinDenoVersion,
+		ImportMap:              c.ImportMap,
+		LockFile:               c.LockFile,
+		FrozenLockfile:         c.FrozenLockfile,
+		OfflineMode:            c.OfflineMode,
+		Reload:                 c.Reload,
+		ReloadSpecifiers:       slices.Clone(c.ReloadSpecifiers),
+		MaxPendingAsync:        c.MaxPendingAsync,
+		MaxMessageBytes:        c.MaxMessageBytes,
+		StringIDs:              c.StringIDs,
+		FlushWarningThreshold:  c.FlushWarningThreshold,
+		WarmStandby:            c.WarmStandby,
+		HeartbeatInterval:      c.HeartbeatInterval,
+		HeartbeatTimeout:       c.HeartbeatTimeout,
+		CPUHint:                c.CPUHint,
+		V8Flags:                slices.Clone(c.V8Flags),
+		PermissionPrompts:      c.PermissionPrompts,
+		Features:               slices.Clone(c.Features),
+	}
+}
+
+// NewDenoClientFromConfig creates a new Deno client from a ClientConfig snapshot.
+func NewDenoClientFromConfig(config ClientConfig) *DenoClient {
+	c := NewDenoClient(
+		config.DenoBinaryPath,
+		config.ScriptPath,
+		config.ConfigPath,
+		config.Permissions,
+		nil,
+		WithStartupTimeout(config.StartupTimeout),
+		WithShutdownGracePeriod(config.ShutdownGracePeriod),
+		WithDrainTimeout(config.DrainTimeout),
+	)
+	c.ReusePolicy = config.ReusePolicy
+	c.PermissionChangePolicy = config.PermissionChangePolicy
+	c.RestartOnCrash = config.RestartOnCrash
+	c.MaxRestarts = config.MaxRestarts
+	c.RestartBackoff = config.RestartBackoff
+	c.CallTimeout = config.CallTimeout
+	c.MethodTimeouts = maps.Clone(config.MethodTimeouts)
+	c.RequireBackendHealthy = config.RequireBackendHealthy
+	if config.HealthRetryPolicy != nil {
+		policy := *config.HealthRetryPolicy
+		c.HealthRetryPolicy = &policy
+	}
+	c.SkipDiscovery = config.SkipDiscovery
+	c.ConfigResolution = config.ConfigResolution
+	c.ConfigPrecedence = config.ConfigPrecedence
+	c.ConfigSearchPaths = slices.Clone(config.ConfigSearchPaths)
+	c.DryRun = config.DryRun
+	c.ScriptIntegrity = config.ScriptIntegrity
+	c.ClearEnv = config.ClearEnv
+	c.ForwardEnv = slices.Clone(config.ForwardEnv)
+	c.WorkingDir = config.WorkingDir
+	c.MinDenoVersion = config.MinDenoVersion
+	c.ImportMap = config.ImportMap
+	c.LockFile = config.LockFile
+	c.FrozenLockfile = config.FrozenLockfile
+	c.OfflineMode = config.OfflineMode
+	c.Reload = config.Reload
+	c.ReloadSpecifiers = slices.Clone(config.ReloadSpecifiers)
+	c.MaxPendingAsync = config.MaxPendingAsync
+	c.MaxMessageBytes = config.MaxMessageBytes
+	c.StringIDs = config.StringIDs
+	c.FlushWarningThreshold = config.FlushWarningThreshold
+	c.WarmStandby = config.WarmStandby
+	c.HeartbeatInterval = config.HeartbeatInterval
+	c.HeartbeatTimeout = config.HeartbeatTimeout
+	c.CPUHint = config.CPUHint
+	c.V8Flags = slices.Clone(config.V8Flags)
+	c.PermissionPrompts = config.PermissionPrompts
+	c.Features = slices.Clone(config.Features)
+	return c
+}
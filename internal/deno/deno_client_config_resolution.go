@@ -0,0 +1,230 @@
+package deno
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ConfigResolution controls where the deno config file is looked for when none is given.
+type ConfigResolution int
+
+const (
+	// ConfigResolutionScriptRelative walks up from the directory containing the script.
+	ConfigResolutionScriptRelative ConfigResolution = iota
+	// ConfigResolutionCwdRelative walks up from the WorkingDir, or the provider's working directory
+	// when none is set, like the deno CLI does when run from that directory.
+	ConfigResolutionCwdRelative
+)
+
+// String returns the name of the config resolution strategy, as used in logs.
+func (r ConfigResolution) String() string {
+	switch r {
+	case ConfigResolutionScriptRelative:
+		return "script-relative"
+	case ConfigResolutionCwdRelative:
+		return "cwd-relative"
+	default:
+		return fmt.Sprintf("ConfigResolution(%d)", int(r))
+	}
+}
+
+// WithConfigResolution sets where the deno config file is looked for when none is given.
+func WithConfigResolution(resolution ConfigResolution) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ConfigResolution = resolution
+	}
+}
+
+// ErrAmbiguousConfig is returned by Start under ConfigPrecedenceStrict when the directory
+// the config file is found in has both a deno.json and a deno.jsonc.
+var ErrAmbiguousConfig = errors.New("ambiguous deno config file")
+
+// ConfigPrecedence controls which config file is used when a directory has both a deno.json and a deno.jsonc.
+// The search always stops at the first directory containing either, so a config further up is never used.
+type ConfigPrecedence int
+
+const (
+	// ConfigPrecedencePreferJSON uses the deno.json.
+	ConfigPrecedencePreferJSON ConfigPrecedence = iota
+	// ConfigPrecedencePreferJSONC uses the deno.jsonc.
+	ConfigPrecedencePreferJSONC
+	// ConfigPrecedenceStrict fails Start with ErrAmbiguousConfig.
+	ConfigPrecedenceStrict
+)
+
+// String returns the name of the config precedence, as used in logs.
+func (p ConfigPrecedence) String() string {
+	switch p {
+	case ConfigPrecedencePreferJSON:
+		return "prefer-json"
+	case ConfigPrecedencePreferJSONC:
+		return "prefer-jsonc"
+	case ConfigPrecedenceStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("ConfigPrecedence(%d)", int(p))
+	}
+}
+
+// WithConfigPrecedence sets which config file is used when a directory has both a deno.json and a deno.jsonc.
+func WithConfigPrecedence(precedence ConfigPrecedence) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ConfigPrecedence = precedence
+	}
+}
+
+// choose picks between a deno.json and a deno.jsonc found in the same directory.
+func (p ConfigPrecedence) choose(denoJsonPath, denoJsoncPath string) configLookup {
+	switch p {
+	case ConfigPrecedencePreferJSONC:
+		return configLookup{path: denoJsoncPath}
+	case ConfigPrecedenceStrict:
+		return configLookup{err: fmt.Errorf("%w: both %s and %s exist, remove one or give the config path explicitly",
+			ErrAmbiguousConfig, denoJsonPath, denoJsoncPath)}
+	default:
+		return configLookup{path: denoJsonPath}
+	}
+}
+
+// WithConfigSearchPaths sets directories checked for a config file before walking up from the script.
+func WithConfigSearchPaths(paths ...string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ConfigSearchPaths = paths
+	}
+}
+
+// configSearchPaths returns the ConfigSearchPaths, made absolute so lookups are cached by where they really are.
+func (c *DenoClient) configSearchPaths() []string {
+	paths := make([]string, 0, len(c.ConfigSearchPaths))
+	for _, path := range c.ConfigSearchPaths {
+		if !filepath.IsAbs(path) && c.WorkingDir != "" {
+			path = filepath.Join(c.WorkingDir, path)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// locateConfig returns the deno config file found in the ConfigSearchPaths or, failing that,
+// by the ConfigResolution strategy, or an empty string if there is none.
+func (c *DenoClient) locateConfig() (string, error) {
+	searchPaths := c.configSearchPaths()
+	if c.ConfigResolution != ConfigResolutionCwdRelative {
+		return locateDenoConfigFile(c.scriptPath, searchPaths, c.ConfigPrecedence)
+	}
+
+	dir := c.WorkingDir
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", nil
+		}
+		dir = cwd
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil
+	}
+	return findDenoConfigFile(absDir, searchPaths, c.ConfigPrecedence)
+}
+
+// ErrInvalidConfig is returned by Start when the deno config file is not valid JSON, or JSONC for a deno.jsonc.
+var ErrInvalidConfig = errors.New("invalid deno config file")
+
+// validateConfigFile checks that a .json or .jsonc config file parses, so a broken config is reported
+// with its location rather than as an obscure deno startup failure. Other files, eg: /dev/null, are left to deno.
+func validateConfigFile(path string) error {
+	ext := filepath.Ext(path)
+	if ext != ".json" && ext != ".jsonc" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the deno config file %s: %w", path, err)
+	}
+	if ext == ".jsonc" {
+		data = stripJSONC(data)
+	}
+
+	var config any
+	if err := json.Unmarshal(data, &config); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := lineAndColumn(data, syntaxErr.Offset)
+			return fmt.Errorf("%w: %s:%d:%d: %v", ErrInvalidConfig, path, line, column, err)
+		}
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	return nil
+}
+
+// stripJSONC turns JSONC into JSON by blanking out comments and trailing commas. Blanking rather
+// than removing them keeps the offsets of everything else, so errors point at the original text.
+func stripJSONC(data []byte) []byte {
+	out := slices.Clone(data)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			// Skip over the string, minding escaped quotes
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+			lastComma = -1
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				// Leave an unterminated comment for the parser to report
+				return out
+			}
+			blank(i, i+end+4)
+			i += end + 3
+		case out[i] == ',':
+			lastComma = i
+		case out[i] == '}' || out[i] == ']':
+			if lastComma >= 0 {
+				blank(lastComma, lastComma+1)
+			}
+			lastComma = -1
+		case out[i] == ' ' || out[i] == '\t' || out[i] == '\r' || out[i] == '\n':
+		default:
+			lastComma = -1
+		}
+	}
+	return out
+}
+
+// lineAndColumn converts a byte offset into data to a 1-based line and column.
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
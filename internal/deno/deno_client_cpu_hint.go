@@ -0,0 +1,9 @@
+package deno
+
+// WithCPUHint sets the number of CPUs the script is told it may use, see CPUHint.
+// Useful in containers, where Deno can not detect the real CPU limit.
+func WithCPUHint(cpus int) DenoClientOption {
+	return func(c *DenoClient) {
+		c.CPUHint = cpus
+	}
+}
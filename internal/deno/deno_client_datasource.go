@@ -2,7 +2,13 @@ package deno
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
 )
 
 // DenoClientDatasource is a client for reading Terraform data sources using a Deno runtime.
@@ -11,6 +17,16 @@ import (
 type DenoClientDatasource struct {
 	// Client is the underlying Deno client used for JSON-RPC communication
 	Client *DenoClient
+
+	// CacheTTL is how long a read result is reused for later reads with the same props, zero disables caching
+	CacheTTL time.Duration
+	// Cache holds the cached read results, it may be shared with other clients, nil disables caching
+	Cache *DatasourceCache
+
+	// streams are the streamed reads in flight, keyed by their stream id
+	streams      map[string]*readStream
+	streamsMu    sync.Mutex
+	nextStreamID atomic.Int64
 }
 
 // NewDenoClientDatasource creates a new DenoClientDatasource with the specified configuration.
@@ -21,18 +37,22 @@ type DenoClientDatasource struct {
 //   - scriptPath: The path to the TypeScript/JavaScript data source script to execute
 //   - configPath: The path to the Deno configuration file (deno.json)
 //   - permissions: The Deno security permissions to grant the runtime
+//   - cacheTTL: How long read results are cached for, keyed on their props, zero disables caching.
+//     Results are cached in a cache of their own, set Cache to share it with other clients.
+//   - opts: Optional settings for the underlying DenoClient, eg: WithStartupTimeout
 //
 // Returns a configured DenoClientDatasource ready to read data.
-func NewDenoClientDatasource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions) *DenoClientDatasource {
-	return &DenoClientDatasource{
-		NewDenoClient(
-			denoBinaryPath,
-			scriptPath,
-			configPath,
-			permissions,
-			nil,
-		),
-	}
+func NewDenoClientDatasource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, cacheTTL time.Duration, opts ...DenoClientOption) *DenoClientDatasource {
+	c := &DenoClientDatasource{CacheTTL: cacheTTL, Cache: NewDatasourceCache()}
+	c.Client = NewDenoClient(
+		denoBinaryPath,
+		scriptPath,
+		configPath,
+		permissions,
+		jsocket.TypedServerMethods(&DenoClientDatasourceServerMethods{c}),
+		append([]DenoClientOption{withContract(datasourceContract)}, opts...)...,
+	)
+	return c
 }
 
 // ReadRequest represents the request payload for reading a Terraform data source.
@@ -40,6 +60,10 @@ func NewDenoClientDatasource(denoBinaryPath, scriptPath, configPath string, perm
 type ReadRequest struct {
 	// Props contains the data source configuration properties as defined in the Terraform schema
 	Props any `json:"props"`
+	// CacheBypass forces a fresh read even if a cached result is available, the fresh result is still cached
+	CacheBypass bool `json:"-"`
+	// StreamID is set by ReadStream, telling the script it may deliver the result as readChunk notifications
+	StreamID string `json:"streamId,omitempty"`
 }
 
 // ReadResponse represents the response from reading a Terraform data source.
@@ -49,6 +73,8 @@ type ReadResponse struct {
 	Result any `json:"result"`
 	// SensitiveResult contains the data source sensitive data (marked as sensitive in Terraform)
 	SensitiveResult any `json:"sensitiveResult"`
+	// Chunks is how many readChunk notifications a streamed read sent, in place of Result
+	Chunks int `json:"chunks,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -65,15 +91,102 @@ type ReadResponse struct {
 // Read executes the data source read operation by calling the "read" method via JSON-RPC.
 // It sends the configuration properties to the Deno runtime and retrieves the resulting data.
 //
+// When CacheTTL is set, a successful result is cached and returned by later reads with the same props,
+// until it expires, without calling Deno at all. Results with error diagnostics are never cached.
+//
 // Parameters:
 //   - ctx: The context for the operation, used for cancellation and timeouts
 //   - params: The read request containing the data source configuration properties
 //
 // Returns the read response containing the retrieved data, or an error if the JSON-RPC call fails.
 func (c *DenoClientDatasource) Read(ctx context.Context, params *ReadRequest) (*ReadResponse, error) {
+	key, err := c.cacheKey(params)
+	if err != nil {
+		return nil, err
+	}
+	if !params.CacheBypass {
+		if response, ok := c.cached(key); ok {
+			return response, nil
+		}
+	}
+
 	var response *ReadResponse
-	if err := c.Client.Socket.Call(ctx, "read", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call read method over JSON-RPC: %v", err)
+	if err := c.Client.Call(ctx, "read", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to call read method over JSON-RPC: %w", err)
 	}
+
+	c.store(key, response)
 	return response, nil
 }
+
+// ReadResult is the outcome of reading a single data source as part of ReadMany.
+type ReadResult struct {
+	// Response is the read response, set when the read succeeded
+	Response *ReadResponse
+	// Err is why the read failed, set when it did
+	Err error
+}
+
+// ReadMany reads many instances of the data source with a single JSON-RPC 2.0 batch of "read" calls,
+// refreshing them all in one round trip against one process instead of one call each.
+//
+// Results are returned in the same order as params. Each read succeeds or fails on its own, so one bad
+// read does not fail the others. Cached results are used just like Read, only the rest are sent to Deno.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - params: The read requests of the data sources to read
+//
+// Returns a result per item, in the same order as params, or an error if the batch as a whole failed.
+func (c *DenoClientDatasource) ReadMany(ctx context.Context, params []*ReadRequest) ([]ReadResult, error) {
+	results := make([]ReadResult, len(params))
+	keys := make([][sha256.Size]byte, len(params))
+	var items []jsocket.BatchItem
+	var pending []int
+	for i, item := range params {
+		key, err := c.cacheKey(item)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		if !item.CacheBypass {
+			if response, ok := c.cached(key); ok {
+				results[i].Response = response
+				continue
+			}
+		}
+		items = append(items, jsocket.BatchItem{Method: "read", Params: item})
+		pending = append(pending, i)
+	}
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	batch, err := c.Client.CallBatch(ctx, items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call read method over JSON-RPC: %w", err)
+	}
+	for j, i := range pending {
+		var response *ReadResponse
+		if err := batch[j].Unmarshal(&response); err != nil {
+			results[i].Err = fmt.Errorf("failed to call read method over JSON-RPC: %w", err)
+			continue
+		}
+		c.store(keys[i], response)
+		results[i].Response = response
+	}
+	return results, nil
+}
+
+// hasErrors reports whether the response has any error diagnostics.
+func (r *ReadResponse) hasErrors() bool {
+	if r.Diagnostics == nil {
+		return false
+	}
+	for _, diag := range *r.Diagnostics {
+		if diag.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
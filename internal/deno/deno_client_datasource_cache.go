@@ -0,0 +1,117 @@
+package deno
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DatasourceCache holds read results shared by many DenoClientDatasource, eg: every data source of a provider,
+// so a data source read again with the same script, config, permissions and props is answered without starting
+// Deno at all. It is safe for concurrent use.
+type DatasourceCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]datasourceCacheEntry
+}
+
+// datasourceCacheEntry is a cached read result, kept as JSON so every hit is decoded into a copy of its own.
+type datasourceCacheEntry struct {
+	response []byte
+	expires  time.Time
+}
+
+// NewDatasourceCache creates an empty DatasourceCache.
+func NewDatasourceCache() *DatasourceCache {
+	return &DatasourceCache{}
+}
+
+// get returns a deep copy of the unexpired cached read result for key, if any.
+func (c *DatasourceCache) get(key [sha256.Size]byte) (*ReadResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	var response *ReadResponse
+	if err := json.Unmarshal(entry.response, &response); err != nil {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return response, true
+}
+
+// put caches a read result under key for ttl. Results that cannot be encoded are not cached.
+func (c *DatasourceCache) put(key [sha256.Size]byte, response *ReadResponse, ttl time.Duration) {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]datasourceCacheEntry)
+	}
+	c.entries[key] = datasourceCacheEntry{response: encoded, expires: time.Now().Add(ttl)}
+}
+
+// cacheEnabled reports whether read results are cached.
+func (c *DenoClientDatasource) cacheEnabled() bool {
+	return c.CacheTTL > 0 && c.Cache != nil
+}
+
+// cacheKey returns the cache key of a read request, the zero key when caching is disabled.
+// The key covers everything that decides what the read returns, as the cache is shared with
+// clients of other scripts.
+func (c *DenoClientDatasource) cacheKey(params *ReadRequest) ([sha256.Size]byte, error) {
+	var key [sha256.Size]byte
+	if !c.cacheEnabled() {
+		return key, nil
+	}
+	encoded, err := json.Marshal(struct {
+		Script      string       `json:"script"`
+		Config      string       `json:"config"`
+		Permissions *Permissions `json:"permissions"`
+		Props       any          `json:"props"`
+	}{c.Client.scriptPath, c.Client.configPath, c.Client.permissions, params.Props})
+	if err != nil {
+		return key, fmt.Errorf("failed to hash data source props: %w", err)
+	}
+	return sha256.Sum256(encoded), nil
+}
+
+// store caches a successful read result under key, when caching is enabled.
+func (c *DenoClientDatasource) store(key [sha256.Size]byte, response *ReadResponse) {
+	if !c.cacheEnabled() || response == nil || response.hasErrors() {
+		return
+	}
+	c.Cache.put(key, response, c.CacheTTL)
+}
+
+// cached returns a copy of the unexpired cached read result for key, if any.
+func (c *DenoClientDatasource) cached(key [sha256.Size]byte) (*ReadResponse, bool) {
+	if !c.cacheEnabled() {
+		return nil, false
+	}
+	return c.Cache.get(key)
+}
+
+// CachedRead returns the cached result of a read, if any, without calling Deno, so callers can skip
+// starting the Deno process when it is not needed. Bypassed reads are never answered from the cache.
+func (c *DenoClientDatasource) CachedRead(params *ReadRequest) (*ReadResponse, bool) {
+	if params.CacheBypass {
+		return nil, false
+	}
+	key, err := c.cacheKey(params)
+	if err != nil {
+		return nil, false
+	}
+	return c.cached(key)
+}
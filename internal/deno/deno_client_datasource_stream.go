@@ -0,0 +1,190 @@
+package deno
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ReadChunkRequest is a readChunk notification from the Deno runtime, delivering part of the
+// result of a streamed read.
+type ReadChunkRequest struct {
+	// StreamID is the streamId of the read the chunk belongs to
+	StreamID string `json:"streamId"`
+	// Seq numbers the chunks of a stream from 0, notifications are handled concurrently
+	// so chunks may arrive out of order and are put back in order by the provider
+	Seq int `json:"seq"`
+	// Chunk is the partial result
+	Chunk any `json:"chunk"`
+}
+
+// DenoClientDatasourceServerMethods implements the server-side JSON-RPC methods that
+// the Deno runtime can call back to the provider. It delivers the chunks of streamed reads.
+type DenoClientDatasourceServerMethods struct {
+	// datasource owns the streamed reads chunks are delivered to
+	datasource *DenoClientDatasource
+}
+
+// ReadChunk handles a chunk of a streamed read, passing it on to the callback given to ReadStream.
+// Chunks of unknown streams, eg: one that already failed, are dropped.
+func (m *DenoClientDatasourceServerMethods) ReadChunk(ctx context.Context, params *ReadChunkRequest) {
+	m.datasource.streamsMu.Lock()
+	stream := m.datasource.streams[params.StreamID]
+	m.datasource.streamsMu.Unlock()
+	if stream != nil {
+		stream.receive(params.Seq, params.Chunk)
+	}
+}
+
+// ReadStream reads the data source like Read, except the script may deliver the result progressively
+// as readChunk notifications, so a huge collection does not have to be buffered in one response. The
+// callback is called once for each chunk, in order and never concurrently. The terminal read response
+// says how many chunks were sent, ReadStream returns once they were all passed to the callback.
+//
+// A script that does not stream its result returns it whole, which is passed to the callback as a single
+// chunk. Streamed reads are never cached. The first error diagnostic of the read is returned as an error.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - params: The read request containing the data source configuration properties
+//   - callback: Called with each chunk, returning an error cancels the read and is returned by ReadStream
+//
+// Returns an error if the JSON-RPC call fails, the read failed or the callback returned an error.
+func (c *DenoClientDatasource) ReadStream(ctx context.Context, params *ReadRequest, callback func(chunk any) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	request := *params
+	request.StreamID = fmt.Sprintf("read-%d", c.nextStreamID.Add(1))
+	stream := &readStream{
+		callback:  callback,
+		cancel:    cancel,
+		buffered:  map[int]any{},
+		delivered: make(chan struct{}, 1),
+	}
+	c.streamsMu.Lock()
+	if c.streams == nil {
+		c.streams = make(map[string]*readStream)
+	}
+	c.streams[request.StreamID] = stream
+	c.streamsMu.Unlock()
+	defer func() {
+		c.streamsMu.Lock()
+		delete(c.streams, request.StreamID)
+		c.streamsMu.Unlock()
+	}()
+
+	var response *ReadResponse
+	err := c.Client.Call(ctx, "read", &request, &response)
+	if streamErr := stream.failed(); streamErr != nil {
+		return streamErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to call read method over JSON-RPC: %w", err)
+	}
+	if response == nil {
+		return nil
+	}
+
+	if err := stream.wait(ctx, response.Chunks); err != nil {
+		return err
+	}
+	if err := readDiagnosticsError(response); err != nil {
+		return err
+	}
+	if response.Chunks == 0 && response.Result != nil {
+		return callback(response.Result)
+	}
+	return nil
+}
+
+// readStream reorders the chunks of a streamed read and passes them to its callback.
+type readStream struct {
+	callback func(chunk any) error
+	// cancel cancels the read once the callback failed
+	cancel context.CancelFunc
+	// delivered is signalled after chunks were passed to the callback
+	delivered chan struct{}
+
+	mu sync.Mutex
+	// next is the seq of the next chunk to pass to the callback
+	next int
+	// buffered are the chunks that arrived before the chunks preceding them
+	buffered map[int]any
+	// err is the error returned by the callback
+	err error
+}
+
+// receive buffers a chunk, then passes every chunk that is now in order to the callback.
+func (s *readStream) receive(seq int, chunk any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil || seq < s.next {
+		return
+	}
+
+	s.buffered[seq] = chunk
+	for {
+		chunk, ok := s.buffered[s.next]
+		if !ok {
+			break
+		}
+		delete(s.buffered, s.next)
+		if err := s.callback(chunk); err != nil {
+			s.err = err
+			s.cancel()
+			break
+		}
+		s.next++
+	}
+
+	select {
+	case s.delivered <- struct{}{}:
+	default:
+	}
+}
+
+// failed returns the error returned by the callback, if any.
+func (s *readStream) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// wait blocks until the given number of chunks were passed to the callback.
+func (s *readStream) wait(ctx context.Context, chunks int) error {
+	for {
+		s.mu.Lock()
+		next, err := s.next, s.err
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if next >= chunks {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			// A failed callback cancels ctx itself
+			if err := s.failed(); err != nil {
+				return err
+			}
+			return fmt.Errorf("gave up waiting for chunk %d of %d of streamed read: %w", next, chunks, ctx.Err())
+		case <-s.delivered:
+		}
+	}
+}
+
+// readDiagnosticsError returns the first error diagnostic of a read response as an error.
+func readDiagnosticsError(response *ReadResponse) error {
+	if response == nil || response.Diagnostics == nil {
+		return nil
+	}
+	for _, diag := range *response.Diagnostics {
+		if diag.Severity == "error" {
+			return fmt.Errorf("%s: %s", diag.Summary, diag.Detail)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package deno
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+)
+
+// newFakeDenoClientDatasource returns a DenoClientDatasource backed by the fake Deno executable.
+func newFakeDenoClientDatasource(t *testing.T, scenario string, cacheTTL time.Duration) *DenoClientDatasource {
+	t.Helper()
+	c := &DenoClientDatasource{
+		Client:   newFakeDenoClient(t, scenario),
+		CacheTTL: cacheTTL,
+		Cache:    NewDatasourceCache(),
+	}
+	c.Client.rpcMethods = jsocket.TypedServerMethods(&DenoClientDatasourceServerMethods{c})
+	return c
+}
+
+func TestDenoClientDatasource_Cache(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "echo-read", 200*time.Millisecond)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}})
+	assert.NoError(t, err)
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.Client.Summary().Calls["read"])
+
+	// Different props are cached separately
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, c.Client.Summary().Calls["read"])
+
+	// A bypass always reads afresh
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}, CacheBypass: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, c.Client.Summary().Calls["read"])
+
+	// Expired results are read afresh
+	time.Sleep(250 * time.Millisecond)
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, c.Client.Summary().Calls["read"])
+}
+
+func TestDenoClientDatasource_CacheDisabled(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "echo-read", 0)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	for range 2 {
+		_, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, c.Client.Summary().Calls["read"])
+}
+
+func TestDenoClientDatasource_CacheConcurrent(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "echo-read", time.Minute)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			_, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": i % 2}})
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	assert.True(t, c.Client.Summary().Calls["read"] <= 20)
+
+	_, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": 0}})
+	assert.NoError(t, err)
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": 1}})
+	assert.NoError(t, err)
+	reads := c.Client.Summary().Calls["read"]
+	_, err = c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, reads, c.Client.Summary().Calls["read"])
+}
+
+func TestDenoClientDatasource_CacheShared(t *testing.T) {
+	cache := NewDatasourceCache()
+	props := map[string]any{"tags": map[string]any{"env": "prod"}}
+
+	first := newFakeDenoClientDatasource(t, "batch-read", time.Minute)
+	first.Cache = cache
+	assert.NoError(t, first.Client.Start(t.Context()))
+	response, err := first.Read(t.Context(), &ReadRequest{Props: props})
+	assert.NoError(t, err)
+	assert.NoError(t, first.Client.Stop())
+
+	// A later client of the same script is answered from the cache without being started
+	second := newFakeDenoClientDatasource(t, "batch-read", time.Minute)
+	second.Cache = cache
+	cached, ok := second.CachedRead(&ReadRequest{Props: props})
+	assert.True(t, ok)
+	assert.Equal(t, response.Result, cached.Result)
+	_, ok = second.CachedRead(&ReadRequest{Props: props, CacheBypass: true})
+	assert.False(t, ok)
+
+	// Hits are deep copies, changing one does not change the cache
+	cached.Result.(map[string]any)["tags"].(map[string]any)["env"] = "dev"
+	again, ok := second.CachedRead(&ReadRequest{Props: props})
+	assert.True(t, ok)
+	assert.Equal(t, "prod", again.Result.(map[string]any)["tags"].(map[string]any)["env"])
+
+	// Other scripts do not share results, even with the same props
+	other := newFakeDenoClientDatasource(t, "batch-read", time.Minute)
+	other.Cache = cache
+	other.Client.scriptPath = "other.ts"
+	_, ok = other.CachedRead(&ReadRequest{Props: props})
+	assert.False(t, ok)
+}
+
+func TestDenoClientDatasource_ReadMany(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "batch-read", 0)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	results, err := c.ReadMany(t.Context(), []*ReadRequest{
+		{Props: map[string]any{"name": "a", "delayMs": 50}},
+		{Props: map[string]any{"fail": true}},
+		{Props: map[string]any{"name": "c"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(results))
+
+	// Results keep the order of the requests even though "a" was answered last
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, any(map[string]any{"name": "a", "delayMs": float64(50)}), results[0].Response.Result)
+	assert.EqualError(t, results[1].Err, "failed to call read method over JSON-RPC: jsonrpc2: code 1 message: lookup failed")
+	assert.Zero(t, results[1].Response)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, any(map[string]any{"name": "c"}), results[2].Response.Result)
+
+	// All three reads went over the wire as a single batch
+	var batches int
+	assert.NoError(t, c.Client.Call(t.Context(), "batches", nil, &batches))
+	assert.Equal(t, 1, batches)
+	assert.Equal(t, 3, c.Client.Summary().Calls["read"])
+}
+
+func TestDenoClientDatasource_ReadManyCached(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "batch-read", time.Minute)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{"name": "a"}})
+	assert.NoError(t, err)
+
+	results, err := c.ReadMany(t.Context(), []*ReadRequest{
+		{Props: map[string]any{"name": "a"}},
+		{Props: map[string]any{"name": "b"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"name": "a"}), results[0].Response.Result)
+	assert.Equal(t, any(map[string]any{"name": "b"}), results[1].Response.Result)
+	assert.Equal(t, 2, c.Client.Summary().Calls["read"])
+
+	// Everything is cached now, so nothing is sent at all
+	results, err = c.ReadMany(t.Context(), []*ReadRequest{{Props: map[string]any{"name": "b"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"name": "b"}), results[0].Response.Result)
+	assert.Equal(t, 2, c.Client.Summary().Calls["read"])
+}
+
+func TestDenoClientDatasource_ReadStream(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "stream-read", time.Minute)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// Chunks are passed on in order, even though notifications are handled concurrently
+	for range 3 {
+		var chunks []any
+		err := c.ReadStream(t.Context(), &ReadRequest{Props: map[string]any{}}, func(chunk any) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []any{0.0, 1.0, 2.0, 3.0, 4.0}, chunks)
+	}
+
+	// Streamed reads are never cached
+	assert.Equal(t, 3, c.Client.Summary().Calls["read"])
+
+	// A plain read still gets the whole result
+	response, err := c.Read(t.Context(), &ReadRequest{Props: map[string]any{}})
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"items": []any{0.0, 1.0, 2.0, 3.0, 4.0}}), response.Result)
+}
+
+func TestDenoClientDatasource_ReadStreamWhole(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "stream-read", 0)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	var chunks []any
+	err := c.ReadStream(t.Context(), &ReadRequest{Props: map[string]any{"whole": true}}, func(chunk any) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"items": []any{0.0, 1.0, 2.0, 3.0, 4.0}}}, chunks)
+}
+
+func TestDenoClientDatasource_ReadStreamCallbackError(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "stream-read", 0)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	errFull := errors.New("buffer full")
+	var chunks []any
+	err := c.ReadStream(t.Context(), &ReadRequest{Props: map[string]any{}}, func(chunk any) error {
+		chunks = append(chunks, chunk)
+		if len(chunks) == 2 {
+			return errFull
+		}
+		return nil
+	})
+	assert.IsError(t, err, errFull)
+	assert.Equal(t, []any{0.0, 1.0}, chunks)
+}
+
+func TestDenoClientDatasource_ReadStreamDiagnostics(t *testing.T) {
+	c := newFakeDenoClientDatasource(t, "stream-read", 0)
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	chunks := 0
+	err := c.ReadStream(t.Context(), &ReadRequest{Props: map[string]any{"fail": true}}, func(chunk any) error {
+		chunks++
+		return nil
+	})
+	assert.EqualError(t, err, "listing failed: page 2 expired")
+	assert.Equal(t, 5, chunks)
+}
+
+func TestReadStream_Reorders(t *testing.T) {
+	var chunks []any
+	s := &readStream{
+		callback:  func(chunk any) error { chunks = append(chunks, chunk); return nil },
+		cancel:    func() {},
+		buffered:  map[int]any{},
+		delivered: make(chan struct{}, 1),
+	}
+	for _, seq := range []int{2, 0, 3, 0, 1} {
+		s.receive(seq, seq)
+	}
+	assert.NoError(t, s.wait(t.Context(), 4))
+	assert.Equal(t, []any{0, 1, 2, 3}, chunks)
+}
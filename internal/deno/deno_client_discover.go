@@ -0,0 +1,180 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrScriptContract is returned by Start when the OpenRPC document of the script shows
+// that it does not implement a method the provider will call, or not compatibly.
+var ErrScriptContract = errors.New("script does not implement the methods the provider calls")
+
+// ScriptContract describes the methods the provider calls on a kind of script. After the health
+// handshake, Start fetches the script's OpenRPC document with rpc.discover and checks it against the
+// contract. Scripts that do not implement rpc.discover are not checked.
+type ScriptContract struct {
+	// Kind names the kind of script in errors, eg: "resource"
+	Kind string
+	// Methods are the methods the provider calls
+	Methods []MethodContract
+}
+
+// MethodContract describes a single method the provider calls.
+type MethodContract struct {
+	// Name is the name of the JSON-RPC method
+	Name string
+	// Params is a value of the type sent as the params of the method, eg: CreateRequest{}.
+	// Its json tags list the params the provider sends, nil sends none.
+	Params any
+}
+
+var (
+	resourceContract = &ScriptContract{Kind: "resource", Methods: []MethodContract{
+		{"create", CreateRequest{}},
+		{"read", CreateReadRequest{}},
+		{"update", UpdateRequest{}},
+		{"delete", DeleteRequest{}},
+	}}
+	datasourceContract = &ScriptContract{Kind: "datasource", Methods: []MethodContract{
+		{"read", ReadRequest{}},
+	}}
+	ephemeralResourceContract = &ScriptContract{Kind: "ephemeral resource", Methods: []MethodContract{
+		{"open", OpenRequest{}},
+	}}
+	actionContract = &ScriptContract{Kind: "action", Methods: []MethodContract{
+		{"invoke", InvokeRequest{}},
+	}}
+)
+
+// withContract sets the contract the script is checked against, see ScriptContract.
+func withContract(contract *ScriptContract) DenoClientOption {
+	return func(c *DenoClient) {
+		c.Contract = contract
+	}
+}
+
+// WithoutDiscovery skips checking the script's OpenRPC document against its ScriptContract.
+func WithoutDiscovery() DenoClientOption {
+	return func(c *DenoClient) {
+		c.SkipDiscovery = true
+	}
+}
+
+// OpenRPCDocument is the subset of an OpenRPC document returned by rpc.discover that is checked.
+type OpenRPCDocument struct {
+	// Methods are the methods the script implements
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+// OpenRPCMethod describes a single method in an OpenRPCDocument.
+type OpenRPCMethod struct {
+	// Name is the name of the JSON-RPC method
+	Name string `json:"name"`
+	// Params are the params of the method, by-name methods take a single object param called "params"
+	Params []OpenRPCParam `json:"params"`
+}
+
+// OpenRPCParam describes a single param of an OpenRPCMethod.
+type OpenRPCParam struct {
+	// Name is the name of the param
+	Name string `json:"name"`
+	// Schema is the JSON schema of the param, only its type and required list are checked
+	Schema struct {
+		Type     any      `json:"type,omitempty"`
+		Required []string `json:"required,omitempty"`
+	} `json:"schema"`
+}
+
+// discover fetches the script's OpenRPC document and checks it against the Contract. Scripts that do not
+// implement rpc.discover, or clients without a contract or with SkipDiscovery set, are not checked.
+func (c *DenoClient) discover(ctx context.Context) error {
+	if c.Contract == nil || c.SkipDiscovery {
+		return nil
+	}
+
+	if c.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.StartupTimeout)
+		defer cancel()
+	}
+
+	var document OpenRPCDocument
+	if err := c.Socket.Call(ctx, "rpc.discover", nil, &document); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to call the Deno JSON-RPC servers rpc.discover method: %w", err)
+	}
+
+	if err := c.Contract.check(&document); err != nil {
+		return fmt.Errorf("deno script %s: %w", c.scriptPath, err)
+	}
+	return nil
+}
+
+// check returns an error describing the first method of the contract that the document
+// does not implement, or implements with params the provider can not satisfy.
+func (contract *ScriptContract) check(document *OpenRPCDocument) error {
+	for _, expected := range contract.Methods {
+		i := slices.IndexFunc(document.Methods, func(m OpenRPCMethod) bool { return m.Name == expected.Name })
+		if i < 0 {
+			return fmt.Errorf("%w: %s script missing required method '%s'", ErrScriptContract, contract.Kind, expected.Name)
+		}
+
+		for _, param := range document.Methods[i].Params {
+			if param.Name != "params" {
+				continue
+			}
+			if param.Schema.Type != nil && param.Schema.Type != "object" {
+				return fmt.Errorf("%w: %s script method '%s' must take an object as its params, not %v",
+					ErrScriptContract, contract.Kind, expected.Name, param.Schema.Type)
+			}
+			sent := jsonFieldNames(expected.Params)
+			for _, required := range param.Schema.Required {
+				if !slices.Contains(sent, required) {
+					return fmt.Errorf("%w: %s script method '%s' requires the param '%s', which the provider does not send",
+						ErrScriptContract, contract.Kind, expected.Name, required)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames returns the names v is encoded to JSON with, for a struct value.
+func jsonFieldNames(v any) []string {
+	if v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var names []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
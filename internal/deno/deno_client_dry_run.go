@@ -0,0 +1,44 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// ErrDryRun is returned by Start when DryRun is set, once it has worked out the Deno command it would
+// have executed. The returned error describes that command.
+var ErrDryRun = errors.New("dry run, the deno process was not started")
+
+// WithDryRun makes Start log the Deno command it would execute and return ErrDryRun, without
+// starting the process, see DryRun.
+func WithDryRun() DenoClientOption {
+	return func(c *DenoClient) {
+		c.DryRun = true
+	}
+}
+
+// dryRun logs the Deno command start would have executed, and its environment without values,
+// then returns ErrDryRun describing the command.
+func (c *DenoClient) dryRun(ctx context.Context, denoBinaryPath string, args []string, workingDir string) error {
+	cmdStr := strings.Join(append([]string{denoBinaryPath}, args...), " ")
+	if workingDir == "" {
+		workingDir = "the provider's working directory"
+	}
+
+	msg := fmt.Sprintf("Dry run, would have executed Deno command in %s: %s", workingDir, cmdStr)
+	envMsg := fmt.Sprintf("Dry run, Deno command environment: %s", redactEnv(c.environ()))
+	if isTestContext() {
+		log.Printf("[INFO] %s", msg)
+		log.Printf("[DEBUG] %s", envMsg)
+	} else {
+		tflog.Info(ctx, msg)
+		tflog.Debug(ctx, envMsg)
+	}
+
+	return fmt.Errorf("%w, it would have executed %s in %s", ErrDryRun, cmdStr, workingDir)
+}
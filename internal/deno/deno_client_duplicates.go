@@ -0,0 +1,24 @@
+package deno
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// duplicateResponseConnOpt warns about scripts that answer a request more than once.
+// The duplicates themselves are ignored, the first response is the one the caller gets.
+func (c *DenoClient) duplicateResponseConnOpt(ctx context.Context) jsonrpc2.ConnOpt {
+	return jsocket.OnDuplicateResponse(func(id jsonrpc2.ID, method string) {
+		msg := fmt.Sprintf("Ignored a duplicate response from the Deno script to %s request %s, it was already answered", method, id)
+		if isTestContext() {
+			log.Printf("[WARN] %s", msg)
+		} else {
+			tflog.Warn(ctx, msg)
+		}
+	})
+}
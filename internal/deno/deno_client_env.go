@@ -0,0 +1,79 @@
+package deno
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// WithWorkingDir sets the working directory of the Deno process.
+func WithWorkingDir(dir string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.WorkingDir = dir
+	}
+}
+
+// WithEnv sets environment variables on the Deno process, overriding any inherited
+// variables of the same name. Useful to inject per-resource secrets or config.
+func WithEnv(env map[string]string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.Env = env
+	}
+}
+
+// WithClearEnv starts the Deno process from an empty environment, rather than
+// inheriting the environment of the provider, for stricter isolation.
+func WithClearEnv() DenoClientOption {
+	return func(c *DenoClient) {
+		c.ClearEnv = true
+	}
+}
+
+// WithForwardEnv only forwards the named variables from the environment of the provider
+// to the Deno process, leaving everything else out, eg: AWS_REGION and HOME.
+func WithForwardEnv(names ...string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ForwardEnv = names
+	}
+}
+
+// environ builds the environment of the Deno process by merging Env onto the environment
+// of the provider. Only the variables named by ForwardEnv are inherited when it is set,
+// and none at all when ClearEnv is set, unless named by ForwardEnv.
+func (c *DenoClient) environ() []string {
+	var base []string
+	switch {
+	case len(c.ForwardEnv) > 0:
+		for _, name := range c.ForwardEnv {
+			if value, ok := os.LookupEnv(name); ok {
+				base = append(base, name+"="+value)
+			}
+		}
+	case !c.ClearEnv:
+		base = os.Environ()
+	}
+
+	env := make([]string, 0, len(base)+len(c.Env))
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, overridden := c.Env[key]; !overridden {
+			env = append(env, kv)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.Env)) {
+		env = append(env, key+"="+c.Env[key])
+	}
+
+	return env
+}
+
+// redactEnv returns env with every value replaced, so it can be logged without leaking secrets.
+func redactEnv(env []string) string {
+	redacted := make([]string, len(env))
+	for i, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		redacted[i] = key + "=<redacted>"
+	}
+	return strings.Join(redacted, " ")
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -14,6 +16,17 @@ import (
 type DenoClientEphemeralResource struct {
 	// Client is the underlying Deno client used for JSON-RPC communication
 	Client *DenoClient
+
+	// AutoRenew renews an opened resource in the background whenever it reports a RenewAt,
+	// until Close is called or the context given to Open ends, see RenewFailed.
+	AutoRenew bool
+
+	// RenewSkew is how long before RenewAt a background renewal is made, to allow for clock skew.
+	RenewSkew time.Duration
+
+	// renewer renews the opened resource in the background, when AutoRenew is set
+	renewer   *autoRenewer
+	renewerMu sync.Mutex
 }
 
 // NewDenoClientEphemeralResource creates a new DenoClientEphemeralResource with the specified configuration.
@@ -24,17 +37,20 @@ type DenoClientEphemeralResource struct {
 //   - scriptPath: The path to the TypeScript/JavaScript ephemeral resource script to execute
 //   - configPath: The path to the Deno configuration file (deno.json)
 //   - permissions: The Deno security permissions to grant the runtime
+//   - opts: Optional settings for the underlying DenoClient, eg: WithStartupTimeout
 //
 // Returns a configured DenoClientEphemeralResource ready to manage ephemeral resources.
-func NewDenoClientEphemeralResource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions) *DenoClientEphemeralResource {
+func NewDenoClientEphemeralResource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, opts ...DenoClientOption) *DenoClientEphemeralResource {
 	return &DenoClientEphemeralResource{
-		NewDenoClient(
+		Client: NewDenoClient(
 			denoBinaryPath,
 			scriptPath,
 			configPath,
 			permissions,
 			nil,
+			append([]DenoClientOption{withContract(ephemeralResourceContract)}, opts...)...,
 		),
+		RenewSkew: DefaultRenewSkew,
 	}
 }
 
@@ -72,15 +88,20 @@ type OpenResponse struct {
 // Open executes the ephemeral resource open operation by calling the "open" method via JSON-RPC.
 // It sends the configuration properties to the Deno runtime and retrieves the resource data.
 //
+// When AutoRenew is set and the response has a RenewAt, the resource is renewed in the background from then on.
+//
 // Parameters:
-//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - ctx: The context for the operation, used for cancellation and timeouts, background renewal stops when it ends
 //   - params: The open request containing the ephemeral resource configuration properties
 //
 // Returns the open response containing the resource data and optional renewal time, or an error if the JSON-RPC call fails.
 func (c *DenoClientEphemeralResource) Open(ctx context.Context, params *OpenRequest) (*OpenResponse, error) {
 	var response *OpenResponse
-	if err := c.Client.Socket.Call(ctx, "open", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call open method over JSON-RPC: %v", err)
+	if err := c.Client.Call(ctx, "open", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to call open method over JSON-RPC: %w", err)
+	}
+	if c.AutoRenew && response != nil && response.RenewAt != nil {
+		c.startAutoRenew(ctx, response)
 	}
 	return response, nil
 }
@@ -112,6 +133,9 @@ type RenewResponse struct {
 	} `json:"diagnostics,omitempty"`
 }
 
+// ErrRenewUnsupported is returned by Renew when the script reported capabilities that lack renew.
+var ErrRenewUnsupported = errors.New("deno script does not support renewing")
+
 // Renew executes the ephemeral resource renewal operation by calling the "renew" method via JSON-RPC.
 // It sends the private state data to the Deno runtime to refresh the resource's lifetime.
 //
@@ -120,10 +144,15 @@ type RenewResponse struct {
 //   - params: The renew request containing the private state data
 //
 // Returns the renew response containing the next renewal time, or an error if the JSON-RPC call fails.
+// Returns ErrRenewUnsupported without calling the script when it reported capabilities that lack renew.
 func (c *DenoClientEphemeralResource) Renew(ctx context.Context, params *RenewRequest) (*RenewResponse, error) {
+	if !c.Client.HasCapability("renew") {
+		return nil, ErrRenewUnsupported
+	}
+
 	var response *RenewResponse
-	if err := c.Client.Socket.Call(ctx, "renew", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call renew method over JSON-RPC: %v", err)
+	if err := c.Client.Call(ctx, "renew", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to call renew method over JSON-RPC: %w", err)
 	}
 	return response, nil
 }
@@ -156,6 +185,8 @@ type CloseResponse struct {
 // Close executes the ephemeral resource close operation by calling the "close" method via JSON-RPC.
 // It sends the private state data to the Deno runtime to clean up the resource.
 // Note: The close method is optional; if not implemented in the script, this method returns nil.
+// Any background renewal is stopped first, cancelling a renewal in flight and waiting for it to settle, and the
+// close call is given the private data of the latest renewal.
 //
 // Parameters:
 //   - ctx: The context for the operation, used for cancellation and timeouts
@@ -164,8 +195,12 @@ type CloseResponse struct {
 // Returns an error if the JSON-RPC call fails or the close operation is not complete.
 // Returns nil if the close method is not implemented (CodeMethodNotFound).
 func (c *DenoClientEphemeralResource) Close(ctx context.Context, params *CloseRequest) (*CloseResponse, error) {
+	if private, renewed := c.stopAutoRenew(); renewed {
+		params = &CloseRequest{Private: private}
+	}
+
 	var response *CloseResponse
-	if err := c.Client.Socket.Call(ctx, "close", params, &response); err != nil {
+	if err := c.Client.Call(ctx, "close", params, &response); err != nil {
 
 		// Close method is optional - return nil if not implemented
 		var rpcErr *jsonrpc2.Error
@@ -173,7 +208,7 @@ func (c *DenoClientEphemeralResource) Close(ctx context.Context, params *CloseRe
 			return nil, nil
 		}
 
-		return nil, fmt.Errorf("failed to call close method over JSON-RPC: %v", err)
+		return nil, fmt.Errorf("failed to call close method over JSON-RPC: %w", err)
 	}
 	return response, nil
 }
@@ -0,0 +1,131 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRenewSkew is how long before its RenewAt an ephemeral resource is renewed by default.
+const DefaultRenewSkew = 30 * time.Second
+
+// ErrRenewFailed is sent on RenewFailed when a background renewal fails.
+var ErrRenewFailed = errors.New("ephemeral resource renewal failed")
+
+// autoRenewer renews an opened ephemeral resource in the background.
+type autoRenewer struct {
+	cancel context.CancelFunc
+	// done is closed once the renewer has stopped
+	done chan struct{}
+	// failed receives the renewal failure that stopped the renewer, if any
+	failed chan error
+
+	mu sync.Mutex
+	// private is the private data from the latest open or renew response
+	private *any
+}
+
+// startAutoRenew renews the resource opened with response in the background, until Close is called or ctx ends.
+// Any renewer of a previously opened resource is stopped first.
+func (c *DenoClientEphemeralResource) startAutoRenew(ctx context.Context, response *OpenResponse) {
+	c.stopAutoRenew()
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &autoRenewer{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		failed:  make(chan error, 1),
+		private: response.Private,
+	}
+	c.renewerMu.Lock()
+	c.renewer = r
+	c.renewerMu.Unlock()
+	go c.autoRenew(ctx, r, *response.RenewAt)
+}
+
+// autoRenew sleeps until renewAt, less RenewSkew, renews the resource and repeats for as long as
+// the script keeps asking to be renewed. Each renewal is given the private data of the previous one.
+func (c *DenoClientEphemeralResource) autoRenew(ctx context.Context, r *autoRenewer, renewAt int64) {
+	defer close(r.done)
+	for {
+		timer := time.NewTimer(time.Until(time.Unix(renewAt, 0).Add(-c.RenewSkew)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		r.mu.Lock()
+		private := r.private
+		r.mu.Unlock()
+
+		response, err := c.Renew(ctx, &RenewRequest{Private: private})
+		if err == nil {
+			err = renewDiagnosticsError(response)
+		}
+		if err != nil {
+			// Being stopped mid renewal is not a failure
+			if ctx.Err() == nil {
+				r.failed <- fmt.Errorf("%w: %w", ErrRenewFailed, err)
+			}
+			return
+		}
+
+		if response.Private != nil {
+			r.mu.Lock()
+			r.private = response.Private
+			r.mu.Unlock()
+		}
+		if response.RenewAt == nil {
+			return
+		}
+		renewAt = *response.RenewAt
+	}
+}
+
+// renewDiagnosticsError returns the first error diagnostic of a renew response as an error.
+func renewDiagnosticsError(response *RenewResponse) error {
+	if response == nil || response.Diagnostics == nil {
+		return nil
+	}
+	for _, diag := range *response.Diagnostics {
+		if diag.Severity == "error" {
+			return fmt.Errorf("%s: %s", diag.Summary, diag.Detail)
+		}
+	}
+	return nil
+}
+
+// stopAutoRenew stops the background renewer, if any, returning the private data of its latest renewal.
+// A renewal in flight is cancelled, which sends $/cancelRequest so the script can abort it, and has
+// settled by the time stopAutoRenew returns, so it can not complete after the resource is closed.
+func (c *DenoClientEphemeralResource) stopAutoRenew() (*any, bool) {
+	c.renewerMu.Lock()
+	r := c.renewer
+	c.renewer = nil
+	c.renewerMu.Unlock()
+	if r == nil {
+		return nil, false
+	}
+	r.cancel()
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.private, true
+}
+
+// RenewFailed returns a channel that receives an error wrapping ErrRenewFailed if a background
+// renewal fails, after which the resource is no longer renewed and should be torn down.
+// It returns nil, which never receives, when the resource is not being renewed in the background.
+func (c *DenoClientEphemeralResource) RenewFailed() <-chan error {
+	c.renewerMu.Lock()
+	defer c.renewerMu.Unlock()
+	if c.renewer == nil {
+		return nil
+	}
+	return c.renewer.failed
+}
@@ -0,0 +1,160 @@
+package deno
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// newFakeDenoClientEphemeralResource returns a DenoClientEphemeralResource backed by the fake Deno executable.
+func newFakeDenoClientEphemeralResource(t *testing.T, scenario string) *DenoClientEphemeralResource {
+	t.Helper()
+	return &DenoClientEphemeralResource{
+		Client:    newFakeDenoClient(t, scenario),
+		AutoRenew: true,
+	}
+}
+
+func TestDenoClientEphemeralResource_AutoRenew(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renewable")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+
+	// The script stops asking to be renewed after its third renewal
+	deadline := time.Now().Add(10 * time.Second)
+	for c.Client.Summary().Calls["renew"] < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 3, c.Client.Summary().Calls["renew"])
+
+	// Close is given the private data of the latest renewal, not the stale data from open
+	response, err := c.Close(t.Context(), &CloseRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"privateData":{"renewals":3}}`, (*response.Diagnostics)[0].Detail)
+	assert.Equal(t, 3, c.Client.Summary().Calls["renew"])
+}
+
+func TestDenoClientEphemeralResource_AutoRenewStopsOnClose(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renew-later")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+	assert.NotZero(t, c.RenewFailed())
+
+	_, err = c.Close(t.Context(), &CloseRequest{})
+	assert.NoError(t, err)
+	assert.Zero(t, c.RenewFailed())
+	assert.Equal(t, 0, c.Client.Summary().Calls["renew"])
+}
+
+func TestDenoClientEphemeralResource_CloseCancelsRenewalInFlight(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renew-in-flight")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+	renewFailed := c.RenewFailed()
+
+	// Wait for the renewal to be in flight, the script holds it until it is cancelled
+	deadline := time.Now().Add(10 * time.Second)
+	for renewing := false; !renewing && time.Now().Before(deadline); {
+		assert.NoError(t, c.Client.Call(t.Context(), "renewing", nil, &renewing))
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	response, err := c.Close(ctx, &CloseRequest{})
+	assert.NoError(t, err)
+	assert.True(t, response.Done)
+
+	// The renewal was cancelled and never completed, so close is given the private data from open
+	var detail struct {
+		Params    map[string]any `json:"params"`
+		Renew     *jsonrpc2.ID   `json:"renew"`
+		Cancelled *jsonrpc2.ID   `json:"cancelled"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte((*response.Diagnostics)[0].Detail), &detail))
+	assert.Equal(t, map[string]any{"privateData": "opened"}, detail.Params)
+	assert.NotZero(t, detail.Renew)
+	assert.Equal(t, detail.Renew, detail.Cancelled)
+
+	// Being cancelled is not a renewal failure
+	assert.Zero(t, c.RenewFailed())
+	select {
+	case err := <-renewFailed:
+		t.Fatalf("cancellation reported as a renewal failure: %v", err)
+	default:
+	}
+}
+
+func TestDenoClientEphemeralResource_AutoRenewStopsWithContext(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renew-later")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	_, err := c.Open(ctx, &OpenRequest{})
+	assert.NoError(t, err)
+	renewer := c.renewer
+
+	cancel()
+	select {
+	case <-renewer.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("renewer did not stop when its context was cancelled")
+	}
+	select {
+	case err := <-c.RenewFailed():
+		t.Fatalf("cancellation reported as a renewal failure: %v", err)
+	default:
+	}
+}
+
+func TestDenoClientEphemeralResource_ReopenStopsPreviousRenewer(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renew-later")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+	first := c.renewer
+
+	_, err = c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+	select {
+	case <-first.done:
+	default:
+		t.Fatal("renewer of the previously opened resource was not stopped")
+	}
+	assert.True(t, first != c.renewer)
+
+	_, err = c.Close(t.Context(), &CloseRequest{})
+	assert.NoError(t, err)
+}
+
+func TestDenoClientEphemeralResource_AutoRenewFailure(t *testing.T) {
+	c := newFakeDenoClientEphemeralResource(t, "renew-fails")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Open(t.Context(), &OpenRequest{})
+	assert.NoError(t, err)
+
+	select {
+	case err := <-c.RenewFailed():
+		assert.IsError(t, err, ErrRenewFailed)
+		assert.Contains(t, err.Error(), "credentials revoked")
+	case <-time.After(10 * time.Second):
+		t.Fatal("renewal failure was not surfaced")
+	}
+}
@@ -0,0 +1,87 @@
+package deno
+
+import (
+	"slices"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+)
+
+// Feature is an optional part of the bridge protocol, that both the provider and the script
+// must support before it is used. Features are negotiated in the health handshake, the provider
+// offers the features it supports and the script answers with those it supports too.
+type Feature string
+
+const (
+	// FeatureBatch sends many calls as a single JSON-RPC 2.0 batch, see DenoClient.CallBatch.
+	FeatureBatch Feature = "batch"
+	// FeatureCancelRequest sends a $/cancelRequest notification for calls that were cancelled.
+	FeatureCancelRequest Feature = "cancelRequest"
+)
+
+// SupportedFeatures are all of the features this provider supports, offered to scripts by default.
+var SupportedFeatures = []Feature{FeatureBatch, FeatureCancelRequest}
+
+// SupportedEncodings are the payload encodings, besides JSON, this provider offers to scripts in the
+// health handshake, in order of preference.
+var SupportedEncodings = []jsocket.PayloadEncoding{jsocket.EncodingCBOR}
+
+// WithFeatures limits the features offered to the script in the health handshake, eg: to rule
+// out a feature a script claims to support but gets wrong. Features this provider does not
+// support are never offered.
+func WithFeatures(features ...Feature) DenoClientOption {
+	return func(c *DenoClient) {
+		c.Features = features
+	}
+}
+
+// offeredFeatures returns the features offered to the script in the health handshake.
+func (c *DenoClient) offeredFeatures() []Feature {
+	if c.Features == nil {
+		return slices.Clone(SupportedFeatures)
+	}
+	offered := []Feature{}
+	for _, feature := range c.Features {
+		if slices.Contains(SupportedFeatures, feature) && !slices.Contains(offered, feature) {
+			offered = append(offered, feature)
+		}
+	}
+	return offered
+}
+
+// negotiateFeatures returns the offered features the script answered with, in the order
+// they were offered. A script that does not answer with any features gets none of them.
+func negotiateFeatures(offered, answered []Feature) []Feature {
+	agreed := []Feature{}
+	for _, feature := range offered {
+		if slices.Contains(answered, feature) {
+			agreed = append(agreed, feature)
+		}
+	}
+	return agreed
+}
+
+// negotiateEncoding returns the first offered payload encoding the script answered with,
+// falling back to JSON when it answered with none of them.
+func negotiateEncoding(offered, answered []jsocket.PayloadEncoding) jsocket.PayloadEncoding {
+	for _, encoding := range offered {
+		if slices.Contains(answered, encoding) {
+			return encoding
+		}
+	}
+	return jsocket.EncodingJSON
+}
+
+// NegotiatedFeatures returns the features both the provider and the script agreed to use
+// when the process was last started. Returns nil if the process has not been started.
+func (c *DenoClient) NegotiatedFeatures() []Feature {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return slices.Clone(c.features)
+}
+
+// hasFeature returns true if the provider and the script agreed to use the given feature.
+func (c *DenoClient) hasFeature(feature Feature) bool {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return slices.Contains(c.features, feature)
+}
@@ -0,0 +1,127 @@
+package deno
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The read path never holds back a complete message: stdout is read as it arrives and each
+// message is decoded as soon as its last byte has been read, without waiting for more data.
+// Any latency between a script writing a response and the provider acting on it is therefore
+// caused by the script itself not flushing its stdout, which flushTracker attributes.
+
+// WithFlushWarning logs a warning whenever a response took at least threshold to arrive
+// and most of that time was spent waiting for the script to finish writing it, ie: the
+// script wrote part of the response but did not flush the rest promptly.
+func WithFlushWarning(threshold time.Duration) DenoClientOption {
+	return func(c *DenoClient) {
+		c.FlushWarningThreshold = threshold
+	}
+}
+
+// flushTracker measures how long each line of stdout was left partially written.
+type flushTracker struct {
+	mu sync.Mutex
+	// partialSince is when the first byte of the current, incomplete, line was read
+	partialSince time.Time
+	// lastStall is how long the most recently completed line was partially written for
+	lastStall time.Duration
+	// sent records when each request still awaiting a response was written
+	sent map[jsonrpc2.ID]time.Time
+}
+
+// observe records a chunk of stdout as it is read.
+func (t *flushTracker) observe(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.partialSince.IsZero() {
+		t.partialSince = now
+	}
+	i := bytes.LastIndexByte(p, '\n')
+	if i < 0 {
+		return
+	}
+	t.lastStall = now.Sub(t.partialSince)
+	t.partialSince = time.Time{}
+	if i < len(p)-1 {
+		t.partialSince = now
+	}
+}
+
+// flushTrackingReader feeds everything read from stdout to a flushTracker.
+type flushTrackingReader struct {
+	io.ReadCloser
+	tracker *flushTracker
+}
+
+func (r *flushTrackingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.tracker.observe(p[:n])
+	return n, err
+}
+
+// flushConnOpts returns the connection options that attribute response latency to the
+// script not flushing, along with the reader to wrap stdout in. Both are nil unless
+// FlushWarningThreshold is set.
+func (c *DenoClient) flushConnOpts(ctx context.Context, stdout io.ReadCloser) (io.ReadCloser, []jsonrpc2.ConnOpt) {
+	if c.FlushWarningThreshold <= 0 {
+		return stdout, nil
+	}
+
+	tracker := &flushTracker{sent: make(map[jsonrpc2.ID]time.Time)}
+	onSend := jsonrpc2.OnSend(func(req *jsonrpc2.Request, resp *jsonrpc2.Response) {
+		if req == nil || req.Notif {
+			return
+		}
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		tracker.sent[req.ID] = time.Now()
+	})
+	onRecv := jsonrpc2.OnRecv(func(req *jsonrpc2.Request, resp *jsonrpc2.Response) {
+		if resp == nil {
+			return
+		}
+		tracker.mu.Lock()
+		sentAt, ok := tracker.sent[resp.ID]
+		delete(tracker.sent, resp.ID)
+		stall := tracker.lastStall
+		tracker.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		latency := time.Since(sentAt)
+		if latency < c.FlushWarningThreshold || stall <= latency/2 {
+			return
+		}
+		c.stats.flushStalled()
+
+		method := "(unknown method)"
+		if req != nil {
+			method = req.Method
+		}
+		msg := fmt.Sprintf(
+			"Response to %s took %s, %s of which was spent waiting for the deno script %s to flush stdout",
+			method, latency.Round(time.Millisecond), stall.Round(time.Millisecond), c.scriptPath,
+		)
+		if isTestContext() {
+			log.Printf("[WARN] %s", msg)
+		} else {
+			tflog.Warn(ctx, msg)
+		}
+	})
+
+	return &flushTrackingReader{stdout, tracker}, []jsonrpc2.ConnOpt{onSend, onRecv}
+}
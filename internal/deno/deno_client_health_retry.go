@@ -0,0 +1,56 @@
+package deno
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrNotHealthy is returned by Start when the script answered the health check with
+// {"ok": false} and the HealthRetryPolicy says not to retry it.
+var ErrNotHealthy = errors.New("script reported it is not healthy")
+
+// HealthRetryPolicy controls how Start retries the health check while waiting for the script to
+// become ready, eg: for a script that finishes an async init some time after the process started.
+// Without a policy, health is called every 250ms until StartupTimeout. StartupTimeout bounds
+// the wait under any policy.
+type HealthRetryPolicy struct {
+	// MaxAttempts bounds how many times health is called, zero means until StartupTimeout
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff is the delay before the second attempt, doubled after each attempt up to MaxBackoff.
+	// Zero uses the default 250ms poll interval, without doubling.
+	Backoff time.Duration `json:"backoff"`
+	// MaxBackoff caps the delay between attempts, zero leaves it uncapped
+	MaxBackoff time.Duration `json:"maxBackoff"`
+	// FailOnNotOk fails Start as soon as the script answers {"ok": false}, rather than retrying.
+	// Errors calling health, eg: because the script is not listening yet, are still retried.
+	FailOnNotOk bool `json:"failOnNotOk"`
+}
+
+// WithHealthRetryPolicy sets how Start retries the health check, see HealthRetryPolicy.
+func WithHealthRetryPolicy(policy HealthRetryPolicy) DenoClientOption {
+	return func(c *DenoClient) {
+		c.HealthRetryPolicy = &policy
+	}
+}
+
+// delay returns how long to wait after the given attempt, counted from 1, before the next one.
+func (p *HealthRetryPolicy) delay(attempt int) time.Duration {
+	if p == nil || p.Backoff <= 0 {
+		return healthPollInterval
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = math.MaxInt64 / 2
+	}
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// exhausted returns true once the given attempt, counted from 1, was the last one allowed.
+func (p *HealthRetryPolicy) exhausted(attempt int) bool {
+	return p != nil && p.MaxAttempts > 0 && attempt >= p.MaxAttempts
+}
@@ -0,0 +1,115 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// DefaultHeartbeatTimeout is how long a heartbeat ping may take by default before the Deno process is deemed wedged.
+const DefaultHeartbeatTimeout = 10 * time.Second
+
+// WithHeartbeat pings the Deno process every interval, killing it when a ping is not answered within
+// timeout, see HeartbeatInterval. A timeout of zero uses DefaultHeartbeatTimeout.
+func WithHeartbeat(interval, timeout time.Duration) DenoClientOption {
+	return func(c *DenoClient) {
+		c.HeartbeatInterval = interval
+		c.HeartbeatTimeout = timeout
+	}
+}
+
+// Ping checks that the Deno process is responsive, returning the round trip time of a ping request,
+// see jsocket.JSocket.Ping. It is safe to call concurrently with other calls.
+func (c *DenoClient) Ping(ctx context.Context) (time.Duration, error) {
+	if owner := c.sharedOwner(); owner != nil {
+		return owner.Ping(ctx)
+	}
+
+	c.startMu.Lock()
+	socket, running := c.Socket, c.running
+	c.startMu.Unlock()
+	if !running || socket == nil {
+		return 0, fmt.Errorf("failed to ping deno script %s: the process has not been started", c.scriptPath)
+	}
+	return socket.Ping(ctx)
+}
+
+// startHeartbeat starts pinging the Deno process in the background, unless HeartbeatInterval is zero or
+// it already is. Must be called with startMu held.
+func (c *DenoClient) startHeartbeat() {
+	if c.HeartbeatInterval <= 0 || c.heartbeatCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.ctx))
+	c.heartbeatCancel = cancel
+	c.heartbeatWG.Add(1)
+	go func() {
+		defer c.heartbeatWG.Done()
+		ticker := time.NewTicker(c.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.checkHeartbeat(ctx)
+			}
+		}
+	}()
+}
+
+// stopHeartbeat stops pinging the Deno process, waiting for an outstanding ping to be abandoned.
+func (c *DenoClient) stopHeartbeat() {
+	c.startMu.Lock()
+	cancel := c.heartbeatCancel
+	c.heartbeatCancel = nil
+	c.startMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	c.heartbeatWG.Wait()
+}
+
+// checkHeartbeat pings the current Deno process, killing it if the ping is not answered within HeartbeatTimeout.
+// A process that is alive but does not answer, eg: because its event loop is blocked, would otherwise hang every
+// call until it times out. Once killed, the next call restarts it as if it had crashed, see RestartOnCrash.
+// Any other failure, eg: the process already exited, is left to the crash supervisor.
+func (c *DenoClient) checkHeartbeat(ctx context.Context) {
+	c.startMu.Lock()
+	socket, process, exit, running := c.Socket, c.process, c.exit, c.running
+	c.startMu.Unlock()
+	if !running || socket == nil || exit == nil {
+		return
+	}
+
+	timeout := c.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = DefaultHeartbeatTimeout
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := socket.Ping(pingCtx)
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return
+	}
+	select {
+	case <-exit.done:
+		return
+	default:
+	}
+
+	msg := fmt.Sprintf("Deno process %s did not answer a ping within %s, its event loop is probably blocked, killing it", c.scriptPath, timeout)
+	if isTestContext() {
+		log.Printf("[WARN] %s", msg)
+	} else {
+		tflog.Warn(ctx, msg)
+	}
+	_ = process.Process.Kill()
+}
@@ -0,0 +1,24 @@
+package deno
+
+import (
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// stringIDPrefix prefixes the counter of string request ids, eg: "denobridge-1".
+const stringIDPrefix = "denobridge-"
+
+// WithStringIDs sends JSON-RPC requests with string ids instead of integers, for
+// script runtimes or JSON-RPC libraries that only accept string ids.
+func WithStringIDs() DenoClientOption {
+	return func(c *DenoClient) {
+		c.StringIDs = true
+	}
+}
+
+// newStringID returns the id of the next outgoing JSON-RPC request when StringIDs is set.
+// Responses are correlated by id whatever its type, so only the ids of outgoing requests need choosing here.
+func (c *DenoClient) newStringID() jsonrpc2.ID {
+	return jsonrpc2.ID{Str: fmt.Sprintf("%s%d", stringIDPrefix, c.nextID.Add(1)), IsString: true}
+}
@@ -0,0 +1,98 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLockfileMismatch is returned by Start when Deno refused to run the script because
+// its dependencies do not match the lockfile, or the lockfile is out of date and frozen.
+var ErrLockfileMismatch = errors.New("deno lockfile integrity check failed")
+
+// moduleNotFoundMarker is the fragment of the error Deno prints to stderr when it can not load a module, eg: a 404.
+const moduleNotFoundMarker = "Module not found"
+
+// lockfileMismatchMarkers are fragments of the errors Deno prints to stderr when a lockfile check fails.
+var lockfileMismatchMarkers = []string{
+	"Integrity check failed",
+	"does not match the expected hash in the lock file",
+	"The lockfile is out of date",
+}
+
+// WithLockFile sets the lockfile passed to Deno via --lock.
+// When frozen is true, --frozen is passed too so Deno errors rather than updating the lockfile.
+func WithLockFile(path string, frozen bool) DenoClientOption {
+	return func(c *DenoClient) {
+		c.LockFile = path
+		c.FrozenLockfile = frozen
+	}
+}
+
+// lockFile returns the lockfile to pass to Deno. When no LockFile is set,
+// a deno.lock next to the config file is used if there is one.
+func (c *DenoClient) lockFile(configPath string) string {
+	if c.LockFile != "" || configPath == "" || configPath == "/dev/null" {
+		return c.LockFile
+	}
+	candidate := filepath.Join(filepath.Dir(configPath), "deno.lock")
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate
+	}
+	return ""
+}
+
+// explainStartupFailure inspects the stderr of a Deno process that died while starting up, returning
+// ErrLockfileMismatch instead of the generic error when a lockfile check failed, or ErrScriptNotFound
+// when its script does not exist, see scriptNotFound. Otherwise the generic error is returned with the tail of stderr, which usually explains it.
+func (c *DenoClient) explainStartupFailure(err error) error {
+	if c.exit == nil {
+		return err
+	}
+	select {
+	case <-c.exit.done:
+	case <-time.After(crashDetectionGrace):
+		return c.exit.withStderrTail(err)
+	}
+
+	lines := c.exit.stderrLines()
+	for _, line := range lines {
+		for _, marker := range lockfileMismatchMarkers {
+			if strings.Contains(line, marker) {
+				return fmt.Errorf("%w for deno script %s:\n%s", ErrLockfileMismatch, c.scriptPath, strings.Join(lines, "\n"))
+			}
+		}
+	}
+	if err := c.scriptNotFound(lines); err != nil {
+		return err
+	}
+	return c.exit.withStderrTail(err)
+}
+
+// scriptNotFound returns an error wrapping ErrScriptNotFound when the script does not exist. A local script is
+// checked with stat, otherwise stderr is searched for Deno failing to load the script itself, rather than an import.
+// Returns nil when the script exists or it can not be told.
+func (c *DenoClient) scriptNotFound(stderr []string) error {
+	scriptArg, err := resolveScriptArg(c.scriptPath)
+	if err != nil {
+		return nil
+	}
+
+	if filepath.IsAbs(scriptArg) {
+		if _, err := os.Stat(scriptArg); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s: %w", ErrScriptNotFound, scriptArg, err)
+		}
+		return nil
+	}
+
+	for _, line := range stderr {
+		if strings.Contains(line, moduleNotFoundMarker) && strings.Contains(line, scriptArg) {
+			return fmt.Errorf("%w: %s:\n%s", ErrScriptNotFound, scriptArg, strings.Join(stderr, "\n"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Log levels understood by SetLogLevel, in order of increasing severity.
+const (
+	LogLevelTrace = "trace"
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+	LogLevelOff   = "off"
+)
+
+// logLevelRanks orders the log levels by severity.
+var logLevelRanks = map[string]int{
+	LogLevelTrace: 0,
+	LogLevelDebug: 1,
+	LogLevelInfo:  2,
+	LogLevelWarn:  3,
+	LogLevelError: 4,
+	LogLevelOff:   5,
+}
+
+// LogLevel returns the current log level, defaults to debug.
+func (c *DenoClient) LogLevel() string {
+	if level := c.logLevel.Load(); level != nil {
+		return *level
+	}
+	return LogLevelDebug
+}
+
+// SetLogLevel changes the log verbosity of a running Deno script without restarting it.
+//
+// The script is told about the new level via the setLogLevel method so it can adjust its
+// own logging, and the routing of the script's stderr is adjusted to match. Lines written
+// to stderr are logged at trace or debug level, and dropped for any less verbose level.
+// Scripts that do not implement setLogLevel only get the provider side adjustment.
+func (c *DenoClient) SetLogLevel(ctx context.Context, level string) error {
+	if _, ok := logLevelRanks[level]; !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+
+	if err := c.Call(ctx, "setLogLevel", map[string]any{"level": level}, nil); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc2.CodeMethodNotFound {
+			return fmt.Errorf("failed to call setLogLevel method over JSON-RPC: %w", err)
+		}
+	}
+
+	c.logLevel.Store(&level)
+	return nil
+}
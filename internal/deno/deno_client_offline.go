@@ -0,0 +1,61 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OfflineMode controls whether the Deno process may fetch remote modules over the network.
+type OfflineMode int
+
+const (
+	// OfflineModeNone lets Deno fetch remote modules as normal.
+	OfflineModeNone OfflineMode = iota
+	// OfflineModeCachedOnly passes --cached-only, remote modules must already be in the Deno cache.
+	OfflineModeCachedOnly
+	// OfflineModeNoRemote passes --no-remote, remote modules can not be used at all.
+	// This is incompatible with http(s):// script paths.
+	OfflineModeNoRemote
+)
+
+// String returns the name of the offline mode, as used in logs.
+func (m OfflineMode) String() string {
+	switch m {
+	case OfflineModeNone:
+		return "none"
+	case OfflineModeCachedOnly:
+		return "cachedOnly"
+	case OfflineModeNoRemote:
+		return "noRemote"
+	default:
+		return fmt.Sprintf("OfflineMode(%d)", int(m))
+	}
+}
+
+// ErrRemoteScriptOffline is returned by Start when OfflineModeNoRemote is set for a remote script.
+var ErrRemoteScriptOffline = errors.New("remote deno scripts can not be run with OfflineModeNoRemote")
+
+// WithOfflineMode stops the Deno process fetching remote modules, so air-gapped runs fail fast rather than hang.
+func WithOfflineMode(mode OfflineMode) DenoClientOption {
+	return func(c *DenoClient) {
+		c.OfflineMode = mode
+	}
+}
+
+// offlineModeArgs returns the arguments for the Deno command that enforce the given OfflineMode.
+func offlineModeArgs(mode OfflineMode, scriptArg string) ([]string, error) {
+	switch mode {
+	case OfflineModeNone:
+		return nil, nil
+	case OfflineModeCachedOnly:
+		return []string{"--cached-only"}, nil
+	case OfflineModeNoRemote:
+		if strings.HasPrefix(scriptArg, "http://") || strings.HasPrefix(scriptArg, "https://") {
+			return nil, fmt.Errorf("%w: %s", ErrRemoteScriptOffline, scriptArg)
+		}
+		return []string{"--no-remote"}, nil
+	default:
+		return nil, fmt.Errorf("unknown deno offline mode: %s", mode)
+	}
+}
@@ -0,0 +1,131 @@
+package deno
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// PermissionChangePolicy controls what happens when the permissions a running Deno process was
+// started with no longer match the permissions it would be started with now, eg: because the
+// PermissionResolver returns something else, or SetPermissions was called.
+type PermissionChangePolicy int
+
+const (
+	// PermissionChangePolicyIgnore keeps the running process, with its old permissions,
+	// until it is restarted for some other reason.
+	PermissionChangePolicyIgnore PermissionChangePolicy = iota
+	// PermissionChangePolicyRestart recycles the process before the next call when its permissions
+	// have changed. The PermissionResolver, if any, is called before every call to detect a change.
+	PermissionChangePolicyRestart
+)
+
+// WithPermissionChangePolicy sets what happens when the permissions of a running process change, see PermissionChangePolicy.
+func WithPermissionChangePolicy(policy PermissionChangePolicy) DenoClientOption {
+	return func(c *DenoClient) {
+		c.PermissionChangePolicy = policy
+	}
+}
+
+// SetPermissions replaces the static permissions given to NewDenoClient, eg: after the provider
+// configuration changed. A running process keeps its old permissions unless the
+// PermissionChangePolicy recycles it, or it is restarted for some other reason.
+func (c *DenoClient) SetPermissions(permissions *Permissions) {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	c.permissions = permissions
+}
+
+// resolvePermissions returns the permissions a process started now would be granted,
+// from the PermissionResolver if set, otherwise the given static permissions.
+func (c *DenoClient) resolvePermissions(ctx context.Context, static *Permissions) (*Permissions, error) {
+	if c.PermissionResolver == nil {
+		return static, nil
+	}
+	resolved, err := c.PermissionResolver(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve deno permissions: %w", err)
+	}
+	return resolved, nil
+}
+
+// grantedPermissions returns a copy of permissions with non-nil lists, as told to the script.
+func grantedPermissions(permissions *Permissions) Permissions {
+	granted := Permissions{Allow: []string{}, Deny: []string{}}
+	if permissions != nil {
+		granted.All = permissions.All
+		granted.Allow = append(granted.Allow, permissions.Allow...)
+		granted.Deny = append(granted.Deny, permissions.Deny...)
+	}
+	return granted
+}
+
+// permissionsHash returns a hash of the granted permissions that ignores the order of their lists.
+func permissionsHash(granted Permissions) string {
+	granted.Allow = slices.Sorted(slices.Values(granted.Allow))
+	granted.Deny = slices.Sorted(slices.Values(granted.Deny))
+	data, _ := json.Marshal(granted)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recyclePermissionChange restarts the running Deno process when the PermissionChangePolicy asks for it
+// and the permissions it would be started with now differ from the ones it is running with.
+func (c *DenoClient) recyclePermissionChange(ctx context.Context) error {
+	if c.PermissionChangePolicy != PermissionChangePolicyRestart {
+		return nil
+	}
+
+	c.startMu.Lock()
+	static, running, runningHash := c.permissions, c.running, c.permissionsHash
+	c.startMu.Unlock()
+	if !running {
+		return nil
+	}
+
+	permissions, err := c.resolvePermissions(ctx, static)
+	if err != nil {
+		return err
+	}
+	if permissionsHash(grantedPermissions(permissions)) == runningHash {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A concurrent call may have recycled the process already
+	c.startMu.Lock()
+	recycled := c.permissionsHash != runningHash
+	c.startMu.Unlock()
+	if recycled {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Restarting Deno process %s, its permissions have changed", c.scriptPath)
+	if isTestContext() {
+		log.Printf("[DEBUG] %s", msg)
+	} else {
+		tflog.Debug(ctx, msg)
+	}
+
+	// The standby was started with the old permissions too
+	c.stopStandby()
+
+	c.startMu.Lock()
+	c.running = false
+	c.startMu.Unlock()
+
+	c.kill()
+	if err := c.Start(c.ctx); err != nil {
+		return fmt.Errorf("failed to restart deno process after its permissions changed: %w", err)
+	}
+	c.stats.restarted()
+	return nil
+}
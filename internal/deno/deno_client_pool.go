@@ -0,0 +1,224 @@
+package deno
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ProcessPool shares long-lived Deno processes between clients with identical configurations,
+// so many operations against the same script pay for a single process start. Clients opt in
+// with WithProcessPool, clients sharing a process multiplex their calls over its connection.
+//
+// Clients share a process when their binary, script, config file, permissions, environment and
+// every other launch setting, see ClientConfig, are the same. Each started client holds a
+// reference to the process, which is stopped once the last client sharing it is stopped.
+//
+// Pooling is opt-in because the script sees every operation through the one process, so a
+// script that keeps state between calls must be able to tell its callers apart.
+type ProcessPool struct {
+	mu    sync.Mutex
+	procs map[string]*pooledProcess
+}
+
+// pooledProcess is a Deno process shared by the clients of a ProcessPool.
+type pooledProcess struct {
+	// key identifies the configuration the process was launched with
+	key string
+	// owner is the client that runs the process, all calls of its members go through it
+	owner *DenoClient
+	// members are the started clients sharing the process, guarded by the pool's mu
+	members []*DenoClient
+	// ready is closed once owner has started, successfully or not
+	ready chan struct{}
+	// err is the error owner failed to start with, only safe to read after ready is closed
+	err error
+}
+
+// NewProcessPool creates an empty ProcessPool.
+func NewProcessPool() *ProcessPool {
+	return &ProcessPool{procs: make(map[string]*pooledProcess)}
+}
+
+// WithProcessPool shares the Deno process of the client with other clients of the pool that
+// have the same configuration, see ProcessPool. A nil pool leaves the client with its own process.
+func WithProcessPool(pool *ProcessPool) DenoClientOption {
+	return func(c *DenoClient) {
+		c.Pool = pool
+	}
+}
+
+// Size returns the number of Deno processes the pool is running, or starting.
+func (p *ProcessPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.procs)
+}
+
+// poolKey identifies the process a client would launch, clients with the same key may share one.
+func poolKey(c *DenoClient) (string, error) {
+	config, err := json.Marshal(struct {
+		Config ClientConfig      `json:"config"`
+		Env    map[string]string `json:"env"`
+	}{c.Config(), c.Env})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the deno client config: %w", err)
+	}
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// acquire returns the process shared by clients configured like c, starting it if c is the first.
+// The process outlives the call that started it, so it is started without ctx's cancellation.
+func (p *ProcessPool) acquire(ctx context.Context, c *DenoClient) (*pooledProcess, error) {
+	key, err := poolKey(c)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	proc, ok := p.procs[key]
+	if !ok {
+		proc = &pooledProcess{key: key, ready: make(chan struct{})}
+		proc.owner = c.newStandby()
+		proc.owner.WarmStandby = c.WarmStandby
+		proc.owner.HeartbeatInterval = c.HeartbeatInterval
+		proc.owner.rpcMethods = p.serverMethods(proc)
+		p.procs[key] = proc
+	}
+	proc.members = append(proc.members, c)
+	p.mu.Unlock()
+
+	if !ok {
+		proc.err = proc.owner.Start(context.WithoutCancel(ctx))
+		if proc.err != nil {
+			// Let the next client retry rather than fail with the same error
+			p.mu.Lock()
+			if p.procs[key] == proc {
+				delete(p.procs, key)
+			}
+			p.mu.Unlock()
+		}
+		close(proc.ready)
+	}
+
+	select {
+	case <-proc.ready:
+	case <-ctx.Done():
+		_ = p.release(c, proc)
+		return nil, ctx.Err()
+	}
+	if proc.err != nil {
+		_ = p.release(c, proc)
+		return nil, proc.err
+	}
+	return proc, nil
+}
+
+// release drops the reference c holds to the process, stopping it when c was the last client sharing it.
+func (p *ProcessPool) release(c *DenoClient, proc *pooledProcess) error {
+	p.mu.Lock()
+	proc.members = slices.DeleteFunc(proc.members, func(member *DenoClient) bool { return member == c })
+	last := len(proc.members) == 0
+	if last && p.procs[proc.key] == proc {
+		delete(p.procs, proc.key)
+	}
+	p.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+	<-proc.ready
+	if proc.err != nil {
+		return nil
+	}
+	return proc.owner.Stop()
+}
+
+// serverMethods returns the server methods of the shared process, which deliver each call the script
+// makes to every member that handles it. The server methods of this package are notifications about a
+// resource, stream or operation, which members that do not know it drop. The result of the first member
+// is returned to the script.
+func (p *ProcessPool) serverMethods(proc *pooledProcess) func(ctx context.Context, conn *jsonrpc2.Conn) map[string]any {
+	return func(ctx context.Context, conn *jsonrpc2.Conn) map[string]any {
+		p.mu.Lock()
+		members := slices.Clone(proc.members)
+		p.mu.Unlock()
+
+		handlers := make(map[string][]reflect.Value)
+		for _, member := range members {
+			if member.rpcMethods == nil {
+				continue
+			}
+			for name, method := range member.rpcMethods(ctx, conn) {
+				handler := reflect.ValueOf(method)
+				// Only methods of the same signature can be called with the same arguments
+				if len(handlers[name]) > 0 && handlers[name][0].Type() != handler.Type() {
+					continue
+				}
+				handlers[name] = append(handlers[name], handler)
+			}
+		}
+
+		methods := make(map[string]any, len(handlers))
+		for name, fns := range handlers {
+			if len(fns) == 1 {
+				methods[name] = fns[0].Interface()
+				continue
+			}
+			methods[name] = reflect.MakeFunc(fns[0].Type(), func(args []reflect.Value) []reflect.Value {
+				results := fns[0].Call(args)
+				for _, fn := range fns[1:] {
+					fn.Call(args)
+				}
+				return results
+			}).Interface()
+		}
+		return methods
+	}
+}
+
+// startPooled starts c by joining the process shared by clients configured like it, see ProcessPool.
+// c adopts what the process reported when it started, so eg: HealthWarnings and PID work as usual,
+// but its calls go through the client that owns the process. Must be called with startMu held.
+func (c *DenoClient) startPooled(ctx context.Context) error {
+	proc, err := c.Pool.acquire(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	owner := proc.owner
+	owner.startMu.Lock()
+	c.ctx = ctx
+	c.process = owner.process
+	c.exit = owner.exit
+	c.denoVersion = owner.denoVersion
+	c.healthWarnings = owner.healthWarnings
+	c.effectivePermissions = owner.effectivePermissions
+	c.permissionsHash = owner.permissionsHash
+	c.features = owner.features
+	c.capabilities = owner.capabilities
+	c.resolvedDenoBinaryPath.Store(owner.resolvedDenoBinaryPath.Load())
+	owner.startMu.Unlock()
+
+	c.pooled = proc
+	c.running = true
+	return nil
+}
+
+// sharedOwner returns the client that owns the shared process c is a member of, or nil when c runs its own process.
+func (c *DenoClient) sharedOwner() *DenoClient {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.pooled == nil {
+		return nil
+	}
+	return c.pooled.owner
+}
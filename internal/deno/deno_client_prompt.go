@@ -0,0 +1,17 @@
+package deno
+
+// WithPermissionPrompts lets Deno prompt for permissions that were not granted, see PermissionPrompts.
+// Only useful when debugging locally with a TTY, under Terraform nothing can answer the prompt.
+func WithPermissionPrompts() DenoClientOption {
+	return func(c *DenoClient) {
+		c.PermissionPrompts = true
+	}
+}
+
+// silentStartupHint suggests why a Deno process that is still running never answered the health handshake.
+func (c *DenoClient) silentStartupHint() string {
+	if c.PermissionPrompts {
+		return "it may be blocked on a permission prompt, grant the permission or disable permission prompts"
+	}
+	return "a missing permission may be the cause, eg: the script caught the permission error and stalled"
+}
@@ -0,0 +1,27 @@
+package deno
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithReload passes --reload to Deno, busting its module cache so upstream changes to
+// remote modules are picked up. With no specifiers the whole cache is reloaded,
+// otherwise only the given specifiers are, eg: "https://deno.land/std".
+func WithReload(specifiers ...string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.Reload = true
+		c.ReloadSpecifiers = specifiers
+	}
+}
+
+// reloadArgs returns the --reload argument for the Deno command, if any.
+func reloadArgs(reload bool, specifiers []string) []string {
+	if !reload {
+		return nil
+	}
+	if len(specifiers) == 0 {
+		return []string{"--reload"}
+	}
+	return []string{fmt.Sprintf("--reload=%s", strings.Join(specifiers, ","))}
+}
@@ -2,9 +2,14 @@ package deno
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"sync"
+	"time"
 
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
@@ -14,8 +19,75 @@ import (
 type DenoClientResource struct {
 	// Client is the underlying Deno client used for JSON-RPC communication
 	Client *DenoClient
+	// DeleteMaxAttempts bounds how many times a busy delete is attempted before giving up
+	DeleteMaxAttempts int
+	// DeleteBackoff is the delay before retrying a busy delete, doubled after each attempt
+	// unless the script supplies a retryAfterMs hint
+	DeleteBackoff time.Duration
+	// StateCompressionThreshold, when non-zero, gzips any State blob returned by the script whose
+	// JSON encoding is at least this many bytes. This is transparent to the script, compressed
+	// blobs are always decompressed before being passed back to it.
+	StateCompressionThreshold int
+	// CreatePollInterval is how often createStatus is polled while a create is pending,
+	// unless the script supplies a pollIntervalMs hint
+	CreatePollInterval time.Duration
+	// MinCreatePollInterval and MaxCreatePollInterval bound any pollIntervalMs hint supplied by the script,
+	// zero leaves that end unbounded
+	MinCreatePollInterval time.Duration
+	MaxCreatePollInterval time.Duration
+	// OnCreateProgress, when set, is called with every progress update reported by a pending create
+	OnCreateProgress func(ctx context.Context, progress *CreateProgress)
+	// OnDeleteProgress, when set, is called with every progress update reported by a pending delete,
+	// updates are handled concurrently so they may arrive out of order
+	OnDeleteProgress func(ctx context.Context, progress *DeleteProgress)
+	// Timeouts bounds how long each CRUD operation may take, by default they are unbounded
+	Timeouts ResourceTimeouts
+	// MaxRetries is how many times a CRUD call that failed with a transient error is retried, none by default.
+	// An error is transient if its code is one of RetryCodes or the script set {"retryable": true} in its data.
+	MaxRetries int
+	// RetryCodes are the JSON-RPC error codes that are always retried, eg: for rate limits or connection resets
+	RetryCodes []int64
+	// RetryBackoff is the delay before the first retry, doubled after each attempt up to 30s and jittered
+	RetryBackoff time.Duration
+	// GenerateID, when true, has the provider generate the id of a new resource and pass it to create,
+	// so a create that is retried after a lost response can be recognised by the backend
+	GenerateID bool
+	// IDGenerator returns the ids used by GenerateID, for backends that constrain the format of ids,
+	// eg: prefixed, ULIDs or numeric ids. Defaults to random UUIDv4s.
+	IDGenerator func() string
+	// ExportBeforeDestroy, when true, has Delete first call exportState so the script can produce a backup of
+	// the resource, see ExportState. Terraform destroys a resource it replaces with Delete too, so this covers
+	// replacements. The delete is not attempted if the export fails, unless the script does not support it.
+	ExportBeforeDestroy bool
+	// OnStateExported, when set, is called with the backup exported by ExportBeforeDestroy so it can be stored,
+	// eg: in a file. Returning an error prevents the delete.
+	OnStateExported func(ctx context.Context, id string, snapshot []byte) error
+
+	// pendingDeletes are the deletes waiting for their deleteComplete notification, keyed by resource id
+	pendingDeletes   map[string]chan *DeleteCompleteRequest
+	pendingDeletesMu sync.Mutex
 }
 
+// CodeResourceBusy is the JSON-RPC error code a script returns from delete to signal that
+// the resource can not be deleted right now, eg: it still has dependents or an async backend
+// operation has not finished yet. Unlike any other error, a busy delete is retried.
+//
+// The error data may contain a retryAfterMs hint, eg: {"retryAfterMs": 5000}.
+const CodeResourceBusy int64 = -32001
+
+// ErrResourceBusy is returned by Delete when the resource was still busy after DeleteMaxAttempts.
+var ErrResourceBusy = errors.New("resource is busy")
+
+const (
+	defaultDeleteMaxAttempts = 5
+	defaultDeleteBackoff     = time.Second
+	maxDeleteBackoff         = 30 * time.Second
+
+	defaultCreatePollInterval    = 2 * time.Second
+	defaultMinCreatePollInterval = 250 * time.Millisecond
+	defaultMaxCreatePollInterval = time.Minute
+)
+
 // NewDenoClientResource creates a new DenoClientResource with the specified configuration.
 // It initializes a Deno runtime process with the given script and permissions.
 //
@@ -24,18 +96,27 @@ type DenoClientResource struct {
 //   - scriptPath: The path to the TypeScript/JavaScript resource script to execute
 //   - configPath: The path to the Deno configuration file (deno.json)
 //   - permissions: The Deno security permissions to grant the runtime
+//   - opts: Optional settings for the underlying DenoClient, eg: WithStartupTimeout
 //
 // Returns a configured DenoClientResource ready to manage resources.
-func NewDenoClientResource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions) *DenoClientResource {
-	return &DenoClientResource{
-		NewDenoClient(
-			denoBinaryPath,
-			scriptPath,
-			configPath,
-			permissions,
-			nil,
-		),
+func NewDenoClientResource(denoBinaryPath, scriptPath, configPath string, permissions *Permissions, opts ...DenoClientOption) *DenoClientResource {
+	c := &DenoClientResource{
+		DeleteMaxAttempts:     defaultDeleteMaxAttempts,
+		DeleteBackoff:         defaultDeleteBackoff,
+		CreatePollInterval:    defaultCreatePollInterval,
+		MinCreatePollInterval: defaultMinCreatePollInterval,
+		MaxCreatePollInterval: defaultMaxCreatePollInterval,
+		RetryBackoff:          defaultRetryBackoff,
 	}
+	c.Client = NewDenoClient(
+		denoBinaryPath,
+		scriptPath,
+		configPath,
+		permissions,
+		jsocket.TypedServerMethods(&DenoClientResourceServerMethods{c}),
+		append([]DenoClientOption{withContract(resourceContract)}, opts...)...,
+	)
+	return c
 }
 
 // CreateRequest represents the request payload for creating a Terraform resource.
@@ -45,6 +126,15 @@ type CreateRequest struct {
 	Props any `json:"props"`
 	// WriteOnlyProps contains any write-only properties that should be passed to the Deno script but not stored in state
 	WriteOnlyProps any `json:"writeOnlyProps,omitempty"`
+	// ID is the provider generated id the script should give the new resource, set when GenerateID is true
+	ID string `json:"id,omitempty"`
+	// IdempotencyKey identifies the logical create, it is the same for every retry of it. A script that sees
+	// a key again, eg: because a create it completed is retried after the response was lost, should return
+	// the state of the object it already created rather than creating a duplicate. Generated by Create if empty.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// PendingAsync is set once the provider reserved a slot for the create, see DenoClient.MaxPendingAsync,
+	// so the script may answer pending. A script that is not told so should complete the create before answering.
+	PendingAsync bool `json:"pendingAsync,omitempty"`
 }
 
 // CreateResponse represents the response from creating a Terraform resource.
@@ -56,6 +146,15 @@ type CreateResponse struct {
 	State any `json:"state"`
 	// SensitiveState contains the resource's sensitive state data to be stored in Terraform state (marked as sensitive)
 	SensitiveState any `json:"sensitiveState"`
+	// Pending indicates the script started a long running create that has not finished yet,
+	// the provider polls createStatus with the ID until it completes
+	Pending bool `json:"pending,omitempty"`
+	// PollIntervalMs optionally tells the provider how often to poll createStatus while the create is pending,
+	// bounded by MinCreatePollInterval and MaxCreatePollInterval
+	PollIntervalMs int64 `json:"pollIntervalMs,omitempty"`
+	// StateChecksum optionally carries the script's checksum of State, see StateChecksum,
+	// the provider stores it and verifies later reads against it
+	StateChecksum string `json:"stateChecksum,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -73,18 +172,225 @@ type CreateResponse struct {
 // It sends the configuration properties to the Deno runtime and retrieves the resource ID and state.
 //
 // Parameters:
-//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - ctx: The context for the operation, used for cancellation and timeouts, further bounded by Timeouts.Create
 //   - params: The create request containing the resource configuration properties
 //
 // Returns the create response containing the resource ID and state, or an error if the JSON-RPC call fails.
+// When the script fails a create midway, after it already created part of the resource, it may attach what
+// it created to the error, see PartialCreateState. Create then returns that partial response together with
+// the error, so the caller can persist it rather than orphaning whatever was created. Likewise, a pending
+// create that fails or is given up on is returned with its id.
 func (c *DenoClientResource) Create(ctx context.Context, params *CreateRequest) (*CreateResponse, error) {
+	return withOperationTimeout(ctx, "create", c.Timeouts.Create, func(ctx context.Context) (*CreateResponse, error) {
+		return c.create(ctx, params)
+	})
+}
+
+// create is Create without its timeout.
+func (c *DenoClientResource) create(ctx context.Context, params *CreateRequest) (*CreateResponse, error) {
+	if c.GenerateID && params.ID == "" {
+		id, err := c.generateID()
+		if err != nil {
+			return nil, err
+		}
+		params.ID = id
+	}
+	// Generated once, so every retry of this create, see call, sends the same key
+	if params.IdempotencyKey == "" {
+		key, err := newUUIDv4()
+		if err != nil {
+			return nil, err
+		}
+		params.IdempotencyKey = key
+	}
+
+	// Reserved before the script starts any backend work, so MaxPendingAsync bounds how much of it is pending
+	done, err := c.Client.beginAsync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	params.PendingAsync = true
+
 	var response *CreateResponse
-	if err := c.Client.Socket.Call(ctx, "create", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call create method over JSON-RPC: %v", err)
+	if err := c.call(ctx, "create", params, &response); err != nil {
+		err = fmt.Errorf("failed to call create method over JSON-RPC: %w", err)
+		partial := partialCreateState(err)
+		if partial == nil {
+			return nil, err
+		}
+		state, compressErr := compressState(partial.State, c.StateCompressionThreshold)
+		if compressErr != nil {
+			return nil, errors.Join(err, compressErr)
+		}
+		partial.State = state
+		return partial, err
+	}
+	if response != nil && response.Pending {
+		var waitErr error
+		response, waitErr = c.waitForCreate(ctx, response.ID, response.PollIntervalMs)
+		if waitErr != nil {
+			// The resource exists in the backend, so its id is returned as partial state rather than orphaning it
+			state, err := compressState(response.State, c.StateCompressionThreshold)
+			if err != nil {
+				return nil, errors.Join(waitErr, err)
+			}
+			response.State = state
+			return response, waitErr
+		}
+	}
+	if response != nil {
+		state, err := compressState(response.State, c.StateCompressionThreshold)
+		if err != nil {
+			return nil, err
+		}
+		response.State = state
 	}
 	return response, nil
 }
 
+// PartialCreateState is the data of an error a script fails a create with after it already created part
+// of the resource, eg: some of its sub-resources, describing what was created so it is not lost. The
+// error may have any code, its data must hold {"partialState": {"id": "...", "state": {...}}}.
+type PartialCreateState struct {
+	// PartialState is the id and state of what was created before the create failed, at least ID must be set
+	PartialState *CreateResponse `json:"partialState"`
+}
+
+// partialCreateState returns the partial id and state the script attached to the error it failed a create
+// with, see PartialCreateState, or nil if there is none.
+func partialCreateState(err error) *CreateResponse {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Data == nil {
+		return nil
+	}
+
+	var data PartialCreateState
+	if json.Unmarshal(*rpcErr.Data, &data) != nil || data.PartialState == nil || data.PartialState.ID == "" {
+		return nil
+	}
+	return &CreateResponse{
+		ID:             data.PartialState.ID,
+		State:          data.PartialState.State,
+		SensitiveState: data.PartialState.SensitiveState,
+	}
+}
+
+// Statuses reported by createStatus.
+const (
+	CreateStatusPending  = "pending"
+	CreateStatusComplete = "complete"
+	CreateStatusFailed   = "failed"
+)
+
+// CreateStatusRequest represents the request payload for polling a pending create.
+type CreateStatusRequest struct {
+	// ID is the identifier returned by the pending create
+	ID string `json:"id"`
+}
+
+// CreateProgress describes how far along a pending create is.
+type CreateProgress struct {
+	// Message is a human readable description of the current step
+	Message string `json:"message"`
+	// Percent optionally reports completion between 0 and 100
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+// String formats the progress for display, eg: "creating... 40%".
+func (p *CreateProgress) String() string {
+	if p.Percent == nil {
+		return p.Message
+	}
+	return fmt.Sprintf("%s %.0f%%", p.Message, *p.Percent)
+}
+
+// CreateStatusResponse represents the response from polling a pending create.
+type CreateStatusResponse struct {
+	// Status is one of CreateStatusPending, CreateStatusComplete or CreateStatusFailed
+	Status string `json:"status"`
+	// Progress optionally reports how far along a pending create is
+	Progress *CreateProgress `json:"progress,omitempty"`
+	// PollIntervalMs optionally changes how often createStatus is polled from now on, see CreateResponse
+	PollIntervalMs int64 `json:"pollIntervalMs,omitempty"`
+	// ID optionally replaces the identifier returned by the pending create, once complete
+	ID string `json:"id,omitempty"`
+	// State contains the resource's state data, once complete
+	State any `json:"state"`
+	// SensitiveState contains the resource's sensitive state data, once complete
+	SensitiveState any `json:"sensitiveState"`
+	// Error describes why the create failed
+	Error string `json:"error,omitempty"`
+}
+
+// waitForCreate polls createStatus until the pending create with the given ID completes or fails,
+// every CreatePollInterval or as often as the script asks with pollIntervalMs. The overall deadline
+// is taken from ctx.
+//
+// The pending create already exists in the backend, so when waiting fails its ID is returned along
+// with the error as partial state, see PartialCreateState, or whatever partial state the script
+// attached to a failed createStatus call.
+func (c *DenoClientResource) waitForCreate(ctx context.Context, id string, pollIntervalMs int64) (*CreateResponse, error) {
+	interval := c.createPollInterval(pollIntervalMs)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return &CreateResponse{ID: id}, fmt.Errorf("gave up waiting for pending create of resource %s: %w", id, ctx.Err())
+		case <-timer.C:
+		}
+
+		var status CreateStatusResponse
+		if err := c.call(ctx, "createStatus", &CreateStatusRequest{ID: id}, &status); err != nil {
+			err = fmt.Errorf("failed to call createStatus method over JSON-RPC: %w", err)
+			if partial := partialCreateState(err); partial != nil {
+				return partial, err
+			}
+			return &CreateResponse{ID: id}, err
+		}
+
+		if status.Progress != nil && c.OnCreateProgress != nil {
+			c.OnCreateProgress(ctx, status.Progress)
+		}
+
+		switch status.Status {
+		case CreateStatusPending:
+			if status.PollIntervalMs > 0 {
+				interval = c.createPollInterval(status.PollIntervalMs)
+			}
+			timer.Reset(interval)
+			continue
+		case CreateStatusComplete:
+			if status.ID != "" {
+				id = status.ID
+			}
+			return &CreateResponse{ID: id, State: status.State, SensitiveState: status.SensitiveState}, nil
+		case CreateStatusFailed:
+			return &CreateResponse{ID: id}, fmt.Errorf("pending create of resource %s failed: %s", id, status.Error)
+		default:
+			return &CreateResponse{ID: id}, fmt.Errorf("createStatus returned an unknown status %q for resource %s", status.Status, id)
+		}
+	}
+}
+
+// createPollInterval returns how long to wait between createStatus polls, honoring a pollIntervalMs
+// hint from the script within MinCreatePollInterval and MaxCreatePollInterval.
+func (c *DenoClientResource) createPollInterval(pollIntervalMs int64) time.Duration {
+	if pollIntervalMs <= 0 {
+		return c.CreatePollInterval
+	}
+	interval := time.Duration(min(pollIntervalMs, math.MaxInt64/int64(time.Millisecond))) * time.Millisecond
+	if c.MinCreatePollInterval > 0 {
+		interval = max(interval, c.MinCreatePollInterval)
+	}
+	if c.MaxCreatePollInterval > 0 {
+		interval = min(interval, c.MaxCreatePollInterval)
+	}
+	return interval
+}
+
 // CreateReadRequest represents the request payload for reading a Terraform resource.
 // It contains the resource ID and configuration properties.
 type CreateReadRequest struct {
@@ -92,6 +398,11 @@ type CreateReadRequest struct {
 	ID string `json:"id"`
 	// Props contains the resource configuration properties
 	Props any `json:"props"`
+	// RefreshOnly tells the script that it must not make any changes, only report the current state
+	RefreshOnly bool `json:"refreshOnly"`
+	// StateChecksum is the checksum stored from the last create or update, when set
+	// the state returned by read is verified against it, it is never sent to the script
+	StateChecksum string `json:"-"`
 }
 
 // CreateReadResponse represents the response from reading a Terraform resource.
@@ -122,14 +433,33 @@ type CreateReadResponse struct {
 // It retrieves the current state of the resource from the external system.
 //
 // Parameters:
-//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - ctx: The context for the operation, used for cancellation and timeouts, further bounded by Timeouts.Read
 //   - params: The read request containing the resource ID and configuration properties
 //
 // Returns the read response with updated properties and state, or an error if the JSON-RPC call fails.
 func (c *DenoClientResource) Read(ctx context.Context, params *CreateReadRequest) (*CreateReadResponse, error) {
+	return withOperationTimeout(ctx, "read", c.Timeouts.Read, func(ctx context.Context) (*CreateReadResponse, error) {
+		return c.read(ctx, params)
+	})
+}
+
+// read is Read without its timeout.
+func (c *DenoClientResource) read(ctx context.Context, params *CreateReadRequest) (*CreateReadResponse, error) {
 	var response *CreateReadResponse
-	if err := c.Client.Socket.Call(ctx, "read", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call read method over JSON-RPC: %v", err)
+	if err := c.call(ctx, "read", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to call read method over JSON-RPC: %w", err)
+	}
+	if response != nil && response.State != nil && params.StateChecksum != "" {
+		if err := response.verifyStateChecksum(params.ID, params.StateChecksum); err != nil {
+			return nil, err
+		}
+	}
+	if response != nil && response.State != nil {
+		state, err := compressState(*response.State, c.StateCompressionThreshold)
+		if err != nil {
+			return nil, err
+		}
+		response.State = &state
 	}
 	return response, nil
 }
@@ -158,6 +488,8 @@ type UpdateResponse struct {
 	State *any `json:"state"`
 	// SensitiveState contains the updated resource sensitive state data after the update operation
 	SensitiveState *any `json:"sensitiveState"`
+	// StateChecksum optionally carries the script's checksum of State, see StateChecksum
+	StateChecksum string `json:"stateChecksum,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -175,14 +507,35 @@ type UpdateResponse struct {
 // It sends the desired configuration to the Deno runtime to modify the external resource.
 //
 // Parameters:
-//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - ctx: The context for the operation, used for cancellation and timeouts, further bounded by Timeouts.Update
 //   - params: The update request containing the resource ID, next properties, and current state
 //
 // Returns the update response with the new resource state, or an error if the JSON-RPC call fails.
 func (c *DenoClientResource) Update(ctx context.Context, params *UpdateRequest) (*UpdateResponse, error) {
+	return withOperationTimeout(ctx, "update", c.Timeouts.Update, func(ctx context.Context) (*UpdateResponse, error) {
+		return c.update(ctx, params)
+	})
+}
+
+// update is Update without its timeout.
+func (c *DenoClientResource) update(ctx context.Context, params *UpdateRequest) (*UpdateResponse, error) {
+	currentState, err := decompressState(params.CurrentState)
+	if err != nil {
+		return nil, err
+	}
+	request := *params
+	request.CurrentState = currentState
+
 	var response *UpdateResponse
-	if err := c.Client.Socket.Call(ctx, "update", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call update method over JSON-RPC: %v", err)
+	if err := c.call(ctx, "update", &request, &response); err != nil {
+		return nil, fmt.Errorf("failed to call update method over JSON-RPC: %w", err)
+	}
+	if response != nil && response.State != nil {
+		state, err := compressState(*response.State, c.StateCompressionThreshold)
+		if err != nil {
+			return nil, err
+		}
+		response.State = &state
 	}
 	return response, nil
 }
@@ -198,6 +551,9 @@ type DeleteRequest struct {
 	State any `json:"state"`
 	// SensitiveState contains the resource sensitive state data
 	SensitiveState any `json:"sensitiveState"`
+	// PendingAsync is set once the provider reserved a slot for the delete, see DenoClient.MaxPendingAsync,
+	// so the script may answer pending. A script that is not told so should complete the delete before answering.
+	PendingAsync bool `json:"pendingAsync,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -216,6 +572,9 @@ type DeleteRequest struct {
 type DeleteResponse struct {
 	// Done indicates whether the delete operation completed successfully
 	Done bool `json:"done"`
+	// Pending indicates the script started a long running delete that has not finished yet,
+	// the provider waits for its deleteComplete notification
+	Pending bool `json:"pending,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -233,16 +592,214 @@ type DeleteResponse struct {
 // It sends the resource information to the Deno runtime to remove the external resource.
 //
 // Parameters:
-//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - ctx: The context for the operation, used for cancellation and timeouts, further bounded by Timeouts.Delete
 //   - params: The delete request containing the resource ID, properties, and state
 //
+// If the script signals that the resource is busy (see CodeResourceBusy) the delete is retried
+// with backoff, honoring any retryAfterMs hint up to 30s, up to DeleteMaxAttempts. Any other error is
+// considered permanent and returned immediately.
+//
+// If the script answers that the delete is pending, Delete reports any deleteProgress
+// notifications to OnDeleteProgress and waits for the deleteComplete notification.
+//
+// When ExportBeforeDestroy is set, a backup of the resource is exported before it is deleted.
+//
 // Returns an error if the JSON-RPC call fails or the delete operation is not complete.
 func (c *DenoClientResource) Delete(ctx context.Context, params *DeleteRequest) (*DeleteResponse, error) {
-	var response *DeleteResponse
-	if err := c.Client.Socket.Call(ctx, "delete", params, &response); err != nil {
-		return nil, fmt.Errorf("failed to call delete method over JSON-RPC: %v", err)
+	return withOperationTimeout(ctx, "delete", c.Timeouts.Delete, func(ctx context.Context) (*DeleteResponse, error) {
+		return c.delete(ctx, params)
+	})
+}
+
+// delete is Delete without its timeout.
+func (c *DenoClientResource) delete(ctx context.Context, params *DeleteRequest) (*DeleteResponse, error) {
+	if err := c.exportBeforeDestroy(ctx, params.ID); err != nil {
+		return nil, err
+	}
+	return c.deleteExported(ctx, params)
+}
+
+// deleteExported is delete once any backup has been exported.
+func (c *DenoClientResource) deleteExported(ctx context.Context, params *DeleteRequest) (*DeleteResponse, error) {
+	state, err := decompressState(params.State)
+	if err != nil {
+		return nil, err
+	}
+	request := *params
+	request.State = state
+
+	// Reserved before the script starts any backend work, so MaxPendingAsync bounds how much of it is pending
+	done, err := c.Client.beginAsync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	request.PendingAsync = true
+
+	// The script may complete a pending delete before its response has been handled
+	complete := c.expectDeleteComplete(params.ID)
+	defer c.forgetDeleteComplete(params.ID)
+
+	backoff := c.DeleteBackoff
+	for attempt := 1; ; attempt++ {
+		var response *DeleteResponse
+		err := c.call(ctx, "delete", &request, &response)
+		if err == nil {
+			if response != nil && response.Pending {
+				return c.waitForDelete(ctx, params.ID, complete)
+			}
+			return response, nil
+		}
+
+		retryAfter, busy := resourceBusy(err)
+		if !busy {
+			return nil, fmt.Errorf("failed to call delete method over JSON-RPC: %w", err)
+		}
+		if attempt >= c.DeleteMaxAttempts {
+			return nil, fmt.Errorf("%w: gave up deleting after %d attempts: %v", ErrResourceBusy, attempt, err)
+		}
+
+		delay := deleteRetryDelay(backoff, retryAfter)
+		backoff = min(backoff*2, maxDeleteBackoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrResourceBusy, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// deleteRetryDelay returns how long to wait before retrying a busy delete, honoring a retryAfter
+// hint from the script, which is capped at maxDeleteBackoff so a script can not stall the delete.
+func deleteRetryDelay(backoff, retryAfter time.Duration) time.Duration {
+	if retryAfter <= 0 {
+		return backoff
+	}
+	return min(retryAfter, maxDeleteBackoff)
+}
+
+// resourceBusy checks if err is a CodeResourceBusy error, returning any retryAfterMs hint.
+func resourceBusy(err error) (time.Duration, bool) {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeResourceBusy {
+		return 0, false
+	}
+
+	var data struct {
+		RetryAfterMs int64 `json:"retryAfterMs"`
+	}
+	if rpcErr.Data != nil {
+		_ = json.Unmarshal(*rpcErr.Data, &data)
+	}
+
+	return time.Duration(min(data.RetryAfterMs, math.MaxInt64/int64(time.Millisecond))) * time.Millisecond, true
+}
+
+// DeleteProgress describes how far along a pending delete is, sent by the script
+// as a deleteProgress notification.
+type DeleteProgress struct {
+	// ID is the identifier of the resource being deleted
+	ID string `json:"id"`
+	// Message is a human readable description of the current step
+	Message string `json:"message"`
+	// Percent optionally reports completion between 0 and 100
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+// String formats the progress for display, eg: "draining... 40%".
+func (p *DeleteProgress) String() string {
+	if p.Percent == nil {
+		return p.Message
+	}
+	return fmt.Sprintf("%s %.0f%%", p.Message, *p.Percent)
+}
+
+// DeleteCompleteRequest is sent by the script as a deleteComplete notification
+// once a pending delete has finished, successfully or not.
+type DeleteCompleteRequest struct {
+	// ID is the identifier of the resource being deleted
+	ID string `json:"id"`
+	// Done indicates whether the delete operation completed successfully
+	Done bool `json:"done"`
+	// Error describes why the delete failed
+	Error string `json:"error,omitempty"`
+	// Diagnostics contains any warnings or errors to display to the user
+	Diagnostics *[]struct {
+		// Severity indicates the diagnostic level ("error" or "warning")
+		Severity string `json:"severity"`
+		// Summary is a short description of the diagnostic
+		Summary string `json:"summary"`
+		// Detail provides additional context about the diagnostic
+		Detail string `json:"detail"`
+		// PropPath optionally specifies which property the diagnostic relates to
+		PropPath *[]string `json:"propPath,omitempty"`
+	} `json:"diagnostics,omitempty"`
+}
+
+// expectDeleteComplete registers interest in the deleteComplete notification for the given resource id.
+func (c *DenoClientResource) expectDeleteComplete(id string) <-chan *DeleteCompleteRequest {
+	c.pendingDeletesMu.Lock()
+	defer c.pendingDeletesMu.Unlock()
+	if c.pendingDeletes == nil {
+		c.pendingDeletes = map[string]chan *DeleteCompleteRequest{}
+	}
+	complete := make(chan *DeleteCompleteRequest, 1)
+	c.pendingDeletes[id] = complete
+	return complete
+}
+
+// forgetDeleteComplete removes the registration made by expectDeleteComplete.
+func (c *DenoClientResource) forgetDeleteComplete(id string) {
+	c.pendingDeletesMu.Lock()
+	defer c.pendingDeletesMu.Unlock()
+	delete(c.pendingDeletes, id)
+}
+
+// waitForDelete waits for the deleteComplete notification of the pending delete of the
+// given resource. The overall deadline is taken from ctx. The caller holds the async slot
+// of the delete, see DenoClient.MaxPendingAsync.
+func (c *DenoClientResource) waitForDelete(ctx context.Context, id string, complete <-chan *DeleteCompleteRequest) (*DeleteResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting for pending delete of resource %s: %w", id, ctx.Err())
+	case <-c.Client.Done():
+		return nil, fmt.Errorf("deno process exited before the pending delete of resource %s completed", id)
+	case result := <-complete:
+		if result.Error != "" {
+			return nil, fmt.Errorf("pending delete of resource %s failed: %s", id, result.Error)
+		}
+		return &DeleteResponse{Done: result.Done, Diagnostics: result.Diagnostics}, nil
+	}
+}
+
+// DenoClientResourceServerMethods implements the server-side JSON-RPC methods that
+// the Deno runtime can call back to the provider. It handles the notifications sent
+// while a delete is pending.
+type DenoClientResourceServerMethods struct {
+	// resource is the client whose pending deletes are reported on
+	resource *DenoClientResource
+}
+
+// DeleteProgress handles progress updates from the Deno runtime during a pending delete,
+// forwarding them to OnDeleteProgress.
+func (c *DenoClientResourceServerMethods) DeleteProgress(ctx context.Context, params *DeleteProgress) {
+	if c.resource.OnDeleteProgress != nil {
+		c.resource.OnDeleteProgress(ctx, params)
+	}
+}
+
+// DeleteComplete handles the completion of a pending delete, waking the Delete waiting for it.
+// Completions of deletes nobody is waiting for, eg: because Delete gave up, are dropped.
+func (c *DenoClientResourceServerMethods) DeleteComplete(ctx context.Context, params *DeleteCompleteRequest) {
+	c.resource.pendingDeletesMu.Lock()
+	defer c.resource.pendingDeletesMu.Unlock()
+	if complete, ok := c.resource.pendingDeletes[params.ID]; ok {
+		select {
+		case complete <- params:
+		default:
+		}
 	}
-	return response, nil
 }
 
 // ModifyPlanRequest represents the request payload for modifying a Terraform plan.
@@ -271,6 +828,11 @@ type ModifyPlanResponse struct {
 	ModifiedProps *any `json:"modifiedProps,omitempty"`
 	// RequiresReplacement indicates that the resource must be replaced (destroy and recreate)
 	RequiresReplacement *bool `json:"requiresReplacement,omitempty"`
+	// ReplaceTriggers lists the dot separated paths of the props whose change forces replacement,
+	// eg: ["region", "network.cidr"], scoping the replacement to those attributes
+	ReplaceTriggers *[]string `json:"replaceTriggers,omitempty"`
+	// Explanation optionally describes why the plan looks the way it does, shown to the user as a note
+	Explanation *string `json:"explanation,omitempty"`
 	// Diagnostics contains any warnings or errors to display to the user
 	Diagnostics *[]struct {
 		// Severity indicates the diagnostic level ("error" or "warning")
@@ -295,8 +857,19 @@ type ModifyPlanResponse struct {
 // Returns the modify plan response with plan customizations, or nil if the method is not implemented.
 // Returns an error if the JSON-RPC call fails.
 func (c *DenoClientResource) ModifyPlan(ctx context.Context, params *ModifyPlanRequest) (*ModifyPlanResponse, error) {
+	if !c.Client.HasCapability("modifyPlan") {
+		return nil, nil
+	}
+
+	currentState, err := decompressState(params.CurrentState)
+	if err != nil {
+		return nil, err
+	}
+	request := *params
+	request.CurrentState = currentState
+
 	var response *ModifyPlanResponse
-	if err := c.Client.Socket.Call(ctx, "modifyPlan", params, &response); err != nil {
+	if err := c.Client.Call(ctx, "modifyPlan", &request, &response); err != nil {
 
 		// ModifyPlan method is optional - return nil if not implemented
 		var rpcErr *jsonrpc2.Error
@@ -304,7 +877,7 @@ func (c *DenoClientResource) ModifyPlan(ctx context.Context, params *ModifyPlanR
 			return nil, nil
 		}
 
-		return nil, fmt.Errorf("failed to call modifyPlan method over JSON-RPC: %v", err)
+		return nil, fmt.Errorf("failed to call modifyPlan method over JSON-RPC: %w", err)
 	}
 
 	return response, nil
@@ -0,0 +1,149 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DeleteResult is the outcome of deleting a single resource as part of a DeleteBatch.
+type DeleteResult struct {
+	// Response is the delete response, set when the delete succeeded
+	Response *DeleteResponse
+	// Err is why the delete failed, set when it did
+	Err error
+}
+
+// DeleteBatchRequest represents the request payload for deleting many resources at once.
+type DeleteBatchRequest struct {
+	// Items are the resources to delete
+	Items []*DeleteRequest `json:"items"`
+}
+
+// DeleteBatchResponse represents the response from deleting many resources at once.
+// It contains a result per item, in the same order as the request.
+type DeleteBatchResponse struct {
+	// Results are the outcomes of each delete
+	Results []struct {
+		DeleteResponse
+		// Error is why the delete of this item failed, if it did
+		Error *jsonrpc2.Error `json:"error,omitempty"`
+	} `json:"results"`
+}
+
+// DeleteBatch deletes many resources managed by the same script with a single "deleteBatch" JSON-RPC call,
+// which is much faster than a delete call per resource for large destroys. Each item succeeds or fails on
+// its own, so one bad delete does not fail the whole batch. Items that are pending are waited for, the
+// batch holding a single async slot for all of them. Items that were busy are retried individually,
+// just like Delete. Scripts that do not implement deleteBatch are sent a delete call per item instead.
+// When ExportBeforeDestroy is set, a backup of each item is exported first, items whose export failed
+// are not deleted and fail on their own.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - params: The delete requests of the resources to delete
+//
+// Returns a result per item, in the same order as params, or an error if the batch as a whole failed.
+func (c *DenoClientResource) DeleteBatch(ctx context.Context, params []*DeleteRequest) ([]DeleteResult, error) {
+	return withOperationTimeout(ctx, "delete", c.Timeouts.Delete, func(ctx context.Context) ([]DeleteResult, error) {
+		return c.deleteBatch(ctx, params)
+	})
+}
+
+// deleteBatch is DeleteBatch without its timeout.
+func (c *DenoClientResource) deleteBatch(ctx context.Context, params []*DeleteRequest) ([]DeleteResult, error) {
+	results := make([]DeleteResult, len(params))
+
+	// Items whose backup could not be exported are not deleted, the rest are batched
+	var batched []int
+	for i, item := range params {
+		if err := c.exportBeforeDestroy(ctx, item.ID); err != nil {
+			results[i].Err = err
+			continue
+		}
+		batched = append(batched, i)
+	}
+	if len(batched) == 0 {
+		return results, nil
+	}
+
+	request := &DeleteBatchRequest{Items: make([]*DeleteRequest, len(batched))}
+	for j, i := range batched {
+		state, err := decompressState(params[i].State)
+		if err != nil {
+			return nil, err
+		}
+		decompressed := *params[i]
+		decompressed.State = state
+		request.Items[j] = &decompressed
+	}
+
+	// The batch is a single operation, so it reserves a single async slot for all of its pending items
+	done, err := c.Client.beginAsync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	complete := make([]<-chan *DeleteCompleteRequest, len(batched))
+	for j, i := range batched {
+		request.Items[j].PendingAsync = true
+		// The script may complete a pending delete before the batch response has been handled
+		complete[j] = c.expectDeleteComplete(params[i].ID)
+	}
+	forget := func() {
+		for _, i := range batched {
+			c.forgetDeleteComplete(params[i].ID)
+		}
+	}
+	defer forget()
+
+	var response *DeleteBatchResponse
+	if err := c.call(ctx, "deleteBatch", request, &response); err != nil {
+		// DeleteBatch method is optional - fall back to a delete per item if not implemented
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+			// Each delete reserves its own slot and registration
+			forget()
+			done()
+			for _, i := range batched {
+				results[i].Response, results[i].Err = c.deleteExported(ctx, params[i])
+			}
+			return results, nil
+		}
+		return nil, fmt.Errorf("failed to call deleteBatch method over JSON-RPC: %w", err)
+	}
+	if response == nil {
+		response = &DeleteBatchResponse{}
+	}
+	if len(response.Results) != len(batched) {
+		return nil, fmt.Errorf("deleteBatch returned %d results for %d items", len(response.Results), len(batched))
+	}
+
+	var busy []int
+	for j, result := range response.Results {
+		i := batched[j]
+		if result.Error == nil {
+			if result.Pending {
+				results[i].Response, results[i].Err = c.waitForDelete(ctx, params[i].ID, complete[j])
+				continue
+			}
+			results[i].Response = &result.DeleteResponse
+			continue
+		}
+		if _, ok := resourceBusy(result.Error); ok {
+			busy = append(busy, i)
+			continue
+		}
+		results[i].Err = fmt.Errorf("failed to delete resource %s: %w", params[i].ID, result.Error)
+	}
+
+	// Busy items are retried once the pending ones have completed, each delete reserves its own slot
+	forget()
+	done()
+	for _, i := range busy {
+		results[i].Response, results[i].Err = c.deleteExported(ctx, params[i])
+	}
+	return results, nil
+}
@@ -0,0 +1,77 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrExportStateUnsupported is returned by ExportState when the script does not implement the
+// optional exportState method.
+var ErrExportStateUnsupported = errors.New("deno script does not support exporting state")
+
+// ExportStateRequest represents the request payload for exporting a backup of a resource.
+type ExportStateRequest struct {
+	// ID is the unique identifier of the resource to export
+	ID string `json:"id"`
+}
+
+// ExportStateResponse represents the response from exporting a backup of a resource.
+type ExportStateResponse struct {
+	// Snapshot is the portable backup of the resource, encoded as a base64 string over JSON-RPC
+	Snapshot []byte `json:"snapshot"`
+}
+
+// ExportState asks the script for a portable backup of a resource by calling the optional "exportState"
+// method via JSON-RPC. The backup is a snapshot that ImportFromSnapshot can later hydrate the resource from,
+// so together they give a backup and restore story for bridge resources.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - id: The unique identifier of the resource to export
+//
+// Returns the snapshot, or ErrExportStateUnsupported if the script does not implement exportState.
+func (c *DenoClientResource) ExportState(ctx context.Context, id string) ([]byte, error) {
+	if !c.Client.HasCapability("exportState") {
+		return nil, ErrExportStateUnsupported
+	}
+
+	var response *ExportStateResponse
+	if err := c.Client.Call(ctx, "exportState", &ExportStateRequest{ID: id}, &response); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+			return nil, ErrExportStateUnsupported
+		}
+		return nil, fmt.Errorf("failed to call exportState method over JSON-RPC: %w", err)
+	}
+	if response == nil {
+		return nil, nil
+	}
+	return response.Snapshot, nil
+}
+
+// exportBeforeDestroy exports a backup of the resource being deleted when ExportBeforeDestroy is set,
+// passing it to OnStateExported. Scripts that do not implement exportState are deleted without a backup,
+// any other failure prevents the delete, so a resource is never destroyed after its backup failed.
+func (c *DenoClientResource) exportBeforeDestroy(ctx context.Context, id string) error {
+	if !c.ExportBeforeDestroy {
+		return nil
+	}
+
+	snapshot, err := c.ExportState(ctx, id)
+	if errors.Is(err, ErrExportStateUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export the state of resource %s before destroying it: %w", id, err)
+	}
+	if c.OnStateExported == nil {
+		return nil
+	}
+	if err := c.OnStateExported(ctx, id, snapshot); err != nil {
+		return fmt.Errorf("failed to store the state exported from resource %s before destroying it: %w", id, err)
+	}
+	return nil
+}
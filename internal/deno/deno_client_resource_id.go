@@ -0,0 +1,33 @@
+package deno
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyGeneratedID is returned by Create when the IDGenerator returned an empty id.
+var ErrEmptyGeneratedID = errors.New("id generator returned an empty id")
+
+// generateID returns a new id from the IDGenerator, or a random UUIDv4 if there isn't one.
+func (c *DenoClientResource) generateID() (string, error) {
+	if c.IDGenerator == nil {
+		return newUUIDv4()
+	}
+	id := c.IDGenerator()
+	if id == "" {
+		return "", ErrEmptyGeneratedID
+	}
+	return id, nil
+}
+
+// newUUIDv4 returns a random RFC 9562 version 4 UUID.
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate a uuid: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,65 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrImportUnsupported is returned by Import when the script does not implement the optional import method.
+var ErrImportUnsupported = errors.New("import not supported by this resource")
+
+// ImportRequest represents the request payload for adopting an existing external object into Terraform.
+type ImportRequest struct {
+	// ID is the unique identifier of the existing object to import
+	ID string `json:"id"`
+}
+
+// ImportResponse represents the response from importing an existing external object.
+// It contains the properties and state that describe the object in Terraform.
+type ImportResponse struct {
+	// Props contains the resource configuration properties of the imported object
+	Props any `json:"props"`
+	// State contains the resource state data of the imported object
+	State any `json:"state"`
+	// SensitiveState contains the resource sensitive state data of the imported object
+	SensitiveState any `json:"sensitiveState"`
+}
+
+// Import adopts an existing external object into Terraform state by calling the optional "import"
+// method via JSON-RPC. Unlike Read, the script is given nothing but the id, so it must describe
+// the object from scratch.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - params: The import request containing the id of the object to adopt
+//
+// Returns the import response containing the object's properties and state, or ErrImportUnsupported
+// if the script does not implement import.
+func (c *DenoClientResource) Import(ctx context.Context, params *ImportRequest) (*ImportResponse, error) {
+	if !c.Client.HasCapability("import") {
+		return nil, ErrImportUnsupported
+	}
+
+	var response *ImportResponse
+	if err := c.Client.Call(ctx, "import", params, &response); err != nil {
+
+		// Import method is optional - report it as unsupported if not implemented
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+			return nil, ErrImportUnsupported
+		}
+
+		return nil, fmt.Errorf("failed to call import method over JSON-RPC: %w", err)
+	}
+	if response != nil {
+		state, err := compressState(response.State, c.StateCompressionThreshold)
+		if err != nil {
+			return nil, err
+		}
+		response.State = state
+	}
+	return response, nil
+}
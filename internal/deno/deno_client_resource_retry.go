@@ -0,0 +1,61 @@
+package deno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// call makes a JSON-RPC call on behalf of a resource operation, retrying transient failures up to
+// MaxRetries times with exponential backoff and jitter. Any other error is returned unchanged.
+func (c *DenoClientResource) call(ctx context.Context, method string, params, result any) error {
+	// A negative backoff would make the jitter panic, and an oversized one overflow once doubled
+	backoff := min(max(c.RetryBackoff, 0), maxRetryBackoff)
+	for attempt := 0; ; attempt++ {
+		err := c.Client.Call(ctx, method, params, result)
+		if err == nil || attempt >= c.MaxRetries || !c.retryable(err) {
+			return err
+		}
+
+		// Equal jitter, so retries of many resources that failed together spread out
+		delay := backoff/2 + rand.N(backoff/2+1)
+		backoff = min(backoff*2, maxRetryBackoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up retrying %s: %w (last error: %w)", method, ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryable returns true if err is a JSON-RPC error with one of the RetryCodes,
+// or that the script marked as retryable with {"retryable": true} in its data.
+func (c *DenoClientResource) retryable(err error) bool {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if slices.Contains(c.RetryCodes, rpcErr.Code) {
+		return true
+	}
+
+	var data struct {
+		Retryable bool `json:"retryable"`
+	}
+	if rpcErr.Data != nil {
+		_ = json.Unmarshal(*rpcErr.Data, &data)
+	}
+	return data.Retryable
+}
@@ -0,0 +1,52 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrImportSnapshotUnsupported is returned by ImportFromSnapshot when the script does not
+// implement the optional importSnapshot method.
+var ErrImportSnapshotUnsupported = errors.New("deno script does not support importing from a snapshot")
+
+// ImportSnapshotRequest represents the request payload for hydrating a resource from a snapshot.
+type ImportSnapshotRequest struct {
+	// Snapshot is the raw snapshot data, encoded as a base64 string over JSON-RPC
+	Snapshot []byte `json:"snapshot"`
+}
+
+// ImportFromSnapshot seeds the state of a resource from a known good snapshot by calling the optional
+// "importSnapshot" method via JSON-RPC, instead of looking the resource up in its live backend.
+// This supports rebuilding Terraform state in disaster recovery scenarios where the backend is unavailable.
+//
+// Parameters:
+//   - ctx: The context for the operation, used for cancellation and timeouts
+//   - snapshot: The snapshot data, passed to the script as is
+//
+// Returns the resource properties and state hydrated from the snapshot, or ErrImportSnapshotUnsupported
+// if the script does not implement importSnapshot.
+func (c *DenoClientResource) ImportFromSnapshot(ctx context.Context, snapshot []byte) (*CreateReadResponse, error) {
+	if !c.Client.HasCapability("importSnapshot") {
+		return nil, ErrImportSnapshotUnsupported
+	}
+
+	var response *CreateReadResponse
+	if err := c.Client.Call(ctx, "importSnapshot", &ImportSnapshotRequest{Snapshot: snapshot}, &response); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+			return nil, ErrImportSnapshotUnsupported
+		}
+		return nil, fmt.Errorf("failed to call importSnapshot method over JSON-RPC: %w", err)
+	}
+	if response != nil && response.State != nil {
+		state, err := compressState(*response.State, c.StateCompressionThreshold)
+		if err != nil {
+			return nil, err
+		}
+		response.State = &state
+	}
+	return response, nil
+}
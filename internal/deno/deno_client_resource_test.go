@@ -0,0 +1,942 @@
+package deno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// newFakeDenoClientResource returns a DenoClientResource backed by the fake Deno
+// executable, with a short delete backoff and create poll interval to keep the tests fast.
+func newFakeDenoClientResource(t *testing.T, scenario string) *DenoClientResource {
+	t.Helper()
+	c := &DenoClientResource{
+		Client:             newFakeDenoClient(t, scenario),
+		DeleteMaxAttempts:  defaultDeleteMaxAttempts,
+		DeleteBackoff:      time.Millisecond,
+		CreatePollInterval: time.Millisecond,
+		RetryBackoff:       time.Millisecond,
+	}
+	c.Client.rpcMethods = jsocket.TypedServerMethods(&DenoClientResourceServerMethods{c})
+	return c
+}
+
+func TestDenoClientResource_DeleteRetriesWhileBusy(t *testing.T) {
+	c := newFakeDenoClientResource(t, "busy")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.NoError(t, err)
+	assert.True(t, response.Done)
+	assert.Equal(t, 3, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_DeleteGivesUpWhenAlwaysBusy(t *testing.T) {
+	c := newFakeDenoClientResource(t, "busy-forever")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+	c.DeleteMaxAttempts = 3
+
+	_, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.IsError(t, err, ErrResourceBusy)
+	assert.Equal(t, 3, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_DeleteBatch(t *testing.T) {
+	c := newFakeDenoClientResource(t, "batch-delete")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	results, err := c.DeleteBatch(t.Context(), []*DeleteRequest{{ID: "a"}, {ID: "missing"}, {ID: "busy"}, {ID: "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.True(t, results[0].Response.Done)
+	assert.EqualError(t, results[1].Err, "failed to delete resource missing: jsonrpc2: code 1 message: bucket not found")
+	assert.Zero(t, results[1].Response)
+	assert.NoError(t, results[2].Err)
+	assert.True(t, results[2].Response.Done)
+	assert.NoError(t, results[3].Err)
+	assert.True(t, results[3].Response.Done)
+
+	// Only the busy item is retried individually
+	assert.Equal(t, 1, c.Client.Summary().Calls["deleteBatch"])
+	assert.Equal(t, 1, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_DeleteBatchWaitsForPendingItems(t *testing.T) {
+	c := newFakeDenoClientResource(t, "batch-delete")
+	c.Client.MaxPendingAsync = 1
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	results, err := c.DeleteBatch(t.Context(), []*DeleteRequest{{ID: "a"}, {ID: "pending"}, {ID: "pending-fails"}, {ID: "busy"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.True(t, results[0].Response.Done)
+	assert.NoError(t, results[1].Err)
+	assert.True(t, results[1].Response.Done)
+	assert.False(t, results[1].Response.Pending)
+	assert.EqualError(t, results[2].Err, "pending delete of resource pending-fails failed: still has dependents")
+	assert.Zero(t, results[2].Response)
+	assert.NoError(t, results[3].Err)
+	assert.True(t, results[3].Response.Done)
+
+	// The batch released its slot before the busy item was retried
+	assert.Equal(t, 1, c.Client.Summary().Calls["delete"])
+	assert.Equal(t, 0, c.Client.PendingAsync())
+}
+
+func TestDenoClientResource_DeleteBatchFallsBackToDelete(t *testing.T) {
+	c := newFakeDenoClientResource(t, "default")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	results, err := c.DeleteBatch(t.Context(), []*DeleteRequest{{ID: "a"}, {ID: "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Error(t, result.Err)
+	}
+	assert.Equal(t, 2, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_DeletePermanentFailureIsNotRetried(t *testing.T) {
+	c := newFakeDenoClientResource(t, "default")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.Error(t, err)
+	assert.NotIsError(t, err, ErrResourceBusy)
+	assert.Equal(t, 1, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_DeleteManualInterventionIsNotRetried(t *testing.T) {
+	c := newFakeDenoClientResource(t, "tampered")
+	c.Client.ReusePolicy = ReusePolicyRestart
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.IsError(t, err, ErrManualIntervention)
+	assert.Contains(t, err.Error(), "resource was modified outside of terraform")
+
+	// The same process saw exactly one attempt, so it was neither retried nor restarted
+	var attempts int
+	assert.NoError(t, c.Client.Call(t.Context(), "deleteAttempts", nil, &attempts))
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, c.Client.Summary().Restarts)
+}
+
+func TestDenoClientResource_CreateValidationErrors(t *testing.T) {
+	c := newFakeDenoClientResource(t, "invalid-create")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{"name": "taken"}})
+	assert.Error(t, err)
+	assert.Equal(t, []ValidationError{
+		{Path: []string{"props", "name"}, Message: "name is already taken"},
+		{Path: []string{"props", "tags", "1"}, Message: "tags must be lowercase"},
+	}, ValidationErrors(err))
+
+	// A validation failure is an ordinary method error, the process stays usable
+	assert.NoError(t, c.Client.Call(t.Context(), "pid", nil, nil))
+}
+
+func TestValidationErrors_OtherErrors(t *testing.T) {
+	assert.Zero(t, ValidationErrors(errors.New("boom")))
+	assert.Zero(t, ValidationErrors(&jsonrpc2.Error{Code: CodeManualIntervention, Message: "halt"}))
+
+	malformed := &jsonrpc2.Error{Code: CodeValidationFailed, Message: "invalid props"}
+	malformed.SetError(map[string]any{"path": "name"})
+	assert.Zero(t, ValidationErrors(malformed))
+}
+
+func TestDenoClientResource_StateCompressionRoundTrip(t *testing.T) {
+	c := newFakeDenoClientResource(t, "large-state")
+	c.StateCompressionThreshold = DefaultStateCompressionThreshold
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	expected, err := json.Marshal(fakeLargeState())
+	assert.NoError(t, err)
+
+	created, err := c.Create(t.Context(), &CreateRequest{})
+	assert.NoError(t, err)
+	assert.True(t, isCompressedState(created.State))
+	compressed, err := json.Marshal(created.State)
+	assert.NoError(t, err)
+	assert.True(t, len(compressed) < len(expected)/4)
+
+	read, err := c.Read(t.Context(), &CreateReadRequest{ID: created.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, created.State, *read.State)
+
+	// The script echoes back the current state it was given, so this asserts
+	// that it received the original state and not the compressed blob.
+	updated, err := c.Update(t.Context(), &UpdateRequest{ID: created.ID, CurrentState: *read.State})
+	assert.NoError(t, err)
+	assert.True(t, isCompressedState(*updated.State))
+	decompressed, err := decompressState(*updated.State)
+	assert.NoError(t, err)
+	actual, err := json.Marshal(decompressed)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestDenoClientResource_StateCompressionDisabled(t *testing.T) {
+	c := newFakeDenoClientResource(t, "large-state")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	created, err := c.Create(t.Context(), &CreateRequest{})
+	assert.NoError(t, err)
+	assert.False(t, isCompressedState(created.State))
+}
+
+func TestDenoClientResource_ReadTransmitsRefreshOnly(t *testing.T) {
+	c := newFakeDenoClientResource(t, "echo-read")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	for _, refreshOnly := range []bool{true, false} {
+		response, err := c.Read(t.Context(), &CreateReadRequest{ID: "123", RefreshOnly: refreshOnly})
+		assert.NoError(t, err)
+		assert.Equal(t, any(refreshOnly), (*response.State).(map[string]any)["refreshOnly"])
+	}
+}
+
+func TestDenoClientResource_CreatePollsPendingCreate(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-create")
+	var progress []string
+	c.OnCreateProgress = func(ctx context.Context, p *CreateProgress) {
+		progress = append(progress, p.String())
+	}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Create(t.Context(), &CreateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", response.ID)
+	assert.Equal(t, any(map[string]any{"ready": true}), response.State)
+	assert.Equal(t, []string{"creating... 40%", "creating... 80%", "finalizing"}, progress)
+}
+
+func TestDenoClientResource_CreateHonorsPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max time.Duration
+		atLeast  time.Duration
+		lessThan time.Duration
+	}{
+		// The script asks for 100ms before the first poll and 200ms before the second
+		{"unbounded", 0, 0, 300 * time.Millisecond, time.Minute},
+		{"floor", 250 * time.Millisecond, 0, 500 * time.Millisecond, time.Minute},
+		{"ceiling", 0, 20 * time.Millisecond, 40 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeDenoClientResource(t, "pending-create-poll-interval")
+			c.CreatePollInterval = time.Hour
+			c.MinCreatePollInterval = tt.min
+			c.MaxCreatePollInterval = tt.max
+			assert.NoError(t, c.Client.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+			start := time.Now()
+			response, err := c.Create(t.Context(), &CreateRequest{})
+			elapsed := time.Since(start)
+			assert.NoError(t, err)
+			assert.Equal(t, "123", response.ID)
+			assert.True(t, elapsed >= tt.atLeast, "took %s, expected at least %s", elapsed, tt.atLeast)
+			assert.True(t, elapsed < tt.lessThan, "took %s, expected less than %s", elapsed, tt.lessThan)
+		})
+	}
+}
+
+func TestDenoClientResource_CreatePollInterval(t *testing.T) {
+	c := &DenoClientResource{
+		CreatePollInterval:    2 * time.Second,
+		MinCreatePollInterval: time.Second,
+		MaxCreatePollInterval: time.Minute,
+	}
+	assert.Equal(t, 2*time.Second, c.createPollInterval(0))
+	assert.Equal(t, 5*time.Second, c.createPollInterval(5000))
+	assert.Equal(t, time.Second, c.createPollInterval(10))
+	assert.Equal(t, time.Minute, c.createPollInterval(3_600_000))
+	assert.Equal(t, time.Minute, c.createPollInterval(math.MaxInt64))
+}
+
+func TestDenoClientResource_CreatePendingFailed(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-create-fails")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Create(t.Context(), &CreateRequest{})
+	assert.EqualError(t, err, "pending create of resource op-1 failed: quota exceeded")
+	assert.Equal(t, "op-1", response.ID)
+}
+
+func TestDenoClientResource_CreatePendingDeadline(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-create-forever")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+
+	response, err := c.Create(ctx, &CreateRequest{})
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "op-1", response.ID)
+}
+
+func TestDenoClientResource_CreatePendingCanceled(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-create-forever")
+	c.Timeouts = ResourceTimeouts{Create: time.Hour}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// Cancel once the create is pending, the resource it started exists and must not be orphaned
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		for c.Client.PendingAsync() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	response, err := c.Create(ctx, &CreateRequest{})
+	assert.IsError(t, err, context.Canceled)
+	assert.NotZero(t, response)
+	assert.Equal(t, "op-1", response.ID)
+	assert.Zero(t, response.State)
+
+	// As does a create timeout giving up on it
+	c.Timeouts = ResourceTimeouts{Create: 50 * time.Millisecond}
+	response, err = c.Create(t.Context(), &CreateRequest{})
+	assert.IsError(t, err, ErrOperationTimeout)
+	assert.NotZero(t, response)
+	assert.Equal(t, "op-1", response.ID)
+}
+
+func TestDenoClientResource_DeleteWaitsForPendingDelete(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-delete")
+	var mu sync.Mutex
+	var progress []string
+	c.OnDeleteProgress = func(ctx context.Context, p *DeleteProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "123", p.ID)
+		progress = append(progress, p.String())
+	}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.NoError(t, err)
+	assert.True(t, response.Done)
+	assert.False(t, response.Pending)
+	assert.Equal(t, 0, c.Client.PendingAsync())
+
+	// Notifications are handled concurrently, so progress may be reported after completion, in any order
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(progress)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"draining... 40%", "draining... 80%"}, slices.Sorted(slices.Values(progress)))
+}
+
+func TestDenoClientResource_DeletePendingFailed(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-delete-fails")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.EqualError(t, err, "pending delete of resource 123 failed: still has dependents")
+}
+
+func TestDenoClientResource_DeletePendingDeadline(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-delete-forever")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Delete(ctx, &DeleteRequest{ID: "123"})
+	assert.IsError(t, err, context.DeadlineExceeded)
+}
+
+func TestDenoClientResource_CreatePendingAsyncCap(t *testing.T) {
+	c := newFakeDenoClientResource(t, "pending-create-forever")
+	c.Client.MaxPendingAsync = 1
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// Occupy the only slot with a create that never completes
+	firstCtx, cancelFirst := context.WithCancel(t.Context())
+	type result struct {
+		response *CreateResponse
+		err      error
+	}
+	firstResult := make(chan result, 1)
+	go func() {
+		response, err := c.Create(firstCtx, &CreateRequest{})
+		firstResult <- result{response, err}
+	}()
+	for c.Client.Summary().Calls["create"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The next pending create blocks until its context ends
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err := c.Create(ctx, &CreateRequest{})
+	assert.IsError(t, err, ErrTooManyPendingAsync)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, c.Client.PendingAsync())
+
+	// The slot is reserved before the script is called, so it never started the blocked create
+	assert.Equal(t, 1, c.Client.Summary().Calls["create"])
+
+	// Cancelling the first create frees its slot, without losing the object it started
+	cancelFirst()
+	first := <-firstResult
+	assert.IsError(t, first.err, context.Canceled)
+	assert.NotZero(t, first.response)
+	assert.Equal(t, "op-1", first.response.ID)
+	assert.Equal(t, 0, c.Client.PendingAsync())
+}
+
+func TestDenoClientResource_ImportFromSnapshot(t *testing.T) {
+	c := newFakeDenoClientResource(t, "snapshot")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	snapshot := []byte(`{"props":{"name":"db"},"state":{"arn":"arn:aws:rds:db"}}`)
+	response, err := c.ImportFromSnapshot(t.Context(), snapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"name": "db"}), *response.Props)
+	assert.Equal(t, any(map[string]any{"arn": "arn:aws:rds:db"}), *response.State)
+}
+
+func TestDenoClientResource_ImportFromSnapshotUnsupported(t *testing.T) {
+	c := newFakeDenoClientResource(t, "default")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.ImportFromSnapshot(t.Context(), []byte(`{}`))
+	assert.IsError(t, err, ErrImportSnapshotUnsupported)
+}
+
+func TestDenoClientResource_ExportBeforeDestroy(t *testing.T) {
+	c := newFakeDenoClientResource(t, "export-state")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	exported := map[string]string{}
+	c.ExportBeforeDestroy = true
+	c.OnStateExported = func(ctx context.Context, id string, snapshot []byte) error {
+		exported[id] = string(snapshot)
+		return nil
+	}
+
+	// The fake refuses to delete a resource whose backup was not exported first
+	response, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.NoError(t, err)
+	assert.True(t, response.Done)
+	assert.Equal(t, map[string]string{"123": `{"id":"123"}`}, exported)
+
+	// A failed export prevents the delete
+	_, err = c.Delete(t.Context(), &DeleteRequest{ID: "unexportable"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "backend unavailable")
+	assert.Equal(t, 1, c.Client.Summary().Calls["delete"])
+
+	// So does failing to store the backup
+	c.OnStateExported = func(ctx context.Context, id string, snapshot []byte) error {
+		return errors.New("disk full")
+	}
+	_, err = c.Delete(t.Context(), &DeleteRequest{ID: "456"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+	assert.Equal(t, 1, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_ExportBeforeDestroyBatch(t *testing.T) {
+	c := newFakeDenoClientResource(t, "export-state")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+	c.ExportBeforeDestroy = true
+
+	// The script has no deleteBatch, so each exported item is deleted on its own
+	results, err := c.DeleteBatch(t.Context(), []*DeleteRequest{{ID: "a"}, {ID: "unexportable"}, {ID: "b"}})
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, 2, c.Client.Summary().Calls["delete"])
+}
+
+func TestDenoClientResource_ExportBeforeDestroyUnsupported(t *testing.T) {
+	c := newFakeDenoClientResource(t, "busy")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+	c.DeleteBackoff = time.Millisecond
+	c.ExportBeforeDestroy = true
+
+	_, err := c.ExportState(t.Context(), "123")
+	assert.IsError(t, err, ErrExportStateUnsupported)
+
+	// A script without exportState is deleted without a backup
+	response, err := c.Delete(t.Context(), &DeleteRequest{ID: "123"})
+	assert.NoError(t, err)
+	assert.True(t, response.Done)
+}
+
+func TestDenoClientResource_GatedByCapabilities(t *testing.T) {
+	c := newFakeDenoClientResource(t, "capabilities")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Import(t.Context(), &ImportRequest{ID: "123"})
+	assert.NoError(t, err)
+
+	// Methods missing from the reported capabilities are skipped without a call
+	response, err := c.ModifyPlan(t.Context(), &ModifyPlanRequest{})
+	assert.NoError(t, err)
+	assert.Zero(t, response)
+	_, err = c.ImportFromSnapshot(t.Context(), []byte(`{}`))
+	assert.IsError(t, err, ErrImportSnapshotUnsupported)
+	assert.Equal(t, 0, c.Client.Summary().Calls["modifyPlan"])
+	assert.Equal(t, 0, c.Client.Summary().Calls["importSnapshot"])
+}
+
+func TestDenoClientResource_ApplyWarnings(t *testing.T) {
+	c := newFakeDenoClientResource(t, "apply-warnings")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	created, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{"size": 500}})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", created.ID)
+	assert.Equal(t, any(map[string]any{"size": float64(100)}), created.State)
+	assert.NotZero(t, created.Diagnostics)
+	assert.Equal(t, 1, len(*created.Diagnostics))
+	assert.Equal(t, "warning", (*created.Diagnostics)[0].Severity)
+	assert.Equal(t, []string{"props", "size"}, *(*created.Diagnostics)[0].PropPath)
+
+	updated, err := c.Update(t.Context(), &UpdateRequest{ID: "123", NextProps: map[string]any{"size": 500}})
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"size": float64(100)}), *updated.State)
+	assert.NotZero(t, updated.Diagnostics)
+	assert.Equal(t, "Value clamped", (*updated.Diagnostics)[0].Summary)
+}
+
+func TestDenoClientResource_Import(t *testing.T) {
+	c := newFakeDenoClientResource(t, "importable")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Import(t.Context(), &ImportRequest{ID: "bucket-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, any(map[string]any{"name": "bucket-1"}), response.Props)
+	assert.Equal(t, any(map[string]any{"size": float64(42)}), response.State)
+}
+
+func TestDenoClientResource_ImportUnsupported(t *testing.T) {
+	c := newFakeDenoClientResource(t, "default")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Import(t.Context(), &ImportRequest{ID: "bucket-1"})
+	assert.IsError(t, err, ErrImportUnsupported)
+	assert.EqualError(t, err, "import not supported by this resource")
+}
+
+func TestDenoClientResource_Timeouts(t *testing.T) {
+	c := newFakeDenoClientResource(t, "slow")
+	c.Timeouts = ResourceTimeouts{Read: 20 * time.Millisecond}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, err := c.Read(t.Context(), &CreateReadRequest{ID: "123"})
+	assert.IsError(t, err, ErrOperationTimeout)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "read did not complete within 20ms")
+
+	// Operations without a timeout are unbounded
+	response, err := c.Create(t.Context(), &CreateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", response.ID)
+}
+
+func TestDenoClientResource_TimeoutsCallerDeadline(t *testing.T) {
+	c := newFakeDenoClientResource(t, "slow")
+	c.Timeouts = ResourceTimeouts{Read: time.Hour}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// The caller's own deadline is not reported as the operation timing out
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.Read(ctx, &CreateReadRequest{ID: "123"})
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.NotIsError(t, err, ErrOperationTimeout)
+}
+
+func TestDenoClientResource_RetriesTransientErrors(t *testing.T) {
+	for _, method := range []string{"rateLimitedRead", "resetRead"} {
+		c := newFakeDenoClientResource(t, "flaky")
+		c.MaxRetries = 3
+		c.RetryCodes = []int64{429}
+		assert.NoError(t, c.Client.Start(t.Context()))
+
+		var response map[string]any
+		assert.NoError(t, c.call(t.Context(), method, nil, &response), method)
+		assert.Equal(t, map[string]any{"ok": true}, response)
+		assert.Equal(t, 3, c.Client.Summary().Calls[method])
+		assert.NoError(t, c.Client.Stop())
+	}
+}
+
+func TestDenoClientResource_CreateRetriesReuseIdempotencyKey(t *testing.T) {
+	c := newFakeDenoClientResource(t, "flaky")
+	c.MaxRetries = 3
+	c.RetryCodes = []int64{429}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	params := &CreateRequest{Props: map[string]any{}}
+	response, err := c.Create(t.Context(), params)
+	assert.NoError(t, err)
+	assert.True(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(params.IdempotencyKey))
+	key := params.IdempotencyKey
+	assert.Equal(t, any(map[string]any{"keys": []any{key, key, key}}), response.State)
+
+	// Another logical create gets a key of its own, while a key given by the caller is kept
+	response, err = c.Create(t.Context(), &CreateRequest{Props: map[string]any{}})
+	assert.NoError(t, err)
+	keys := response.State.(map[string]any)["keys"].([]any)
+	assert.NotEqual(t, any(key), keys[3])
+
+	response, err = c.Create(t.Context(), &CreateRequest{Props: map[string]any{}, IdempotencyKey: "create-1"})
+	assert.NoError(t, err)
+	keys = response.State.(map[string]any)["keys"].([]any)
+	assert.Equal(t, any("create-1"), keys[len(keys)-1])
+}
+
+func TestDenoClientResource_CreatePartialState(t *testing.T) {
+	c := newFakeDenoClientResource(t, "partial-create")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create server")
+	assert.Equal(t, &CreateResponse{
+		ID:             "net-1",
+		State:          map[string]any{"networkId": "net-1"},
+		SensitiveState: map[string]any{"token": "secret"},
+	}, response)
+}
+
+func TestPartialCreateState(t *testing.T) {
+	withData := func(data any) error {
+		err := &jsonrpc2.Error{Code: 1, Message: "failed"}
+		err.SetError(data)
+		return fmt.Errorf("failed to call create method over JSON-RPC: %w", newScriptError("create", err))
+	}
+
+	assert.Equal(t, &CreateResponse{ID: "123", State: map[string]any{"a": "b"}},
+		partialCreateState(withData(map[string]any{"partialState": map[string]any{"id": "123", "state": map[string]any{"a": "b"}}})))
+	assert.Zero(t, partialCreateState(withData(map[string]any{"partialState": map[string]any{"state": map[string]any{}}})))
+	assert.Zero(t, partialCreateState(withData(map[string]any{"retryable": true})))
+	assert.Zero(t, partialCreateState(withData("not an object")))
+	assert.Zero(t, partialCreateState(&jsonrpc2.Error{Code: 1, Message: "failed"}))
+	assert.Zero(t, partialCreateState(errors.New("failed")))
+}
+
+func TestDenoClientResource_RetriesExhausted(t *testing.T) {
+	c := newFakeDenoClientResource(t, "flaky")
+	c.MaxRetries = 1
+	c.RetryCodes = []int64{429}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	var rpcErr *jsonrpc2.Error
+	assert.True(t, errors.As(c.call(t.Context(), "rateLimitedRead", nil, nil), &rpcErr))
+	assert.Equal(t, int64(429), rpcErr.Code)
+	assert.Equal(t, 2, c.Client.Summary().Calls["rateLimitedRead"])
+}
+
+func TestDenoClientResource_RetryHonoursCancellation(t *testing.T) {
+	c := newFakeDenoClientResource(t, "flaky")
+	c.MaxRetries = 3
+	c.RetryCodes = []int64{429}
+	c.RetryBackoff = time.Hour
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	err := c.call(ctx, "rateLimitedRead", nil, nil)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "rate limited")
+	assert.Equal(t, 1, c.Client.Summary().Calls["rateLimitedRead"])
+}
+
+func TestDenoClientResource_RetryNegativeBackoff(t *testing.T) {
+	c := newFakeDenoClientResource(t, "flaky")
+	c.MaxRetries = 1
+	c.RetryCodes = []int64{429}
+	c.RetryBackoff = -time.Second
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// Retried straight away rather than panicking
+	assert.Error(t, c.call(t.Context(), "rateLimitedRead", nil, nil))
+	assert.Equal(t, 2, c.Client.Summary().Calls["rateLimitedRead"])
+}
+
+func TestDenoClientResource_NonRetryableErrorsPassThrough(t *testing.T) {
+	c := newFakeDenoClientResource(t, "poison")
+	c.MaxRetries = 3
+	c.RetryCodes = []int64{429}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	err := c.call(t.Context(), "fail", nil, nil)
+	var scriptErr *ScriptError
+	assert.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, error(&jsonrpc2.Error{Code: 1, Message: "ordinary failure"}), scriptErr.Unwrap())
+	assert.Equal(t, 1, c.Client.Summary().Calls["fail"])
+}
+
+func TestResourceBusy(t *testing.T) {
+	hinted := &jsonrpc2.Error{Code: CodeResourceBusy}
+	hinted.SetError(map[string]any{"retryAfterMs": 1500})
+	retryAfter, busy := resourceBusy(fmt.Errorf("wrapped: %w", hinted))
+	assert.True(t, busy)
+	assert.Equal(t, 1500*time.Millisecond, retryAfter)
+
+	retryAfter, busy = resourceBusy(&jsonrpc2.Error{Code: CodeResourceBusy})
+	assert.True(t, busy)
+	assert.Equal(t, time.Duration(0), retryAfter)
+
+	_, busy = resourceBusy(&jsonrpc2.Error{Code: jsonrpc2.CodeInternalError})
+	assert.False(t, busy)
+}
+
+func TestDeleteRetryDelay(t *testing.T) {
+	assert.Equal(t, 2*time.Second, deleteRetryDelay(2*time.Second, 0))
+	assert.Equal(t, 1500*time.Millisecond, deleteRetryDelay(2*time.Second, 1500*time.Millisecond))
+
+	// An oversized hint, eg: a day, is capped rather than stalling the delete
+	hinted := &jsonrpc2.Error{Code: CodeResourceBusy}
+	hinted.SetError(map[string]any{"retryAfterMs": 86400000})
+	retryAfter, _ := resourceBusy(hinted)
+	assert.Equal(t, maxDeleteBackoff, deleteRetryDelay(time.Second, retryAfter))
+
+	hinted.SetError(map[string]any{"retryAfterMs": int64(math.MaxInt64)})
+	retryAfter, _ = resourceBusy(hinted)
+	assert.Equal(t, maxDeleteBackoff, deleteRetryDelay(time.Second, retryAfter))
+}
+
+func TestDenoClientResource_CreateWithGeneratedID(t *testing.T) {
+	c := newFakeDenoClientResource(t, "generated-id")
+	c.GenerateID = true
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	response, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{}})
+	assert.NoError(t, err)
+	assert.True(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(response.ID))
+}
+
+func TestDenoClientResource_CreateWithCustomIDGenerator(t *testing.T) {
+	c := newFakeDenoClientResource(t, "generated-id")
+	c.GenerateID = true
+	var next int
+	c.IDGenerator = func() string {
+		next++
+		return fmt.Sprintf("res_%d", next)
+	}
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	for _, expected := range []string{"res_1", "res_2"} {
+		response, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{}})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, response.ID)
+	}
+}
+
+func TestDenoClientResource_CreateWithEmptyGeneratedID(t *testing.T) {
+	c := newFakeDenoClientResource(t, "generated-id")
+	c.GenerateID = true
+	c.IDGenerator = func() string { return "" }
+
+	_, err := c.Create(t.Context(), &CreateRequest{Props: map[string]any{}})
+	assert.IsError(t, err, ErrEmptyGeneratedID)
+	assert.Equal(t, 0, c.Client.Summary().Calls["create"])
+}
+
+func TestDenoClientResource_ReadStateChecksum(t *testing.T) {
+	c := newFakeDenoClientResource(t, "echo-read")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	// echo-read returns the request params as the state
+	checksum, err := StateChecksum(map[string]any{"id": "123", "props": map[string]any{"size": 1}, "refreshOnly": false})
+	assert.NoError(t, err)
+
+	t.Run("match", func(t *testing.T) {
+		response, err := c.Read(t.Context(), &CreateReadRequest{ID: "123", Props: map[string]any{"size": 1}, StateChecksum: checksum})
+		assert.NoError(t, err)
+		assert.Zero(t, response.Diagnostics)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		response, err := c.Read(t.Context(), &CreateReadRequest{ID: "123", Props: map[string]any{"size": 2}, StateChecksum: checksum})
+		assert.NoError(t, err)
+		assert.NotZero(t, response.Diagnostics)
+		assert.Equal(t, 1, len(*response.Diagnostics))
+		assert.Equal(t, "warning", (*response.Diagnostics)[0].Severity)
+		assert.Equal(t, "State checksum mismatch", (*response.Diagnostics)[0].Summary)
+		assert.Contains(t, (*response.Diagnostics)[0].Detail, checksum)
+	})
+
+	t.Run("opt in", func(t *testing.T) {
+		response, err := c.Read(t.Context(), &CreateReadRequest{ID: "123", Props: map[string]any{"size": 2}})
+		assert.NoError(t, err)
+		assert.Zero(t, response.Diagnostics)
+	})
+}
+
+func TestDenoClientResource_ProcessPoolDeliversNotifications(t *testing.T) {
+	pool := NewProcessPool()
+	resources := []*DenoClientResource{
+		newFakeDenoClientResource(t, "pending-delete"),
+		newFakeDenoClientResource(t, "pending-delete"),
+	}
+	for _, c := range resources {
+		c.Client.Pool = pool
+		assert.NoError(t, c.Client.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Client.Stop()) }()
+	}
+	assert.Equal(t, resources[0].Client.PID(), resources[1].Client.PID())
+
+	// Each delete is completed by a notification the shared process sends to both resources
+	var wg sync.WaitGroup
+	for i, c := range resources {
+		wg.Go(func() {
+			response, err := c.Delete(t.Context(), &DeleteRequest{ID: fmt.Sprint(i)})
+			assert.NoError(t, err)
+			assert.True(t, response.Done)
+		})
+	}
+	wg.Wait()
+}
+
+type typedProps struct {
+	Name string `json:"name"`
+}
+
+type typedState struct {
+	Name     string `json:"name"`
+	Revision int    `json:"revision"`
+}
+
+func TestDenoClientResource_Typed(t *testing.T) {
+	c := newFakeDenoClientResource(t, "typed")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	id, state, err := CreateTyped[typedProps, typedState](t.Context(), c, typedProps{Name: "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", id)
+	assert.Equal(t, typedState{Name: "web", Revision: 1}, state)
+
+	read, err := ReadTyped[typedProps, typedState](t.Context(), c, id, typedProps{Name: "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, &TypedReadResponse[typedProps, typedState]{Props: typedProps{Name: "web"}, State: state, Exists: true}, read)
+
+	read, err = ReadTyped[typedProps, typedState](t.Context(), c, "gone", typedProps{Name: "web"})
+	assert.NoError(t, err)
+	assert.False(t, read.Exists)
+
+	state, err = UpdateTyped(t.Context(), c, id, typedProps{Name: "api"}, typedProps{Name: "web"}, state)
+	assert.NoError(t, err)
+	assert.Equal(t, typedState{Name: "api", Revision: 2}, state)
+
+	assert.NoError(t, DeleteTyped(t.Context(), c, id, typedProps{Name: "api"}, state))
+	assert.Error(t, DeleteTyped(t.Context(), c, "stuck", typedProps{Name: "api"}, state))
+}
+
+func TestDenoClientResource_TypedErrors(t *testing.T) {
+	c := newFakeDenoClientResource(t, "typed")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	_, _, err := CreateTyped[typedProps, typedState](t.Context(), c, typedProps{Name: "taken"})
+	assert.EqualError(t, err, "Name taken: name is already taken")
+
+	_, _, err = CreateTyped[typedProps, typedState](t.Context(), c, typedProps{Name: "wrong-shape"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decode state into deno.typedState")
+}
+
+func TestDenoClientResource_TypedPartialCreate(t *testing.T) {
+	c := newFakeDenoClientResource(t, "partial-create")
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	id, state, err := CreateTyped[typedProps, struct {
+		NetworkID string `json:"networkId"`
+	}](t.Context(), c, typedProps{Name: "web"})
+	assert.Error(t, err)
+	assert.Equal(t, "net-1", id)
+	assert.Equal(t, "net-1", state.NetworkID)
+}
+
+func TestDenoClientResource_TypedStateCompression(t *testing.T) {
+	c := newFakeDenoClientResource(t, "large-state")
+	c.StateCompressionThreshold = DefaultStateCompressionThreshold
+	assert.NoError(t, c.Client.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+	type item struct {
+		Index int `json:"index"`
+	}
+	_, state, err := CreateTyped[any, struct {
+		Items []item `json:"items"`
+	}](t.Context(), c, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, len(state.Items))
+	assert.Equal(t, 499, state.Items[499].Index)
+}
@@ -0,0 +1,43 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOperationTimeout is returned when a resource operation did not complete within its timeout,
+// as opposed to failing for any other reason, eg: a transport error.
+var ErrOperationTimeout = errors.New("resource operation timed out")
+
+// ResourceTimeouts bounds how long each resource operation may take. Each operation that
+// is zero has no timeout, beyond the deadline of the context it is called with.
+type ResourceTimeouts struct {
+	// Create bounds Create, including polling a pending create until it completes
+	Create time.Duration
+	// Read bounds Read
+	Read time.Duration
+	// Update bounds Update
+	Update time.Duration
+	// Delete bounds Delete, including any retries of a busy delete
+	Delete time.Duration
+}
+
+// withOperationTimeout calls fn with a context bounded by timeout, when non-zero. If fn fails
+// because the timeout passed, the error is wrapped with ErrOperationTimeout and names the operation.
+// Whatever fn returned alongside the error is kept, eg: the partial state of a create, see Create.
+func withOperationTimeout[T any](ctx context.Context, operation string, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := fn(timeoutCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return result, fmt.Errorf("%w: %s did not complete within %s: %w", ErrOperationTimeout, operation, timeout, err)
+	}
+	return result, err
+}
@@ -0,0 +1,161 @@
+package deno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TypedReadResponse is the response of ReadTyped.
+type TypedReadResponse[P, S any] struct {
+	// Props are the props of the resource as read from the external system, zero when the script returned none
+	Props P
+	// State is the state of the resource, zero when the script returned none
+	State S
+	// Exists is false when the resource no longer exists in the external system
+	Exists bool
+}
+
+// CreateTyped creates a resource like DenoClientResource.Create, for Go callers that know the shape of its props
+// and state. The props are marshalled into the request as is and the state of the response is unmarshalled into S,
+// so a script returning a state of the wrong shape fails here, rather than wherever the state is used.
+//
+// An error diagnostic from the script is returned as an error and warnings are dropped. Use Create when the
+// write-only props, sensitive state or diagnostics are needed. Like Create, when the script failed the create
+// midway, the id and state of what it created are returned together with the error, see PartialCreateState.
+func CreateTyped[P, S any](ctx context.Context, c *DenoClientResource, props P) (id string, state S, err error) {
+	response, err := c.Create(ctx, &CreateRequest{Props: props})
+	if response == nil {
+		if err == nil {
+			err = c.noResponseError("create")
+		}
+		return "", state, err
+	}
+
+	state, decodeErr := decodeTypedState[S](response.State)
+	if err != nil || decodeErr != nil {
+		return response.ID, state, errors.Join(err, decodeErr)
+	}
+	return response.ID, state, diagnosticsError(response.Diagnostics)
+}
+
+// ReadTyped reads a resource like DenoClientResource.Read, unmarshalling the props and state of the response into
+// P and S, see CreateTyped. A response without exists is taken to mean the resource still exists.
+func ReadTyped[P, S any](ctx context.Context, c *DenoClientResource, id string, props P) (*TypedReadResponse[P, S], error) {
+	response, err := c.Read(ctx, &CreateReadRequest{ID: id, Props: props})
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, c.noResponseError("read")
+	}
+	if err := diagnosticsError(response.Diagnostics); err != nil {
+		return nil, err
+	}
+
+	typed := &TypedReadResponse[P, S]{Exists: response.Exists == nil || *response.Exists}
+	if response.Props != nil {
+		if typed.Props, err = decodeTyped[P](*response.Props, "props"); err != nil {
+			return nil, err
+		}
+	}
+	if response.State != nil {
+		if typed.State, err = decodeTypedState[S](*response.State); err != nil {
+			return nil, err
+		}
+	}
+	return typed, nil
+}
+
+// UpdateTyped updates a resource like DenoClientResource.Update, returning the state of the response unmarshalled
+// into S, see CreateTyped. The state is zero when the script returned none.
+func UpdateTyped[P, S any](ctx context.Context, c *DenoClientResource, id string, nextProps, currentProps P, currentState S) (S, error) {
+	var state S
+	response, err := c.Update(ctx, &UpdateRequest{ID: id, NextProps: nextProps, CurrentProps: currentProps, CurrentState: currentState})
+	if err != nil {
+		return state, err
+	}
+	if response == nil {
+		return state, c.noResponseError("update")
+	}
+	if err := diagnosticsError(response.Diagnostics); err != nil {
+		return state, err
+	}
+	if response.State == nil {
+		return state, nil
+	}
+	return decodeTypedState[S](*response.State)
+}
+
+// DeleteTyped deletes a resource like DenoClientResource.Delete, see CreateTyped. It fails when the script
+// did not report the delete as done.
+func DeleteTyped[P, S any](ctx context.Context, c *DenoClientResource, id string, props P, state S) error {
+	response, err := c.Delete(ctx, &DeleteRequest{ID: id, Props: props, State: state})
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return c.noResponseError("delete")
+	}
+	if err := diagnosticsError(response.Diagnostics); err != nil {
+		return err
+	}
+	if !response.Done {
+		return fmt.Errorf("deno script %s did not report the delete of %s as done", c.Client.scriptPath, id)
+	}
+	return nil
+}
+
+// noResponseError is returned by the typed helpers when the script answered method with null.
+func (c *DenoClientResource) noResponseError(method string) error {
+	return fmt.Errorf("the %s method of deno script %s returned no response", method, c.Client.scriptPath)
+}
+
+// decodeTypedState unmarshals a state returned by the script into S, decompressing it first, see StateCompressionThreshold.
+func decodeTypedState[S any](state any) (S, error) {
+	decompressed, err := decompressState(state)
+	if err != nil {
+		var zero S
+		return zero, err
+	}
+	return decodeTyped[S](decompressed, "state")
+}
+
+// decodeTyped unmarshals a value returned by the script into T, by way of its JSON encoding.
+func decodeTyped[T any](value any, what string) (T, error) {
+	var typed T
+	if value == nil {
+		return typed, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return typed, fmt.Errorf("failed to encode %s: %w", what, err)
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return typed, fmt.Errorf("failed to decode %s into %T: %w", what, typed, err)
+	}
+	return typed, nil
+}
+
+// resourceDiagnostics is the type of the Diagnostics of the resource responses.
+type resourceDiagnostics = *[]struct {
+	Severity string    `json:"severity"`
+	Summary  string    `json:"summary"`
+	Detail   string    `json:"detail"`
+	PropPath *[]string `json:"propPath,omitempty"`
+}
+
+// diagnosticsError joins the error diagnostics into an error, or returns nil when there are none.
+func diagnosticsError(diagnostics resourceDiagnostics) error {
+	if diagnostics == nil {
+		return nil
+	}
+	var errs []error
+	for _, diagnostic := range *diagnostics {
+		if diagnostic.Severity == "error" {
+			errs = append(errs, fmt.Errorf("%s: %s", diagnostic.Summary, diagnostic.Detail))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package deno
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ScriptError is returned by Call when a method of the script failed with a JSON-RPC error,
+// preserving its code, message and data. It wraps the *jsonrpc2.Error, so errors.As can still
+// find that, and its message is unchanged.
+type ScriptError struct {
+	// Method is the JSON-RPC method that failed
+	Method string
+	// Code is the JSON-RPC error code returned by the script
+	Code int64
+	// Message is the error message returned by the script
+	Message string
+	// Data is the raw error data returned by the script, if any
+	Data *json.RawMessage
+
+	err error
+}
+
+// Error implements error.
+func (e *ScriptError) Error() string {
+	if e.err == nil {
+		return (&jsonrpc2.Error{Code: e.Code, Message: e.Message, Data: e.Data}).Error()
+	}
+	return e.err.Error()
+}
+
+// Unwrap returns the error the script failed the call with.
+func (e *ScriptError) Unwrap() error {
+	return e.err
+}
+
+// ScriptErrorDiagnostic is the diagnostic a script may describe in the data of an error, eg:
+// {"severity": "warning", "summary": "Bucket already gone", "propPath": ["props", "name"]}.
+type ScriptErrorDiagnostic struct {
+	// Severity is "error", the default, or "warning"
+	Severity string `json:"severity,omitempty"`
+	// Summary is a short description of the diagnostic, defaults to the summary of the failed operation
+	Summary string `json:"summary,omitempty"`
+	// Detail provides additional context about the diagnostic, defaults to the error message
+	Detail string `json:"detail,omitempty"`
+	// PropPath optionally specifies which property the diagnostic relates to
+	PropPath *[]string `json:"propPath,omitempty"`
+}
+
+// Diagnostic returns the diagnostic described by the error data, or nil when the data is absent
+// or does not follow the ScriptErrorDiagnostic convention.
+func (e *ScriptError) Diagnostic() *ScriptErrorDiagnostic {
+	if e.Data == nil {
+		return nil
+	}
+	var diagnostic ScriptErrorDiagnostic
+	if err := json.Unmarshal(*e.Data, &diagnostic); err != nil {
+		return nil
+	}
+	if diagnostic.Severity == "" && diagnostic.Summary == "" && diagnostic.Detail == "" && diagnostic.PropPath == nil {
+		return nil
+	}
+	if diagnostic.Severity == "" {
+		diagnostic.Severity = "error"
+	}
+	if diagnostic.Detail == "" {
+		diagnostic.Detail = e.Message
+	}
+	return &diagnostic
+}
+
+// newScriptError wraps err in a ScriptError when the script failed the call with a JSON-RPC error.
+// Any other error is returned as is.
+func newScriptError(method string, err error) error {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	return &ScriptError{
+		Method:  method,
+		Code:    rpcErr.Code,
+		Message: rpcErr.Message,
+		Data:    rpcErr.Data,
+		err:     err,
+	}
+}
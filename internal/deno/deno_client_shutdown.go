@@ -0,0 +1,225 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownGracePeriod is how long Stop waits for the Deno process to exit by default,
+// both after asking it to shutdown and again after sending it SIGTERM.
+const DefaultShutdownGracePeriod = 5 * time.Second
+
+// ErrShutdownForced is returned by Stop when the Deno process ignored both the shutdown
+// notification and SIGTERM for longer than ShutdownGracePeriod and had to be killed.
+var ErrShutdownForced = errors.New("deno process did not exit within the shutdown grace period and was killed")
+
+// WithShutdownGracePeriod sets how long Stop waits for the Deno process to exit
+// before escalating from the shutdown notification to SIGTERM, and then to SIGKILL.
+func WithShutdownGracePeriod(period time.Duration) DenoClientOption {
+	return func(c *DenoClient) {
+		c.ShutdownGracePeriod = period
+	}
+}
+
+// DefaultDrainTimeout is how long Stop waits for in-flight calls to finish by default.
+const DefaultDrainTimeout = 30 * time.Second
+
+// WithDrainTimeout sets how long Stop waits for in-flight calls to finish before asking
+// the Deno process to shutdown, see DrainTimeout.
+func WithDrainTimeout(timeout time.Duration) DenoClientOption {
+	return func(c *DenoClient) {
+		c.DrainTimeout = timeout
+	}
+}
+
+// inFlightCalls tracks the calls waiting on the script, so Stop can let them finish first.
+type inFlightCalls struct {
+	mu   sync.Mutex
+	next uint64
+	// methods are the methods of the in-flight calls, keyed by a sequence number
+	methods map[uint64]string
+	// idle is closed when the last in-flight call finishes
+	idle chan struct{}
+}
+
+// begin records a call of the given method as in-flight, until the returned func is called.
+func (f *inFlightCalls) begin(method string) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.methods) == 0 {
+		f.methods = make(map[uint64]string)
+		f.idle = make(chan struct{})
+	}
+	f.next++
+	seq := f.next
+	f.methods[seq] = method
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.methods, seq)
+		if len(f.methods) == 0 {
+			close(f.idle)
+		}
+	}
+}
+
+// drain waits up to timeout for the in-flight calls to finish. It returns the methods,
+// sorted, of the calls that were still in-flight when it gave up.
+func (f *inFlightCalls) drain(timeout time.Duration) []string {
+	f.mu.Lock()
+	if len(f.methods) == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+		return nil
+	case <-timer.C:
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pending := slices.Collect(maps.Values(f.methods))
+	slices.Sort(pending)
+	return pending
+}
+
+// waitForExit waits up to timeout for the Deno process to exit, returning true if it did.
+func (c *DenoClient) waitForExit(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.exit.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// terminate escalates the shutdown of a Deno process that ignored the shutdown notification.
+// It sends SIGTERM and waits up to ShutdownGracePeriod before killing the process. Returns
+// true if the process exited after SIGTERM, false if it had to be killed.
+//
+// Windows has no SIGTERM, so there the process is killed straight away.
+func (c *DenoClient) terminate() bool {
+	if err := c.process.Process.Signal(syscall.SIGTERM); err == nil && c.waitForExit(c.ShutdownGracePeriod) {
+		return true
+	}
+	_ = c.process.Process.Kill()
+	<-c.exit.done
+	return false
+}
+
+// exited returns true if the Deno process has already exited, without waiting.
+func (c *DenoClient) exited() bool {
+	select {
+	case <-c.exit.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProcessExitError is returned by Stop when the Deno process exited unexpectedly, ie: before Stop asked
+// it to shutdown, or afterwards but not cleanly. Stop appends the last lines the process wrote to stderr.
+type ProcessExitError struct {
+	// ExitCode is the exit code of the process, -1 if it was killed by a signal
+	ExitCode int
+	// Signal is the name of the signal that killed the process, eg: "SIGKILL", empty if it exited by itself
+	Signal string
+	// BeforeShutdown is true if the process exited before Stop asked it to shutdown, ie: it crashed
+	BeforeShutdown bool
+
+	err error
+}
+
+// newProcessExitError describes err, the result of waiting on a Deno process that exited unexpectedly.
+func newProcessExitError(err error, beforeShutdown bool) *ProcessExitError {
+	exit := &ProcessExitError{BeforeShutdown: beforeShutdown, err: err}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exit.ExitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			exit.Signal = signalName(status.Signal())
+		}
+	case err != nil:
+		exit.ExitCode = -1
+	}
+	return exit
+}
+
+// Error implements error.
+func (e *ProcessExitError) Error() string {
+	var how string
+	switch {
+	case e.Signal != "":
+		how = "killed by " + e.Signal
+	case e.ExitCode >= 0:
+		how = fmt.Sprintf("exited with code %d", e.ExitCode)
+	default:
+		how = e.err.Error()
+	}
+	if e.BeforeShutdown {
+		how += " before it was asked to shutdown"
+	}
+	return "deno child proc died: " + how
+}
+
+// Unwrap returns the error waiting on the process returned, nil if it exited with code 0.
+func (e *ProcessExitError) Unwrap() error {
+	return e.err
+}
+
+// signalNames are the names of the signals a Deno process is commonly killed by.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+}
+
+// signalName returns the name of sig, eg: "SIGKILL", falling back to its number and description.
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d (%s)", int(sig), sig)
+}
+
+// expectedShutdownExit returns true if err, the result of waiting on a Deno process that exited after
+// being asked to shutdown, is part of a normal shutdown. Besides a clean exit, a process that died
+// from SIGPIPE was writing to the socket Stop had already closed, so it too shut down as asked.
+func expectedShutdownExit(err error) bool {
+	if err == nil {
+		return true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGPIPE
+}
@@ -0,0 +1,125 @@
+package deno
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// WithWarmStandby keeps a second Deno process warm alongside the primary, which takes over
+// at once if the primary crashes, see WarmStandby. Only suitable for stateless scripts.
+func WithWarmStandby() DenoClientOption {
+	return func(c *DenoClient) {
+		c.WarmStandby = true
+	}
+}
+
+// newStandby returns an unstarted client that launches the same script, the same way, as c.
+func (c *DenoClient) newStandby() *DenoClient {
+	standby := NewDenoClientFromConfig(c.Config())
+	standby.rpcMethods = c.rpcMethods
+	standby.PermissionResolver = c.PermissionResolver
+	standby.Contract = c.Contract
+	standby.Env = maps.Clone(c.Env)
+	standby.logLevel.Store(c.logLevel.Load())
+	standby.WarmStandby = false
+	// The primary pings whichever process it runs, including a promoted standby
+	standby.HeartbeatInterval = 0
+	return standby
+}
+
+// spawnStandby starts a new standby process in the background, unless one is already running or starting.
+func (c *DenoClient) spawnStandby() {
+	if !c.WarmStandby || c.standby.Load() != nil || !c.standbyStarting.CompareAndSwap(false, true) {
+		return
+	}
+
+	ctx := c.ctx
+	c.standbyWG.Add(1)
+	go func() {
+		defer c.standbyWG.Done()
+		defer c.standbyStarting.Store(false)
+
+		standby := c.newStandby()
+		if err := standby.Start(ctx); err != nil {
+			msg := fmt.Sprintf("Failed to start warm standby for deno script %s: %v", c.scriptPath, err)
+			if isTestContext() {
+				log.Printf("[WARN] %s", msg)
+			} else {
+				tflog.Warn(ctx, msg)
+			}
+			return
+		}
+		c.standby.Store(standby)
+	}()
+}
+
+// promoteStandby replaces the crashed primary process with the given exit record with the warm
+// standby, if one is ready, and starts a new standby in the background. It returns false when there
+// was no standby to promote. Concurrent calls all see the same crash, once one of them has replaced
+// the process the others return true without promoting again, so they retry on the new process.
+func (c *DenoClient) promoteStandby(ctx context.Context, exit *processExit) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.replaced(exit) {
+		return true
+	}
+
+	standby := c.standby.Swap(nil)
+	if standby == nil {
+		return false
+	}
+	if standby.exited() {
+		_ = standby.Stop()
+		return false
+	}
+
+	msg := fmt.Sprintf("Deno process %s crashed (%v), failing over to the warm standby", c.scriptPath, exit.err)
+	if isTestContext() {
+		log.Printf("[WARN] %s", msg)
+	} else {
+		tflog.Warn(ctx, msg)
+	}
+
+	// Reap the crashed process, then adopt the standby's
+	c.kill()
+	c.startMu.Lock()
+	c.process = standby.process
+	c.Socket = standby.Socket
+	c.exit = standby.exit
+	c.denoVersion = standby.denoVersion
+	c.healthWarnings = standby.healthWarnings
+	c.effectivePermissions = standby.effectivePermissions
+	c.permissionsHash = standby.permissionsHash
+	c.features = standby.features
+	c.capabilities = standby.capabilities
+	c.running = true
+	c.startMu.Unlock()
+	// The standby's process keeps counting its traffic into the standby's stats
+	c.stats.adopt(&standby.stats)
+	c.stats.restarted()
+
+	c.spawnStandby()
+	return true
+}
+
+// stopStandby stops the standby process, waiting for one that is still starting.
+func (c *DenoClient) stopStandby() {
+	c.standbyWG.Wait()
+	standby := c.standby.Swap(nil)
+	if standby == nil {
+		return
+	}
+	if err := standby.Stop(); err != nil {
+		msg := fmt.Sprintf("Failed to stop warm standby for deno script %s: %v", c.scriptPath, err)
+		if isTestContext() {
+			log.Printf("[WARN] %s", msg)
+		} else {
+			tflog.Warn(c.ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package deno
+
+import (
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunSummary is a machine-readable report of everything a DenoClient did during its session.
+// It is intended for higher-level tooling, eg: CI dashboards tracking bridge performance over time.
+type RunSummary struct {
+	// Calls counts how many times each JSON-RPC method was called.
+	Calls map[string]int `json:"calls"`
+	// Errors is the total number of calls that returned an error.
+	Errors int `json:"errors"`
+	// Restarts is the number of times the Deno process was restarted.
+	Restarts int `json:"restarts"`
+	// Duration is the wall clock time between the first Start and Stop (or now if still running).
+	Duration time.Duration `json:"duration"`
+	// CallDuration is the total time spent waiting on JSON-RPC calls.
+	CallDuration time.Duration `json:"callDuration"`
+	// BytesSent is the number of bytes written to the Deno process stdin.
+	BytesSent int64 `json:"bytesSent"`
+	// BytesReceived is the number of bytes read from the Deno process stdout.
+	BytesReceived int64 `json:"bytesReceived"`
+	// FlushStalls is the number of responses mostly delayed by the script not flushing, see WithFlushWarning.
+	FlushStalls int `json:"flushStalls"`
+}
+
+// runStats accumulates the statistics that make up a RunSummary.
+type runStats struct {
+	mu            sync.Mutex
+	calls         map[string]int
+	errors        int
+	restarts      int
+	flushStalls   int
+	startedAt     time.Time
+	stoppedAt     time.Time
+	callDuration  time.Duration
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	// adopted are the stats of promoted warm standbys, their processes keep counting into them
+	adopted []*runStats
+}
+
+// started records the start of the session, only the first call has any effect.
+func (s *runStats) started() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startedAt.IsZero() {
+		s.startedAt = time.Now()
+	}
+}
+
+// stopped records the end of the session.
+func (s *runStats) stopped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stoppedAt = time.Now()
+}
+
+// restarted records a restart of the Deno process.
+func (s *runStats) restarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts++
+}
+
+// adopt folds the stats of a promoted warm standby into the summary, including what its process does from now on.
+func (s *runStats) adopt(other *runStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adopted = append(s.adopted, other)
+}
+
+// flushStalled records a response that was mostly delayed by the script not flushing.
+func (s *runStats) flushStalled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushStalls++
+}
+
+// called records the outcome of a single JSON-RPC call.
+func (s *runStats) called(method string, duration time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[method]++
+	s.callDuration += duration
+	if err != nil {
+		s.errors++
+	}
+}
+
+// summary builds a point in time RunSummary from the accumulated statistics.
+func (s *runStats) summary() *RunSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &RunSummary{
+		Calls:         make(map[string]int, len(s.calls)),
+		Errors:        s.errors,
+		Restarts:      s.restarts,
+		FlushStalls:   s.flushStalls,
+		CallDuration:  s.callDuration,
+		BytesSent:     s.bytesSent.Load(),
+		BytesReceived: s.bytesReceived.Load(),
+	}
+	maps.Copy(summary.Calls, s.calls)
+	for _, adopted := range s.adopted {
+		other := adopted.summary()
+		for method, count := range other.Calls {
+			summary.Calls[method] += count
+		}
+		summary.Errors += other.Errors
+		summary.Restarts += other.Restarts
+		summary.FlushStalls += other.FlushStalls
+		summary.CallDuration += other.CallDuration
+		summary.BytesSent += other.BytesSent
+		summary.BytesReceived += other.BytesReceived
+	}
+
+	if !s.startedAt.IsZero() {
+		end := s.stoppedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		summary.Duration = end.Sub(s.startedAt)
+	}
+
+	return summary
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	io.Writer
+	count *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.count.Add(int64(n))
+	return n, err
+}
+
+// Summary returns a machine-readable report of the client's session so far.
+// The returned value is a snapshot and can be marshaled to JSON.
+func (c *DenoClient) Summary() *RunSummary {
+	return c.stats.summary()
+}
@@ -0,0 +1,268 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	defaultMaxRestarts    = 3
+	defaultRestartBackoff = 500 * time.Millisecond
+	maxRestartBackoff     = 30 * time.Second
+
+	// stderrTailLines is how many of the most recent stderr lines are kept for error reports.
+	stderrTailLines = 50
+
+	// crashDetectionGrace is how long to wait for the process to be reaped after
+	// its pipes closed, before deciding the error was not caused by a crash.
+	crashDetectionGrace = time.Second
+)
+
+// ErrRestartsExhausted is returned when a crashed Deno process can not be restarted
+// because it has already been restarted MaxRestarts times.
+var ErrRestartsExhausted = errors.New("deno process crashed too many times")
+
+// ErrStdoutClosed is returned when the Deno process closed its stdout while still running,
+// eg: because stdout was handed to a detached subprocess. The process can no longer respond
+// to calls, so it is killed and treated like any other fatal protocol error, see ReusePolicy.
+var ErrStdoutClosed = errors.New("deno process closed its stdout while still running")
+
+// processExit records when & how a Deno process exited, along with what it last wrote to stderr.
+type processExit struct {
+	// done is closed once the process has exited and all of its stderr has been logged
+	done chan struct{}
+	// err is the result of process.Wait, only safe to read after done is closed
+	err error
+	// stdoutClosed is set when the process closed its stdout while still running
+	stdoutClosed atomic.Bool
+	// shuttingDown is set once Stop has asked the process to exit, from then on its exit is expected
+	shuttingDown atomic.Bool
+	// stderrTail keeps the most recent lines the process wrote to stderr
+	stderrTail lineRing
+}
+
+// closedChan is returned by Done when no process has been started.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// PID returns the OS process ID of the Deno process, or -1 when it has not been started.
+// Useful to find the process behind a hung resource operation.
+func (c *DenoClient) PID() int {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.process == nil || c.process.Process == nil {
+		return -1
+	}
+	return c.process.Process.Pid
+}
+
+// Done returns a channel that is closed once the current Deno process has exited, so callers
+// can select on process death alongside their own timeouts. After a restart, Done must be
+// called again to watch the new process. When no process has been started the returned
+// channel is already closed.
+func (c *DenoClient) Done() <-chan struct{} {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.exit == nil {
+		return closedChan
+	}
+	return c.exit.done
+}
+
+// stdoutReader calls onEOF the first time the stdout of a Deno process reaches EOF.
+type stdoutReader struct {
+	io.ReadCloser
+	onEOF func()
+	once  sync.Once
+}
+
+func (r *stdoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.once.Do(r.onEOF)
+	}
+	return n, err
+}
+
+// checkStdoutEOF distinguishes a stdout EOF caused by the process exiting from one where the
+// process closed its stdout but kept running. The latter is a protocol error, so the process
+// is killed rather than left lingering. It is called from the socket read loop, before the
+// EOF is returned, so the verdict is in place by the time any pending call fails.
+func (c *DenoClient) checkStdoutEOF(ctx context.Context, exit *processExit, process *exec.Cmd) {
+	select {
+	case <-exit.done:
+		return
+	case <-time.After(crashDetectionGrace):
+	}
+
+	// A process that is shutting down may close its stdout before it exits, Stop deals with any stragglers
+	if exit.shuttingDown.Load() {
+		return
+	}
+
+	exit.stdoutClosed.Store(true)
+	msg := fmt.Sprintf("Deno process %s closed its stdout while still running, killing it", c.scriptPath)
+	if isTestContext() {
+		log.Printf("[WARN] %s", msg)
+	} else {
+		tflog.Warn(ctx, msg)
+	}
+	_ = process.Process.Kill()
+}
+
+// current returns the socket of the Deno process along with its exit record. Every start
+// replaces the exit record, so it doubles as the generation of the process: callers that saw
+// the same crash can tell whether one of them already replaced the process.
+func (c *DenoClient) current() (*jsocket.JSocket, *processExit) {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return c.Socket, c.exit
+}
+
+// replaced returns true if the process whose exit record is exit is no longer the current one,
+// eg: because a concurrent call already restarted it. It takes startMu, so must not be called with it held.
+func (c *DenoClient) replaced(exit *processExit) bool {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	return c.exit != exit
+}
+
+// crashed returns true if err was caused by the Deno process with the given exit record exiting unexpectedly.
+func (c *DenoClient) crashed(exit *processExit, err error) bool {
+	if exit == nil || exit.stdoutClosed.Load() {
+		return false
+	}
+	// Writing to the stdin of a process that just died fails before the connection notices it closed
+	if !errors.Is(err, jsonrpc2.ErrClosed) && !errors.Is(err, io.ErrUnexpectedEOF) &&
+		!errors.Is(err, os.ErrClosed) && !errors.Is(err, syscall.EPIPE) {
+		return false
+	}
+
+	select {
+	case <-exit.done:
+		return true
+	case <-time.After(crashDetectionGrace):
+		return false
+	}
+}
+
+// restartCrashed relaunches the crashed Deno process with the given exit record, re-establishing
+// the jsocket and re-running the health handshake, after an exponential backoff. Concurrent calls
+// all see the same crash, only the first one restarts the process, the others just retry on it.
+func (c *DenoClient) restartCrashed(ctx context.Context, exit *processExit) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.replaced(exit) {
+		return nil
+	}
+
+	if c.crashRestarts >= c.MaxRestarts {
+		return exit.withStderrTail(fmt.Errorf("%w: gave up after %d restarts, last error: %v", ErrRestartsExhausted, c.crashRestarts, exit.err))
+	}
+
+	backoff := restartBackoff(c.RestartBackoff, c.crashRestarts)
+	c.crashRestarts++
+
+	msg := fmt.Sprintf("Deno process %s crashed (%v), restarting in %s (restart %d of %d)", c.scriptPath, exit.err, backoff, c.crashRestarts, c.MaxRestarts)
+	if isTestContext() {
+		log.Printf("[WARN] %s", msg)
+	} else {
+		tflog.Warn(ctx, msg)
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("aborted restarting crashed deno process: %w", ctx.Err())
+	case <-time.After(backoff):
+	}
+
+	c.startMu.Lock()
+	c.running = false
+	c.startMu.Unlock()
+
+	c.kill()
+	if err := c.Start(c.ctx); err != nil {
+		return fmt.Errorf("failed to restart crashed deno process: %w", err)
+	}
+	c.stats.restarted()
+
+	return nil
+}
+
+// restartBackoff returns how long to wait before the next restart after the given number of restarts,
+// doubling each time up to maxRestartBackoff, so a large MaxRestarts can not stall a call for hours.
+func restartBackoff(backoff time.Duration, restarts int) time.Duration {
+	for range restarts {
+		backoff = min(backoff*2, maxRestartBackoff)
+	}
+	return backoff
+}
+
+// lineRing keeps the most recent lines written to it.
+type lineRing struct {
+	mu   sync.Mutex
+	buf  []string
+	next int
+}
+
+// add records a line, evicting the oldest line once full.
+func (r *lineRing) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buf) < stderrTailLines {
+		r.buf = append(r.buf, line)
+		return
+	}
+	r.buf[r.next] = line
+	r.next = (r.next + 1) % stderrTailLines
+}
+
+// lines returns the recorded lines, oldest first.
+func (r *lineRing) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(append([]string{}, r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// StderrTail returns the most recent lines the Deno process wrote to stderr, oldest first,
+// eg: so they can be attached to Terraform diagnostics when a call fails.
+func (c *DenoClient) StderrTail() []string {
+	_, exit := c.current()
+	return exit.stderrLines()
+}
+
+// stderrLines returns the most recent lines the process wrote to stderr, oldest first, nil when
+// no process has been started.
+func (e *processExit) stderrLines() []string {
+	if e == nil {
+		return nil
+	}
+	return e.stderrTail.lines()
+}
+
+// withStderrTail appends the most recent stderr lines of the process to err, as they usually explain why it failed.
+func (e *processExit) withStderrTail(err error) error {
+	lines := e.stderrLines()
+	if len(lines) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\nlast stderr lines:\n%s", err, strings.Join(lines, "\n"))
+}
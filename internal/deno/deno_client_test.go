@@ -0,0 +1,3168 @@
+package deno
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/brad-jones/terraform-provider-denobridge/internal/jsocket"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// fakeDenoEnvVar names the environment variable that turns the test binary into a
+// fake Deno executable, serving the JSON-RPC methods of the named scenario.
+const fakeDenoEnvVar = "DENOBRIDGE_FAKE_DENO"
+
+// fakeDenoFeaturesEnvVar names the environment variable that overrides the features the fake
+// Deno executable agrees to in the health handshake, a comma separated list or "none".
+// By default it agrees to every offered feature.
+const fakeDenoFeaturesEnvVar = "DENOBRIDGE_FAKE_DENO_FEATURES"
+
+// fakeDenoSpawnLogEnvVar names the environment variable holding the path of a file
+// the fake Deno executable appends its pid to when it starts.
+const fakeDenoSpawnLogEnvVar = "DENOBRIDGE_FAKE_DENO_SPAWN_LOG"
+
+// fakeDenoMethod is a JSON-RPC method served by the fake Deno executable.
+type fakeDenoMethod func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error)
+
+// fakeWedged makes the ping method of the wedged scenario block forever, like a process whose event loop is blocked.
+var fakeWedged atomic.Bool
+
+// fakeDenoScenarios maps a scenario name to the methods the fake Deno executable
+// serves in addition to the default health, handshake, pid, args & shutdown methods.
+var fakeDenoScenarios = map[string]map[string]fakeDenoMethod{
+	"default": {},
+	"poison": {
+		"corrupt": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeParseError, Message: "Parse error"}
+		},
+		"fail": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return nil, &jsonrpc2.Error{Code: 1, Message: "ordinary failure"}
+		},
+	},
+	"slow": {
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			time.Sleep(200 * time.Millisecond)
+			return map[string]any{}, nil
+		},
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			time.Sleep(200 * time.Millisecond)
+			return map[string]any{"id": "123"}, nil
+		},
+	},
+	"generated-id": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			return map[string]any{"id": params.ID}, nil
+		},
+	},
+	"partial-create": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			err := &jsonrpc2.Error{Code: 1, Message: "failed to create server"}
+			err.SetError(map[string]any{"partialState": map[string]any{
+				"id":             "net-1",
+				"state":          map[string]any{"networkId": "net-1"},
+				"sensitiveState": map[string]any{"token": "secret"},
+			}})
+			return nil, err
+		},
+	},
+	"typed": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Props map[string]any `json:"props"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			if params.Props["name"] == "taken" {
+				return map[string]any{"id": "", "diagnostics": []any{map[string]any{"severity": "error", "summary": "Name taken", "detail": "name is already taken"}}}, nil
+			}
+			if params.Props["name"] == "wrong-shape" {
+				return map[string]any{"id": "123", "state": map[string]any{"name": 42}}, nil
+			}
+			return map[string]any{"id": "123", "state": map[string]any{"name": params.Props["name"], "revision": 1}}, nil
+		},
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID    string         `json:"id"`
+				Props map[string]any `json:"props"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			if params.ID == "gone" {
+				return map[string]any{"exists": false}, nil
+			}
+			return map[string]any{"props": params.Props, "state": map[string]any{"name": params.Props["name"], "revision": 1}}, nil
+		},
+		"update": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				NextProps    map[string]any `json:"nextProps"`
+				CurrentState struct {
+					Revision int `json:"revision"`
+				} `json:"currentState"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			return map[string]any{"state": map[string]any{"name": params.NextProps["name"], "revision": params.CurrentState.Revision + 1}}, nil
+		},
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			return map[string]any{"done": params.ID != "stuck"}, nil
+		},
+	},
+	"snapshot": {
+		"importSnapshot": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Snapshot []byte `json:"snapshot"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			var snapshot map[string]any
+			if err := json.Unmarshal(params.Snapshot, &snapshot); err != nil {
+				return nil, err
+			}
+			return map[string]any{"props": snapshot["props"], "state": snapshot["state"]}, nil
+		},
+	},
+	"export-state": {
+		"exportState": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params ExportStateRequest
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			if params.ID == "unexportable" {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "backend unavailable"}
+			}
+			fakeDenoExported.Store(params.ID, true)
+			return map[string]any{"snapshot": []byte(`{"id":"` + params.ID + `"}`)}, nil
+		},
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params DeleteRequest
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			// A resource must never be deleted before its backup was exported
+			if _, ok := fakeDenoExported.Load(params.ID); !ok {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "deleted before export"}
+			}
+			return map[string]any{"done": true}, nil
+		},
+	},
+	"script-error": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			err := &jsonrpc2.Error{Code: 42, Message: "bucket exists"}
+			err.SetError(map[string]any{"severity": "warning", "propPath": []string{"props", "name"}})
+			return nil, err
+		},
+	},
+	"capabilities": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": true, "capabilities": []string{"import"}, "protocolVersion": "1.2.0"}, nil
+		},
+		"import": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"props": map[string]any{}, "state": map[string]any{}}, nil
+		},
+		// Implemented but not reported, so never called
+		"modifyPlan": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return nil, errors.New("modifyPlan was called despite not being a reported capability")
+		},
+	},
+	"protocol-mismatch": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": true, "protocolVersion": "2.0.0"}, nil
+		},
+	},
+	"cbor": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": true, "encodings": []string{"msgpack", "cbor"}}, nil
+		},
+		// Answers with the params exactly as they were sent, so a CBOR payload comes back as CBOR
+		"echo": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return req.Params, nil
+		},
+		"params": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return string(*req.Params), nil
+		},
+	},
+	"importable": {
+		"import": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			return map[string]any{"props": map[string]any{"name": params.ID}, "state": map[string]any{"size": 42}}, nil
+		},
+	},
+	"flaky": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				IdempotencyKey string `json:"idempotencyKey"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			fakeDenoIdempotencyKeys.mu.Lock()
+			defer fakeDenoIdempotencyKeys.mu.Unlock()
+			fakeDenoIdempotencyKeys.keys = append(fakeDenoIdempotencyKeys.keys, params.IdempotencyKey)
+			if fakeDenoFlakyCalls.Add(1) < 3 {
+				return nil, &jsonrpc2.Error{Code: 429, Message: "rate limited"}
+			}
+			return map[string]any{"id": "123", "state": map[string]any{"keys": fakeDenoIdempotencyKeys.keys}}, nil
+		},
+		"rateLimitedRead": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if fakeDenoFlakyCalls.Add(1) < 3 {
+				return nil, &jsonrpc2.Error{Code: 429, Message: "rate limited"}
+			}
+			return map[string]any{"ok": true}, nil
+		},
+		"resetRead": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if fakeDenoFlakyCalls.Add(1) < 3 {
+				retryable := &jsonrpc2.Error{Code: 1, Message: "connection reset"}
+				retryable.SetError(map[string]any{"retryable": true})
+				return nil, retryable
+			}
+			return map[string]any{"ok": true}, nil
+		},
+	},
+	"large-state": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"id": "123", "state": fakeLargeState()}, nil
+		},
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"props": map[string]any{}, "state": fakeLargeState()}, nil
+		},
+		"update": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				CurrentState json.RawMessage `json:"currentState"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			return map[string]any{"state": params.CurrentState}, nil
+		},
+	},
+	"apply-warnings": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{
+				"id":          "123",
+				"state":       map[string]any{"size": 100},
+				"diagnostics": []any{map[string]any{"severity": "warning", "summary": "Value clamped", "detail": "size was clamped to max", "propPath": []string{"props", "size"}}},
+			}, nil
+		},
+		"update": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{
+				"state":       map[string]any{"size": 100},
+				"diagnostics": []any{map[string]any{"severity": "warning", "summary": "Value clamped", "detail": "size was clamped to max"}},
+			}, nil
+		},
+	},
+	"slow-flush": {
+		"buffered": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return "buffered", nil
+		},
+		"slowHandler": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			time.Sleep(200 * time.Millisecond)
+			return "done", nil
+		},
+	},
+	"renewable": {
+		"open": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"result": map[string]any{}, "renewAt": time.Now().Unix(), "privateData": map[string]any{"renewals": 0}}, nil
+		},
+		"renew": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Private struct {
+					Renewals int `json:"renewals"`
+				} `json:"privateData"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			renewals := params.Private.Renewals + 1
+			if renewals == 3 {
+				return map[string]any{"privateData": map[string]any{"renewals": renewals}}, nil
+			}
+			return map[string]any{"renewAt": time.Now().Unix(), "privateData": map[string]any{"renewals": renewals}}, nil
+		},
+		"close": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			// Echo the private data back, so the test can see what close was given
+			return map[string]any{"done": true, "diagnostics": []any{map[string]any{"severity": "warning", "summary": "closed", "detail": string(*req.Params)}}}, nil
+		},
+	},
+	"renew-later": {
+		"open": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"result": map[string]any{}, "renewAt": time.Now().Add(time.Hour).Unix()}, nil
+		},
+	},
+	"renew-fails": {
+		"open": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"result": map[string]any{}, "renewAt": time.Now().Unix()}, nil
+		},
+		"renew": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return nil, &jsonrpc2.Error{Code: 1, Message: "credentials revoked"}
+		},
+	},
+	"renew-in-flight": {
+		"open": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"result": map[string]any{}, "renewAt": time.Now().Unix(), "privateData": "opened"}, nil
+		},
+		"renew": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			fakeDenoLongRunningID.Store(&req.ID)
+			select {
+			case <-fakeDenoCancelled:
+				return nil, &jsonrpc2.Error{Code: 1, Message: "aborted"}
+			case <-time.After(10 * time.Second):
+				return map[string]any{"privateData": "renewed"}, nil
+			}
+		},
+		"renewing": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoLongRunningID.Load() != nil, nil
+		},
+		jsocket.CancelRequestMethod: func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params jsocket.CancelRequestParams
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			fakeDenoCancelledID.Store(&params.ID)
+			fakeDenoCancelOnce.Do(func() { close(fakeDenoCancelled) })
+			return nil, nil
+		},
+		"close": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			// The cancellation is sent without waiting, so it may arrive just after close
+			select {
+			case <-fakeDenoCancelled:
+			case <-time.After(5 * time.Second):
+			}
+			detail, _ := json.Marshal(map[string]any{
+				"params":    req.Params,
+				"renew":     fakeDenoLongRunningID.Load(),
+				"cancelled": fakeDenoCancelledID.Load(),
+			})
+			return map[string]any{"done": true, "diagnostics": []map[string]any{
+				{"severity": "warning", "summary": "closed", "detail": string(detail)},
+			}}, nil
+		},
+	},
+	"log-level": {
+		"setLogLevel": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Level string `json:"level"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			fakeDenoLogLevel.Store(&params.Level)
+			return nil, nil
+		},
+		"logLevel": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoLogLevel.Load(), nil
+		},
+	},
+	"slow-boot": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": fakeDenoHealthChecks.Add(1) > 3}, nil
+		},
+		"healthChecks": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoHealthChecks.Load(), nil
+		},
+	},
+	"discover": {
+		"rpc.discover": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoOpenRPC("create", "read", "update", "delete"), nil
+		},
+	},
+	"discover-missing-update": {
+		"rpc.discover": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoOpenRPC("create", "read", "delete"), nil
+		},
+	},
+	"discover-incompatible-read": {
+		"rpc.discover": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			document := fakeDenoOpenRPC("create", "read", "update", "delete")
+			document["methods"].([]map[string]any)[1]["params"] = []map[string]any{{
+				"name":   "params",
+				"schema": map[string]any{"type": "object", "required": []string{"id", "etag"}},
+			}}
+			return document, nil
+		},
+	},
+	"warming-up": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": fakeDenoHealthChecks.Add(1) > 2}, nil
+		},
+		"healthChecks": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoHealthChecks.Load(), nil
+		},
+	},
+	"never-healthy": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": false}, nil
+		},
+	},
+	"backend-down": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": true, "backend": map[string]any{"ok": false, "message": "401 Unauthorized"}}, nil
+		},
+	},
+	"closes-stdout": {
+		"closeStdout": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			_ = os.Stdout.Close()
+			return nil, nil
+		},
+	},
+	"health-warnings": {
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"ok": true, "warnings": []string{"config option foo is deprecated", "API token expires in 3 days"}}, nil
+		},
+	},
+	"crashy": {
+		"crashOnce": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			spawned, _ := os.ReadFile(os.Getenv(fakeDenoSpawnLogEnvVar))
+			if strings.Count(string(spawned), "\n") == 1 {
+				fmt.Fprintln(os.Stderr, "fatal: first process crashed")
+				os.Exit(3)
+			}
+			return os.Getpid(), nil
+		},
+		"crashOnceLater": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			// Gives concurrent calls time to arrive, so they all see the same crash
+			spawned, _ := os.ReadFile(os.Getenv(fakeDenoSpawnLogEnvVar))
+			if strings.Count(string(spawned), "\n") == 1 {
+				time.Sleep(300 * time.Millisecond)
+				fmt.Fprintln(os.Stderr, "fatal: first process crashed")
+				os.Exit(3)
+			}
+			return os.Getpid(), nil
+		},
+		"crash": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			fmt.Fprintln(os.Stderr, "fatal: out of memory")
+			os.Exit(3)
+			return nil, nil
+		},
+	},
+	"exits-early": {
+		"exit": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			os.Exit(0)
+			return nil, nil
+		},
+	},
+	"wedged": {
+		"wedge": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			fakeWedged.Store(true)
+			return nil, nil
+		},
+		"ping": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if fakeWedged.Load() {
+				select {}
+			}
+			return map[string]any{}, nil
+		},
+	},
+	"echo-read": {
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"props": map[string]any{}, "state": req.Params}, nil
+		},
+	},
+	"stream-read": {
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Props struct {
+					Whole bool `json:"whole"`
+					Fail  bool `json:"fail"`
+				} `json:"props"`
+				StreamID string `json:"streamId"`
+			}
+			_ = json.Unmarshal(*req.Params, &params)
+			if params.StreamID == "" || params.Props.Whole {
+				return map[string]any{"result": map[string]any{"items": []int{0, 1, 2, 3, 4}}}, nil
+			}
+			for i := range 5 {
+				_ = conn.Notify(ctx, "readChunk", map[string]any{"streamId": params.StreamID, "seq": i, "chunk": i})
+			}
+			if params.Props.Fail {
+				return map[string]any{"chunks": 5, "diagnostics": []map[string]any{
+					{"severity": "error", "summary": "listing failed", "detail": "page 2 expired"},
+				}}, nil
+			}
+			return map[string]any{"chunks": 5}, nil
+		},
+	},
+	"busy": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if fakeDenoDeleteAttempts.Add(1) <= 2 {
+				err := &jsonrpc2.Error{Code: CodeResourceBusy, Message: "resource has dependents"}
+				err.SetError(map[string]any{"retryAfterMs": 10})
+				return nil, err
+			}
+			return map[string]any{"done": true}, nil
+		},
+	},
+	"tampered": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			fakeDenoDeleteAttempts.Add(1)
+			return nil, &jsonrpc2.Error{Code: CodeManualIntervention, Message: "resource was modified outside of terraform"}
+		},
+		"deleteAttempts": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoDeleteAttempts.Load(), nil
+		},
+	},
+	"invalid-create": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			err := &jsonrpc2.Error{Code: CodeValidationFailed, Message: "invalid props"}
+			err.SetError([]map[string]any{
+				{"path": []string{"props", "name"}, "message": "name is already taken"},
+				{"path": []string{"props", "tags", "1"}, "message": "tags must be lowercase"},
+			})
+			return nil, err
+		},
+	},
+	"pending-create": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				PendingAsync bool `json:"pendingAsync"`
+			}
+			_ = json.Unmarshal(*req.Params, &params)
+			if !params.PendingAsync {
+				return map[string]any{"id": "123", "state": map[string]any{"ready": true}}, nil
+			}
+			return map[string]any{"id": "op-1", "pending": true}, nil
+		},
+		"createStatus": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			switch fakeDenoCreatePolls.Add(1) {
+			case 1:
+				return map[string]any{"status": "pending", "progress": map[string]any{"message": "creating...", "percent": 40}}, nil
+			case 2:
+				return map[string]any{"status": "pending", "progress": map[string]any{"message": "creating...", "percent": 80}}, nil
+			default:
+				return map[string]any{
+					"status":   "complete",
+					"progress": map[string]any{"message": "finalizing"},
+					"id":       "123",
+					"state":    map[string]any{"ready": true},
+				}, nil
+			}
+		},
+	},
+	"pending-create-poll-interval": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"id": "op-1", "pending": true, "pollIntervalMs": 100}, nil
+		},
+		"createStatus": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if fakeDenoCreatePolls.Add(1) == 1 {
+				return map[string]any{"status": "pending", "pollIntervalMs": 200}, nil
+			}
+			return map[string]any{"status": "complete", "id": "123"}, nil
+		},
+	},
+	"pending-create-fails": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"id": "op-1", "pending": true}, nil
+		},
+		"createStatus": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"status": "failed", "error": "quota exceeded"}, nil
+		},
+	},
+	"pending-create-forever": {
+		"create": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"id": "op-1", "pending": true}, nil
+		},
+		"createStatus": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"status": "pending"}, nil
+		},
+	},
+	"pending-delete": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID           string `json:"id"`
+				PendingAsync bool   `json:"pendingAsync"`
+			}
+			_ = json.Unmarshal(*req.Params, &params)
+			if !params.PendingAsync {
+				return map[string]any{"done": true}, nil
+			}
+			go func() {
+				for _, percent := range []int{40, 80} {
+					_ = conn.Notify(ctx, "deleteProgress", map[string]any{"id": params.ID, "message": "draining...", "percent": percent})
+				}
+				_ = conn.Notify(ctx, "deleteComplete", map[string]any{"id": params.ID, "done": true})
+			}()
+			return map[string]any{"pending": true}, nil
+		},
+	},
+	"pending-delete-fails": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				ID string `json:"id"`
+			}
+			_ = json.Unmarshal(*req.Params, &params)
+			go func() {
+				_ = conn.Notify(ctx, "deleteComplete", map[string]any{"id": params.ID, "error": "still has dependents"})
+			}()
+			return map[string]any{"pending": true}, nil
+		},
+	},
+	"pending-delete-forever": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"pending": true}, nil
+		},
+	},
+	"batch-delete": {
+		"deleteBatch": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Items []struct {
+					ID           string `json:"id"`
+					PendingAsync bool   `json:"pendingAsync"`
+				} `json:"items"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			results := make([]any, len(params.Items))
+			for i, item := range params.Items {
+				switch {
+				case item.ID == "pending" && item.PendingAsync:
+					go func() {
+						_ = conn.Notify(ctx, "deleteComplete", map[string]any{"id": item.ID, "done": true})
+					}()
+					results[i] = map[string]any{"pending": true}
+				case item.ID == "pending-fails" && item.PendingAsync:
+					go func() {
+						_ = conn.Notify(ctx, "deleteComplete", map[string]any{"id": item.ID, "error": "still has dependents"})
+					}()
+					results[i] = map[string]any{"pending": true}
+				case item.ID == "missing":
+					results[i] = map[string]any{"error": map[string]any{"code": 1, "message": "bucket not found"}}
+				case item.ID == "busy":
+					results[i] = map[string]any{"error": map[string]any{"code": CodeResourceBusy, "message": "resource has dependents"}}
+				default:
+					results[i] = map[string]any{"done": true}
+				}
+			}
+			return map[string]any{"results": results}, nil
+		},
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"done": true}, nil
+		},
+	},
+	"batch-read": {
+		"read": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				Props map[string]any `json:"props"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			if params.Props["fail"] == true {
+				return nil, &jsonrpc2.Error{Code: 1, Message: "lookup failed"}
+			}
+			// Answer out of order, the client must still correlate by id
+			if delay, ok := params.Props["delayMs"].(float64); ok {
+				time.Sleep(time.Duration(delay) * time.Millisecond)
+			}
+			return map[string]any{"result": params.Props}, nil
+		},
+		"batches": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return fakeDenoBatches.Load(), nil
+		},
+	},
+	"cancellable": {
+		"longRunning": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			fakeDenoLongRunningID.Store(&req.ID)
+			select {
+			case <-fakeDenoCancelled:
+				return nil, &jsonrpc2.Error{Code: 1, Message: "aborted"}
+			case <-time.After(10 * time.Second):
+				return "finished", nil
+			}
+		},
+		jsocket.CancelRequestMethod: func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params jsocket.CancelRequestParams
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				return nil, err
+			}
+			fakeDenoCancelledID.Store(&params.ID)
+			fakeDenoCancelOnce.Do(func() { close(fakeDenoCancelled) })
+			return nil, nil
+		},
+		"cancelled": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return map[string]any{"request": fakeDenoLongRunningID.Load(), "cancelled": fakeDenoCancelledID.Load()}, nil
+		},
+	},
+	"duplicate-response": {
+		"twice": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if err := conn.Reply(ctx, req.ID, "first"); err != nil {
+				return nil, err
+			}
+			return "second", nil
+		},
+	},
+	"busy-forever": {
+		"delete": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return nil, &jsonrpc2.Error{Code: CodeResourceBusy, Message: "resource has dependents"}
+		},
+	},
+}
+
+// fakeLargeState returns a large & highly compressible state blob.
+func fakeLargeState() map[string]any {
+	items := make([]any, 500)
+	for i := range items {
+		items[i] = map[string]any{"index": i, "name": fmt.Sprintf("item-%d", i), "enabled": i%2 == 0}
+	}
+	return map[string]any{"items": items}
+}
+
+// fakeDenoHealthChecks counts the health calls received by the fake Deno executable.
+var fakeDenoHealthChecks atomic.Int32
+
+// fakeDenoLogLevel is the log level last set on the fake Deno executable.
+var fakeDenoLogLevel atomic.Pointer[string]
+
+// fakeDenoDeleteAttempts counts the delete calls received by the fake Deno executable.
+var fakeDenoDeleteAttempts atomic.Int32
+
+// fakeDenoExported records the ids of the resources exported by the fake Deno executable.
+var fakeDenoExported sync.Map
+
+// fakeDenoFlakyCalls counts the calls received by the flaky methods of the fake Deno executable.
+var fakeDenoFlakyCalls atomic.Int32
+
+// fakeDenoIdempotencyKeys records the idempotency keys of every create received by the flaky scenario.
+var fakeDenoIdempotencyKeys struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+// fakeDenoOpenRPC returns an OpenRPC document listing the given methods, each taking the by-name params sent by the provider.
+func fakeDenoOpenRPC(methods ...string) map[string]any {
+	document := map[string]any{"openrpc": "1.3.2", "info": map[string]any{"title": "fake", "version": "1.0.0"}}
+	list := []map[string]any{}
+	for _, method := range methods {
+		list = append(list, map[string]any{
+			"name":   method,
+			"params": []map[string]any{{"name": "params", "schema": map[string]any{"type": "object", "required": []string{"id"}}}},
+		})
+	}
+	document["methods"] = list
+	return document
+}
+
+// fakeDenoCreatePolls counts the createStatus calls received by the fake Deno executable.
+var fakeDenoCreatePolls atomic.Int32
+
+// fakeDenoLongRunningID is the id of the last longRunning call received by the fake Deno executable.
+var fakeDenoLongRunningID atomic.Pointer[jsonrpc2.ID]
+
+// fakeDenoCancelledID is the id of the last request cancelled with $/cancelRequest.
+var fakeDenoCancelledID atomic.Pointer[jsonrpc2.ID]
+
+// fakeDenoCancelled is closed when the fake Deno executable receives its first $/cancelRequest.
+var (
+	fakeDenoCancelled  = make(chan struct{})
+	fakeDenoCancelOnce sync.Once
+)
+
+// fakeDenoBatches counts the JSON-RPC batches received by the fake Deno executable.
+var fakeDenoBatches atomic.Int32
+
+// TestMain lets the test binary double as a fake Deno executable so the DenoClient
+// can be exercised end to end without a real Deno runtime.
+func TestMain(m *testing.M) {
+	if scenario, ok := os.LookupEnv(fakeDenoEnvVar); ok {
+		if len(os.Args) > 1 && os.Args[1] == "--version" {
+			printFakeDenoVersion()
+			return
+		}
+		runFakeDeno(scenario)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// fakeDenoVersion is the version the fake Deno executable reports.
+const fakeDenoVersion = "2.1.4"
+
+// printFakeDenoVersion mimics `deno --version`, recording the call in the spawn log if set.
+func printFakeDenoVersion() {
+	if spawnLog := os.Getenv(fakeDenoSpawnLogEnvVar); spawnLog != "" {
+		f, err := os.OpenFile(spawnLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			os.Exit(1)
+		}
+		_, _ = fmt.Fprintln(f, "--version")
+		_ = f.Close()
+	}
+	fmt.Printf("deno %s (stable, release, x86_64-unknown-linux-gnu)\nv8 13.0.245.12-rusty\ntypescript 5.6.2\n", fakeDenoVersion)
+}
+
+// runFakeDeno serves the methods of the given scenario over stdin/stdout until
+// the shutdown notification is received or stdin is closed.
+func runFakeDeno(scenario string) {
+	// Like Deno, report writes to a closed stdout as errors rather than dying from SIGPIPE,
+	// which otherwise happens when a response races with the client closing the socket.
+	if scenario != "sigpipe-after-shutdown" {
+		signal.Ignore(syscall.SIGPIPE)
+	}
+
+	var handshake json.RawMessage
+	methods := map[string]fakeDenoMethod{
+		"health": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var offered HealthRequest
+			if req.Params != nil {
+				handshake = *req.Params
+				_ = json.Unmarshal(handshake, &offered)
+			}
+			// Agree to every offered feature, unless the scenario says otherwise
+			if features := os.Getenv(fakeDenoFeaturesEnvVar); features != "" {
+				offered.Features = nil
+				for _, feature := range strings.Split(features, ",") {
+					if feature != "none" {
+						offered.Features = append(offered.Features, Feature(feature))
+					}
+				}
+			}
+			return map[string]any{"ok": true, "features": offered.Features}, nil
+		},
+		"handshake": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return handshake, nil
+		},
+		"pid": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return os.Getpid(), nil
+		},
+		"args": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return os.Args[1:], nil
+		},
+		"environ": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return os.Environ(), nil
+		},
+		"cwd": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return os.Getwd()
+		},
+		"requestID": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return req.ID, nil
+		},
+		"echo": func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			// Answer after a delay that varies with the params, so responses overtake each other
+			var params struct {
+				N int `json:"n"`
+			}
+			if req.Params != nil {
+				_ = json.Unmarshal(*req.Params, &params)
+			}
+			time.Sleep(time.Duration(params.N*7919%5) * time.Millisecond)
+			return req.Params, nil
+		},
+	}
+	if spawnLog := os.Getenv(fakeDenoSpawnLogEnvVar); spawnLog != "" {
+		f, err := os.OpenFile(spawnLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			os.Exit(1)
+		}
+		_, _ = fmt.Fprintln(f, os.Getpid())
+		_ = f.Close()
+	}
+
+	// A script that throws while loading never serves the health method
+	if scenario == "throws-on-load" {
+		_, _ = fmt.Fprintln(os.Stderr, "error: Uncaught (in promise) TypeError: boom")
+		_, _ = fmt.Fprintln(os.Stderr, "    at file:///fake.ts:3:9")
+		os.Exit(1)
+	}
+
+	if scenario == "module-not-found" {
+		_, _ = fmt.Fprintln(os.Stderr, `error: Module not found "https://example.com/missing.ts".`)
+		os.Exit(1)
+	}
+
+	// Deno checks the lockfile before running the script at all
+	if scenario == "lockfile-mismatch" {
+		_, _ = fmt.Fprintln(os.Stderr, "error: Integrity check failed for remote specifier.")
+		_, _ = fmt.Fprintln(os.Stderr, "The source code is invalid, as it does not match the expected hash in the lock file.")
+		os.Exit(10)
+	}
+
+	for name, method := range fakeDenoScenarios[scenario] {
+		methods[name] = method
+	}
+
+	// Stubborn scenarios ignore the shutdown notification and stdin closing, so the
+	// client has to escalate to signals. The very stubborn one ignores SIGTERM too.
+	stubborn := strings.HasPrefix(scenario, "stubborn")
+	if scenario == "stubborn-ignores-sigterm" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+
+	var stdout io.Writer = os.Stdout
+	if scenario == "slow-flush" {
+		stdout = &slowFlushWriter{os.Stdout}
+	}
+	var stdin io.Reader = os.Stdin
+	if scenario == "batch-read" {
+		stdin = &batchCountingReader{Reader: os.Stdin, lineStart: true}
+	}
+	stdio := &struct {
+		io.Reader
+		io.Writer
+		io.Closer
+	}{stdin, stdout, os.Stdin}
+
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsocket.NewBatchStream(jsonrpc2.NewPlainObjectStream(stdio)),
+		jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if req.Method == "shutdown" {
+				if scenario == "slow-exit-after-shutdown" {
+					// Like a script flushing buffers on its way out, after closing stdout
+					_ = os.Stdout.Close()
+					time.Sleep(crashDetectionGrace + 500*time.Millisecond)
+					os.Exit(0)
+				}
+				if scenario == "sigpipe-after-shutdown" {
+					// Like a runtime that dies from SIGPIPE, still writing to stdout after the client closed the socket
+					for {
+						_, _ = os.Stdout.Write([]byte("{}\n"))
+						time.Sleep(time.Millisecond)
+					}
+				}
+				if scenario == "dies-on-shutdown" {
+					_, _ = fmt.Fprintln(os.Stderr, "error: failed to flush the write buffer")
+					os.Exit(3)
+				}
+				if stubborn {
+					return nil, nil
+				}
+				os.Exit(0)
+			}
+			method, ok := methods[req.Method]
+			if !ok {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "Method not found"}
+			}
+			return method(ctx, conn, req)
+		})),
+	)
+
+	<-conn.DisconnectNotify()
+	if stubborn {
+		time.Sleep(time.Hour)
+	}
+	os.Exit(0)
+}
+
+// slowFlushWriter mimics a script that does not flush promptly, the responses of the
+// "buffered" method are written in two halves with a pause in between.
+type slowFlushWriter struct {
+	io.Writer
+}
+
+func (w *slowFlushWriter) Write(p []byte) (int, error) {
+	if !bytes.Contains(p, []byte(`"buffered"`)) {
+		return w.Writer.Write(p)
+	}
+	half := len(p) / 2
+	n, err := w.Writer.Write(p[:half])
+	if err != nil {
+		return n, err
+	}
+	time.Sleep(200 * time.Millisecond)
+	m, err := w.Writer.Write(p[half:])
+	return n + m, err
+}
+
+// batchCountingReader counts the lines read that are JSON-RPC batches, ie: arrays.
+type batchCountingReader struct {
+	io.Reader
+	lineStart bool
+}
+
+func (r *batchCountingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	for _, b := range p[:n] {
+		if r.lineStart && b == '[' {
+			fakeDenoBatches.Add(1)
+		}
+		r.lineStart = b == '\n'
+	}
+	return n, err
+}
+
+// newFakeDenoClient returns a DenoClient that launches the test binary as a fake
+// Deno executable running the given scenario.
+func newFakeDenoClient(t *testing.T, scenario string, opts ...DenoClientOption) *DenoClient {
+	t.Helper()
+	t.Setenv(fakeDenoEnvVar, scenario)
+	// Under -race the fake would otherwise sleep for a second on exit, waiting for late race reports
+	t.Setenv("GORACE", "atexit_sleep_ms=0")
+
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	return NewDenoClient(bin, "fake.ts", "/dev/null", nil, nil, opts...)
+}
+
+func TestDenoClient_ReusePolicyRestart(t *testing.T) {
+	c := newFakeDenoClient(t, "poison")
+	c.ReusePolicy = ReusePolicyRestart
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+
+	err := c.Call(t.Context(), "corrupt", nil, nil)
+	assert.Error(t, err)
+
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.NotEqual(t, firstPid, secondPid)
+	assert.Equal(t, 1, c.Summary().Restarts)
+}
+
+func TestDenoClient_ReusePolicyError(t *testing.T) {
+	c := newFakeDenoClient(t, "poison")
+	assert.NoError(t, c.Start(t.Context()))
+	defer c.kill()
+
+	err := c.Call(t.Context(), "corrupt", nil, nil)
+	assert.Error(t, err)
+
+	err = c.Call(t.Context(), "pid", nil, nil)
+	assert.IsError(t, err, ErrProcessPoisoned)
+}
+
+func TestDenoClient_OrdinaryErrorKeepsProcess(t *testing.T) {
+	c := newFakeDenoClient(t, "poison")
+	c.ReusePolicy = ReusePolicyRestart
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+
+	err := c.Call(t.Context(), "fail", nil, nil)
+	assert.Error(t, err)
+
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.Equal(t, firstPid, secondPid)
+}
+
+func TestDenoClient_PermissionResolver(t *testing.T) {
+	type envKey struct{}
+
+	resolver := func(ctx context.Context) (*Permissions, error) {
+		if ctx.Value(envKey{}) == "prod" {
+			return &Permissions{Allow: []string{"write=/var/app"}}, nil
+		}
+		return &Permissions{Allow: []string{"read", "write"}}, nil
+	}
+
+	for env, expected := range map[string][]string{
+		"prod": {"--allow-write=/var/app"},
+		"dev":  {"--allow-read", "--allow-write"},
+	} {
+		t.Run(env, func(t *testing.T) {
+			c := newFakeDenoClient(t, "default")
+			c.permissions = &Permissions{All: true}
+			c.PermissionResolver = resolver
+
+			ctx := context.WithValue(t.Context(), envKey{}, env)
+			assert.NoError(t, c.Start(ctx))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			var args []string
+			assert.NoError(t, c.Call(ctx, "args", nil, &args))
+			assert.NotSliceContains(t, args, "--allow-all")
+			for _, flag := range expected {
+				assert.SliceContains(t, args, flag)
+			}
+		})
+	}
+}
+
+func TestDenoClient_HandshakeIncludesPermissions(t *testing.T) {
+	for name, tc := range map[string]struct {
+		permissions *Permissions
+		expected    Permissions
+	}{
+		"none": {
+			permissions: nil,
+			expected:    Permissions{Allow: []string{}, Deny: []string{}},
+		},
+		"restricted": {
+			permissions: &Permissions{Allow: []string{"read", "net=example.com"}, Deny: []string{"ffi"}},
+			expected:    Permissions{Allow: []string{"read", "net=example.com"}, Deny: []string{"ffi"}},
+		},
+		"all": {
+			permissions: &Permissions{All: true},
+			expected:    Permissions{All: true, Allow: []string{}, Deny: []string{}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeDenoClient(t, "default")
+			c.permissions = tc.permissions
+			assert.NoError(t, c.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			var handshake HealthRequest
+			assert.NoError(t, c.Call(t.Context(), "handshake", nil, &handshake))
+			assert.Equal(t, tc.expected, handshake.Permissions)
+		})
+	}
+}
+
+func TestDenoClient_CPUHint(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     []DenoClientOption
+		expected int
+	}{
+		"default":    {expected: runtime.NumCPU()},
+		"configured": {opts: []DenoClientOption{WithCPUHint(2)}, expected: 2},
+		"omitted":    {opts: []DenoClientOption{WithCPUHint(0)}, expected: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeDenoClient(t, "default", tc.opts...)
+			assert.NoError(t, c.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			var handshake HealthRequest
+			assert.NoError(t, c.Call(t.Context(), "handshake", nil, &handshake))
+			assert.Equal(t, tc.expected, handshake.CPUHint)
+		})
+	}
+}
+
+func TestDenoClient_EffectivePermissions(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.permissions = &Permissions{All: true}
+	c.PermissionResolver = func(ctx context.Context) (*Permissions, error) {
+		return &Permissions{Allow: []string{"net=api.example.com"}, Deny: []string{"env"}}, nil
+	}
+	assert.Zero(t, c.EffectivePermissions())
+
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// The resolved permissions, not the configured ones
+	expected := &Permissions{Allow: []string{"net=api.example.com"}, Deny: []string{"env"}}
+	assert.Equal(t, expected, c.EffectivePermissions())
+
+	tf := c.EffectivePermissions().MapToDenoPermissionsTF()
+	assert.False(t, tf.All.ValueBool())
+	assert.Equal(t, 1, len(tf.Allow.Elements()))
+}
+
+func TestDenoClient_PermissionResolverError(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.PermissionResolver = func(ctx context.Context) (*Permissions, error) {
+		return nil, errors.New("policy engine unavailable")
+	}
+
+	err := c.Start(t.Context())
+	assert.EqualError(t, err, "failed to resolve deno permissions: policy engine unavailable")
+}
+
+func TestDenoClient_InvalidPermissions(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.permissions = &Permissions{Allow: []string{"nett", "read"}, Deny: []string{"ruin"}}
+
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrInvalidPermission)
+	assert.Contains(t, err.Error(), `allow "nett"`)
+	assert.Contains(t, err.Error(), `deny "ruin"`)
+	assert.Equal(t, -1, c.PID())
+}
+
+func TestDenoClient_PermissionChangeRestart(t *testing.T) {
+	var mu sync.Mutex
+	allow := []string{"read", "env"}
+	c := newFakeDenoClient(t, "default", WithPermissionChangePolicy(PermissionChangePolicyRestart))
+	c.PermissionResolver = func(ctx context.Context) (*Permissions, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &Permissions{Allow: slices.Clone(allow)}, nil
+	}
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+
+	// The same permissions in another order are not a change
+	mu.Lock()
+	allow = []string{"env", "read"}
+	mu.Unlock()
+	var samePid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &samePid))
+	assert.Equal(t, firstPid, samePid)
+
+	mu.Lock()
+	allow = []string{"read", "env", "net"}
+	mu.Unlock()
+
+	// The process is recycled before the next call, which sees the new permissions
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.NotEqual(t, firstPid, secondPid)
+	assert.Equal(t, []string{"read", "env", "net"}, c.EffectivePermissions().Allow)
+
+	var args []string
+	assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+	assert.SliceContains(t, args, "--allow-net")
+	assert.Equal(t, 1, c.Summary().Restarts)
+}
+
+func TestDenoClient_PermissionChangeSetPermissions(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithPermissionChangePolicy(PermissionChangePolicyRestart))
+	c.permissions = &Permissions{Allow: []string{"read"}}
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+
+	c.SetPermissions(&Permissions{All: true})
+
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.NotEqual(t, firstPid, secondPid)
+	assert.True(t, c.EffectivePermissions().All)
+}
+
+func TestDenoClient_PermissionChangeIgnore(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.permissions = &Permissions{Allow: []string{"read"}}
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+
+	c.SetPermissions(&Permissions{All: true})
+
+	// The running process keeps its old permissions
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.Equal(t, firstPid, secondPid)
+	assert.False(t, c.EffectivePermissions().All)
+}
+
+func TestDenoClient_Summary(t *testing.T) {
+	c := newFakeDenoClient(t, "poison")
+	assert.NoError(t, c.Start(t.Context()))
+
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, nil))
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, nil))
+	assert.Error(t, c.Call(t.Context(), "fail", nil, nil))
+	assert.NoError(t, c.Stop())
+
+	summary := c.Summary()
+	assert.Equal(t, map[string]int{"pid": 2, "fail": 1}, summary.Calls)
+	assert.Equal(t, 1, summary.Errors)
+	assert.Equal(t, 0, summary.Restarts)
+	assert.True(t, summary.Duration > 0)
+	assert.True(t, summary.Duration >= summary.CallDuration)
+	assert.True(t, summary.BytesSent > 0)
+	assert.True(t, summary.BytesReceived > 0)
+
+	// The summary is frozen once the client has been stopped
+	assert.Equal(t, summary.Duration, c.Summary().Duration)
+
+	data, err := json.Marshal(summary)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"calls":{"fail":1,"pid":2}`)
+}
+
+func TestDenoClient_ConfigRoundTrip(t *testing.T) {
+	original := newFakeDenoClient(t, "default")
+	original.permissions = &Permissions{Allow: []string{"read"}, Deny: []string{"net"}}
+	original.ReusePolicy = ReusePolicyRestart
+	original.PermissionChangePolicy = PermissionChangePolicyRestart
+	original.CallTimeout = time.Minute
+	original.MethodTimeouts = map[string]time.Duration{"create": time.Hour}
+	original.HealthRetryPolicy = &HealthRetryPolicy{MaxAttempts: 3, Backoff: time.Second, FailOnNotOk: true}
+	original.ConfigResolution = ConfigResolutionCwdRelative
+	original.ConfigPrecedence = ConfigPrecedenceStrict
+	original.DrainTimeout = time.Second
+	original.ConfigSearchPaths = []string{"/opt/tooling"}
+	original.ScriptIntegrity = "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	original.HeartbeatInterval = time.Minute
+	original.HeartbeatTimeout = 5 * time.Second
+	original.MaxMessageBytes = 1 << 20
+
+	data, err := json.Marshal(original.Config())
+	assert.NoError(t, err)
+
+	var config ClientConfig
+	assert.NoError(t, json.Unmarshal(data, &config))
+	assert.Equal(t, original.Config(), config)
+
+	c := NewDenoClientFromConfig(config)
+	assert.Equal(t, original.Config(), c.Config())
+
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var args []string
+	assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+	assert.SliceContains(t, args, "--allow-read")
+	assert.SliceContains(t, args, "--deny-net")
+}
+
+func TestDenoClient_ConfigIsASnapshot(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.permissions = &Permissions{Allow: []string{"read"}}
+
+	config := c.Config()
+	config.Permissions.Allow[0] = "write"
+	assert.Equal(t, []string{"read"}, c.permissions.Allow)
+}
+
+func TestDenoClient_MethodTimeouts(t *testing.T) {
+	c := newFakeDenoClient(t, "slow")
+	c.CallTimeout = 20 * time.Millisecond
+	c.MethodTimeouts = map[string]time.Duration{"create": 5 * time.Second}
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	err := c.Call(t.Context(), "read", nil, nil)
+	assert.IsError(t, err, context.DeadlineExceeded)
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	assert.NoError(t, c.Call(t.Context(), "create", nil, &created))
+	assert.Equal(t, "123", created.ID)
+}
+
+func TestDenoClient_SocketDefaultCallTimeout(t *testing.T) {
+	c := newFakeDenoClient(t, "slow")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+	c.Socket.DefaultCallTimeout = 20 * time.Millisecond
+
+	err := c.Socket.Call(context.Background(), "read", nil, nil)
+	assert.IsError(t, err, jsocket.ErrCallTimeout)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "read did not respond within 20ms")
+
+	// A deadline set by the caller wins over the default
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var created struct {
+		ID string `json:"id"`
+	}
+	assert.NoError(t, c.Socket.Call(ctx, "create", nil, &created))
+	assert.Equal(t, "123", created.ID)
+}
+
+func TestDenoClient_SetLogLevel(t *testing.T) {
+	c := newFakeDenoClient(t, "log-level")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	assert.Equal(t, LogLevelDebug, c.LogLevel())
+	assert.NoError(t, c.SetLogLevel(t.Context(), LogLevelTrace))
+	assert.Equal(t, LogLevelTrace, c.LogLevel())
+
+	var level string
+	assert.NoError(t, c.Call(t.Context(), "logLevel", nil, &level))
+	assert.Equal(t, LogLevelTrace, level)
+}
+
+func TestDenoClient_SetLogLevelWithoutScriptSupport(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	assert.NoError(t, c.SetLogLevel(t.Context(), LogLevelWarn))
+	assert.Equal(t, LogLevelWarn, c.LogLevel())
+}
+
+func TestDenoClient_SetLogLevelUnknown(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.EqualError(t, c.SetLogLevel(t.Context(), "verbose"), `unknown log level "verbose"`)
+	assert.Equal(t, LogLevelDebug, c.LogLevel())
+}
+
+func TestDenoClient_PipeToLogHonorsLogLevel(t *testing.T) {
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	var buf strings.Builder
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := newFakeDenoClient(t, "default")
+	c.pipeToLog(t.Context(), strings.NewReader("first\n"), "[deno stderr] ", &lineRing{})
+
+	level := LogLevelError
+	c.logLevel.Store(&level)
+	c.pipeToLog(t.Context(), strings.NewReader("second\n"), "[deno stderr] ", &lineRing{})
+
+	level = LogLevelTrace
+	c.logLevel.Store(&level)
+	c.pipeToLog(t.Context(), strings.NewReader("third\n"), "[deno stderr] ", &lineRing{})
+
+	assert.Contains(t, buf.String(), "[DEBUG] [deno stderr] first")
+	assert.NotContains(t, buf.String(), "second")
+	assert.Contains(t, buf.String(), "[TRACE] [deno stderr] third")
+}
+
+func TestDenoClient_ConcurrentStartSpawnsOneProcess(t *testing.T) {
+	spawnLog := filepath.Join(t.TempDir(), "spawn.log")
+	t.Setenv(fakeDenoSpawnLogEnvVar, spawnLog)
+
+	c := newFakeDenoClient(t, "default")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			assert.NoError(t, c.Start(t.Context()))
+		})
+	}
+	wg.Wait()
+
+	var pid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+	assert.NoError(t, c.Stop())
+
+	spawned, err := os.ReadFile(spawnLog)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d\n", pid), string(spawned))
+}
+
+func TestDenoClient_StartAfterStopSpawnsNewProcess(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+
+	var firstPid, secondPid int
+	assert.NoError(t, c.Start(t.Context()))
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+	assert.NoError(t, c.Stop())
+
+	assert.NoError(t, c.Start(t.Context()))
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.NoError(t, c.Stop())
+
+	assert.NotEqual(t, firstPid, secondPid)
+}
+
+func TestDenoClient_StartPollsHealthUntilReady(t *testing.T) {
+	c := newFakeDenoClient(t, "slow-boot")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var checks int
+	assert.NoError(t, c.Call(t.Context(), "healthChecks", nil, &checks))
+	assert.Equal(t, 4, checks)
+}
+
+func TestDenoClient_HealthRetryPolicy(t *testing.T) {
+	t.Run("retries not ok", func(t *testing.T) {
+		c := newFakeDenoClient(t, "warming-up", WithHealthRetryPolicy(HealthRetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     10 * time.Millisecond,
+		}))
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+
+		var checks int
+		assert.NoError(t, c.Call(t.Context(), "healthChecks", nil, &checks))
+		assert.Equal(t, 3, checks)
+	})
+
+	t.Run("fails fast on not ok", func(t *testing.T) {
+		c := newFakeDenoClient(t, "warming-up", WithHealthRetryPolicy(HealthRetryPolicy{FailOnNotOk: true}))
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrNotHealthy)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		c := newFakeDenoClient(t, "warming-up", WithHealthRetryPolicy(HealthRetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     10 * time.Millisecond,
+		}))
+		err := c.Start(t.Context())
+		assert.EqualError(t, err, "deno script fake.ts did not become healthy after 2 attempts")
+	})
+}
+
+func TestHealthRetryPolicy_Delay(t *testing.T) {
+	var none *HealthRetryPolicy
+	assert.Equal(t, healthPollInterval, none.delay(3))
+
+	policy := &HealthRetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.delay(2))
+	assert.Equal(t, 800*time.Millisecond, policy.delay(4))
+	assert.Equal(t, time.Second, policy.delay(5))
+	assert.Equal(t, time.Second, policy.delay(100))
+
+	uncapped := &HealthRetryPolicy{Backoff: time.Second}
+	assert.True(t, uncapped.delay(100) > 0)
+}
+
+func TestDenoClient_Discover(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		c := newFakeDenoClient(t, "discover", withContract(resourceContract))
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+
+	t.Run("not implemented", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default", withContract(resourceContract))
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+
+	t.Run("missing method", func(t *testing.T) {
+		c := newFakeDenoClient(t, "discover-missing-update", withContract(resourceContract))
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrScriptContract)
+		assert.Contains(t, err.Error(), "resource script missing required method 'update'")
+	})
+
+	t.Run("incompatible params", func(t *testing.T) {
+		c := newFakeDenoClient(t, "discover-incompatible-read", withContract(resourceContract))
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrScriptContract)
+		assert.Contains(t, err.Error(), "resource script method 'read' requires the param 'etag', which the provider does not send")
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		c := newFakeDenoClient(t, "discover-missing-update", withContract(resourceContract), WithoutDiscovery())
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+}
+
+func TestDenoClient_StartupTimeout(t *testing.T) {
+	assert.Equal(t, DefaultStartupTimeout, newFakeDenoClient(t, "default").StartupTimeout)
+
+	c := newFakeDenoClient(t, "never-healthy", WithStartupTimeout(600*time.Millisecond))
+	began := time.Now()
+	err := c.Start(t.Context())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "deno script fake.ts did not become healthy within")
+	assert.Contains(t, err.Error(), "the process is still running but did not answer, a missing permission may be the cause")
+	assert.True(t, time.Since(began) < 5*time.Second)
+}
+
+func TestDenoClient_NoPrompt(t *testing.T) {
+	for _, prompt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prompt=%t", prompt), func(t *testing.T) {
+			c := newFakeDenoClient(t, "default")
+			c.PermissionPrompts = prompt
+			assert.NoError(t, c.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			var args []string
+			assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+			assert.Equal(t, !prompt, slices.Contains(args, "--no-prompt"))
+		})
+	}
+}
+
+func TestDenoClient_StartupAbortedByCaller(t *testing.T) {
+	c := newFakeDenoClient(t, "never-healthy", WithStartupTimeout(time.Minute))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	err := c.Start(ctx)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(began) < 5*time.Second)
+}
+
+func TestDenoClient_HealthWarnings(t *testing.T) {
+	c := newFakeDenoClient(t, "health-warnings")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	assert.Equal(t, []string{"config option foo is deprecated", "API token expires in 3 days"}, c.HealthWarnings())
+}
+
+func TestDenoClient_RequireBackendHealthy(t *testing.T) {
+	c := newFakeDenoClient(t, "backend-down")
+	c.RequireBackendHealthy = true
+
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrBackendUnhealthy)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+}
+
+func TestDenoClient_RequireBackendHealthyNotReported(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.RequireBackendHealthy = true
+
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrBackendUnhealthy)
+	assert.Contains(t, err.Error(), "the script does not report backend health")
+}
+
+func TestDenoClient_BackendUnhealthyIsNotRequiredByDefault(t *testing.T) {
+	c := newFakeDenoClient(t, "backend-down")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+}
+
+func TestDenoClient_Env(t *testing.T) {
+	t.Setenv("DENOBRIDGE_INHERITED", "inherited")
+	t.Setenv("DENOBRIDGE_OVERRIDDEN", "inherited")
+
+	c := newFakeDenoClient(t, "default", WithEnv(map[string]string{
+		"DENOBRIDGE_OVERRIDDEN": "overridden",
+		"DENOBRIDGE_SECRET":     "hunter2",
+	}))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var environ []string
+	assert.NoError(t, c.Call(t.Context(), "environ", nil, &environ))
+	assert.SliceContains(t, environ, "DENOBRIDGE_INHERITED=inherited")
+	assert.SliceContains(t, environ, "DENOBRIDGE_OVERRIDDEN=overridden")
+	assert.SliceContains(t, environ, "DENOBRIDGE_SECRET=hunter2")
+	assert.NotContains(t, strings.Join(environ, "\n"), "DENOBRIDGE_OVERRIDDEN=inherited")
+}
+
+func TestDenoClient_ClearEnv(t *testing.T) {
+	t.Setenv("DENOBRIDGE_INHERITED", "inherited")
+
+	c := newFakeDenoClient(t, "default", WithClearEnv(), WithEnv(map[string]string{
+		fakeDenoEnvVar: "default",
+	}))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var environ []string
+	assert.NoError(t, c.Call(t.Context(), "environ", nil, &environ))
+	assert.Equal(t, []string{fakeDenoEnvVar + "=default"}, environ)
+}
+
+func TestDenoClient_WorkingDirDefaultsToScriptDir(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	c := NewDenoClient(bin, filepath.Join(dir, "main.ts"), "/dev/null", nil, nil)
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var cwd string
+	assert.NoError(t, c.Call(t.Context(), "cwd", nil, &cwd))
+	assert.Equal(t, dir, cwd)
+}
+
+func TestDenoClient_WorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	c := newFakeDenoClient(t, "default", WithWorkingDir(dir))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var cwd string
+	assert.NoError(t, c.Call(t.Context(), "cwd", nil, &cwd))
+	assert.Equal(t, dir, cwd)
+}
+
+func TestDenoClient_WorkingDirMissing(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithWorkingDir(filepath.Join(t.TempDir(), "missing")))
+	err := c.Start(t.Context())
+	assert.IsError(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), "invalid working directory for deno script fake.ts")
+}
+
+func TestDenoClient_ScriptDirMissing(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	c.scriptPath = filepath.Join(t.TempDir(), "missing", "main.ts")
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrScriptNotFound)
+	assert.IsError(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), c.scriptPath)
+}
+
+func TestDenoClient_ForwardEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "ap-southeast-2")
+	t.Setenv("DENOBRIDGE_SECRET", "inherited")
+	t.Setenv("DENOBRIDGE_OVERRIDDEN", "inherited")
+
+	c := newFakeDenoClient(t, "default",
+		WithForwardEnv(fakeDenoEnvVar, "AWS_REGION", "DENOBRIDGE_OVERRIDDEN", "DENOBRIDGE_UNSET"),
+		WithEnv(map[string]string{"DENOBRIDGE_OVERRIDDEN": "explicit"}),
+	)
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var environ []string
+	assert.NoError(t, c.Call(t.Context(), "environ", nil, &environ))
+	assert.Equal(t, []string{
+		fakeDenoEnvVar + "=default",
+		"AWS_REGION=ap-southeast-2",
+		"DENOBRIDGE_OVERRIDDEN=explicit",
+	}, environ)
+}
+
+func TestDenoClient_MinDenoVersion(t *testing.T) {
+	spawnLog := filepath.Join(t.TempDir(), "spawn.log")
+	t.Setenv(fakeDenoSpawnLogEnvVar, spawnLog)
+
+	c := newFakeDenoClient(t, "default", WithMinDenoVersion("2.0.0"))
+	for range 2 {
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	}
+
+	// The detected version is cached across restarts
+	spawned, err := os.ReadFile(spawnLog)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(spawned), "--version"))
+}
+
+func TestDenoClient_MinDenoVersionTooOld(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithMinDenoVersion("2.2.0"))
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrDenoVersionTooOld)
+	assert.Contains(t, err.Error(), "detected deno 2.1.4")
+	assert.Contains(t, err.Error(), "at least 2.2.0 is required")
+}
+
+func TestDenoClient_MinDenoVersionInvalid(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithMinDenoVersion("latest"))
+	assert.Error(t, c.Start(t.Context()))
+}
+
+func TestDenoClient_ImportMap(t *testing.T) {
+	dir := t.TempDir()
+	importMap := filepath.Join(dir, "import_map.json")
+	assert.NoError(t, os.WriteFile(importMap, []byte(`{"imports":{}}`), 0o600))
+
+	for _, given := range []string{"import_map.json", importMap, "file://" + filepath.ToSlash(importMap)} {
+		c := newFakeDenoClient(t, "default", WithWorkingDir(dir), WithImportMap(given))
+		assert.NoError(t, c.Start(t.Context()))
+
+		var args []string
+		assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+		assert.NoError(t, c.Stop())
+
+		// The import map must come before the script
+		assert.Equal(t, "--import-map="+importMap, args[len(args)-2])
+	}
+}
+
+func TestDenoClient_ImportMapMissing(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithWorkingDir(t.TempDir()), WithImportMap("import_map.json"))
+	err := c.Start(t.Context())
+	assert.IsError(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), "invalid import map for deno script fake.ts")
+}
+
+func TestDenoClient_LockFile(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "deno.lock")
+	c := newFakeDenoClient(t, "default", WithLockFile(lockFile, true))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var args []string
+	assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+	assert.SliceContains(t, args, "--lock="+lockFile)
+	assert.SliceContains(t, args, "--frozen")
+}
+
+func TestDenoClient_LockFileNextToConfig(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "deno.json")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`{}`), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "deno.lock"), []byte(`{}`), 0o600))
+
+	c := NewDenoClient(bin, "fake.ts", configPath, nil, nil)
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var args []string
+	assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+	assert.SliceContains(t, args, "--lock="+filepath.Join(dir, "deno.lock"))
+	assert.NotContains(t, strings.Join(args, " "), "--frozen")
+}
+
+func TestDenoClient_ConfigResolution(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	// The script has a config in its own directory, the working directory has one a level up
+	root := t.TempDir()
+	scriptDir := filepath.Join(root, "scripts")
+	workingDir := filepath.Join(root, "work", "nested")
+	assert.NoError(t, os.MkdirAll(scriptDir, 0o700))
+	assert.NoError(t, os.MkdirAll(workingDir, 0o700))
+	scriptConfig := filepath.Join(scriptDir, "deno.json")
+	cwdConfig := filepath.Join(root, "work", "deno.jsonc")
+	assert.NoError(t, os.WriteFile(scriptConfig, []byte(`{}`), 0o600))
+	assert.NoError(t, os.WriteFile(cwdConfig, []byte(`{}`), 0o600))
+	scriptPath := filepath.Join(scriptDir, "main.ts")
+
+	tests := []struct {
+		name     string
+		opts     []DenoClientOption
+		expected string
+	}{
+		{"default", []DenoClientOption{WithWorkingDir(workingDir)}, scriptConfig},
+		{"script-relative", []DenoClientOption{WithWorkingDir(workingDir), WithConfigResolution(ConfigResolutionScriptRelative)}, scriptConfig},
+		{"cwd-relative", []DenoClientOption{WithWorkingDir(workingDir), WithConfigResolution(ConfigResolutionCwdRelative)}, cwdConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewDenoClient(bin, scriptPath, "", nil, nil, tt.opts...)
+			assert.Equal(t, tt.expected, c.Config().ConfigPath)
+
+			assert.NoError(t, c.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			var args []string
+			assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+			i := slices.Index(args, "-c")
+			assert.True(t, i >= 0 && i+1 < len(args))
+			assert.Equal(t, tt.expected, args[i+1])
+		})
+	}
+
+	t.Run("cwd-relative without a working dir", func(t *testing.T) {
+		t.Chdir(workingDir)
+		c := NewDenoClient(bin, scriptPath, "", nil, nil, WithConfigResolution(ConfigResolutionCwdRelative))
+		assert.Equal(t, cwdConfig, c.Config().ConfigPath)
+	})
+
+	t.Run("an explicit config path wins", func(t *testing.T) {
+		c := NewDenoClient(bin, scriptPath, scriptConfig, nil, nil,
+			WithWorkingDir(workingDir), WithConfigResolution(ConfigResolutionCwdRelative))
+		assert.Equal(t, scriptConfig, c.Config().ConfigPath)
+	})
+}
+
+func TestConfigResolution_String(t *testing.T) {
+	assert.Equal(t, "script-relative", ConfigResolutionScriptRelative.String())
+	assert.Equal(t, "cwd-relative", ConfigResolutionCwdRelative.String())
+	assert.Equal(t, "ConfigResolution(7)", ConfigResolution(7).String())
+}
+
+func TestDenoClient_ConfigPrecedence(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	// The script directory has both configs, its parent has one that must never be used
+	root := t.TempDir()
+	scriptDir := filepath.Join(root, "scripts")
+	assert.NoError(t, os.MkdirAll(scriptDir, 0o700))
+	jsonConfig := filepath.Join(scriptDir, "deno.json")
+	jsoncConfig := filepath.Join(scriptDir, "deno.jsonc")
+	for _, path := range []string{jsonConfig, jsoncConfig, filepath.Join(root, "deno.json")} {
+		assert.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+	}
+	scriptPath := filepath.Join(scriptDir, "main.ts")
+
+	tests := []struct {
+		name     string
+		opts     []DenoClientOption
+		expected string
+	}{
+		{"default", nil, jsonConfig},
+		{"prefer-json", []DenoClientOption{WithConfigPrecedence(ConfigPrecedencePreferJSON)}, jsonConfig},
+		{"prefer-jsonc", []DenoClientOption{WithConfigPrecedence(ConfigPrecedencePreferJSONC)}, jsoncConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewDenoClient(bin, scriptPath, "", nil, nil, tt.opts...)
+			assert.Equal(t, tt.expected, c.Config().ConfigPath)
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		c := NewDenoClient(bin, scriptPath, "", nil, nil, WithConfigPrecedence(ConfigPrecedenceStrict))
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrAmbiguousConfig)
+		assert.Contains(t, err.Error(), jsonConfig)
+		assert.Contains(t, err.Error(), jsoncConfig)
+	})
+
+	t.Run("strict with an explicit config path", func(t *testing.T) {
+		c := NewDenoClient(bin, scriptPath, jsoncConfig, nil, nil, WithConfigPrecedence(ConfigPrecedenceStrict))
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+}
+
+func TestDenoClient_ConfigSearchPaths(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	// The script's own tree has a config, a sibling tooling directory has another
+	root := t.TempDir()
+	appDir := filepath.Join(root, "app")
+	scriptDir := filepath.Join(appDir, "scripts")
+	toolingDir := filepath.Join(root, "tooling")
+	emptyDir := filepath.Join(root, "empty")
+	for _, dir := range []string{scriptDir, toolingDir, emptyDir} {
+		assert.NoError(t, os.MkdirAll(dir, 0o700))
+	}
+	appConfig := filepath.Join(appDir, "deno.json")
+	toolingConfig := filepath.Join(toolingDir, "deno.jsonc")
+	explicitConfig := filepath.Join(root, "explicit.json")
+	for _, path := range []string{appConfig, toolingConfig, explicitConfig} {
+		assert.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+	}
+	scriptPath := filepath.Join(scriptDir, "main.ts")
+
+	tests := []struct {
+		name       string
+		scriptPath string
+		configPath string
+		opts       []DenoClientOption
+		expected   string
+	}{
+		{"upward walk", scriptPath, "", nil, appConfig},
+		{"search path", scriptPath, "", []DenoClientOption{WithConfigSearchPaths(toolingDir)}, toolingConfig},
+		{"search paths in order", scriptPath, "", []DenoClientOption{WithConfigSearchPaths(emptyDir, toolingDir)}, toolingConfig},
+		{"empty search path", scriptPath, "", []DenoClientOption{WithConfigSearchPaths(emptyDir)}, appConfig},
+		{"relative search path", scriptPath, "", []DenoClientOption{WithWorkingDir(appDir), WithConfigSearchPaths("../tooling")}, toolingConfig},
+		{"explicit config", scriptPath, explicitConfig, []DenoClientOption{WithConfigSearchPaths(toolingDir)}, explicitConfig},
+		{"remote script", "https://example.com/main.ts", "", []DenoClientOption{WithConfigSearchPaths(toolingDir)}, toolingConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewDenoClient(bin, tt.scriptPath, tt.configPath, nil, nil, tt.opts...)
+			assert.Equal(t, tt.expected, c.Config().ConfigPath)
+		})
+	}
+}
+
+func TestDenoClient_ConfigLookupSeesChanges(t *testing.T) {
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	root := t.TempDir()
+	scriptDir := filepath.Join(root, "scripts")
+	assert.NoError(t, os.MkdirAll(scriptDir, 0o700))
+	scriptPath := filepath.Join(scriptDir, "main.ts")
+	lookup := func() string {
+		return NewDenoClient(bin, scriptPath, "", nil, nil).Config().ConfigPath
+	}
+	assert.Equal(t, "", lookup())
+
+	// A config added after the first lookup is found by the next one
+	parentConfig := filepath.Join(root, "deno.json")
+	assert.NoError(t, os.WriteFile(parentConfig, []byte(`{}`), 0o600))
+	assert.Equal(t, parentConfig, lookup())
+
+	// As is one that is closer to the script
+	scriptConfig := filepath.Join(scriptDir, "deno.jsonc")
+	assert.NoError(t, os.WriteFile(scriptConfig, []byte(`{}`), 0o600))
+	assert.Equal(t, scriptConfig, lookup())
+
+	// And deleting it falls back to the parent's again
+	assert.NoError(t, os.Remove(scriptConfig))
+	assert.Equal(t, parentConfig, lookup())
+}
+
+func TestConfigLookup_Stale(t *testing.T) {
+	dir := t.TempDir()
+	lookup := walkDenoConfigFile(dir, nil, ConfigPrecedencePreferJSON)
+	assert.False(t, lookup.stale())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated.txt"), nil, 0o600))
+	assert.True(t, lookup.stale())
+}
+
+func TestDenoClient_InvalidConfig(t *testing.T) {
+	t.Setenv(fakeDenoEnvVar, "default")
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "main.ts")
+
+	t.Run("jsonc with comments", func(t *testing.T) {
+		config := filepath.Join(dir, "commented.jsonc")
+		assert.NoError(t, os.WriteFile(config, []byte("{\n  // the tasks\n  \"tasks\": { \"a\": \"b\", }, /* trailing */\n}\n"), 0o600))
+		c := NewDenoClient(bin, scriptPath, config, nil, nil)
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+
+	t.Run("json with comments", func(t *testing.T) {
+		config := filepath.Join(dir, "commented.json")
+		assert.NoError(t, os.WriteFile(config, []byte("{\n  // not allowed\n}\n"), 0o600))
+		c := NewDenoClient(bin, scriptPath, config, nil, nil)
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), config+":2:")
+	})
+
+	t.Run("broken jsonc", func(t *testing.T) {
+		config := filepath.Join(dir, "broken.jsonc")
+		assert.NoError(t, os.WriteFile(config, []byte("{\n  // a comment\n  \"tasks\": {\n}\n"), 0o600))
+		c := NewDenoClient(bin, scriptPath, config, nil, nil)
+		assert.IsError(t, c.Start(t.Context()), ErrInvalidConfig)
+	})
+}
+
+func TestStripJSONC(t *testing.T) {
+	input := `{
+  // a comment
+  "url": "https://example.com", /* a block
+  comment */ "quote": "a \"// not a comment\"",
+  "list": [1, 2,],
+}`
+	var parsed map[string]any
+	assert.NoError(t, json.Unmarshal(stripJSONC([]byte(input)), &parsed))
+	assert.Equal(t, "https://example.com", parsed["url"])
+	assert.Equal(t, `a "// not a comment"`, parsed["quote"])
+	assert.Equal(t, any([]any{1.0, 2.0}), parsed["list"])
+	assert.Equal(t, len(input), len(stripJSONC([]byte(input))))
+}
+
+func TestConfigPrecedence_String(t *testing.T) {
+	assert.Equal(t, "prefer-json", ConfigPrecedencePreferJSON.String())
+	assert.Equal(t, "prefer-jsonc", ConfigPrecedencePreferJSONC.String())
+	assert.Equal(t, "strict", ConfigPrecedenceStrict.String())
+	assert.Equal(t, "ConfigPrecedence(7)", ConfigPrecedence(7).String())
+}
+
+func TestDenoClient_ScriptError(t *testing.T) {
+	c := newFakeDenoClient(t, "script-error")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	err := c.Call(t.Context(), "create", nil, nil)
+	var scriptErr *ScriptError
+	assert.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, "create", scriptErr.Method)
+	assert.Equal(t, int64(42), scriptErr.Code)
+	assert.Equal(t, "bucket exists", scriptErr.Message)
+	assert.Equal(t, &ScriptErrorDiagnostic{
+		Severity: "warning",
+		Detail:   "bucket exists",
+		PropPath: &[]string{"props", "name"},
+	}, scriptErr.Diagnostic())
+
+	// The underlying JSON-RPC error is still there, with the same message
+	var rpcErr *jsonrpc2.Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, rpcErr.Error(), err.Error())
+
+	// Errors without data describe no diagnostic
+	err = c.Call(t.Context(), "missing", nil, nil)
+	assert.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, jsonrpc2.CodeMethodNotFound, scriptErr.Code)
+	assert.Zero(t, scriptErr.Diagnostic())
+}
+
+func TestDenoClient_Capabilities(t *testing.T) {
+	c := newFakeDenoClient(t, "capabilities")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	assert.Equal(t, []string{"import"}, c.Capabilities())
+	assert.True(t, c.HasCapability("import"))
+	assert.False(t, c.HasCapability("modifyPlan"))
+}
+
+func TestDenoClient_CapabilitiesNotReported(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// A script that only answers ok may implement anything
+	assert.Zero(t, c.Capabilities())
+	assert.True(t, c.HasCapability("modifyPlan"))
+}
+
+func TestDenoClient_ProtocolVersionMismatch(t *testing.T) {
+	c := newFakeDenoClient(t, "protocol-mismatch")
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrProtocolVersionMismatch)
+	assert.Contains(t, err.Error(), "the script speaks 2.0.0 but the provider speaks "+ProtocolVersion)
+	_ = c.Stop()
+}
+
+func TestCheckProtocolVersion(t *testing.T) {
+	assert.NoError(t, checkProtocolVersion(""))
+	assert.NoError(t, checkProtocolVersion("1"))
+	assert.NoError(t, checkProtocolVersion("1.9.3"))
+	assert.IsError(t, checkProtocolVersion("0.9.0"), ErrProtocolVersionMismatch)
+	assert.IsError(t, checkProtocolVersion("two"), ErrProtocolVersionMismatch)
+}
+
+func TestDenoClient_LockFileMismatch(t *testing.T) {
+	c := newFakeDenoClient(t, "lockfile-mismatch")
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrLockfileMismatch)
+	assert.Contains(t, err.Error(), "does not match the expected hash in the lock file")
+}
+
+func TestDenoClient_OfflineMode(t *testing.T) {
+	for mode, flag := range map[OfflineMode]string{OfflineModeCachedOnly: "--cached-only", OfflineModeNoRemote: "--no-remote"} {
+		c := newFakeDenoClient(t, "default", WithOfflineMode(mode))
+		assert.NoError(t, c.Start(t.Context()))
+
+		var args []string
+		assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+		assert.NoError(t, c.Stop())
+
+		assert.Equal(t, flag, args[len(args)-2])
+	}
+}
+
+func TestDenoClient_OfflineModeNoRemoteRejectsRemoteScript(t *testing.T) {
+	bin, err := os.Executable()
+	assert.NoError(t, err)
+
+	c := NewDenoClient(bin, "https://example.com/mod.ts", "/dev/null", nil, nil, WithOfflineMode(OfflineModeNoRemote))
+	err = c.Start(t.Context())
+	assert.IsError(t, err, ErrRemoteScriptOffline)
+	assert.Contains(t, err.Error(), "https://example.com/mod.ts")
+}
+
+func TestDenoClient_Reload(t *testing.T) {
+	for flag, opt := range map[string]DenoClientOption{
+		"--reload": WithReload(),
+		"--reload=https://deno.land/std,jsr:@std/path": WithReload("https://deno.land/std", "jsr:@std/path"),
+	} {
+		c := newFakeDenoClient(t, "default", opt)
+		assert.NoError(t, c.Start(t.Context()))
+
+		var args []string
+		assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+		assert.NoError(t, c.Stop())
+
+		assert.Equal(t, flag, args[len(args)-2])
+	}
+}
+
+func TestDenoClient_V8Flags(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithV8Flags("--max-old-space-size=4096", "--stack-size=2048"))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var args []string
+	assert.NoError(t, c.Call(t.Context(), "args", nil, &args))
+	assert.Equal(t, "--v8-flags=--max-old-space-size=4096,--stack-size=2048", args[len(args)-2])
+}
+
+func TestDenoClient_V8FlagsInvalid(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithV8Flags("max-old-space-size=4096"))
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrInvalidV8Flag)
+	assert.Contains(t, err.Error(), `"max-old-space-size=4096"`)
+}
+
+func TestDenoClient_StringIDs(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithStringIDs())
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// Concurrent calls must each be correlated with their own response
+	var wg sync.WaitGroup
+	ids := make([]json.RawMessage, 20)
+	for i := range ids {
+		wg.Go(func() {
+			assert.NoError(t, c.Call(t.Context(), "requestID", nil, &ids[i]))
+		})
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		// The wire format of the id is a JSON string
+		var str string
+		assert.NoError(t, json.Unmarshal(id, &str))
+		assert.True(t, strings.HasPrefix(str, stringIDPrefix))
+		assert.False(t, seen[str])
+		seen[str] = true
+	}
+}
+
+func TestDenoClient_IntegerIDs(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var id json.RawMessage
+	assert.NoError(t, c.Call(t.Context(), "requestID", nil, &id))
+	var num uint64
+	assert.NoError(t, json.Unmarshal(id, &num))
+}
+
+func TestDenoClient_ConcurrentCalls(t *testing.T) {
+	for _, opts := range [][]DenoClientOption{nil, {WithStringIDs()}} {
+		c := newFakeDenoClient(t, "default", opts...)
+		assert.NoError(t, c.Start(t.Context()))
+
+		// Hundreds of overlapping calls, answered out of order, must each get their own response
+		type echo struct {
+			N       int    `json:"n"`
+			Payload string `json:"payload"`
+		}
+		results := make([]echo, 500)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Go(func() {
+				params := echo{N: i, Payload: strings.Repeat(fmt.Sprint(i), i%50)}
+				assert.NoError(t, c.Call(t.Context(), "echo", params, &results[i]))
+			})
+		}
+		wg.Wait()
+		for i, result := range results {
+			assert.Equal(t, echo{N: i, Payload: strings.Repeat(fmt.Sprint(i), i%50)}, result)
+		}
+		assert.NoError(t, c.Stop())
+	}
+}
+
+func TestDenoClient_MaxMessageBytes(t *testing.T) {
+	var logs syncBuffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	c := newFakeDenoClient(t, "large-state", WithMaxMessageBytes(4096))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	err := c.Call(t.Context(), "read", nil, nil)
+	assert.IsError(t, err, jsocket.ErrMessageTooLarge)
+	assert.Contains(t, logs.String(), "sent a JSON-RPC message that was discarded")
+
+	// The process is still usable
+	var pid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+	assert.Equal(t, c.PID(), pid)
+}
+
+func TestDenoClient_CancelRequest(t *testing.T) {
+	c := newFakeDenoClient(t, "cancellable")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	err := c.Call(ctx, "longRunning", nil, nil)
+	assert.IsError(t, err, context.DeadlineExceeded)
+
+	// The cancellation is delivered in the background, for the id of the cancelled request
+	var ids struct {
+		Request   *jsonrpc2.ID `json:"request"`
+		Cancelled *jsonrpc2.ID `json:"cancelled"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ids.Cancelled == nil && time.Now().Before(deadline) {
+		assert.NoError(t, c.Call(t.Context(), "cancelled", nil, &ids))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotZero(t, ids.Cancelled)
+	assert.Equal(t, *ids.Request, *ids.Cancelled)
+}
+
+func TestDenoClient_NegotiatedFeatures(t *testing.T) {
+	t.Run("all agreed", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default")
+		assert.Zero(t, c.NegotiatedFeatures())
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+		assert.Equal(t, SupportedFeatures, c.NegotiatedFeatures())
+
+		// Every supported feature was offered
+		var handshake HealthRequest
+		assert.NoError(t, c.Call(t.Context(), "handshake", nil, &handshake))
+		assert.Equal(t, SupportedFeatures, handshake.Features)
+	})
+
+	t.Run("limited by the provider", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default", WithFeatures(FeatureCancelRequest, "compression"))
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+		assert.Equal(t, []Feature{FeatureCancelRequest}, c.NegotiatedFeatures())
+
+		// Features the provider does not support are never offered
+		var handshake HealthRequest
+		assert.NoError(t, c.Call(t.Context(), "handshake", nil, &handshake))
+		assert.Equal(t, []Feature{FeatureCancelRequest}, handshake.Features)
+	})
+
+	t.Run("limited by the script", func(t *testing.T) {
+		t.Setenv(fakeDenoFeaturesEnvVar, "cancelRequest,cbor")
+		c := newFakeDenoClient(t, "default")
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+
+		// Features the script claims but the provider never offered are ignored
+		assert.Equal(t, []Feature{FeatureCancelRequest}, c.NegotiatedFeatures())
+	})
+}
+
+func TestDenoClient_NegotiatedEncoding(t *testing.T) {
+	t.Run("cbor", func(t *testing.T) {
+		c := newFakeDenoClient(t, "cbor")
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+		assert.Equal(t, jsocket.EncodingCBOR, c.Socket.PayloadEncoding())
+
+		type payload struct {
+			ID   int64  `json:"id"`
+			Data []byte `json:"data"`
+		}
+		sent := payload{ID: 9007199254740993, Data: []byte{0, 0xff}}
+
+		// The script received the params as CBOR
+		var raw string
+		assert.NoError(t, c.Call(t.Context(), "params", sent, &raw))
+		assert.True(t, strings.HasPrefix(raw, `{"`+jsocket.CBORPayloadKey+`":`), raw)
+
+		// And its CBOR result decodes into the same value
+		var echoed payload
+		assert.NoError(t, c.Call(t.Context(), "echo", sent, &echoed))
+		assert.Equal(t, sent, echoed)
+	})
+
+	t.Run("json fallback", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default")
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+		assert.Equal(t, jsocket.EncodingJSON, c.Socket.PayloadEncoding())
+
+		// CBOR was offered, but the script did not answer with it
+		var handshake HealthRequest
+		assert.NoError(t, c.Call(t.Context(), "handshake", nil, &handshake))
+		assert.Equal(t, SupportedEncodings, handshake.Encodings)
+	})
+}
+
+func TestDenoClient_UnagreedFeaturesAreNotUsed(t *testing.T) {
+	t.Setenv(fakeDenoFeaturesEnvVar, "none")
+
+	t.Run("batch", func(t *testing.T) {
+		c := newFakeDenoClientDatasource(t, "batch-read", 0)
+		assert.NoError(t, c.Client.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Client.Stop()) }()
+
+		results, err := c.ReadMany(t.Context(), []*ReadRequest{
+			{Props: map[string]any{"name": "a"}},
+			{Props: map[string]any{"name": "b"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, any(map[string]any{"name": "a"}), results[0].Response.Result)
+		assert.Equal(t, any(map[string]any{"name": "b"}), results[1].Response.Result)
+
+		// The reads were sent one at a time rather than as a batch
+		var batches int
+		assert.NoError(t, c.Client.Call(t.Context(), "batches", nil, &batches))
+		assert.Equal(t, 0, batches)
+		assert.Equal(t, 2, c.Client.Summary().Calls["read"])
+	})
+
+	t.Run("cancel request", func(t *testing.T) {
+		c := newFakeDenoClient(t, "cancellable")
+		assert.NoError(t, c.Start(t.Context()))
+		defer func() { assert.NoError(t, c.Stop()) }()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+		defer cancel()
+		assert.IsError(t, c.Call(ctx, "longRunning", nil, nil), context.DeadlineExceeded)
+
+		// Give a cancellation, if one were sent, time to arrive
+		time.Sleep(100 * time.Millisecond)
+		var ids struct {
+			Request   *jsonrpc2.ID `json:"request"`
+			Cancelled *jsonrpc2.ID `json:"cancelled"`
+		}
+		assert.NoError(t, c.Call(t.Context(), "cancelled", nil, &ids))
+		assert.NotZero(t, ids.Request)
+		assert.Zero(t, ids.Cancelled)
+	})
+}
+
+// syncBuffer is a strings.Builder that is safe to write to from background goroutines.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestDenoClient_DuplicateResponse(t *testing.T) {
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := newFakeDenoClient(t, "duplicate-response")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// The first response wins
+	var result string
+	assert.NoError(t, c.Call(t.Context(), "twice", nil, &result))
+	assert.Equal(t, "first", result)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "duplicate response") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Contains(t, buf.String(), "[WARN] Ignored a duplicate response from the Deno script to twice request")
+
+	// The client is still healthy
+	for range 3 {
+		assert.NoError(t, c.Call(t.Context(), "twice", nil, &result))
+		assert.Equal(t, "first", result)
+	}
+	var pid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+	assert.NotZero(t, pid)
+}
+
+func TestRedactEnv(t *testing.T) {
+	redacted := redactEnv([]string{"TOKEN=hunter2", "EMPTY=", "NOVALUE"})
+	assert.Equal(t, "TOKEN=<redacted> EMPTY=<redacted> NOVALUE=<redacted>", redacted)
+	assert.NotContains(t, redacted, "hunter2")
+}
+
+func TestDenoClient_StopSendsSigtermAfterGracePeriod(t *testing.T) {
+	c := newFakeDenoClient(t, "stubborn", WithShutdownGracePeriod(100*time.Millisecond))
+	assert.NoError(t, c.Start(t.Context()))
+
+	began := time.Now()
+	assert.NoError(t, c.Stop())
+	assert.True(t, time.Since(began) >= 100*time.Millisecond)
+}
+
+func TestDenoClient_StopKillsAfterGracePeriod(t *testing.T) {
+	c := newFakeDenoClient(t, "stubborn-ignores-sigterm", WithShutdownGracePeriod(100*time.Millisecond))
+	assert.NoError(t, c.Start(t.Context()))
+
+	began := time.Now()
+	assert.IsError(t, c.Stop(), ErrShutdownForced)
+	assert.True(t, time.Since(began) >= 200*time.Millisecond)
+}
+
+func TestDenoClient_PID(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.Equal(t, -1, c.PID())
+
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var pid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+	assert.Equal(t, pid, c.PID())
+}
+
+func TestDenoClient_Done(t *testing.T) {
+	c := newFakeDenoClient(t, "crashy")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { _ = c.Stop() }()
+
+	done := c.Done()
+	select {
+	case <-done:
+		t.Fatal("done was closed while the process is running")
+	default:
+	}
+
+	assert.Error(t, c.Call(t.Context(), "crash", nil, nil))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("done was not closed after the process exited")
+	}
+}
+
+func TestDenoClient_StdoutClosedWhileAlive(t *testing.T) {
+	c := newFakeDenoClient(t, "closes-stdout")
+	c.RestartOnCrash = true
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { _ = c.Stop() }()
+
+	done := c.Done()
+	assert.IsError(t, c.Call(t.Context(), "closeStdout", nil, nil), ErrStdoutClosed)
+
+	// The lingering process is killed rather than restarted
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the process was not killed after closing its stdout")
+	}
+	assert.Equal(t, 0, c.Summary().Restarts)
+	assert.IsError(t, c.Call(t.Context(), "pid", nil, nil), ErrProcessPoisoned)
+}
+
+func TestDenoClient_RestartOnCrash(t *testing.T) {
+	t.Setenv(fakeDenoSpawnLogEnvVar, filepath.Join(t.TempDir(), "spawn.log"))
+
+	c := newFakeDenoClient(t, "crashy")
+	c.RestartOnCrash = true
+	c.RestartBackoff = time.Millisecond
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// The stderr tail may be read while the process is being replaced
+	stop := make(chan struct{})
+	var tails sync.WaitGroup
+	tails.Go(func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = c.StderrTail()
+			}
+		}
+	})
+
+	var firstPid, secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+	assert.NoError(t, c.Call(t.Context(), "crashOnce", nil, &secondPid))
+	assert.NotEqual(t, firstPid, secondPid)
+	assert.Equal(t, 1, c.Summary().Restarts)
+	close(stop)
+	tails.Wait()
+}
+
+func TestDenoClient_RestartOnCrashConcurrentCalls(t *testing.T) {
+	t.Setenv(fakeDenoSpawnLogEnvVar, filepath.Join(t.TempDir(), "spawn.log"))
+
+	c := newFakeDenoClient(t, "crashy")
+	c.RestartOnCrash = true
+	c.RestartBackoff = time.Millisecond
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	firstPid := c.PID()
+	pids := make([]int, 4)
+	errs := make([]error, len(pids))
+	var wg sync.WaitGroup
+	for i := range pids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.Call(t.Context(), "crashOnceLater", nil, &pids[i])
+		}()
+	}
+	wg.Wait()
+
+	// Every call saw the same crash, yet the process was only restarted once
+	for i := range pids {
+		assert.NoError(t, errs[i])
+		assert.NotEqual(t, firstPid, pids[i])
+		assert.Equal(t, c.PID(), pids[i])
+	}
+	assert.Equal(t, 1, c.Summary().Restarts)
+}
+
+func TestDenoClient_RestartOnCrashExhausted(t *testing.T) {
+	c := newFakeDenoClient(t, "crashy")
+	c.RestartOnCrash = true
+	c.MaxRestarts = 1
+	c.RestartBackoff = time.Millisecond
+	assert.NoError(t, c.Start(t.Context()))
+	defer c.kill()
+
+	// The first crash is restarted & retried once, which crashes again
+	assert.Error(t, c.Call(t.Context(), "crash", nil, nil))
+	assert.Equal(t, 1, c.Summary().Restarts)
+
+	err := c.Call(t.Context(), "crash", nil, nil)
+	assert.IsError(t, err, ErrRestartsExhausted)
+	assert.Contains(t, err.Error(), "fatal: out of memory")
+}
+
+func TestDenoClient_CrashWithoutRestart(t *testing.T) {
+	c := newFakeDenoClient(t, "crashy")
+	assert.NoError(t, c.Start(t.Context()))
+	defer c.kill()
+
+	assert.Error(t, c.Call(t.Context(), "crash", nil, nil))
+	assert.Equal(t, 0, c.Summary().Restarts)
+}
+
+func TestDenoClient_StderrTailOnFailedHealth(t *testing.T) {
+	c := newFakeDenoClient(t, "throws-on-load")
+	// The script must exist, otherwise the failure is put down to it missing, see TestDenoClient_ScriptNotFound
+	c.scriptPath = filepath.Join(t.TempDir(), "fake.ts")
+	assert.NoError(t, os.WriteFile(c.scriptPath, nil, 0o644))
+	err := c.Start(t.Context())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "last stderr lines:\nerror: Uncaught (in promise) TypeError: boom")
+	assert.Equal(t, []string{"error: Uncaught (in promise) TypeError: boom", "    at file:///fake.ts:3:9"}, c.StderrTail())
+}
+
+func TestDenoClient_ScriptNotFound(t *testing.T) {
+	c := newFakeDenoClient(t, "throws-on-load")
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrScriptNotFound)
+	assert.IsError(t, err, fs.ErrNotExist)
+	assert.Contains(t, err.Error(), "fake.ts")
+}
+
+func TestDenoClient_RemoteScriptNotFound(t *testing.T) {
+	c := newFakeDenoClient(t, "module-not-found")
+	c.scriptPath = "https://example.com/missing.ts"
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrScriptNotFound)
+	assert.Contains(t, err.Error(), `Module not found "https://example.com/missing.ts"`)
+
+	// Only the script itself missing counts, not one of its imports
+	c = newFakeDenoClient(t, "module-not-found")
+	c.scriptPath = "https://example.com/main.ts"
+	err = c.Start(t.Context())
+	assert.Error(t, err)
+	assert.NotIsError(t, err, ErrScriptNotFound)
+}
+
+func TestDenoClient_StderrTailOnCrashedStop(t *testing.T) {
+	c := newFakeDenoClient(t, "dies-on-shutdown")
+	assert.NoError(t, c.Start(t.Context()))
+
+	err := c.Stop()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "deno child proc died: exited with code 3\n")
+	assert.Contains(t, err.Error(), "error: failed to flush the write buffer")
+
+	var exitErr *ProcessExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode)
+	assert.False(t, exitErr.BeforeShutdown)
+}
+
+func TestDenoClient_StopToleratesExitAfterShutdown(t *testing.T) {
+	for _, scenario := range []string{"slow-exit-after-shutdown", "sigpipe-after-shutdown"} {
+		c := newFakeDenoClient(t, scenario)
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop(), scenario)
+	}
+}
+
+func TestDenoClient_StopReportsCrashBeforeShutdown(t *testing.T) {
+	c := newFakeDenoClient(t, "crashy")
+	assert.NoError(t, c.Start(t.Context()))
+	assert.Error(t, c.Call(t.Context(), "crash", nil, nil))
+	<-c.Done()
+
+	err := c.Stop()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "deno child proc died: exited with code 3 before it was asked to shutdown")
+	assert.Contains(t, err.Error(), "fatal: out of memory")
+
+	var exitErr *ProcessExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode)
+	assert.Equal(t, "", exitErr.Signal)
+	assert.True(t, exitErr.BeforeShutdown)
+}
+
+func TestDenoClient_StopReportsCleanExitBeforeShutdown(t *testing.T) {
+	c := newFakeDenoClient(t, "exits-early")
+	assert.NoError(t, c.Start(t.Context()))
+	assert.Error(t, c.Call(t.Context(), "exit", nil, nil))
+	<-c.Done()
+
+	err := c.Stop()
+	var exitErr *ProcessExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 0, exitErr.ExitCode)
+	assert.True(t, exitErr.BeforeShutdown)
+	assert.Equal(t, "deno child proc died: exited with code 0 before it was asked to shutdown", exitErr.Error())
+}
+
+func TestDenoClient_StopReportsSignal(t *testing.T) {
+	c := newFakeDenoClient(t, "default")
+	assert.NoError(t, c.Start(t.Context()))
+	assert.NoError(t, c.process.Process.Kill())
+	<-c.Done()
+
+	err := c.Stop()
+	var exitErr *ProcessExitError
+	assert.True(t, errors.As(err, &exitErr), "%v", err)
+	assert.Equal(t, -1, exitErr.ExitCode)
+	assert.Equal(t, "SIGKILL", exitErr.Signal)
+	assert.Contains(t, err.Error(), "deno child proc died: killed by SIGKILL before it was asked to shutdown")
+}
+
+func TestDenoClient_Ping(t *testing.T) {
+	for _, scenario := range []string{"default", "wedged"} {
+		t.Run(scenario, func(t *testing.T) {
+			c := newFakeDenoClient(t, scenario)
+			_, err := c.Ping(t.Context())
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "the process has not been started")
+
+			assert.NoError(t, c.Start(t.Context()))
+			defer func() { assert.NoError(t, c.Stop()) }()
+
+			// The default scenario has no ping method, which still proves the process is responsive
+			rtt, err := c.Ping(t.Context())
+			assert.NoError(t, err)
+			assert.True(t, rtt > 0, "round trip time %s", rtt)
+		})
+	}
+}
+
+func TestDenoClient_PingDuringCall(t *testing.T) {
+	c := newFakeDenoClient(t, "slow")
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, c.Call(t.Context(), "read", nil, nil))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rtt, err := c.Ping(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, rtt < 150*time.Millisecond, "ping queued behind the read, took %s", rtt)
+	wg.Wait()
+}
+
+func TestDenoClient_HeartbeatRestartsWedgedProcess(t *testing.T) {
+	var logs syncBuffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	c := newFakeDenoClient(t, "wedged", WithHeartbeat(20*time.Millisecond, 100*time.Millisecond))
+	c.RestartOnCrash = true
+	c.RestartBackoff = time.Millisecond
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	var firstPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &firstPid))
+	assert.NoError(t, c.Call(t.Context(), "wedge", nil, nil))
+
+	select {
+	case <-c.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the wedged process was not killed")
+	}
+	assert.Contains(t, logs.String(), "did not answer a ping within 100ms, its event loop is probably blocked, killing it")
+
+	var secondPid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &secondPid))
+	assert.NotEqual(t, firstPid, secondPid)
+	assert.Equal(t, 1, c.Summary().Restarts)
+}
+
+func TestDenoClient_HeartbeatLeavesResponsiveProcess(t *testing.T) {
+	c := newFakeDenoClient(t, "wedged", WithHeartbeat(10*time.Millisecond, time.Second))
+	assert.NoError(t, c.Start(t.Context()))
+	pid := c.PID()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, pid, c.PID())
+	select {
+	case <-c.Done():
+		t.Fatal("a responsive process was killed")
+	default:
+	}
+	assert.NoError(t, c.Stop())
+	assert.True(t, c.heartbeatCancel == nil)
+}
+
+func TestDenoClient_StopCyclesUnderRace(t *testing.T) {
+	for range 100 {
+		c := newFakeDenoClient(t, "default")
+		assert.NoError(t, c.Start(t.Context()))
+
+		var pid int
+		assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+		assert.NoError(t, c.Stop())
+	}
+}
+
+func TestLineRing(t *testing.T) {
+	var r lineRing
+	for i := range stderrTailLines + 5 {
+		r.add(fmt.Sprint(i))
+	}
+	lines := r.lines()
+	assert.Equal(t, stderrTailLines, len(lines))
+	assert.Equal(t, "5", lines[0])
+	assert.Equal(t, fmt.Sprint(stderrTailLines+4), lines[len(lines)-1])
+}
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, 500*time.Millisecond, restartBackoff(500*time.Millisecond, 0))
+	assert.Equal(t, 2*time.Second, restartBackoff(500*time.Millisecond, 2))
+
+	// Doubling is capped, rather than growing for hours or overflowing
+	assert.Equal(t, maxRestartBackoff, restartBackoff(500*time.Millisecond, 10))
+	assert.Equal(t, maxRestartBackoff, restartBackoff(500*time.Millisecond, 100))
+}
+
+func TestDenoClient_FlushWarning(t *testing.T) {
+	c := newFakeDenoClient(t, "slow-flush", WithFlushWarning(100*time.Millisecond))
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	// A slow handler that writes its response in one go is not the script's buffering
+	var result string
+	assert.NoError(t, c.Call(t.Context(), "slowHandler", nil, &result))
+	assert.Equal(t, "done", result)
+	assert.Equal(t, 0, c.Summary().FlushStalls)
+
+	// A response written in halves is attributed to the script not flushing
+	assert.NoError(t, c.Call(t.Context(), "buffered", nil, &result))
+	assert.Equal(t, "buffered", result)
+	assert.Equal(t, 1, c.Summary().FlushStalls)
+}
+
+func TestFlushTracker_Observe(t *testing.T) {
+	tracker := &flushTracker{}
+
+	// A complete line is not stalled
+	tracker.observe([]byte("{}\n"))
+	assert.Equal(t, time.Duration(0), tracker.lastStall)
+
+	// A line completed by a later read stalled in between
+	tracker.observe([]byte("{\"a\":"))
+	time.Sleep(20 * time.Millisecond)
+	tracker.observe([]byte("1}\n{\"b\":"))
+	assert.True(t, tracker.lastStall >= 20*time.Millisecond)
+	assert.False(t, tracker.partialSince.IsZero())
+
+	tracker.observe([]byte("2}\n"))
+	assert.True(t, tracker.lastStall < 20*time.Millisecond)
+	assert.True(t, tracker.partialSince.IsZero())
+}
+
+// waitForStandby waits for the warm standby of c to be up and running.
+func waitForStandby(t *testing.T, c *DenoClient) *DenoClient {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if standby := c.standby.Load(); standby != nil {
+			return standby
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("warm standby did not start")
+	return nil
+}
+
+func TestDenoClient_WarmStandbyTakesOver(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithWarmStandby())
+	// A cold restart would not complete before the test times out
+	c.RestartBackoff = time.Hour
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	primary := c.PID()
+	standby := waitForStandby(t, c).PID()
+	assert.NotEqual(t, primary, standby)
+
+	assert.NoError(t, c.process.Process.Kill())
+	<-c.Done()
+
+	began := time.Now()
+	var pid int
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, &pid))
+	assert.True(t, time.Since(began) < time.Second, "failover took %s", time.Since(began))
+	assert.Equal(t, standby, pid)
+	assert.Equal(t, standby, c.PID())
+	assert.Equal(t, 1, c.Summary().Restarts)
+
+	// A new standby replaces the promoted one
+	next := waitForStandby(t, c).PID()
+	assert.NotEqual(t, standby, next)
+	assert.NotEqual(t, primary, next)
+}
+
+func TestDenoClient_WarmStandbyConcurrentCalls(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithWarmStandby())
+	// A cold restart would not complete before the test times out
+	c.RestartBackoff = time.Hour
+	assert.NoError(t, c.Start(t.Context()))
+	defer func() { assert.NoError(t, c.Stop()) }()
+
+	standby := waitForStandby(t, c).PID()
+	assert.NoError(t, c.process.Process.Kill())
+	<-c.Done()
+
+	pids := make([]int, 4)
+	errs := make([]error, len(pids))
+	var wg sync.WaitGroup
+	for i := range pids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.Call(t.Context(), "pid", nil, &pids[i])
+		}()
+	}
+	wg.Wait()
+
+	// Every call saw the same crash, yet only the first one promoted the standby
+	for i := range pids {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, standby, pids[i])
+	}
+	assert.Equal(t, standby, c.PID())
+	assert.Equal(t, 1, c.Summary().Restarts)
+
+	// The traffic of the promoted process is part of the summary
+	before := c.Summary()
+	assert.NoError(t, c.Call(t.Context(), "pid", nil, nil))
+	after := c.Summary()
+	assert.True(t, after.BytesSent > before.BytesSent)
+	assert.True(t, after.BytesReceived > before.BytesReceived)
+}
+
+func TestDenoClient_WarmStandbyStopped(t *testing.T) {
+	c := newFakeDenoClient(t, "default", WithWarmStandby())
+	assert.NoError(t, c.Start(t.Context()))
+	standby := waitForStandby(t, c)
+
+	assert.NoError(t, c.Stop())
+	assert.Zero(t, c.standby.Load())
+	select {
+	case <-standby.Done():
+	default:
+		t.Fatal("standby process is still running")
+	}
+}
+
+func TestDenoClient_ProcessPoolSharesProcess(t *testing.T) {
+	pool := NewProcessPool()
+	a := newFakeDenoClient(t, "default", WithProcessPool(pool))
+	b := newFakeDenoClient(t, "default", WithProcessPool(pool))
+	other := newFakeDenoClient(t, "default", WithProcessPool(pool), WithStartupTimeout(time.Minute))
+	assert.NoError(t, a.Start(t.Context()))
+	assert.NoError(t, b.Start(t.Context()))
+	assert.NoError(t, other.Start(t.Context()))
+	defer func() { assert.NoError(t, other.Stop()) }()
+
+	var pidA, pidB, pidOther int
+	assert.NoError(t, a.Call(t.Context(), "pid", nil, &pidA))
+	assert.NoError(t, b.Call(t.Context(), "pid", nil, &pidB))
+	assert.NoError(t, other.Call(t.Context(), "pid", nil, &pidOther))
+	assert.Equal(t, pidA, pidB)
+	assert.Equal(t, pidA, a.PID())
+	assert.NotEqual(t, pidA, pidOther)
+	assert.Equal(t, 2, pool.Size())
+
+	// The shared process outlives all but the last client using it
+	done := b.Done()
+	assert.NoError(t, a.Stop())
+	assert.NoError(t, b.Call(t.Context(), "pid", nil, &pidB))
+	assert.Equal(t, pidA, pidB)
+
+	assert.NoError(t, b.Stop())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared process is still running after its last client stopped")
+	}
+	assert.Equal(t, 1, pool.Size())
+
+	// A client started after the last one stopped gets a new process
+	assert.NoError(t, a.Start(t.Context()))
+	defer func() { assert.NoError(t, a.Stop()) }()
+	assert.NoError(t, a.Call(t.Context(), "pid", nil, &pidB))
+	assert.NotEqual(t, pidA, pidB)
+}
+
+func TestDenoClient_ProcessPoolConcurrentStart(t *testing.T) {
+	pool := NewProcessPool()
+	clients := make([]*DenoClient, 8)
+	for i := range clients {
+		clients[i] = newFakeDenoClient(t, "default", WithProcessPool(pool))
+	}
+
+	var wg sync.WaitGroup
+	pids := make([]int, len(clients))
+	for i, c := range clients {
+		wg.Go(func() {
+			assert.NoError(t, c.Start(t.Context()))
+			assert.NoError(t, c.Call(t.Context(), "pid", nil, &pids[i]))
+		})
+	}
+	wg.Wait()
+
+	for _, pid := range pids {
+		assert.Equal(t, pids[0], pid)
+	}
+	for _, c := range clients {
+		assert.NoError(t, c.Stop())
+	}
+	assert.Equal(t, 0, pool.Size())
+}
+
+func TestDenoClient_ProcessPoolStartFailure(t *testing.T) {
+	pool := NewProcessPool()
+	c := newFakeDenoClient(t, "protocol-mismatch", WithProcessPool(pool))
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrProtocolVersionMismatch)
+	assert.Equal(t, 0, pool.Size())
+}
+
+func TestDenoClient_StopWaitsForInFlightCalls(t *testing.T) {
+	c := newFakeDenoClient(t, "slow")
+	assert.NoError(t, c.Start(t.Context()))
+
+	created := make(chan error, 1)
+	var result struct {
+		ID string `json:"id"`
+	}
+	go func() { created <- c.Call(t.Context(), "create", nil, &result) }()
+	for len(c.calls.drain(0)) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.NoError(t, c.Stop())
+	assert.NoError(t, <-created)
+	assert.Equal(t, "123", result.ID)
+}
+
+func TestDenoClient_StopDrainTimeout(t *testing.T) {
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := newFakeDenoClient(t, "slow", WithDrainTimeout(10*time.Millisecond))
+	assert.NoError(t, c.Start(t.Context()))
+
+	read := make(chan error, 1)
+	go func() { read <- c.Call(t.Context(), "read", nil, nil) }()
+	for len(c.calls.drain(0)) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.NoError(t, c.Stop())
+	assert.Error(t, <-read)
+	assert.Contains(t, buf.String(), "[WARN] Stopping deno script fake.ts with calls still in flight after 10ms: read")
+}
+
+func TestDenoClient_ScriptIntegrity(t *testing.T) {
+	script := []byte(`export default {};`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(script)
+	}))
+	defer server.Close()
+	scriptURL := server.URL + "/main.ts"
+
+	sum := sha256.Sum256(script)
+	integrity := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	t.Run("match", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default", WithScriptIntegrity(integrity))
+		c.scriptPath = scriptURL
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+
+		// Deno runs the verified copy, not whatever it would fetch from the URL itself
+		assert.NotContains(t, strings.Join(c.process.Args, " "), scriptURL)
+		verified := c.process.Args[len(c.process.Args)-1]
+		assert.Equal(t, ".ts", filepath.Ext(verified))
+		launched, err := os.ReadFile(verified)
+		assert.NoError(t, err)
+		assert.Equal(t, script, launched)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		tampered := "sha256-" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+		c := newFakeDenoClient(t, "default", WithScriptIntegrity(tampered))
+		c.scriptPath = scriptURL
+		err := c.Start(t.Context())
+		assert.IsError(t, err, ErrScriptIntegrityMismatch)
+		assert.Contains(t, err.Error(), tampered)
+		assert.Contains(t, err.Error(), integrity)
+		assert.Equal(t, -1, c.PID())
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default", WithScriptIntegrity("md5-abc"))
+		c.scriptPath = scriptURL
+		err := c.Start(t.Context())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid script integrity")
+	})
+
+	t.Run("local script", func(t *testing.T) {
+		c := newFakeDenoClient(t, "default", WithScriptIntegrity("sha256-not-checked"))
+		assert.NoError(t, c.Start(t.Context()))
+		assert.NoError(t, c.Stop())
+	})
+}
+
+func TestDenoClient_DryRun(t *testing.T) {
+	t.Setenv("DENO_TOFU_BRIDGE_TEST_MODE", "true")
+
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := newFakeDenoClient(t, "default", WithDryRun())
+	err := c.Start(t.Context())
+	assert.IsError(t, err, ErrDryRun)
+
+	script, absErr := filepath.Abs("fake.ts")
+	assert.NoError(t, absErr)
+	assert.Contains(t, err.Error(), "--no-prompt "+script)
+	assert.Contains(t, buf.String(), "[INFO] Dry run, would have executed Deno command in")
+	assert.Equal(t, -1, c.PID())
+}
@@ -0,0 +1,31 @@
+package deno
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidV8Flag is returned by Start when an entry of V8Flags does not look like a V8 flag.
+var ErrInvalidV8Flag = errors.New("invalid v8 flag")
+
+// WithV8Flags passes flags through to V8, eg: "--max-old-space-size=4096" to raise the heap limit
+// for scripts that process large payloads.
+func WithV8Flags(flags ...string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.V8Flags = flags
+	}
+}
+
+// v8FlagsArgs validates the given V8 flags and returns the --v8-flags argument for the Deno command, if any.
+func v8FlagsArgs(flags []string) ([]string, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, "--") {
+			return nil, fmt.Errorf("%w %q: v8 flags must begin with --", ErrInvalidV8Flag, flag)
+		}
+	}
+	return []string{fmt.Sprintf("--v8-flags=%s", strings.Join(flags, ","))}, nil
+}
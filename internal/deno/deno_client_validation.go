@@ -0,0 +1,36 @@
+package deno
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// CodeValidationFailed is the JSON-RPC error code a script returns when it rejects its input
+// at runtime, eg: during create or update, with the offending props listed in the error data
+// as an array of ValidationError.
+const CodeValidationFailed int64 = -32003
+
+// ValidationError describes why the script rejected a single prop.
+type ValidationError struct {
+	// Path is the path to the offending prop, in the same form as the PropPath of a diagnostic, eg: ["props", "tags", "0"]
+	Path []string `json:"path"`
+	// Message describes why the prop was rejected
+	Message string `json:"message"`
+}
+
+// ValidationErrors returns the validation errors carried by err, when the script failed a call
+// with CodeValidationFailed. Returns nil for any other error, or when the error data is not
+// an array of ValidationError.
+func ValidationErrors(err error) []ValidationError {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeValidationFailed || rpcErr.Data == nil {
+		return nil
+	}
+	var validationErrors []ValidationError
+	if err := json.Unmarshal(*rpcErr.Data, &validationErrors); err != nil {
+		return nil
+	}
+	return validationErrors
+}
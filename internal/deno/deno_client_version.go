@@ -0,0 +1,70 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ErrDenoVersionTooOld is returned by Start when the Deno binary is older than MinDenoVersion.
+var ErrDenoVersionTooOld = errors.New("deno binary is too old")
+
+// WithMinDenoVersion sets the minimum version of Deno the script may be run with, eg: "2.1.0".
+func WithMinDenoVersion(version string) DenoClientOption {
+	return func(c *DenoClient) {
+		c.MinDenoVersion = version
+	}
+}
+
+// checkDenoVersion fails when the Deno binary is older than MinDenoVersion.
+// The detected version is cached, so only the first Start shells out to the binary.
+func (c *DenoClient) checkDenoVersion(ctx context.Context, denoBinaryPath string) error {
+	if c.MinDenoVersion == "" {
+		return nil
+	}
+
+	required, err := semver.NewVersion(c.MinDenoVersion)
+	if err != nil {
+		return fmt.Errorf("invalid minimum deno version %q: %w", c.MinDenoVersion, err)
+	}
+
+	if c.denoVersion == nil {
+		detected, err := detectDenoVersion(ctx, denoBinaryPath)
+		if err != nil {
+			return err
+		}
+		c.denoVersion = detected
+	}
+
+	if c.denoVersion.LessThan(required) {
+		return fmt.Errorf("%w: detected deno %s at %s but at least %s is required", ErrDenoVersionTooOld, c.denoVersion, denoBinaryPath, required)
+	}
+
+	return nil
+}
+
+// detectDenoVersion runs `deno --version` and parses the version from the first line,
+// eg: "deno 2.1.4 (stable, release, x86_64-unknown-linux-gnu)".
+func detectDenoVersion(ctx context.Context, denoBinaryPath string) (*semver.Version, error) {
+	output, err := exec.CommandContext(ctx, denoBinaryPath, "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect the version of deno binary %s: %w", denoBinaryPath, classifyDenoBinaryError(denoBinaryPath, err))
+	}
+
+	firstLine, _, _ := strings.Cut(string(output), "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) < 2 || fields[0] != "deno" {
+		return nil, fmt.Errorf("failed to parse the version of deno binary %s from %q", denoBinaryPath, strings.TrimSpace(firstLine))
+	}
+
+	version, err := semver.NewVersion(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the version of deno binary %s: %w", denoBinaryPath, err)
+	}
+
+	return version, nil
+}
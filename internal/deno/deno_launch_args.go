@@ -0,0 +1,197 @@
+package deno
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DenoLaunchOptions holds everything that decides the arguments a Deno process is launched with.
+// Anything that needs the filesystem to be found, like an auto-detected config file or lockfile,
+// or an import map that must exist, is resolved beforehand so that buildDenoArgs has no side effects.
+type DenoLaunchOptions struct {
+	// ScriptPath is the local path, file:// URL or remote URL of the script to run
+	ScriptPath string
+	// ConfigPath is the deno config file passed via -c, empty or "/dev/null" passes none
+	ConfigPath string
+	// LockFile is the lockfile passed via --lock, empty passes none
+	LockFile string
+	// FrozenLockfile passes --frozen so Deno errors rather than updating the lockfile
+	FrozenLockfile bool
+	// Permissions are the permissions granted to the script, nil grants none
+	Permissions *Permissions
+	// ImportMap is the already resolved import map passed via --import-map, empty passes none
+	ImportMap string
+	// OfflineMode controls whether Deno may fetch remote modules
+	OfflineMode OfflineMode
+	// Reload passes --reload, limited to ReloadSpecifiers when there are any
+	Reload bool
+	// ReloadSpecifiers limits --reload to the given specifiers
+	ReloadSpecifiers []string
+	// V8Flags are passed through to V8 via --v8-flags
+	V8Flags []string
+	// Prompt lets Deno prompt for permissions that were not granted, rather than passing --no-prompt
+	Prompt bool
+}
+
+// buildDenoArgs returns the full list of arguments to launch Deno with, for the given options.
+// Relative paths are made absolute, as the process may run from a different working directory.
+func buildDenoArgs(opts DenoLaunchOptions) ([]string, error) {
+	args := []string{"run", "-q"}
+
+	// There is no TTY to answer a permission prompt under Terraform, so fail fast instead of hanging
+	if !opts.Prompt {
+		args = append(args, "--no-prompt")
+	}
+
+	if opts.ConfigPath != "" && opts.ConfigPath != "/dev/null" {
+		absConfigPath, err := filepath.Abs(opts.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve config path: %w", err)
+		}
+		args = append(args, "-c", absConfigPath)
+	}
+
+	if opts.LockFile != "" {
+		absLockFile, err := filepath.Abs(opts.LockFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve lockfile path: %w", err)
+		}
+		args = append(args, fmt.Sprintf("--lock=%s", absLockFile))
+	}
+	if opts.FrozenLockfile {
+		args = append(args, "--frozen")
+	}
+
+	if opts.Permissions != nil {
+		if opts.Permissions.All {
+			args = append(args, "--allow-all")
+		} else {
+			allowArgs, err := permissionFlags("--allow-", opts.Permissions.Allow)
+			if err != nil {
+				return nil, err
+			}
+			denyArgs, err := permissionFlags("--deny-", opts.Permissions.Deny)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, allowArgs...)
+			args = append(args, denyArgs...)
+		}
+	}
+
+	scriptArg, err := resolveScriptArg(opts.ScriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The import map must come before the script argument
+	if opts.ImportMap != "" {
+		args = append(args, fmt.Sprintf("--import-map=%s", opts.ImportMap))
+	}
+
+	offlineArgs, err := offlineModeArgs(opts.OfflineMode, scriptArg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, offlineArgs...)
+	args = append(args, reloadArgs(opts.Reload, opts.ReloadSpecifiers)...)
+
+	v8Args, err := v8FlagsArgs(opts.V8Flags)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, v8Args...)
+
+	return append(args, scriptArg), nil
+}
+
+// permissionFlags returns a single flag per permission for the given allow or deny entries, in the
+// order each permission first appears. Entries holding a comma separated list, eg: "read=/tmp,/var",
+// are passed on verbatim and repeated entries are merged, eg: "read=/tmp" and "read=/var" become
+// --allow-read=/tmp,/var. An entry without a value covers the whole permission, so it wins over
+// any scoped entries of the same permission.
+func permissionFlags(prefix string, entries []string) ([]string, error) {
+	var names []string
+	values := map[string][]string{}
+	whole := map[string]bool{}
+	for _, entry := range entries {
+		name, value, scoped := strings.Cut(entry, "=")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+		if !scoped {
+			whole[name] = true
+			continue
+		}
+		split, err := splitScopedValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", prefix, entry, err)
+		}
+		for _, v := range split {
+			if !slices.Contains(values[name], v) {
+				values[name] = append(values[name], v)
+			}
+		}
+	}
+
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		if whole[name] {
+			flags = append(flags, prefix+name)
+		} else {
+			flags = append(flags, fmt.Sprintf("%s%s=%s", prefix, name, strings.Join(values[name], ",")))
+		}
+	}
+	return flags, nil
+}
+
+// isPackageSpecifier returns true if scriptPath is an npm: or jsr: specifier, eg: "jsr:@scope/pkg/main.ts",
+// which Deno resolves from its registry, so there is no local file or directory behind it.
+func isPackageSpecifier(scriptPath string) bool {
+	return strings.HasPrefix(scriptPath, "npm:") || strings.HasPrefix(scriptPath, "jsr:")
+}
+
+// resolveScriptArg returns the script argument for the Deno command. Local paths and
+// file:// URLs are resolved to an absolute path, remote URLs and npm: or jsr: specifiers
+// are passed as-is.
+func resolveScriptArg(scriptPath string) (string, error) {
+	if isPackageSpecifier(scriptPath) {
+		return scriptPath, nil
+	}
+	if !strings.Contains(scriptPath, "://") {
+		absPath, err := filepath.Abs(scriptPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve script path: %w", err)
+		}
+		return absPath, nil
+	}
+
+	parsedURL, err := url.Parse(scriptPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse script URL: %w", err)
+	}
+	if parsedURL.Scheme != "file" {
+		// Remote URL (http://, https://, etc.) - pass as-is
+		return scriptPath, nil
+	}
+
+	absPath, err := filepath.Abs(fileURLPath(parsedURL))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve script path: %w", err)
+	}
+	return absPath, nil
+}
+
+// fileURLPath converts a file:// URL to a local path.
+func fileURLPath(u *url.URL) string {
+	path := u.Path
+	// On Windows, url.Parse for file:///C:/path gives Path="/C:/path"
+	// We need to remove the leading slash before the drive letter
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
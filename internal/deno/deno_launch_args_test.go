@@ -0,0 +1,233 @@
+package deno
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBuildDenoArgs(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.ts")
+	config := filepath.Join(dir, "deno.json")
+	lock := filepath.Join(dir, "deno.lock")
+	scriptURL := (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(script), "/")}).String()
+
+	tests := []struct {
+		name     string
+		opts     DenoLaunchOptions
+		expected []string
+	}{
+		{
+			name:     "script only",
+			opts:     DenoLaunchOptions{ScriptPath: script},
+			expected: []string{"run", "-q", "--no-prompt", script},
+		},
+		{
+			name:     "empty config",
+			opts:     DenoLaunchOptions{ScriptPath: script, ConfigPath: "/dev/null"},
+			expected: []string{"run", "-q", "--no-prompt", script},
+		},
+		{
+			name:     "config and frozen lockfile",
+			opts:     DenoLaunchOptions{ScriptPath: script, ConfigPath: config, LockFile: lock, FrozenLockfile: true},
+			expected: []string{"run", "-q", "--no-prompt", "-c", config, "--lock=" + lock, "--frozen", script},
+		},
+		{
+			name:     "allow all",
+			opts:     DenoLaunchOptions{ScriptPath: script, Permissions: &Permissions{All: true, Allow: []string{"read"}}},
+			expected: []string{"run", "-q", "--no-prompt", "--allow-all", script},
+		},
+		{
+			name:     "allow and deny",
+			opts:     DenoLaunchOptions{ScriptPath: script, Permissions: &Permissions{Allow: []string{"read", "net"}, Deny: []string{"env"}}},
+			expected: []string{"run", "-q", "--no-prompt", "--allow-read", "--allow-net", "--deny-env", script},
+		},
+		{
+			name:     "file url",
+			opts:     DenoLaunchOptions{ScriptPath: scriptURL},
+			expected: []string{"run", "-q", "--no-prompt", script},
+		},
+		{
+			name:     "remote url",
+			opts:     DenoLaunchOptions{ScriptPath: "https://example.com/main.ts", OfflineMode: OfflineModeCachedOnly},
+			expected: []string{"run", "-q", "--no-prompt", "--cached-only", "https://example.com/main.ts"},
+		},
+		{
+			name:     "npm specifier",
+			opts:     DenoLaunchOptions{ScriptPath: "npm:@scope/bridge-script@1.2.3"},
+			expected: []string{"run", "-q", "--no-prompt", "npm:@scope/bridge-script@1.2.3"},
+		},
+		{
+			name:     "jsr specifier",
+			opts:     DenoLaunchOptions{ScriptPath: "jsr:@scope/bridge-script@^1/main.ts"},
+			expected: []string{"run", "-q", "--no-prompt", "jsr:@scope/bridge-script@^1/main.ts"},
+		},
+		{
+			name: "everything before the script",
+			opts: DenoLaunchOptions{
+				ScriptPath:       script,
+				ImportMap:        "https://example.com/import_map.json",
+				OfflineMode:      OfflineModeNoRemote,
+				Reload:           true,
+				ReloadSpecifiers: []string{"jsr:@std", "npm:zod"},
+				V8Flags:          []string{"--max-old-space-size=4096"},
+			},
+			expected: []string{
+				"run", "-q", "--no-prompt",
+				"--import-map=https://example.com/import_map.json",
+				"--no-remote",
+				"--reload=jsr:@std,npm:zod",
+				"--v8-flags=--max-old-space-size=4096",
+				script,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := buildDenoArgs(tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+
+	t.Run("prompt", func(t *testing.T) {
+		actual, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: script, Prompt: true})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"run", "-q", script}, actual)
+	})
+
+	t.Run("relative paths", func(t *testing.T) {
+		actual, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: "main.ts", ConfigPath: "deno.json"})
+		assert.NoError(t, err)
+		absScript, _ := filepath.Abs("main.ts")
+		absConfig, _ := filepath.Abs("deno.json")
+		assert.Equal(t, []string{"run", "-q", "--no-prompt", "-c", absConfig, absScript}, actual)
+	})
+
+	t.Run("remote script with no remote", func(t *testing.T) {
+		_, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: "https://example.com/main.ts", OfflineMode: OfflineModeNoRemote})
+		assert.IsError(t, err, ErrRemoteScriptOffline)
+	})
+
+	t.Run("invalid v8 flag", func(t *testing.T) {
+		_, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: script, V8Flags: []string{"max-old-space-size=4096"}})
+		assert.IsError(t, err, ErrInvalidV8Flag)
+	})
+}
+
+func TestFileURLPath(t *testing.T) {
+	u, err := url.Parse("file:///C:/scripts/main.ts")
+	assert.NoError(t, err)
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, `C:\scripts\main.ts`, fileURLPath(u))
+	} else {
+		assert.Equal(t, "C:/scripts/main.ts", fileURLPath(u))
+	}
+
+	u, err = url.Parse("file:///tmp/main.ts")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/tmp/main.ts"), fileURLPath(u))
+}
+
+func TestResolveScriptArg(t *testing.T) {
+	local, err := filepath.Abs("main.ts")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		scriptPath string
+		expected   string
+	}{
+		{"local path", "main.ts", local},
+		{"file url", "file://" + filepath.ToSlash(local), local},
+		{"https url", "https://example.com/main.ts", "https://example.com/main.ts"},
+		{"npm specifier", "npm:bridge-script@1.2.3/main", "npm:bridge-script@1.2.3/main"},
+		{"jsr specifier", "jsr:@scope/bridge-script/main.ts", "jsr:@scope/bridge-script/main.ts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scriptArg, err := resolveScriptArg(tt.scriptPath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, scriptArg)
+		})
+	}
+}
+
+func TestLocateDenoConfigFile_NonLocalScripts(t *testing.T) {
+	// A config in the working directory is only found for a local script
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "deno.json"), []byte(`{}`), 0o600))
+	t.Chdir(dir)
+
+	located, err := locateDenoConfigFile("main.ts", nil, ConfigPrecedencePreferJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, "deno.json", located)
+
+	for _, scriptPath := range []string{"https://example.com/main.ts", "npm:bridge-script", "jsr:@scope/bridge-script"} {
+		located, err := locateDenoConfigFile(scriptPath, nil, ConfigPrecedencePreferJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, "", located, "script %s", scriptPath)
+
+		c := NewDenoClient("deno", scriptPath, "", nil, nil)
+		workingDir, err := c.resolveWorkingDir(scriptPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "", workingDir, "script %s", scriptPath)
+	}
+}
+
+func TestBuildDenoArgs_ScopedPermissions(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "main.ts")
+
+	tests := []struct {
+		name        string
+		permissions *Permissions
+		expected    []string
+	}{
+		{
+			name:        "net hosts",
+			permissions: &Permissions{Allow: []string{"net=example.com:443,api.example.com"}},
+			expected:    []string{"--allow-net=example.com:443,api.example.com"},
+		},
+		{
+			name:        "read and write paths",
+			permissions: &Permissions{Allow: []string{"read=/tmp,/var", "write=/tmp"}},
+			expected:    []string{"--allow-read=/tmp,/var", "--allow-write=/tmp"},
+		},
+		{
+			name:        "run binaries",
+			permissions: &Permissions{Allow: []string{"run=curl,whoami"}},
+			expected:    []string{"--allow-run=curl,whoami"},
+		},
+		{
+			name:        "repeated entries are merged",
+			permissions: &Permissions{Allow: []string{"read=/tmp", "net=example.com", "read=/var,/tmp"}},
+			expected:    []string{"--allow-read=/tmp,/var", "--allow-net=example.com"},
+		},
+		{
+			name:        "whole permission wins",
+			permissions: &Permissions{Allow: []string{"read=/tmp", "read"}, Deny: []string{"net=evil.com", "net=bad.com"}},
+			expected:    []string{"--allow-read", "--deny-net=evil.com,bad.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: script, Permissions: tt.permissions})
+			assert.NoError(t, err)
+			assert.Equal(t, append(append([]string{"run", "-q", "--no-prompt"}, tt.expected...), script), actual)
+		})
+	}
+
+	t.Run("paths containing commas", func(t *testing.T) {
+		for _, entry := range []string{"read=/tmp,", "read=/my,,dir", "write=,/tmp", "read="} {
+			_, err := buildDenoArgs(DenoLaunchOptions{ScriptPath: script, Permissions: &Permissions{Allow: []string{entry}}})
+			assert.IsError(t, err, ErrInvalidPermission)
+			assert.Contains(t, err.Error(), "--allow-"+entry)
+		}
+	})
+}
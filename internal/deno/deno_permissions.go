@@ -1,6 +1,11 @@
 package deno
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
@@ -9,11 +14,58 @@ import (
 // It controls what system resources the Deno runtime can access during execution.
 type Permissions struct {
 	// All grants all permissions when true, effectively disabling security restrictions
-	All bool
+	All bool `json:"all"`
 	// Allow is a list of specific permissions to grant (e.g., "read", "write", "net", "env")
-	Allow []string
+	Allow []string `json:"allow"`
 	// Deny is a list of specific permissions to explicitly deny
-	Deny []string
+	Deny []string `json:"deny"`
+	// AllowUnknown skips checking entries against the known permission names in Validate,
+	// for permissions added by a newer version of Deno than this provider knows about
+	AllowUnknown bool `json:"allowUnknown,omitempty"`
+}
+
+// knownPermissions are the names of the permissions Deno understands, as in --allow-<name>.
+var knownPermissions = []string{"net", "read", "write", "run", "env", "sys", "ffi", "import", "hrtime"}
+
+// ErrInvalidPermission is returned by Validate for each allow or deny entry that is not a Deno permission.
+var ErrInvalidPermission = errors.New("invalid deno permission")
+
+// Validate checks every allow and deny entry is a known Deno permission, either on its own
+// (eg: "net") or scoped to a value (eg: "net=example.com"), so a typo fails fast with a clear
+// error rather than as a cryptic Deno startup failure. Every invalid entry is reported.
+func (permissions *Permissions) Validate() error {
+	if permissions == nil {
+		return nil
+	}
+	var errs []error
+	for _, entry := range permissions.Allow {
+		if err := permissions.validateEntry(entry); err != nil {
+			errs = append(errs, fmt.Errorf("allow %q: %w", entry, err))
+		}
+	}
+	for _, entry := range permissions.Deny {
+		if err := permissions.validateEntry(entry); err != nil {
+			errs = append(errs, fmt.Errorf("deny %q: %w", entry, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateEntry checks a single allow or deny entry.
+func (permissions *Permissions) validateEntry(entry string) error {
+	name, value, scoped := strings.Cut(entry, "=")
+	if name == "" {
+		return fmt.Errorf("%w: missing permission name", ErrInvalidPermission)
+	}
+	if scoped {
+		if _, err := splitScopedValues(value); err != nil {
+			return err
+		}
+	}
+	if !permissions.AllowUnknown && !slices.Contains(knownPermissions, name) {
+		return fmt.Errorf("%w: unknown permission %q, expected one of %s", ErrInvalidPermission, name, strings.Join(knownPermissions, ", "))
+	}
+	return nil
 }
 
 // MapToDenoPermissionsTF converts Go-native Permissions to Terraform Framework types.
@@ -60,6 +112,20 @@ func (permissions *Permissions) MapToDenoPermissionsTF() *PermissionsTF {
 	return output
 }
 
+// splitScopedValues splits the value of a scoped entry, eg: "/tmp,/var" of "read=/tmp,/var", into
+// its comma separated values. Deno splits scoped values on commas and has no way to escape them,
+// so an empty value, which is what a path containing a comma would leave behind, is an error.
+func splitScopedValues(value string) ([]string, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing value after =", ErrInvalidPermission)
+	}
+	values := strings.Split(value, ",")
+	if slices.Contains(values, "") {
+		return nil, fmt.Errorf("%w: empty value in %q, values are separated by commas so a value can not contain one", ErrInvalidPermission, value)
+	}
+	return values, nil
+}
+
 // PermissionsTF represents Deno runtime security permissions using Terraform Framework types.
 // This struct is used for schema definitions and state management in Terraform.
 type PermissionsTF struct {
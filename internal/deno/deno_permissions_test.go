@@ -1,6 +1,8 @@
 package deno
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
@@ -144,3 +146,56 @@ func TestDenoPermissions_MapToDenoPermissions_NullLists(t *testing.T) {
 		t.Errorf("Expected empty or nil Deny list for null value, got %d items", len(result.Deny))
 	}
 }
+
+// TestDenoPermissions_Validate tests that known permissions, scoped or not, are accepted.
+func TestDenoPermissions_Validate(t *testing.T) {
+	perms := &Permissions{
+		Allow: []string{"net", "read=/tmp", "env=HOME,PATH", "import=jsr.io"},
+		Deny:  []string{"run", "write=/etc"},
+	}
+	if err := perms.Validate(); err != nil {
+		t.Errorf("Expected valid permissions, got %v", err)
+	}
+
+	var nilPerms *Permissions
+	if err := nilPerms.Validate(); err != nil {
+		t.Errorf("Expected nil permissions to be valid, got %v", err)
+	}
+}
+
+// TestDenoPermissions_Validate_Invalid tests that every invalid entry is reported.
+func TestDenoPermissions_Validate_Invalid(t *testing.T) {
+	perms := &Permissions{
+		Allow: []string{"nett", "read", "=/tmp", "read=/a,,b"},
+		Deny:  []string{"write=", "sys"},
+	}
+	err := perms.Validate()
+	if !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("Expected ErrInvalidPermission, got %v", err)
+	}
+
+	for _, entry := range []string{`allow "nett"`, `allow "=/tmp"`, `allow "read=/a,,b"`, `deny "write="`} {
+		if !strings.Contains(err.Error(), entry) {
+			t.Errorf("Expected error to report %s, got %v", entry, err)
+		}
+	}
+	for _, entry := range []string{`allow "read"`, `deny "sys"`} {
+		if strings.Contains(err.Error(), entry) {
+			t.Errorf("Expected error not to report %s, got %v", entry, err)
+		}
+	}
+}
+
+// TestDenoPermissions_Validate_AllowUnknown tests the escape hatch for permissions newer than the provider.
+func TestDenoPermissions_Validate_AllowUnknown(t *testing.T) {
+	perms := &Permissions{Allow: []string{"gpu", "net=example.com"}, AllowUnknown: true}
+	if err := perms.Validate(); err != nil {
+		t.Errorf("Expected unknown permissions to be allowed, got %v", err)
+	}
+
+	// Malformed entries are still rejected
+	perms.Allow = []string{"gpu="}
+	if err := perms.Validate(); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("Expected ErrInvalidPermission, got %v", err)
+	}
+}
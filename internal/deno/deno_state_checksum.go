@@ -0,0 +1,68 @@
+package deno
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// StateChecksum computes the checksum a script returns alongside its state to opt in to
+// integrity checks, the hex encoded sha256 of the state's JSON encoding with object keys
+// sorted and no HTML escaping, matching the stateChecksum helper in the TypeScript library.
+func StateChecksum(state any) (string, error) {
+	// Round trip through JSON so that structs and maps alike end up as sorted key maps
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state for checksum: %w", err)
+	}
+	var normalized any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return "", fmt.Errorf("failed to unmarshal state for checksum: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(normalized); err != nil {
+		return "", fmt.Errorf("failed to encode state for checksum: %w", err)
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// verifyStateChecksum compares the read state against the checksum stored from the last
+// create or update, a mismatch is appended to the response as a warning diagnostic.
+func (r *CreateReadResponse) verifyStateChecksum(id string, want string) error {
+	got, err := StateChecksum(*r.State)
+	if err != nil {
+		return err
+	}
+	if got == want {
+		return nil
+	}
+
+	if r.Diagnostics == nil {
+		r.Diagnostics = &[]struct {
+			Severity string    `json:"severity"`
+			Summary  string    `json:"summary"`
+			Detail   string    `json:"detail"`
+			PropPath *[]string `json:"propPath,omitempty"`
+		}{}
+	}
+	*r.Diagnostics = append(*r.Diagnostics, struct {
+		Severity string    `json:"severity"`
+		Summary  string    `json:"summary"`
+		Detail   string    `json:"detail"`
+		PropPath *[]string `json:"propPath,omitempty"`
+	}{
+		Severity: "warning",
+		Summary:  "State checksum mismatch",
+		Detail: fmt.Sprintf(
+			"The state read for resource %s does not match the checksum stored when it was last written (expected %s, got %s), it may have been modified outside of Terraform.",
+			id, want, got,
+		),
+	})
+	return nil
+}
@@ -0,0 +1,28 @@
+package deno
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestStateChecksum(t *testing.T) {
+	// sha256 of {"a":"<x>","b":1.5,"c":[true,null]}, the same bytes JSON.stringify produces with sorted keys
+	const expected = "5ae96a95871bf1449202c2e4008789125629579eb02244bb173b44cdb0950306"
+
+	t.Run("sorted keys", func(t *testing.T) {
+		actual, err := StateChecksum(map[string]any{"c": []any{true, nil}, "b": 1.5, "a": "<x>"})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		actual, err := StateChecksum(struct {
+			C []any   `json:"c"`
+			B float64 `json:"b"`
+			A string  `json:"a"`
+		}{C: []any{true, nil}, B: 1.5, A: "<x>"})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+}
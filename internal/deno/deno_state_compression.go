@@ -0,0 +1,86 @@
+package deno
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// gzipStateMarker is the key of the single key object a compressed state blob is stored as,
+// eg: {"$gzip": "H4sIAAAAAAAA/..."}. Its presence tells the provider the blob is compressed.
+const gzipStateMarker = "$gzip"
+
+// DefaultStateCompressionThreshold is the size in bytes of the JSON encoded state, over which
+// state blobs are compressed when state compression is enabled.
+const DefaultStateCompressionThreshold = 1024
+
+// compressState gzips the JSON encoding of state into a marker object when it is at least
+// threshold bytes long. Smaller states, or a threshold of zero, return state unchanged.
+func compressState(state any, threshold int) (any, error) {
+	if state == nil || threshold <= 0 || isCompressedState(state) {
+		return state, nil
+	}
+
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state for compression: %w", err)
+	}
+	if len(raw) < threshold {
+		return state, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, fmt.Errorf("failed to compress state: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress state: %w", err)
+	}
+
+	return map[string]any{gzipStateMarker: base64.StdEncoding.EncodeToString(buf.Bytes())}, nil
+}
+
+// decompressState reverses compressState, any state without the marker is returned unchanged.
+// Numbers are decoded as json.Number so the state is passed on to the script byte for byte.
+func decompressState(state any) (any, error) {
+	if !isCompressedState(state) {
+		return state, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(state.(map[string]any)[gzipStateMarker].(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode compressed state: %w", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress state: %w", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress state: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decompressed any
+	if err := decoder.Decode(&decompressed); err != nil {
+		return nil, fmt.Errorf("failed to decode decompressed state: %w", err)
+	}
+
+	return decompressed, nil
+}
+
+// isCompressedState returns true if state is a compressed state marker object.
+func isCompressedState(state any) bool {
+	m, ok := state.(map[string]any)
+	if !ok || len(m) != 1 {
+		return false
+	}
+	_, ok = m[gzipStateMarker].(string)
+	return ok
+}
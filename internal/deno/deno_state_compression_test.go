@@ -0,0 +1,68 @@
+package deno
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCompressState(t *testing.T) {
+	state := map[string]any{"greeting": "hello world"}
+
+	t.Run("disabled", func(t *testing.T) {
+		actual, err := compressState(state, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, any(state), actual)
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		actual, err := compressState(state, 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, any(state), actual)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		actual, err := compressState(nil, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, nil, actual)
+	})
+
+	t.Run("already compressed", func(t *testing.T) {
+		compressed, err := compressState(state, 1)
+		assert.NoError(t, err)
+		actual, err := compressState(compressed, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, compressed, actual)
+	})
+}
+
+func TestDecompressState(t *testing.T) {
+	t.Run("round trip preserves numbers", func(t *testing.T) {
+		original := `{"big":12345678901234567890,"list":[1,2,3],"pi":3.141592653589793238}`
+		var state any
+		assert.NoError(t, json.Unmarshal([]byte(original), &state))
+
+		compressed, err := compressState(json.RawMessage(original), 1)
+		assert.NoError(t, err)
+		assert.True(t, isCompressedState(compressed))
+
+		decompressed, err := decompressState(compressed)
+		assert.NoError(t, err)
+		actual, err := json.Marshal(decompressed)
+		assert.NoError(t, err)
+		assert.Equal(t, original, string(actual))
+	})
+
+	t.Run("uncompressed state is unchanged", func(t *testing.T) {
+		state := map[string]any{"$gzip": "not alone", "other": true}
+		actual, err := decompressState(state)
+		assert.NoError(t, err)
+		assert.Equal(t, any(state), actual)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, err := decompressState(map[string]any{"$gzip": "bm90IGd6aXA="})
+		assert.Error(t, err)
+	})
+}
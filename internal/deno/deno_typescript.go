@@ -0,0 +1,199 @@
+package deno
+
+//go:generate go run ./gendts -o ../../lib/providers/rpc_types.d.ts
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// typeScriptTypes are the request & response types of the JSON-RPC methods, written by
+// GenerateTypeScript in this order.
+var typeScriptTypes = []any{
+	// Startup
+	HealthRequest{},
+	HealthResponse{},
+
+	// Resource
+	CreateRequest{},
+	CreateResponse{},
+	PartialCreateState{},
+	CreateStatusRequest{},
+	CreateProgress{},
+	CreateStatusResponse{},
+	CreateReadRequest{},
+	CreateReadResponse{},
+	UpdateRequest{},
+	UpdateResponse{},
+	DeleteRequest{},
+	DeleteResponse{},
+	DeleteProgress{},
+	DeleteCompleteRequest{},
+	DeleteBatchRequest{},
+	DeleteBatchResponse{},
+	ModifyPlanRequest{},
+	ModifyPlanResponse{},
+	ImportRequest{},
+	ImportResponse{},
+	ImportSnapshotRequest{},
+	ExportStateRequest{},
+	ExportStateResponse{},
+
+	// Ephemeral Resource
+	OpenRequest{},
+	OpenResponse{},
+	RenewRequest{},
+	RenewResponse{},
+	CloseRequest{},
+	CloseResponse{},
+
+	// Action
+	InvokeRequest{},
+	InvokeResponse{},
+	InvokeProgressRequest{},
+
+	// Datasource
+	ReadRequest{},
+	ReadResponse{},
+	ReadChunkRequest{},
+}
+
+// GenerateTypeScript writes a TypeScript declaration file with an interface matching the JSON encoding of
+// each JSON-RPC request & response type, so scripts can type what they receive and return without drifting.
+// Fields with omitempty are optional and pointers are nullable. The output is deterministic.
+func GenerateTypeScript(w io.Writer) error {
+	named := map[reflect.Type]bool{}
+	for _, v := range typeScriptTypes {
+		named[reflect.TypeOf(v)] = true
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "// Code generated by internal/deno/gendts. DO NOT EDIT.")
+	for _, v := range typeScriptTypes {
+		t := reflect.TypeOf(v)
+		body, err := typeScriptObject(t, named, "")
+		if err != nil {
+			return fmt.Errorf("failed to generate typescript for %s: %w", t.Name(), err)
+		}
+		fmt.Fprintf(out, "\nexport interface %s %s\n", t.Name(), body)
+	}
+	return out.Flush()
+}
+
+// jsonMarshalerType is used to find types with a custom JSON encoding, which are typed as unknown.
+var jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+
+// typeScriptType returns the TypeScript type of the JSON encoding of t. Types in named are referenced
+// by name, other structs are written inline at the given indent.
+func typeScriptType(t reflect.Type, named map[reflect.Type]bool, indent string) (string, error) {
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return "unknown", nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return "unknown", nil
+	case reflect.Pointer:
+		elem, err := typeScriptType(t.Elem(), named, indent)
+		if err != nil || elem == "unknown" {
+			return elem, err
+		}
+		return elem + " | null", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number", nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return "string", nil
+		}
+		elem, err := typeScriptType(t.Elem(), named, indent)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, " | ") && !strings.HasSuffix(elem, "}") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return "", fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		elem, err := typeScriptType(t.Elem(), named, indent)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + elem + ">", nil
+	case reflect.Struct:
+		if named[t] {
+			return t.Name(), nil
+		}
+		return typeScriptObject(t, named, indent)
+	default:
+		return "", fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// typeScriptObject returns a TypeScript object type with a property for each field in the JSON encoding
+// of the struct t, with its closing brace at the given indent.
+func typeScriptObject(t reflect.Type, named map[reflect.Type]bool, indent string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	if err := writeTypeScriptFields(&sb, t, named, indent+"  "); err != nil {
+		return "", err
+	}
+	sb.WriteString(indent + "}")
+	return sb.String(), nil
+}
+
+// writeTypeScriptFields writes a TypeScript property for each field in the JSON encoding of the
+// struct t, flattening embedded structs like encoding/json does.
+func writeTypeScriptFields(sb *strings.Builder, t reflect.Type, named map[reflect.Type]bool, indent string) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := writeTypeScriptFields(sb, embedded, named, indent); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		tsType, err := typeScriptType(field.Type, named, indent)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		optional := ""
+		if strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,") {
+			optional = "?"
+		}
+		fmt.Fprintf(sb, "%s%s%s: %s;\n", indent, name, optional, tsType)
+	}
+	return nil
+}
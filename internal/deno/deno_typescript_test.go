@@ -0,0 +1,110 @@
+package deno
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestGenerateTypeScript(t *testing.T) {
+	var first, second bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&first))
+	assert.NoError(t, GenerateTypeScript(&second))
+	assert.Equal(t, first.String(), second.String())
+
+	// The committed declarations must be regenerated with go generate when the types change
+	committed, err := os.ReadFile("../../lib/providers/rpc_types.d.ts")
+	assert.NoError(t, err)
+	assert.Equal(t, string(committed), first.String())
+}
+
+func TestGenerateTypeScript_AllRequestsAndResponses(t *testing.T) {
+	listed := map[string]bool{}
+	for _, v := range typeScriptTypes {
+		listed[reflect.TypeOf(v).Name()] = true
+	}
+
+	// Every exported request & response type of the package must be in typeScriptTypes
+	sources, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+	for _, source := range sources {
+		if strings.HasSuffix(source, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), source, nil, parser.SkipObjectResolution)
+		assert.NoError(t, err)
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				name := typeSpec.Name.Name
+				// Generic types, eg: TypedReadResponse, are Go side views of a response, not sent as such
+				if !ast.IsExported(name) || typeSpec.TypeParams != nil {
+					continue
+				}
+				if strings.HasSuffix(name, "Request") || strings.HasSuffix(name, "Response") {
+					assert.True(t, listed[name], "%s is missing from typeScriptTypes", name)
+				}
+			}
+		}
+	}
+}
+
+func TestTypeScriptObject(t *testing.T) {
+	type embedded struct {
+		Embedded string `json:"embedded"`
+	}
+	type example struct {
+		embedded
+		Required string `json:"required"`
+		Optional int64  `json:"optional,omitempty"`
+		Nullable *bool  `json:"nullable"`
+		Unknown  *any   `json:"unknown,omitempty"`
+		Skipped  bool   `json:"-"`
+		Untagged float64
+		List     []*string         `json:"list"`
+		Map      map[string]string `json:"map"`
+		Named    *CreateProgress   `json:"named,omitempty"`
+		Inline   []struct {
+			Name string `json:"name"`
+		} `json:"inline"`
+		unexported string
+	}
+
+	named := map[reflect.Type]bool{reflect.TypeFor[CreateProgress](): true}
+	actual, err := typeScriptObject(reflect.TypeFor[example](), named, "")
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  embedded: string;
+  required: string;
+  optional?: number;
+  nullable: boolean | null;
+  unknown?: unknown;
+  Untagged: number;
+  list: (string | null)[];
+  map: Record<string, string>;
+  named?: CreateProgress | null;
+  inline: {
+    name: string;
+  }[];
+}`, actual)
+}
+
+func TestTypeScriptObject_Unsupported(t *testing.T) {
+	type example struct {
+		Callback func() `json:"callback"`
+	}
+	_, err := typeScriptObject(reflect.TypeFor[example](), nil, "")
+	assert.EqualError(t, err, "field Callback: unsupported type func()")
+}
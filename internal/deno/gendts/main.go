@@ -0,0 +1,32 @@
+// Command gendts writes the TypeScript interfaces matching the JSON-RPC request & response
+// types of the deno package, see deno.GenerateTypeScript. It is run by go generate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/deno"
+)
+
+func main() {
+	out := flag.String("o", "", "the .d.ts file to write, defaults to stdout")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := deno.GenerateTypeScript(&buf); err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
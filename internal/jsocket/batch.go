@@ -0,0 +1,262 @@
+package jsocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// BatchItem is a single call sent as part of a batch by CallBatch.
+type BatchItem struct {
+	// Method is the remote method to invoke
+	Method string
+	// Params contains the input parameters, nil omits them from the request
+	Params any
+}
+
+// BatchResult is the outcome of a single call sent as part of a batch by CallBatch.
+type BatchResult struct {
+	// Result is the raw JSON result of the call, set when it succeeded, a CBOR result is transcoded to JSON
+	Result json.RawMessage
+	// Err is why the call failed, set when it did, a *jsonrpc2.Error for errors returned by the remote method
+	Err error
+}
+
+// Unmarshal decodes the result of the call into v, or returns the error of the call.
+func (r BatchResult) Unmarshal(v any) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return json.Unmarshal(r.Result, v)
+}
+
+// CallBatch sends many JSON-RPC requests to the remote peer as a single JSON-RPC 2.0 batch,
+// one array written in one go, and waits for all of their responses.
+//
+// Results are returned in the same order as items, whatever order the remote peer processed
+// or answered them in, responses are correlated with their requests by id. Each call succeeds
+// or fails on its own, so an error returned by one remote method does not fail the others.
+// The returned error is only set when the batch could not be sent at all. Like Call, the calls
+// still outstanding when the context is cancelled are cancelled on the remote peer, and a context
+// without a deadline is bounded by DefaultCallTimeout.
+func (j *JSocket) CallBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel, timeout := j.withDefaultTimeout(ctx)
+	defer cancel()
+
+	// Marshal all params up front, so a bad item fails the batch before anything is sent
+	params := make([]any, len(items))
+	for i, item := range items {
+		if item.Params == nil {
+			continue
+		}
+		payload, err := j.encodePayload(item.Params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode params of batch item %d (%s): %w", i, item.Method, err)
+		}
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal params of batch item %d (%s): %w", i, item.Method, err)
+		}
+		params[i] = json.RawMessage(raw)
+	}
+
+	ids := make([]jsonrpc2.ID, len(items))
+	seq := j.batchSeq.Add(1)
+	for i := range items {
+		ids[i] = jsonrpc2.ID{Str: fmt.Sprintf("batch-%d-%d", seq, i), IsString: true}
+	}
+	if err := j.claimIDs(ids...); err != nil {
+		return nil, fmt.Errorf("failed to send batch: %w", err)
+	}
+	defer j.releaseIDs(ids...)
+
+	// The stream holds back the requests until the last one is written, then writes them as one array
+	j.stream.expectOutgoing(ids)
+	waiters := make([]jsonrpc2.Waiter, len(items))
+	for i, item := range items {
+		waiter, err := j.conn.DispatchCall(ctx, item.Method, params[i], jsonrpc2.PickID(ids[i]))
+		if err != nil {
+			j.stream.abandonOutgoing(ids)
+			return nil, fmt.Errorf("failed to send batch: %w", err)
+		}
+		waiters[i] = waiter
+	}
+
+	results := make([]BatchResult, len(items))
+	for i, waiter := range waiters {
+		results[i].Err = waiter.Wait(ctx, &results[i].Result)
+		if results[i].Err == nil {
+			results[i].Result, results[i].Err = payloadJSON(results[i].Result)
+		}
+		if results[i].Err != nil && ctx.Err() != nil && errors.Is(results[i].Err, ctx.Err()) {
+			j.cancelRequest(ids[i])
+		}
+		results[i].Err = callTimeoutError(messageTooLargeError(results[i].Err), items[i].Method, timeout)
+	}
+	return results, nil
+}
+
+// NewBatchStream wraps stream with support for JSON-RPC 2.0 batches, see CallBatch. JSocket uses it
+// for every connection, it is exported for peers that use jsonrpc2 directly and want to answer batches.
+func NewBatchStream(stream jsonrpc2.ObjectStream) jsonrpc2.ObjectStream {
+	return &batchStream{ObjectStream: stream}
+}
+
+// batchStream wraps an ObjectStream with support for JSON-RPC 2.0 batches, which jsonrpc2 does not
+// understand. Incoming arrays are split into their messages, and the responses to an incoming batch
+// of requests are held back and written as a single array once all of them are ready. Likewise the
+// requests of an outgoing batch, started by CallBatch, are held back and written as a single array.
+type batchStream struct {
+	jsonrpc2.ObjectStream
+
+	// queue holds the remaining messages of the last array read, only touched by the read loop
+	queue []json.RawMessage
+
+	mu       sync.Mutex
+	incoming map[jsonrpc2.ID]*pendingBatch
+	outgoing map[jsonrpc2.ID]*pendingBatch
+}
+
+// pendingBatch collects the messages of a batch until all of them have been written.
+type pendingBatch struct {
+	index    map[jsonrpc2.ID]int
+	messages []json.RawMessage
+	missing  int
+}
+
+// newPendingBatch creates a pendingBatch for the given ids, in order.
+func newPendingBatch(ids []jsonrpc2.ID) *pendingBatch {
+	b := &pendingBatch{
+		index:    make(map[jsonrpc2.ID]int, len(ids)),
+		messages: make([]json.RawMessage, len(ids)),
+		missing:  len(ids),
+	}
+	for i, id := range ids {
+		b.index[id] = i
+	}
+	return b
+}
+
+// batchMessage is the part of a JSON-RPC message needed to route it.
+type batchMessage struct {
+	ID     *jsonrpc2.ID `json:"id"`
+	Method *string      `json:"method"`
+}
+
+// expectOutgoing holds back the requests with the given ids until all of them have been written.
+func (s *batchStream) expectOutgoing(ids []jsonrpc2.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.outgoing == nil {
+		s.outgoing = make(map[jsonrpc2.ID]*pendingBatch)
+	}
+	batch := newPendingBatch(ids)
+	for _, id := range ids {
+		s.outgoing[id] = batch
+	}
+}
+
+// abandonOutgoing forgets an outgoing batch that failed to be sent.
+func (s *batchStream) abandonOutgoing(ids []jsonrpc2.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.outgoing, id)
+	}
+}
+
+// ReadObject implements jsonrpc2.ObjectStream.
+func (s *batchStream) ReadObject(v any) error {
+	if len(s.queue) == 0 {
+		var raw json.RawMessage
+		if err := s.ObjectStream.ReadObject(&raw); err != nil {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+			return json.Unmarshal(raw, v)
+		}
+		if err := json.Unmarshal(raw, &s.queue); err != nil {
+			return err
+		}
+		if len(s.queue) == 0 {
+			return fmt.Errorf("jsonrpc2: empty batch")
+		}
+		s.expectIncoming(s.queue)
+	}
+	next := s.queue[0]
+	s.queue = s.queue[1:]
+	return json.Unmarshal(next, v)
+}
+
+// expectIncoming holds back the responses to the requests of an incoming batch until all of
+// them have been written. Notifications and responses in the batch are not answered.
+func (s *batchStream) expectIncoming(messages []json.RawMessage) {
+	var ids []jsonrpc2.ID
+	for _, raw := range messages {
+		var msg batchMessage
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.Method != nil && msg.ID != nil {
+			ids = append(ids, *msg.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.incoming == nil {
+		s.incoming = make(map[jsonrpc2.ID]*pendingBatch)
+	}
+	batch := newPendingBatch(ids)
+	for _, id := range ids {
+		s.incoming[id] = batch
+	}
+}
+
+// WriteObject implements jsonrpc2.ObjectStream.
+func (s *batchStream) WriteObject(obj any) error {
+	s.mu.Lock()
+	if len(s.incoming) == 0 && len(s.outgoing) == 0 {
+		s.mu.Unlock()
+		return s.ObjectStream.WriteObject(obj)
+	}
+	defer s.mu.Unlock()
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var msg batchMessage
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.ID == nil {
+		return s.ObjectStream.WriteObject(json.RawMessage(raw))
+	}
+
+	// Requests belong to outgoing batches and responses to incoming ones
+	pending := s.incoming
+	if msg.Method != nil {
+		pending = s.outgoing
+	}
+	batch, ok := pending[*msg.ID]
+	if !ok {
+		return s.ObjectStream.WriteObject(json.RawMessage(raw))
+	}
+
+	batch.messages[batch.index[*msg.ID]] = raw
+	batch.missing--
+	if batch.missing > 0 {
+		return nil
+	}
+	for id := range batch.index {
+		delete(pending, id)
+	}
+	return s.ObjectStream.WriteObject(batch.messages)
+}
@@ -0,0 +1,800 @@
+package jsocket
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// cborMaxDepth bounds the nesting of CBOR items, so a cyclic value or a hostile payload
+// fails rather than exhausting the stack.
+const cborMaxDepth = 1000
+
+// CBOR major types, see RFC 8949 section 3.1.
+const (
+	cborUint   byte = 0
+	cborNegInt byte = 1
+	cborBytes  byte = 2
+	cborText   byte = 3
+	cborArray  byte = 4
+	cborMap    byte = 5
+	cborTag    byte = 6
+	cborSimple byte = 7
+)
+
+// CBOR simple values and the break stop code of indefinite length items.
+const (
+	cborFalse   byte = 0xf4
+	cborTrue    byte = 0xf5
+	cborNull    byte = 0xf6
+	cborFloat64 byte = 0xfb
+	cborBreak   byte = 0xff
+)
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	jsonNumberType    = reflect.TypeFor[json.Number]()
+)
+
+// marshalCBOR encodes v as CBOR, following the rules of encoding/json: struct fields are named by
+// their json tags and honour omitempty, omitzero & "-", embedded structs are flattened and types
+// implementing json.Marshaler encode as the value of their JSON. Unlike JSON, a []byte encodes as
+// a CBOR byte string and integers keep their full 64 bits.
+func marshalCBOR(v any) ([]byte, error) {
+	e := &cborEncoder{}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// cborEncoder appends CBOR items to buf.
+type cborEncoder struct {
+	buf []byte
+}
+
+// head appends the head of an item of the given major type, with n as its argument.
+func (e *cborEncoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major<<5|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major<<5|27), n)
+	}
+}
+
+// text appends a text string.
+func (e *cborEncoder) text(s string) {
+	e.head(cborText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// int appends a signed integer.
+func (e *cborEncoder) int(n int64) {
+	if n < 0 {
+		e.head(cborNegInt, uint64(-1-n))
+		return
+	}
+	e.head(cborUint, uint64(n))
+}
+
+// float appends a float, like encoding/json NaN and infinities are not supported.
+func (e *cborEncoder) float(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("cbor: unsupported value: %v", f)
+	}
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, cborFloat64), math.Float64bits(f))
+	return nil
+}
+
+// number appends a json.Number as an integer when it is one, otherwise as a float.
+func (e *cborEncoder) number(n json.Number) error {
+	if n == "" {
+		n = "0"
+	}
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		e.int(i)
+		return nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		e.head(cborUint, u)
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("cbor: invalid number literal %q", n)
+	}
+	return e.float(f)
+}
+
+// json appends the value of a JSON document, eg: the output of a json.Marshaler.
+func (e *cborEncoder) json(raw []byte, depth int) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return fmt.Errorf("cbor: invalid JSON from MarshalJSON: %w", err)
+	}
+	return e.encode(reflect.ValueOf(v), depth+1)
+}
+
+// marshaler returns the json.Marshaler or encoding.TextMarshaler implemented by v, if any,
+// including those implemented by a pointer to an addressable v.
+func marshaler(v reflect.Value, iface reflect.Type) (any, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(iface) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// encode appends the CBOR encoding of v.
+func (e *cborEncoder) encode(v reflect.Value, depth int) error {
+	if depth > cborMaxDepth {
+		return errors.New("cbor: value nested too deeply")
+	}
+	if !v.IsValid() {
+		e.buf = append(e.buf, cborNull)
+		return nil
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf = append(e.buf, cborNull)
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		return e.encode(v.Elem(), depth+1)
+	}
+
+	// Like encoding/json, types that marshal themselves do so, JSON first
+	if m, ok := marshaler(v, jsonMarshalerType); ok {
+		raw, err := m.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("cbor: error calling MarshalJSON for type %s: %w", v.Type(), err)
+		}
+		return e.json(raw, depth)
+	}
+	if m, ok := marshaler(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return fmt.Errorf("cbor: error calling MarshalText for type %s: %w", v.Type(), err)
+		}
+		e.text(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, cborTrue)
+		} else {
+			e.buf = append(e.buf, cborFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.head(cborUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return e.float(v.Float())
+	case reflect.String:
+		if v.Type() == jsonNumberType {
+			return e.number(json.Number(v.String()))
+		}
+		e.text(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(cborBytes, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.array(v, depth)
+	case reflect.Array:
+		return e.array(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		return e.mapping(v, depth)
+	case reflect.Struct:
+		return e.structure(v, depth)
+	case reflect.Pointer:
+		return e.encode(v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("cbor: unsupported type: %s", v.Type())
+	}
+	return nil
+}
+
+// array appends the elements of a slice or array.
+func (e *cborEncoder) array(v reflect.Value, depth int) error {
+	e.head(cborArray, uint64(v.Len()))
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapping appends the entries of a map, sorted by key like encoding/json.
+func (e *cborEncoder) mapping(v reflect.Value, depth int) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	e.head(cborMap, uint64(len(entries)))
+	for _, entry := range entries {
+		e.text(entry.key)
+		if err := e.encode(entry.value, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapKey returns the text of a map key, following the rules of encoding/json.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := marshaler(k, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("cbor: error calling MarshalText for type %s: %w", k.Type(), err)
+		}
+		return string(text), nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cbor: unsupported map key type: %s", k.Type())
+}
+
+// structure appends the fields of a struct as a map.
+func (e *cborEncoder) structure(v reflect.Value, depth int) error {
+	type entry struct {
+		name  string
+		value reflect.Value
+	}
+	var entries []entry
+	for _, field := range cachedCBORFields(v.Type()) {
+		value, ok := fieldByIndex(v, field.index)
+		if !ok {
+			continue
+		}
+		if field.omitEmpty && isEmptyValue(value) || field.omitZero && isZeroValue(value) {
+			continue
+		}
+		entries = append(entries, entry{field.name, value})
+	}
+
+	e.head(cborMap, uint64(len(entries)))
+	for _, entry := range entries {
+		e.text(entry.name)
+		if err := e.encode(entry.value, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of v at index, false when it is reached through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty as far as omitempty is concerned.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// isZeroValue reports whether v is zero as far as omitzero is concerned, using its IsZero method if it has one.
+func isZeroValue(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return v.IsZero()
+	}
+	if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return true
+		}
+		return z.IsZero()
+	}
+	return v.IsZero()
+}
+
+// cborField is a struct field encoded by marshalCBOR.
+type cborField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	omitZero  bool
+}
+
+// cborFields caches the fields of each struct type encoded by marshalCBOR.
+var cborFields sync.Map
+
+// cachedCBORFields returns the fields of struct type t, see structFields.
+func cachedCBORFields(t reflect.Type) []cborField {
+	if fields, ok := cborFields.Load(t); ok {
+		return fields.([]cborField)
+	}
+	fields, _ := cborFields.LoadOrStore(t, structFields(t))
+	return fields.([]cborField)
+}
+
+// structFields returns the fields of struct type t that encoding/json would encode, in order. Like
+// encoding/json, the fields of untagged embedded structs are promoted, and of the fields sharing a name
+// the shallowest wins, then the tagged one, while a tie drops all of them.
+func structFields(t reflect.Type) []cborField {
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+	var candidates []cborField
+	visited := map[reflect.Type]bool{}
+	current := []level{{typ: t}}
+	for len(current) > 0 {
+		var next []level
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+
+			for i := range l.typ.NumField() {
+				sf := l.typ.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(l.index), i)
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, level{typ: ft, index: index})
+					continue
+				}
+				field := cborField{name: name, index: index, tagged: name != ""}
+				if name == "" {
+					field.name = sf.Name
+				}
+				for opt := range strings.SplitSeq(opts, ",") {
+					field.omitEmpty = field.omitEmpty || opt == "omitempty"
+					field.omitZero = field.omitZero || opt == "omitzero"
+				}
+				candidates = append(candidates, field)
+			}
+		}
+		current = next
+	}
+
+	// Keep the dominant field of each name
+	byName := map[string][]cborField{}
+	for _, field := range candidates {
+		byName[field.name] = append(byName[field.name], field)
+	}
+	var fields []cborField
+	for _, named := range byName {
+		slices.SortStableFunc(named, func(a, b cborField) int {
+			if len(a.index) != len(b.index) {
+				return len(a.index) - len(b.index)
+			}
+			if a.tagged != b.tagged {
+				if a.tagged {
+					return -1
+				}
+				return 1
+			}
+			return 0
+		})
+		if len(named) > 1 && len(named[0].index) == len(named[1].index) && named[0].tagged == named[1].tagged {
+			continue
+		}
+		fields = append(fields, named[0])
+	}
+	slices.SortFunc(fields, func(a, b cborField) int { return slices.Compare(a.index, b.index) })
+	return fields
+}
+
+// unmarshalCBOR decodes CBOR data into v, by way of the JSON document it stands for, so a payload
+// decodes exactly the same whichever encoding carried it. Byte strings become base64 strings, which
+// encoding/json decodes into a []byte, and integers keep all of their digits.
+func unmarshalCBOR(data []byte, v any) error {
+	raw, err := cborToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// cborToJSON transcodes a single CBOR item to JSON. Tags other than bignums are ignored, undefined
+// becomes null, and only text & integer map keys are supported.
+func cborToJSON(data []byte) ([]byte, error) {
+	d := &cborDecoder{data: data}
+	if err := d.item(0); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("cbor: %d trailing bytes after the top level item", len(d.data)-d.pos)
+	}
+	return d.out, nil
+}
+
+// cborDecoder transcodes the CBOR in data to JSON in out.
+type cborDecoder struct {
+	data []byte
+	pos  int
+	out  []byte
+}
+
+// errCBORTruncated is returned when the data ends in the middle of an item.
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// read returns the next n bytes.
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head reads the head of the next item, returning its major type, additional info and argument.
+// The argument of an indefinite length item, whose additional info is 31, is zero.
+func (d *cborDecoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		b, err := d.read(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, x := range b {
+			arg = arg<<8 | uint64(x)
+		}
+		return major, info, arg, nil
+	case info == 31 && major >= cborBytes && major <= cborMap || info == 31 && major == cborSimple:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("cbor: invalid additional info %d for major type %d", info, major)
+}
+
+// atBreak consumes the break stop code that ends an indefinite length item, if it is next.
+func (d *cborDecoder) atBreak() (bool, error) {
+	if d.pos >= len(d.data) {
+		return false, errCBORTruncated
+	}
+	if d.data[d.pos] == cborBreak {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// item transcodes the next item.
+func (d *cborDecoder) item(depth int) error {
+	if depth > cborMaxDepth {
+		return errors.New("cbor: data nested too deeply")
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborUint:
+		d.out = strconv.AppendUint(d.out, arg, 10)
+	case cborNegInt:
+		d.negative(arg)
+	case cborBytes:
+		b, err := d.str(cborBytes, info, arg)
+		if err != nil {
+			return err
+		}
+		d.out = append(d.out, '"')
+		d.out = base64.StdEncoding.AppendEncode(d.out, b)
+		d.out = append(d.out, '"')
+	case cborText:
+		b, err := d.str(cborText, info, arg)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(b) {
+			return errors.New("cbor: invalid UTF-8 in text string")
+		}
+		quoted, err := json.Marshal(string(b))
+		if err != nil {
+			return err
+		}
+		d.out = append(d.out, quoted...)
+	case cborArray:
+		return d.array(info, arg, depth)
+	case cborMap:
+		return d.mapping(info, arg, depth)
+	case cborTag:
+		return d.tag(arg, depth)
+	case cborSimple:
+		return d.simple(info, arg)
+	}
+	return nil
+}
+
+// negative appends the negative integer -1-n.
+func (d *cborDecoder) negative(n uint64) {
+	if n <= math.MaxInt64 {
+		d.out = strconv.AppendInt(d.out, -1-int64(n), 10)
+		return
+	}
+	i := new(big.Int).SetUint64(n)
+	d.out = i.Neg(i.Add(i, big.NewInt(1))).Append(d.out, 10)
+}
+
+// str reads a byte or text string, joining the chunks of an indefinite length one.
+func (d *cborDecoder) str(major, info byte, arg uint64) ([]byte, error) {
+	if info != 31 {
+		return d.read(arg)
+	}
+	var joined []byte
+	for {
+		done, err := d.atBreak()
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return joined, nil
+		}
+		chunkMajor, chunkInfo, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkInfo == 31 {
+			return nil, errors.New("cbor: invalid chunk in indefinite length string")
+		}
+		chunk, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		joined = append(joined, chunk...)
+	}
+}
+
+// more reports whether another element of an array or map follows, counting down n for definite lengths.
+func (d *cborDecoder) more(info byte, n *uint64) (bool, error) {
+	if info == 31 {
+		done, err := d.atBreak()
+		return !done, err
+	}
+	if *n == 0 {
+		return false, nil
+	}
+	*n--
+	return true, nil
+}
+
+// array transcodes the elements of an array.
+func (d *cborDecoder) array(info byte, n uint64, depth int) error {
+	if info != 31 && n > uint64(len(d.data)-d.pos) {
+		return errCBORTruncated
+	}
+	d.out = append(d.out, '[')
+	for i := 0; ; i++ {
+		more, err := d.more(info, &n)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if err := d.item(depth + 1); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, ']')
+	return nil
+}
+
+// mapping transcodes the entries of a map, whose keys must be text strings or integers.
+func (d *cborDecoder) mapping(info byte, n uint64, depth int) error {
+	if info != 31 && n > uint64(len(d.data)-d.pos)/2 {
+		return errCBORTruncated
+	}
+	d.out = append(d.out, '{')
+	for i := 0; ; i++ {
+		more, err := d.more(info, &n)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if err := d.key(); err != nil {
+			return err
+		}
+		d.out = append(d.out, ':')
+		if err := d.item(depth + 1); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, '}')
+	return nil
+}
+
+// key transcodes a map key to a JSON string.
+func (d *cborDecoder) key() error {
+	if d.pos >= len(d.data) {
+		return errCBORTruncated
+	}
+	switch d.data[d.pos] >> 5 {
+	case cborText:
+		return d.item(0)
+	case cborUint, cborNegInt:
+		d.out = append(d.out, '"')
+		if err := d.item(0); err != nil {
+			return err
+		}
+		d.out = append(d.out, '"')
+		return nil
+	}
+	return fmt.Errorf("cbor: unsupported map key of major type %d", d.data[d.pos]>>5)
+}
+
+// tag transcodes a tagged item, bignums become integers and other tags are ignored.
+func (d *cborDecoder) tag(number uint64, depth int) error {
+	if number != 2 && number != 3 {
+		return d.item(depth + 1)
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	if major != cborBytes {
+		return errors.New("cbor: bignum is not a byte string")
+	}
+	b, err := d.str(cborBytes, info, arg)
+	if err != nil {
+		return err
+	}
+	i := new(big.Int).SetBytes(b)
+	if number == 3 {
+		i.Neg(i.Add(i, big.NewInt(1)))
+	}
+	d.out = i.Append(d.out, 10)
+	return nil
+}
+
+// simple transcodes a simple value or float.
+func (d *cborDecoder) simple(info byte, arg uint64) error {
+	var f float64
+	switch info {
+	case 20:
+		d.out = append(d.out, "false"...)
+		return nil
+	case 21:
+		d.out = append(d.out, "true"...)
+		return nil
+	case 22, 23:
+		d.out = append(d.out, "null"...)
+		return nil
+	case 25:
+		f = halfToFloat64(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	case 31:
+		return errors.New("cbor: unexpected break")
+	default:
+		return fmt.Errorf("cbor: unsupported simple value %d", arg)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("cbor: unsupported value: %v", f)
+	}
+	d.out = strconv.AppendFloat(d.out, f, 'g', -1, 64)
+	return nil
+}
+
+// halfToFloat64 converts an IEEE 754 half precision float.
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,233 @@
+package jsocket
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestMarshalCBOR(t *testing.T) {
+	// Expected encodings are from RFC 8949 appendix A, apart from floats which are always sent as doubles
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "zero", value: 0, expected: "00"},
+		{name: "small int", value: 23, expected: "17"},
+		{name: "one byte int", value: 24, expected: "1818"},
+		{name: "two byte int", value: 1000, expected: "1903e8"},
+		{name: "max uint64", value: uint64(math.MaxUint64), expected: "1bffffffffffffffff"},
+		{name: "negative", value: -1, expected: "20"},
+		{name: "negative two bytes", value: -1000, expected: "3903e7"},
+		{name: "min int64", value: int64(math.MinInt64), expected: "3b7fffffffffffffff"},
+		{name: "float", value: 1.1, expected: "fb3ff199999999999a"},
+		{name: "json number int", value: json.Number("9007199254740993"), expected: "1b0020000000000001"},
+		{name: "json number float", value: json.Number("1.1"), expected: "fb3ff199999999999a"},
+		{name: "true", value: true, expected: "f5"},
+		{name: "false", value: false, expected: "f4"},
+		{name: "nil", value: nil, expected: "f6"},
+		{name: "nil pointer", value: (*int)(nil), expected: "f6"},
+		{name: "nil slice", value: []int(nil), expected: "f6"},
+		{name: "text", value: "a", expected: "6161"},
+		{name: "unicode text", value: "ü", expected: "62c3bc"},
+		{name: "bytes", value: []byte{1, 2, 3, 4}, expected: "4401020304"},
+		{name: "array", value: []int{1, 2, 3}, expected: "83010203"},
+		{name: "byte array", value: [2]byte{1, 2}, expected: "820102"},
+		{name: "map sorted by key", value: map[string]any{"b": []int{2, 3}, "a": 1}, expected: "a26161016162820203"},
+		{name: "int keys", value: map[int]bool{1: true}, expected: "a16131f5"},
+		{name: "raw message", value: json.RawMessage(`{"a":[1,2.5]}`), expected: "a1616182" + "01" + "fb4004000000000000"},
+		{name: "text marshaler", value: time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), expected: "74" + hex.EncodeToString([]byte("2013-03-21T20:04:00Z"))},
+		{
+			name: "struct tags",
+			value: struct {
+				Name    string `json:"name"`
+				Skipped string `json:"-"`
+				Empty   string `json:"empty,omitempty"`
+				Zero    int    `json:"zero,omitzero"`
+				Plain   bool
+				private int
+			}{Name: "a", Skipped: "b", private: 1},
+			expected: "a2646e616d65616165506c61696ef4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := marshalCBOR(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, hex.EncodeToString(data))
+		})
+	}
+}
+
+func TestMarshalCBOR_Errors(t *testing.T) {
+	type cycle struct {
+		Next *cycle `json:"next"`
+	}
+	loop := &cycle{}
+	loop.Next = loop
+
+	tests := []struct {
+		name  string
+		value any
+		err   string
+	}{
+		{name: "nan", value: math.NaN(), err: "unsupported value"},
+		{name: "infinity", value: math.Inf(1), err: "unsupported value"},
+		{name: "channel", value: make(chan int), err: "unsupported type"},
+		{name: "struct key", value: map[struct{}]int{{}: 1}, err: "unsupported map key type"},
+		{name: "cycle", value: loop, err: "nested too deeply"},
+		{name: "invalid number", value: json.Number("1x"), err: "invalid number literal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := marshalCBOR(tt.value)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestCBORToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		cbor     string
+		expected string
+	}{
+		{name: "uint", cbor: "1903e8", expected: "1000"},
+		{name: "max uint64", cbor: "1bffffffffffffffff", expected: "18446744073709551615"},
+		{name: "negative", cbor: "3903e7", expected: "-1000"},
+		{name: "min negative", cbor: "3bffffffffffffffff", expected: "-18446744073709551616"},
+		{name: "bignum", cbor: "c249010000000000000000", expected: "18446744073709551616"},
+		{name: "negative bignum", cbor: "c349010000000000000000", expected: "-18446744073709551617"},
+		{name: "half float", cbor: "f93e00", expected: "1.5"},
+		{name: "largest half float", cbor: "f97bff", expected: "65504"},
+		{name: "subnormal half float", cbor: "f90001", expected: "5.960464477539063e-08"},
+		{name: "negative half float", cbor: "f9c400", expected: "-4"},
+		{name: "single float", cbor: "fa47c35000", expected: "100000"},
+		{name: "double float", cbor: "fb3ff199999999999a", expected: "1.1"},
+		{name: "simple values", cbor: "84f4f5f6f7", expected: "[false,true,null,null]"},
+		{name: "bytes", cbor: "4401020304", expected: `"AQIDBA=="`},
+		{name: "text", cbor: "6449455446", expected: `"IETF"`},
+		{name: "escaped text", cbor: "62225c", expected: `"\"\\"`},
+		{name: "indefinite bytes", cbor: "5f42010243030405ff", expected: `"AQIDBAU="`},
+		{name: "indefinite text", cbor: "7f657374726561646d696e67ff", expected: `"streaming"`},
+		{name: "empty indefinite array", cbor: "9fff", expected: "[]"},
+		{name: "nested arrays", cbor: "8301820203820405", expected: "[1,[2,3],[4,5]]"},
+		{name: "map", cbor: "a26161016162820203", expected: `{"a":1,"b":[2,3]}`},
+		{name: "indefinite map", cbor: "bf61610161629f0203ffff", expected: `{"a":1,"b":[2,3]}`},
+		{name: "int keys", cbor: "a201022003", expected: `{"1":2,"-1":3}`},
+		{name: "ignored tag", cbor: "c074323031332d30332d32315432303a30343a30305a", expected: `"2013-03-21T20:04:00Z"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.cbor)
+			assert.NoError(t, err)
+			raw, err := cborToJSON(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(raw))
+		})
+	}
+}
+
+func TestCBORToJSON_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cbor string
+		err  string
+	}{
+		{name: "empty", cbor: "", err: "unexpected end of data"},
+		{name: "truncated argument", cbor: "1903", err: "unexpected end of data"},
+		{name: "truncated string", cbor: "6449", err: "unexpected end of data"},
+		{name: "truncated array", cbor: "8301", err: "unexpected end of data"},
+		{name: "huge length", cbor: "9bffffffffffffffff", err: "unexpected end of data"},
+		{name: "unterminated indefinite array", cbor: "9f01", err: "unexpected end of data"},
+		{name: "trailing bytes", cbor: "0000", err: "trailing bytes"},
+		{name: "reserved additional info", cbor: "1c", err: "invalid additional info"},
+		{name: "indefinite int", cbor: "1f", err: "invalid additional info"},
+		{name: "lone break", cbor: "ff", err: "unexpected break"},
+		{name: "invalid utf-8", cbor: "61ff", err: "invalid UTF-8"},
+		{name: "mixed chunks", cbor: "5f6161ff", err: "invalid chunk"},
+		{name: "byte string key", cbor: "a14100f5", err: "unsupported map key"},
+		{name: "nan", cbor: "f97e00", err: "unsupported value"},
+		{name: "unassigned simple value", cbor: "f0", err: "unsupported simple value"},
+		{name: "deep nesting", cbor: strings.Repeat("81", cborMaxDepth+1) + "00", err: "nested too deeply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.cbor)
+			assert.NoError(t, err)
+			_, err = cborToJSON(data)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+// cborTestInner is embedded by cborTestPayload, its fields are promoted like they are by encoding/json.
+type cborTestInner struct {
+	Label  string `json:"label"`
+	Shadow string `json:"shadow"`
+}
+
+// cborTestPayload covers the encoding/json rules marshalCBOR follows.
+type cborTestPayload struct {
+	cborTestInner
+	Shadow   string            `json:"shadow"`
+	ID       int64             `json:"id"`
+	Big      uint64            `json:"big"`
+	Negative int64             `json:"negative"`
+	Ratio    float64           `json:"ratio"`
+	Data     []byte            `json:"data"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Nested   *cborTestPayload  `json:"nested,omitempty"`
+	Raw      json.RawMessage   `json:"raw"`
+	When     time.Time         `json:"when"`
+	Any      any               `json:"any"`
+	Skipped  string            `json:"-"`
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	payload := cborTestPayload{
+		cborTestInner: cborTestInner{Label: "inner", Shadow: "hidden"},
+		Shadow:        "outer",
+		ID:            math.MaxInt64,
+		Big:           math.MaxUint64,
+		Negative:      -(1 << 53) - 1,
+		Ratio:         0.25,
+		Data:          []byte{0, 1, 2, 0xff},
+		Tags:          map[string]string{"b": "2", "a": "1"},
+		Nested:        &cborTestPayload{ID: 9007199254740993, Data: []byte("nested")},
+		Raw:           json.RawMessage(`{"x":[true,null]}`),
+		When:          time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC),
+		Any:           map[string]any{"list": []any{"a", 1.5}},
+		Skipped:       "never sent",
+	}
+
+	data, err := marshalCBOR(payload)
+	assert.NoError(t, err)
+
+	// The CBOR stands for the same document as the JSON of the payload
+	fromCBOR, err := cborToJSON(data)
+	assert.NoError(t, err)
+	fromJSON, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, string(fromJSON), string(fromCBOR))
+
+	// And decodes into the same value the JSON does, with every digit and byte intact
+	var viaCBOR, viaJSON cborTestPayload
+	assert.NoError(t, unmarshalCBOR(data, &viaCBOR))
+	assert.NoError(t, json.Unmarshal(fromJSON, &viaJSON))
+	assert.Equal(t, viaJSON, viaCBOR)
+	assert.Equal(t, payload.ID, viaCBOR.ID)
+	assert.Equal(t, payload.Big, viaCBOR.Big)
+	assert.Equal(t, payload.Nested.ID, viaCBOR.Nested.ID)
+	assert.Equal(t, payload.Data, viaCBOR.Data)
+	assert.Equal(t, "outer", viaCBOR.Shadow)
+	assert.Zero(t, viaCBOR.Skipped)
+}
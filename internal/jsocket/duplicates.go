@@ -0,0 +1,49 @@
+package jsocket
+
+import (
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// answeredWindow is how many answered request ids are remembered to recognise duplicate responses.
+const answeredWindow = 1024
+
+// OnDuplicateResponse returns a connection option that calls f whenever the remote peer sends a
+// second response for a request that was already answered, naming the method of that request.
+//
+// Duplicate responses are always ignored, the first response wins and the caller that is waiting
+// for it is never disturbed. The option only reports them, eg: to warn about a buggy script.
+// Only the most recently answered requests are remembered, a duplicate of a much older one is
+// ignored without being reported.
+func OnDuplicateResponse(f func(id jsonrpc2.ID, method string)) jsonrpc2.ConnOpt {
+	var (
+		mu       sync.Mutex
+		answered = make(map[jsonrpc2.ID]string, answeredWindow)
+		order    = make([]jsonrpc2.ID, 0, answeredWindow)
+	)
+	return jsonrpc2.OnRecv(func(req *jsonrpc2.Request, resp *jsonrpc2.Response) {
+		if resp == nil {
+			return
+		}
+
+		mu.Lock()
+		// A response with a matching request is the first one, remember it
+		if req != nil {
+			if len(order) == answeredWindow {
+				delete(answered, order[0])
+				order = order[1:]
+			}
+			answered[resp.ID] = req.Method
+			order = append(order, resp.ID)
+			mu.Unlock()
+			return
+		}
+		method, ok := answered[resp.ID]
+		mu.Unlock()
+
+		if ok {
+			f(resp.ID, method)
+		}
+	})
+}
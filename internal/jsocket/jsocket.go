@@ -127,14 +127,39 @@
 //   - func(ctx context.Context) ... - no parameters (for parameterless methods)
 //
 // Where T is the parameter type and R is the response type.
+//
+// # Payload Encodings
+//
+// Params and results are sent as plain JSON by default. Once both peers agree to it, eg: during a handshake,
+// SetPayloadEncoding(EncodingCBOR) sends them as CBOR instead, which carries binary data natively and keeps
+// 64 bit integers exact, while the JSON-RPC envelope stays the same:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"greet","params":{"$cbor":"oWROYW1lZUFsaWNl"}}
+//
+// Incoming payloads are decoded whichever encoding they were sent with, and CBOR payloads decode into the
+// same Go values their JSON would, following the json tags of the target types.
+//
+// # Concurrency
+//
+// A JSocket is safe for concurrent use. Any number of goroutines may Call, CallBatch and Notify
+// at once over the one connection, each caller receives the response to its own request whatever
+// order the remote peer answers them in. The underlying jsonrpc2.Conn serializes writes, so the
+// messages of concurrent callers are never interleaved on the stream, and it correlates responses
+// with the requests awaiting them by id. JSocket guarantees those ids are unique among the requests
+// in flight, see ErrDuplicateRequestID. Incoming requests are handled concurrently too, so server
+// methods must be safe for concurrent use.
 package jsocket
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/sourcegraph/jsonrpc2"
@@ -146,7 +171,44 @@ import (
 // JSocket automatically routes incoming requests to registered server methods
 // and supports both synchronous calls and fire-and-forget notifications.
 type JSocket struct {
-	conn *jsonrpc2.Conn
+	// NewID optionally chooses the id of each request sent by Call, eg: to send string ids.
+	// By default ids are sequential integers. Set it before making any calls. It is called
+	// concurrently by concurrent calls, and must not return the id of a request still in flight.
+	NewID func() jsonrpc2.ID
+	// DefaultCallTimeout, when non-zero, bounds each call whose context has no deadline of its own,
+	// as a safety net for callers that pass context.Background(). Set it before making any calls.
+	DefaultCallTimeout time.Duration
+
+	conn             *jsonrpc2.Conn
+	stream           *batchStream
+	limited          *limitedStream
+	ids              atomic.Uint64
+	batchSeq         atomic.Uint64
+	noCancelRequests atomic.Bool
+	cbor             atomic.Bool
+	// inFlight holds the ids of the requests awaiting a response
+	inFlight sync.Map
+}
+
+// CancelRequestMethod is the notification sent to the remote peer when the context of a call
+// is cancelled before its response arrived, its params hold the id of the cancelled request.
+const CancelRequestMethod = "$/cancelRequest"
+
+// PingMethod is the request sent by Ping, it takes no params and its result is ignored.
+const PingMethod = "ping"
+
+// ErrCallTimeout is returned by Call when DefaultCallTimeout elapsed before the response arrived,
+// it also matches context.DeadlineExceeded.
+var ErrCallTimeout = errors.New("call timed out")
+
+// ErrDuplicateRequestID is returned by Call and CallBatch instead of sending a request whose id is the id of
+// a request still in flight, eg: because NewID repeated itself. The response would be routed to only one of them.
+var ErrDuplicateRequestID = errors.New("duplicate request id")
+
+// CancelRequestParams are the params of a CancelRequestMethod notification.
+type CancelRequestParams struct {
+	// ID is the id of the cancelled request
+	ID jsonrpc2.ID `json:"id"`
 }
 
 // New creates a new JSocket instance that wraps a JSON-RPC 2.0 bidirectional connection.
@@ -165,13 +227,9 @@ type JSocket struct {
 // Additional connection options can be provided via opts to customize behavior such as
 // logging, interceptors, or other JSON-RPC connection settings.
 func New(ctx context.Context, reader io.ReadCloser, writer io.Writer, serverMethods func(ctx context.Context, c *jsonrpc2.Conn) map[string]any, opts ...jsonrpc2.ConnOpt) *JSocket {
-	stream := jsonrpc2.NewPlainObjectStream(&struct {
-		io.ReadCloser
-		io.Writer
-	}{
-		ReadCloser: reader,
-		Writer:     writer,
-	})
+	limited := newLimitedStream(reader, writer)
+	stream := &batchStream{ObjectStream: limited}
+	j := &JSocket{stream: stream, limited: limited}
 
 	handler := jsonrpc2.AsyncHandler(
 		jsonrpc2.HandlerWithError(func(ctx context.Context, c *jsonrpc2.Conn, r *jsonrpc2.Request) (any, error) {
@@ -204,7 +262,7 @@ func New(ctx context.Context, reader io.ReadCloser, writer io.Writer, serverMeth
 
 				// Unmarshal params into the parameter if params exist
 				if r.Params != nil && len(*r.Params) > 0 {
-					if err := json.Unmarshal(*r.Params, paramValue.Interface()); err != nil {
+					if err := decodePayload(*r.Params, paramValue.Interface()); err != nil {
 						return nil, fmt.Errorf("failed to unmarshal params: %w", err)
 					}
 				}
@@ -234,7 +292,7 @@ func New(ctx context.Context, reader io.ReadCloser, writer io.Writer, serverMeth
 					return nil, nil
 				}
 				// Otherwise it's a response
-				return result.Interface(), nil
+				return j.encodePayload(result.Interface())
 			case 2:
 				// Two return values - (response, error)
 				response := results[0].Interface()
@@ -246,14 +304,15 @@ func New(ctx context.Context, reader io.ReadCloser, writer io.Writer, serverMeth
 					}
 					return nil, fmt.Errorf("method failed: %w", err)
 				}
-				return response, nil
+				return j.encodePayload(response)
 			default:
 				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "Method has unsupported number of return values"}
 			}
 		}),
 	)
 
-	return &JSocket{jsonrpc2.NewConn(ctx, stream, handler, opts...)}
+	j.conn = jsonrpc2.NewConn(ctx, stream, handler, opts...)
+	return j
 }
 
 // Call sends a JSON-RPC request to the remote peer and waits for a response.
@@ -261,15 +320,131 @@ func New(ctx context.Context, reader io.ReadCloser, writer io.Writer, serverMeth
 // input parameters, and result will be populated with the response data.
 // The call blocks until a response is received or the context is cancelled.
 // Returns an error if the call fails or the remote method returns an error.
+//
+// If the context is cancelled before the response arrives, a CancelRequestMethod notification
+// is sent so the remote peer can abort its work, unless disabled with SetCancelRequests. Sending
+// it is best-effort and does not delay the return of Call. The request id is always chosen by NewID, ids picked with jsonrpc2.PickID
+// are overridden. A context without a deadline is bounded by DefaultCallTimeout, see ErrCallTimeout.
+// A response over the limit of SetMaxMessageBytes fails the call with ErrMessageTooLarge.
+// Params are sent with the encoding chosen by SetPayloadEncoding, the result is decoded whichever encoding it came in.
+// Call is safe for concurrent use.
 func (j *JSocket) Call(ctx context.Context, method string, params, result any, opts ...jsonrpc2.CallOption) error {
-	return j.conn.Call(ctx, method, params, result, opts...)
+	ctx, cancel, timeout := j.withDefaultTimeout(ctx)
+	defer cancel()
+
+	id := j.nextID()
+	if err := j.claimIDs(id); err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer j.releaseIDs(id)
+	params, err := j.encodePayload(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params of %s: %w", method, err)
+	}
+	waiter, err := j.conn.DispatchCall(ctx, method, params, append(opts, jsonrpc2.PickID(id))...)
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	err = waiter.Wait(ctx, &raw)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		j.cancelRequest(id)
+	}
+	if err == nil && result != nil {
+		err = decodePayload(raw, result)
+	}
+	return callTimeoutError(messageTooLargeError(err), method, timeout)
+}
+
+// Ping sends a PingMethod request to the remote peer and returns how long it took to respond, eg: to
+// tell a peer whose event loop is blocked from one that is merely idle. Any response counts, so a peer
+// that does not implement PingMethod still answers with a method not found error. Like Call, Ping is
+// safe for concurrent use and its round trip queues behind no other call.
+func (j *JSocket) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	err := j.Call(ctx, PingMethod, nil, nil)
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+		err = nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to ping: %w", err)
+	}
+	return time.Since(start), nil
+}
+
+// withDefaultTimeout bounds ctx by DefaultCallTimeout when it has no deadline of its own,
+// returning the timeout that was applied, if any.
+func (j *JSocket) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc, time.Duration) {
+	if _, ok := ctx.Deadline(); ok || j.DefaultCallTimeout <= 0 {
+		return ctx, func() {}, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, j.DefaultCallTimeout)
+	return ctx, cancel, j.DefaultCallTimeout
+}
+
+// callTimeoutError wraps err with ErrCallTimeout, naming the method and the timeout, when
+// the call failed because the timeout applied by withDefaultTimeout elapsed.
+func callTimeoutError(err error, method string, timeout time.Duration) error {
+	if timeout <= 0 || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %s did not respond within %s: %w", ErrCallTimeout, method, timeout, err)
+}
+
+// nextID returns the id of the next request sent by Call.
+func (j *JSocket) nextID() jsonrpc2.ID {
+	if j.NewID != nil {
+		return j.NewID()
+	}
+	return jsonrpc2.ID{Num: j.ids.Add(1)}
+}
+
+// claimIDs records the given ids as in flight, or fails with ErrDuplicateRequestID
+// without claiming any of them when one already is.
+func (j *JSocket) claimIDs(ids ...jsonrpc2.ID) error {
+	for i, id := range ids {
+		if _, loaded := j.inFlight.LoadOrStore(id, struct{}{}); loaded {
+			j.releaseIDs(ids[:i]...)
+			return fmt.Errorf("%w: %s is still in flight", ErrDuplicateRequestID, id)
+		}
+	}
+	return nil
+}
+
+// releaseIDs forgets in flight ids claimed by claimIDs.
+func (j *JSocket) releaseIDs(ids ...jsonrpc2.ID) {
+	for _, id := range ids {
+		j.inFlight.Delete(id)
+	}
+}
+
+// SetCancelRequests controls whether a CancelRequestMethod notification is sent for calls whose
+// context is cancelled, eg: to stop sending them to a peer that does not understand them. Enabled by default.
+func (j *JSocket) SetCancelRequests(enabled bool) {
+	j.noCancelRequests.Store(!enabled)
+}
+
+// cancelRequest tells the remote peer that the request with the given id was cancelled,
+// without waiting for the notification to be sent.
+func (j *JSocket) cancelRequest(id jsonrpc2.ID) {
+	if j.noCancelRequests.Load() {
+		return
+	}
+	go func() {
+		_ = j.conn.Notify(context.Background(), CancelRequestMethod, &CancelRequestParams{ID: id})
+	}()
 }
 
 // Notify sends a JSON-RPC notification to the remote peer without expecting a response.
 // Notifications are fire-and-forget messages that don't include a request ID and won't
 // receive a response from the server. This is useful for events or updates where no
-// acknowledgment is needed.
+// acknowledgment is needed. Notify is safe for concurrent use.
 func (j *JSocket) Notify(ctx context.Context, method string, params any, opts ...jsonrpc2.CallOption) error {
+	params, err := j.encodePayload(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params of %s: %w", method, err)
+	}
 	return j.conn.Notify(ctx, method, params, opts...)
 }
 
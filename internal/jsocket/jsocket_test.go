@@ -0,0 +1,262 @@
+package jsocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestJSocket_DuplicateRequestID(t *testing.T) {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := New(t.Context(), serverReader, serverWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return map[string]any{
+			"block": func() {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+			},
+		}
+	})
+	defer func() { assert.NoError(t, server.Close()) }()
+	client := New(t.Context(), clientReader, clientWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return nil
+	})
+	defer func() { assert.NoError(t, client.Close()) }()
+	client.NewID = func() jsonrpc2.ID { return jsonrpc2.ID{Num: 1} }
+
+	first := make(chan error, 1)
+	go func() { first <- client.Call(t.Context(), "block", nil, nil) }()
+	<-started
+
+	// Sending it would steal the response of the first call
+	err := client.Call(t.Context(), "block", nil, nil)
+	assert.IsError(t, err, ErrDuplicateRequestID)
+
+	close(release)
+	assert.NoError(t, <-first)
+	// The id is free again once the first call was answered
+	assert.NoError(t, client.Call(t.Context(), "block", nil, nil))
+}
+
+func TestJSocket_MaxMessageBytes(t *testing.T) {
+	clientReader, peerWriter := io.Pipe()
+	peerReader, clientWriter := io.Pipe()
+	client := New(t.Context(), clientReader, clientWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return nil
+	})
+	defer func() { _ = client.Close() }()
+	client.SetMaxMessageBytes(1024)
+	var reported []error
+	var reportedMu sync.Mutex
+	client.OnMessageTooLarge(func(err error) {
+		reportedMu.Lock()
+		defer reportedMu.Unlock()
+		reported = append(reported, err)
+	})
+
+	requests := json.NewDecoder(peerReader)
+	call := func(frame func(id string) string) error {
+		result := make(chan error, 1)
+		go func() { result <- client.Call(t.Context(), "read", nil, nil) }()
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		assert.NoError(t, requests.Decode(&req))
+		_, err := io.WriteString(peerWriter, frame(string(req.ID))+"\n")
+		assert.NoError(t, err)
+		return <-result
+	}
+	huge := strings.Repeat("x", 4096)
+
+	// A response over the limit fails its call, whether its id comes before or after the bulk of it
+	err := call(func(id string) string {
+		return `{"jsonrpc":"2.0","id":` + id + `,"result":{"data":"` + huge + `"}}`
+	})
+	assert.IsError(t, err, ErrMessageTooLarge)
+	assert.Contains(t, err.Error(), "over the limit of 1024 bytes")
+	err = call(func(id string) string {
+		return `{"jsonrpc":"2.0","result":{"nested":[{"data":"` + huge + `"}]},"note":"` + huge + `","id":` + id + `}`
+	})
+	assert.IsError(t, err, ErrMessageTooLarge)
+
+	// A notification over the limit is dropped, and responses under it are delivered as usual
+	err = call(func(id string) string {
+		return `{"jsonrpc":"2.0","method":"log","params":{"data":"` + huge + `"}}` + "\n" +
+			`{"jsonrpc":"2.0","id":` + id + `,"result":{"data":"small"}}`
+	})
+	assert.NoError(t, err)
+	reportedMu.Lock()
+	assert.Equal(t, 3, len(reported))
+	reportedMu.Unlock()
+
+	// Without an id to fail, the connection is closed rather than leaving the call waiting forever
+	err = call(func(id string) string {
+		return `{"jsonrpc":"2.0","result":"` + huge + `"}`
+	})
+	assert.Error(t, err)
+	assert.NotIsError(t, err, ErrMessageTooLarge)
+}
+
+func TestJSocket_MaxMessageBytesRequest(t *testing.T) {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	server := New(t.Context(), serverReader, serverWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return map[string]any{
+			"echo": func(params map[string]any) (map[string]any, error) { return params, nil },
+		}
+	})
+	defer func() { assert.NoError(t, server.Close()) }()
+	server.SetMaxMessageBytes(1024)
+	client := New(t.Context(), clientReader, clientWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return nil
+	})
+	defer func() { assert.NoError(t, client.Close()) }()
+
+	// The request never reaches the method, it is answered with an error straight away
+	var result map[string]any
+	err := client.Call(t.Context(), "echo", map[string]any{"data": strings.Repeat("x", 4096)}, &result)
+	assert.IsError(t, err, ErrMessageTooLarge)
+
+	results, err := client.CallBatch(t.Context(), []BatchItem{
+		{Method: "echo", Params: map[string]any{"data": "small"}},
+		{Method: "echo", Params: map[string]any{"data": strings.Repeat("x", 4096)}},
+	})
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.IsError(t, result.Err, ErrMessageTooLarge)
+	}
+
+	assert.NoError(t, client.Call(t.Context(), "echo", map[string]any{"data": "small"}, &result))
+	assert.Equal(t, map[string]any{"data": "small"}, result)
+}
+
+// payloadTest is sent back and forth by TestJSocket_PayloadEncoding, with values JSON numbers in JavaScript cannot hold.
+type payloadTest struct {
+	ID     int64          `json:"id"`
+	Big    uint64         `json:"big"`
+	Data   []byte         `json:"data"`
+	Nested *payloadTest   `json:"nested,omitempty"`
+	Extra  map[string]any `json:"extra,omitempty"`
+}
+
+func TestJSocket_PayloadEncoding(t *testing.T) {
+	sent := payloadTest{
+		ID:     9007199254740993,
+		Big:    18446744073709551615,
+		Data:   []byte{0, 1, 0xfe, 0xff},
+		Nested: &payloadTest{ID: -9007199254740993, Data: []byte("nested")},
+		Extra:  map[string]any{"ok": true},
+	}
+
+	for _, encodings := range []struct{ client, server PayloadEncoding }{
+		{EncodingJSON, EncodingJSON},
+		{EncodingCBOR, EncodingCBOR},
+		// Incoming payloads are decoded whichever encoding they came in, so peers may switch at different times
+		{EncodingCBOR, EncodingJSON},
+		{EncodingJSON, EncodingCBOR},
+	} {
+		t.Run(string(encodings.client)+" to "+string(encodings.server), func(t *testing.T) {
+			clientReader, serverWriter := io.Pipe()
+			serverReader, clientWriter := io.Pipe()
+			notified := make(chan payloadTest, 1)
+			server := New(t.Context(), serverReader, serverWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+				return map[string]any{
+					"echo":   func(params payloadTest) (payloadTest, error) { return params, nil },
+					"notify": func(params payloadTest) { notified <- params },
+				}
+			})
+			defer func() { assert.NoError(t, server.Close()) }()
+			client := New(t.Context(), clientReader, clientWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+				return nil
+			})
+			defer func() { assert.NoError(t, client.Close()) }()
+			assert.NoError(t, client.SetPayloadEncoding(encodings.client))
+			assert.NoError(t, server.SetPayloadEncoding(encodings.server))
+			assert.Equal(t, encodings.client, client.PayloadEncoding())
+
+			var echoed payloadTest
+			assert.NoError(t, client.Call(t.Context(), "echo", sent, &echoed))
+			assert.Equal(t, sent, echoed)
+
+			assert.NoError(t, client.Notify(t.Context(), "notify", sent))
+			assert.Equal(t, sent, <-notified)
+
+			results, err := client.CallBatch(t.Context(), []BatchItem{{Method: "echo", Params: sent}, {Method: "echo", Params: sent.Nested}})
+			assert.NoError(t, err)
+			var first, second payloadTest
+			assert.NoError(t, results[0].Unmarshal(&first))
+			assert.NoError(t, results[1].Unmarshal(&second))
+			assert.Equal(t, sent, first)
+			assert.Equal(t, *sent.Nested, second)
+			// Batch results are always handed back as JSON
+			assert.True(t, json.Valid(results[0].Result))
+			assert.NotContains(t, string(results[0].Result), CBORPayloadKey)
+		})
+	}
+}
+
+func TestJSocket_PayloadEncodingOnTheWire(t *testing.T) {
+	clientReader, peerWriter := io.Pipe()
+	peerReader, clientWriter := io.Pipe()
+	client := New(t.Context(), clientReader, clientWriter, func(ctx context.Context, c *jsonrpc2.Conn) map[string]any {
+		return nil
+	})
+	defer func() { _ = client.Close() }()
+	assert.NoError(t, client.SetPayloadEncoding(EncodingCBOR))
+
+	requests := json.NewDecoder(peerReader)
+	call := func(result string) (string, map[string]json.RawMessage) {
+		answer := make(chan string, 1)
+		go func() {
+			var got struct {
+				Value string `json:"value"`
+			}
+			assert.NoError(t, client.Call(t.Context(), "read", struct {
+				Value string `json:"value"`
+			}{"sent"}, &got))
+			answer <- got.Value
+		}()
+		var req struct {
+			ID     json.RawMessage            `json:"id"`
+			Params map[string]json.RawMessage `json:"params"`
+		}
+		assert.NoError(t, requests.Decode(&req))
+		_, err := io.WriteString(peerWriter, `{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":`+result+"}\n")
+		assert.NoError(t, err)
+		return <-answer, req.Params
+	}
+
+	// The envelope stays JSON, only the params are CBOR
+	value, params := call(`{"value":"json"}`)
+	assert.Equal(t, "json", value)
+	assert.Equal(t, 1, len(params))
+	var data []byte
+	assert.NoError(t, json.Unmarshal(params[CBORPayloadKey], &data))
+	var decoded struct {
+		Value string `json:"value"`
+	}
+	assert.NoError(t, unmarshalCBOR(data, &decoded))
+	assert.Equal(t, "sent", decoded.Value)
+
+	// {"value": "cbor"}
+	value, _ = call(`{"$cbor":"oWV2YWx1ZWRjYm9y"}`)
+	assert.Equal(t, "cbor", value)
+
+	// An object that merely has a $cbor key among others is plain JSON
+	value, _ = call(`{"$cbor":"oWV2YWx1ZWRjYm9y","value":"json"}`)
+	assert.Equal(t, "json", value)
+
+	assert.Error(t, client.SetPayloadEncoding("msgpack"))
+	assert.Equal(t, EncodingCBOR, client.PayloadEncoding())
+}
@@ -0,0 +1,317 @@
+package jsocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DefaultMaxMessageBytes is the size limit of a single incoming message by default, see SetMaxMessageBytes.
+const DefaultMaxMessageBytes = 16 << 20
+
+// CodeMessageTooLarge is the code of the error response JSocket makes up for a response that exceeded the
+// limit of SetMaxMessageBytes, and of the error it answers such a request with. Call and CallBatch return
+// ErrMessageTooLarge rather than this code.
+const CodeMessageTooLarge int64 = -32090
+
+// ErrMessageTooLarge is returned by Call and CallBatch when the response exceeded the limit of
+// SetMaxMessageBytes, and reported to OnMessageTooLarge for every incoming message that did.
+var ErrMessageTooLarge = errors.New("json-rpc message too large")
+
+const (
+	// skeletonLimit bounds the outline of an over-limit message kept to recover its ids, see readFrame.
+	skeletonLimit = 64 << 10
+	// skeletonStringLimit is the longest string kept in the outline, longer ones are replaced by "".
+	skeletonStringLimit = 256
+)
+
+// SetMaxMessageBytes limits the size of each message read from the remote peer, zero or less removes the
+// limit. Defaults to DefaultMaxMessageBytes. A message over the limit is discarded as it is read, without
+// being buffered, so a misbehaving peer can not exhaust memory. The call it answered fails with
+// ErrMessageTooLarge, a request is answered with a CodeMessageTooLarge error and a notification is dropped.
+// When the ids of the message can not be made out, the connection is closed instead, as the call it
+// answered would otherwise never return.
+func (j *JSocket) SetMaxMessageBytes(limit int64) {
+	j.limited.limit.Store(limit)
+}
+
+// OnMessageTooLarge calls f with an error wrapping ErrMessageTooLarge for every incoming message that
+// exceeded the limit of SetMaxMessageBytes, eg: to log it. f is called from the read loop, so must not block.
+func (j *JSocket) OnMessageTooLarge(f func(err error)) {
+	j.limited.onTooLarge.Store(&f)
+}
+
+// messageTooLargeError returns ErrMessageTooLarge in place of the error response JSocket made up for a
+// response that exceeded the limit, any other error is returned unchanged.
+func messageTooLargeError(err error) error {
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == CodeMessageTooLarge {
+		return fmt.Errorf("%w: %s", ErrMessageTooLarge, rpcErr.Message)
+	}
+	return err
+}
+
+// limitedStream is a plain JSON object stream, like jsonrpc2.NewPlainObjectStream, that discards incoming
+// messages over a size limit rather than reading them into memory.
+type limitedStream struct {
+	reader *bufio.Reader
+	closer io.Closer
+
+	limit      atomic.Int64
+	onTooLarge atomic.Pointer[func(err error)]
+
+	// writeMu serializes writes, the read loop answers over-limit requests alongside the writes of jsonrpc2
+	writeMu sync.Mutex
+	encoder *json.Encoder
+}
+
+// newLimitedStream creates a limitedStream reading from reader and writing to writer, limited to DefaultMaxMessageBytes.
+func newLimitedStream(reader io.ReadCloser, writer io.Writer) *limitedStream {
+	s := &limitedStream{reader: bufio.NewReader(reader), closer: reader, encoder: json.NewEncoder(writer)}
+	s.limit.Store(DefaultMaxMessageBytes)
+	return s
+}
+
+// WriteObject implements jsonrpc2.ObjectStream.
+func (s *limitedStream) WriteObject(obj any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.encoder.Encode(obj)
+}
+
+// Close implements jsonrpc2.ObjectStream.
+func (s *limitedStream) Close() error {
+	return s.closer.Close()
+}
+
+// ReadObject implements jsonrpc2.ObjectStream.
+func (s *limitedStream) ReadObject(v any) error {
+	for {
+		limit := s.limit.Load()
+		raw, size, skeleton, err := readFrame(s.reader, limit)
+		if err != nil {
+			return err
+		}
+		if skeleton == nil {
+			return json.Unmarshal(raw, v)
+		}
+
+		tooLarge := fmt.Errorf("%w: received a message of %d bytes, over the limit of %d bytes", ErrMessageTooLarge, size, limit)
+		if f := s.onTooLarge.Load(); f != nil {
+			(*f)(tooLarge)
+		}
+		responses, ok := s.reject(skeleton, tooLarge)
+		if !ok {
+			return fmt.Errorf("%w, and its id could not be made out", tooLarge)
+		}
+		if responses != nil {
+			return json.Unmarshal(responses, v)
+		}
+	}
+}
+
+// reject answers the requests of an over-limit message, given its outline, with a CodeMessageTooLarge error
+// and returns the error responses to hand to jsonrpc2 in place of its responses, if any. It returns false when
+// the ids of the message can not be made out.
+func (s *limitedStream) reject(skeleton []byte, tooLarge error) (json.RawMessage, bool) {
+	var messages []batchMessage
+	batch := skeleton[0] == '['
+	if batch {
+		if err := json.Unmarshal(skeleton, &messages); err != nil {
+			return nil, false
+		}
+	} else {
+		var msg batchMessage
+		if err := json.Unmarshal(skeleton, &msg); err != nil {
+			return nil, false
+		}
+		messages = []batchMessage{msg}
+	}
+
+	var responses []*jsonrpc2.Response
+	for _, msg := range messages {
+		switch {
+		case msg.ID == nil && msg.Method == nil:
+			return nil, false
+		case msg.ID == nil:
+			// Notifications are not answered
+		case msg.Method != nil:
+			if err := s.WriteObject(newTooLargeResponse(*msg.ID, tooLarge)); err != nil {
+				return nil, false
+			}
+		default:
+			responses = append(responses, newTooLargeResponse(*msg.ID, tooLarge))
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil, true
+	}
+	var raw []byte
+	var err error
+	if batch {
+		raw, err = json.Marshal(responses)
+	} else {
+		raw, err = json.Marshal(responses[0])
+	}
+	return raw, err == nil
+}
+
+// newTooLargeResponse returns a CodeMessageTooLarge error response for the message with the given id.
+func newTooLargeResponse(id jsonrpc2.ID, tooLarge error) *jsonrpc2.Response {
+	return &jsonrpc2.Response{ID: id, Error: &jsonrpc2.Error{Code: CodeMessageTooLarge, Message: tooLarge.Error()}}
+}
+
+// readFrame reads the next JSON value from r. Once the value exceeds limit bytes, unless limit is zero or less,
+// it stops keeping it and returns its size along with an outline instead: its object members, or for an array
+// the members of its elements, with nested objects & arrays replaced by null and long strings emptied. The
+// outline is enough to make out the ids of an over-limit message, without holding it in memory.
+func readFrame(r *bufio.Reader, limit int64) (raw []byte, size int64, skeleton []byte, err error) {
+	first, err := skipSpace(r)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	// Only the members of the message, or of the messages of a batch, are kept in the outline
+	keepDepth := 1
+	if first == '[' {
+		keepDepth = 2
+	}
+
+	var (
+		depth        int
+		inString     bool
+		escaped      bool
+		elided       bool
+		elidedDepth  int
+		stringStart  int
+		longString   bool
+		skeletonLost bool
+		over         bool
+	)
+	keepSkeleton := func(b ...byte) {
+		if !skeletonLost {
+			skeleton = append(skeleton, b...)
+			skeletonLost = len(skeleton) > skeletonLimit
+		}
+	}
+
+	b := first
+	for {
+		size++
+		if !over {
+			raw = append(raw, b)
+			if limit > 0 && size > limit {
+				over, raw = true, nil
+			}
+		}
+
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if b == '\\' {
+				escaped = true
+			} else if b == '"' {
+				inString = false
+			}
+			if elided {
+				break
+			}
+			switch {
+			case !inString && longString:
+				skeleton = append(skeleton[:stringStart], '"', '"')
+			case !inString || len(skeleton)-stringStart < skeletonStringLimit:
+				keepSkeleton(b)
+			default:
+				longString = true
+			}
+		case b == '"':
+			inString, longString = true, false
+			stringStart = len(skeleton)
+			if !elided {
+				keepSkeleton(b)
+			}
+		case b == '{' || b == '[':
+			depth++
+			if !elided && depth > keepDepth {
+				elided, elidedDepth = true, depth-1
+				keepSkeleton([]byte("null")...)
+			} else if !elided {
+				keepSkeleton(b)
+			}
+		case b == '}' || b == ']':
+			depth--
+			if elided && depth == elidedDepth {
+				elided = false
+			} else if !elided {
+				keepSkeleton(b)
+			}
+		default:
+			if !elided {
+				keepSkeleton(b)
+			}
+		}
+
+		// A value ends with its closing bracket, or for a bare string or literal, when it ends at the top level
+		if depth == 0 && !inString {
+			if first == '{' || first == '[' || first == '"' {
+				break
+			}
+			next, err := r.ReadByte()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			if isSpace(next) || next == '{' || next == '[' || next == '"' {
+				_ = r.UnreadByte()
+				break
+			}
+			b = next
+			continue
+		}
+
+		b, err = r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil, 0, nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, 0, nil, err
+		}
+	}
+
+	if !over {
+		return raw, size, nil, nil
+	}
+	if skeletonLost || len(skeleton) == 0 {
+		// Still report the message as over the limit, with an outline that does not make out any ids
+		return nil, size, []byte("null"), nil
+	}
+	return nil, size, skeleton, nil
+}
+
+// skipSpace skips JSON whitespace, returning the first byte after it.
+func skipSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !isSpace(b) {
+			return b, nil
+		}
+	}
+}
+
+// isSpace returns true for the whitespace JSON allows between values.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,264 @@
+package jsocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestReadFrame(t *testing.T) {
+	long := strings.Repeat("x", skeletonStringLimit+1)
+
+	// A batch of small messages, so its outline alone is over skeletonLimit
+	var huge strings.Builder
+	huge.WriteString("[")
+	for i := range skeletonLimit / 128 {
+		if i > 0 {
+			huge.WriteString(",")
+		}
+		huge.WriteString(`{"id":1,"method":"` + strings.Repeat("m", 128) + `"}`)
+	}
+	huge.WriteString("]")
+
+	tests := []struct {
+		name     string
+		input    string
+		limit    int64
+		frames   []string
+		skeleton string
+		err      error
+	}{
+		{
+			name:   "object",
+			input:  `{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`,
+			frames: []string{`{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`},
+		},
+		{
+			name:   "leading whitespace",
+			input:  " \t\r\n{\"id\":1}",
+			frames: []string{`{"id":1}`},
+		},
+		{
+			name:   "escaped quotes and brackets in strings",
+			input:  `{"id":1,"result":"a \"quoted\" }] {[ \\"}{"id":2}`,
+			frames: []string{`{"id":1,"result":"a \"quoted\" }] {[ \\"}`, `{"id":2}`},
+		},
+		{
+			name:   "bare literals",
+			input:  "true false null 12.5e3 -1",
+			frames: []string{"true", "false", "null", "12.5e3", "-1"},
+		},
+		{
+			name:   "bare string",
+			input:  `"a \"b\" ]"`,
+			frames: []string{`"a \"b\" ]"`},
+		},
+		{
+			name:   "concatenated objects",
+			input:  `{"id":1}{"id":2}[{"id":3}]`,
+			frames: []string{`{"id":1}`, `{"id":2}`, `[{"id":3}]`},
+		},
+		{
+			name:   "concatenated strings and literals",
+			input:  `"a""b"1"c"true[2]null{"id":3}`,
+			frames: []string{`"a"`, `"b"`, `1`, `"c"`, `true`, `[2]`, `null`, `{"id":3}`},
+		},
+		{
+			name:   "at the limit",
+			input:  `{"id":1}`,
+			limit:  8,
+			frames: []string{`{"id":1}`},
+		},
+		{
+			name:     "object over the limit",
+			input:    `{"jsonrpc":"2.0","id":7,"result":{"data":[1,2,3],"more":{"deep":true}}}`,
+			limit:    10,
+			skeleton: `{"jsonrpc":"2.0","id":7,"result":null}`,
+		},
+		{
+			name:     "batch over the limit",
+			input:    `[{"id":1,"result":[1,2,3]},{"id":"b","method":"m","params":{"x":[{}]}},{"method":"n"}]`,
+			limit:    10,
+			skeleton: `[{"id":1,"result":null},{"id":"b","method":"m","params":null},{"method":"n"}]`,
+		},
+		{
+			name:     "long strings emptied",
+			input:    `{"id":1,"error":{"code":1},"message":"` + long + `"}`,
+			limit:    10,
+			skeleton: `{"id":1,"error":null,"message":""}`,
+		},
+		{
+			name:     "escaped strings over the limit",
+			input:    `{"id":"a\"}b","result":"]"}`,
+			limit:    10,
+			skeleton: `{"id":"a\"}b","result":"]"}`,
+		},
+		{
+			name:     "bare literal over the limit",
+			input:    "123456",
+			limit:    3,
+			skeleton: "123456",
+		},
+		{
+			name:     "outline over skeletonLimit",
+			input:    huge.String(),
+			limit:    10,
+			skeleton: "null",
+		},
+		{
+			name:  "empty",
+			input: " \n",
+			err:   io.EOF,
+		},
+		{
+			name:  "truncated object",
+			input: `{"id":1,"result":[`,
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "truncated string",
+			input: `"abc`,
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "truncated after a frame",
+			input:  `{"id":1}{"id":`,
+			frames: []string{`{"id":1}`},
+			err:    io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input))
+
+			for _, frame := range tt.frames {
+				raw, size, skeleton, err := readFrame(r, tt.limit)
+				assert.NoError(t, err)
+				assert.Equal(t, frame, string(raw))
+				assert.Equal(t, int64(len(frame)), size)
+				assert.Zero(t, skeleton)
+			}
+
+			// An over-limit message is the whole input
+			if tt.skeleton != "" {
+				raw, size, skeleton, err := readFrame(r, tt.limit)
+				assert.NoError(t, err)
+				assert.Zero(t, raw)
+				assert.Equal(t, int64(len(tt.input)), size)
+				assert.Equal(t, tt.skeleton, string(skeleton))
+			}
+
+			expected := tt.err
+			if expected == nil {
+				expected = io.EOF
+			}
+			_, _, _, err := readFrame(r, tt.limit)
+			assert.IsError(t, err, expected)
+		})
+	}
+}
+
+func TestLimitedStreamReject(t *testing.T) {
+	tooLarge := errors.New("too large")
+
+	tests := []struct {
+		name      string
+		skeleton  string
+		ok        bool
+		responses []jsonrpc2.ID
+		batch     bool
+		answered  []jsonrpc2.ID
+	}{
+		{
+			name:      "response",
+			skeleton:  `{"jsonrpc":"2.0","id":1,"result":null}`,
+			ok:        true,
+			responses: []jsonrpc2.ID{{Num: 1}},
+		},
+		{
+			name:     "request",
+			skeleton: `{"jsonrpc":"2.0","id":"a","method":"create","params":null}`,
+			ok:       true,
+			answered: []jsonrpc2.ID{{Str: "a", IsString: true}},
+		},
+		{
+			name:     "notification",
+			skeleton: `{"jsonrpc":"2.0","method":"log","params":null}`,
+			ok:       true,
+		},
+		{
+			name:      "batch",
+			skeleton:  `[{"id":1,"result":null},{"id":2,"method":"m","params":null},{"method":"n"},{"id":3,"error":null}]`,
+			ok:        true,
+			responses: []jsonrpc2.ID{{Num: 1}, {Num: 3}},
+			batch:     true,
+			answered:  []jsonrpc2.ID{{Num: 2}},
+		},
+		{
+			name:     "batch of requests",
+			skeleton: `[{"id":1,"method":"m"},{"id":2,"method":"m"}]`,
+			ok:       true,
+			answered: []jsonrpc2.ID{{Num: 1}, {Num: 2}},
+		},
+		{
+			name:     "outline lost",
+			skeleton: "null",
+		},
+		{
+			name:     "no id or method",
+			skeleton: `{"jsonrpc":"2.0","result":null}`,
+		},
+		{
+			name:     "batch with a message without id or method",
+			skeleton: `[{"id":1,"result":null},{"jsonrpc":"2.0"}]`,
+		},
+		{
+			name:     "bare literal",
+			skeleton: "123456",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written bytes.Buffer
+			s := newLimitedStream(io.NopCloser(strings.NewReader("")), &written)
+
+			raw, ok := s.reject([]byte(tt.skeleton), tooLarge)
+			assert.Equal(t, tt.ok, ok)
+
+			var responses []*jsonrpc2.Response
+			switch {
+			case len(tt.responses) == 0:
+				assert.Zero(t, raw)
+			case tt.batch:
+				assert.NoError(t, json.Unmarshal(raw, &responses))
+			default:
+				var response *jsonrpc2.Response
+				assert.NoError(t, json.Unmarshal(raw, &response))
+				responses = append(responses, response)
+			}
+			assert.Equal(t, len(tt.responses), len(responses))
+			for i, response := range responses {
+				assert.Equal(t, tt.responses[i], response.ID)
+				assert.Equal(t, CodeMessageTooLarge, response.Error.Code)
+				assert.Equal(t, "too large", response.Error.Message)
+			}
+
+			// Requests are answered right away, rather than handed to jsonrpc2
+			decoder := json.NewDecoder(&written)
+			for _, id := range tt.answered {
+				var answer *jsonrpc2.Response
+				assert.NoError(t, decoder.Decode(&answer))
+				assert.Equal(t, id, answer.ID)
+				assert.Equal(t, CodeMessageTooLarge, answer.Error.Code)
+			}
+			assert.False(t, decoder.More())
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package jsocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadEncoding is how the params and results of messages are encoded within their JSON-RPC envelope,
+// see SetPayloadEncoding.
+type PayloadEncoding string
+
+const (
+	// EncodingJSON sends params and results as plain JSON, the default.
+	EncodingJSON PayloadEncoding = "json"
+	// EncodingCBOR sends params and results as CBOR (RFC 8949), which carries binary data natively and keeps
+	// integers exact beyond the 2^53 of a JavaScript number. The envelope stays JSON, a CBOR payload is sent
+	// as an object holding the base64 encoded CBOR under the CBORPayloadKey.
+	EncodingCBOR PayloadEncoding = "cbor"
+)
+
+// CBORPayloadKey is the only key of the params or result object that carries a CBOR encoded payload,
+// eg: {"$cbor": "oWJva/U="}.
+const CBORPayloadKey = "$cbor"
+
+// cborPayload is the params or result of a message whose payload is CBOR encoded.
+type cborPayload struct {
+	CBOR []byte `json:"$cbor"`
+}
+
+// SetPayloadEncoding chooses how the params of requests and notifications, and the results of responses,
+// sent from now on are encoded, eg: once the remote peer advertised that it supports CBOR. Incoming
+// payloads are decoded whichever encoding the remote peer used, so switching needs no coordination.
+// Error details, pings and cancel notifications are always sent as JSON.
+func (j *JSocket) SetPayloadEncoding(encoding PayloadEncoding) error {
+	switch encoding {
+	case EncodingJSON, "":
+		j.cbor.Store(false)
+	case EncodingCBOR:
+		j.cbor.Store(true)
+	default:
+		return fmt.Errorf("unsupported payload encoding %q", encoding)
+	}
+	return nil
+}
+
+// PayloadEncoding returns the encoding of the payloads sent, see SetPayloadEncoding.
+func (j *JSocket) PayloadEncoding() PayloadEncoding {
+	if j.cbor.Load() {
+		return EncodingCBOR
+	}
+	return EncodingJSON
+}
+
+// encodePayload returns v as it is sent, wrapped in a cborPayload when CBOR is in use.
+func (j *JSocket) encodePayload(v any) (any, error) {
+	if v == nil || !j.cbor.Load() {
+		return v, nil
+	}
+	data, err := marshalCBOR(v)
+	if err != nil {
+		return nil, err
+	}
+	return &cborPayload{CBOR: data}, nil
+}
+
+// cborPayloadData returns the CBOR carried by raw, false when raw is a plain JSON payload.
+func cborPayloadData(raw json.RawMessage) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(`"`+CBORPayloadKey+`"`)) {
+		return nil, false
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &payload); err != nil || len(payload) != 1 {
+		return nil, false
+	}
+	var data []byte
+	if err := json.Unmarshal(payload[CBORPayloadKey], &data); err != nil || data == nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// decodePayload decodes the params or result in raw into v, whichever encoding it was sent with.
+func decodePayload(raw json.RawMessage, v any) error {
+	if data, ok := cborPayloadData(raw); ok {
+		return unmarshalCBOR(data, v)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// payloadJSON returns the params or result in raw as plain JSON, whichever encoding it was sent with.
+func payloadJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if data, ok := cborPayloadData(raw); ok {
+		return cborToJSON(data)
+	}
+	return raw, nil
+}
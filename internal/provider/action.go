@@ -113,10 +113,12 @@ func (a *denoBridgeAction) Invoke(ctx context.Context, req action.InvokeRequest,
 		data.Permissions.MapToDenoPermissions(),
 		resp,
 	)
+	c.Client.DryRun = a.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -126,7 +128,7 @@ func (a *denoBridgeAction) Invoke(ctx context.Context, req action.InvokeRequest,
 	// Call the invoke JSON-RPC method
 	response, err := c.Invoke(ctx, &deno.InvokeRequest{Props: dynamic.FromDynamic(data.Props)})
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to invoke action", err.Error())
+		addCallError(&resp.Diagnostics, "Failed to invoke action", err.Error(), err)
 		return
 	}
 
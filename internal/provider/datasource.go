@@ -3,11 +3,13 @@ package provider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/brad-jones/terraform-provider-denobridge/internal/deno"
 	"github.com/brad-jones/terraform-provider-denobridge/internal/dynamic"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -35,6 +37,7 @@ type denoBridgeDataSourceModel struct {
 	SensitiveResult types.Dynamic       `tfsdk:"sensitive_result"`
 	ConfigFile      types.String        `tfsdk:"config_file"`
 	Permissions     *deno.PermissionsTF `tfsdk:"permissions"`
+	CacheTTL        types.String        `tfsdk:"cache_ttl"`
 }
 
 // Metadata returns the data source type name.
@@ -68,6 +71,10 @@ func (d *denoBridgeDataSource) Schema(_ context.Context, _ datasource.SchemaRequ
 				Description: "File path to a deno config file to use with the deno script. Useful for import maps, etc...",
 				Optional:    true,
 			},
+			"cache_ttl": schema.StringAttribute{
+				Description: "How long a result is reused by later reads of a data source with the same path, config file, permissions and props, without starting Deno, eg: '5m'. Defaults to no caching.",
+				Optional:    true,
+			},
 			"permissions": schema.SingleNestedAttribute{
 				Description: "Deno runtime permissions for the script.",
 				Optional:    true,
@@ -121,30 +128,58 @@ func (d *denoBridgeDataSource) Read(ctx context.Context, req datasource.ReadRequ
 		return
 	}
 
-	// Start the Deno server
+	var cacheTTL time.Duration
+	if !state.CacheTTL.IsNull() {
+		ttl, err := time.ParseDuration(state.CacheTTL.ValueString())
+		if err != nil || ttl < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("cache_ttl"),
+				"Invalid cache TTL",
+				fmt.Sprintf("Expected a non-negative duration, eg: '5m', got: %q", state.CacheTTL.ValueString()),
+			)
+			return
+		}
+		cacheTTL = ttl
+	}
+
 	c := deno.NewDenoClientDatasource(
 		d.providerConfig.DenoBinaryPath,
 		state.Path.ValueString(),
 		state.ConfigFile.ValueString(),
 		state.Permissions.MapToDenoPermissions(),
+		cacheTTL,
 	)
-	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
-		return
-	}
-	defer func() {
-		if err := c.Client.Stop(); err != nil {
-			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
+	c.Cache = d.providerConfig.DatasourceCache
+	c.Client.DryRun = d.providerConfig.DryRun
+	request := &deno.ReadRequest{Props: dynamic.FromDynamic(state.Props)}
+
+	// A cached result is used without starting the Deno server at all
+	response, cached := c.CachedRead(request)
+	if !cached {
+		// Start the Deno server
+		if err := c.Client.Start(ctx); err != nil {
+			addStartError(&resp.Diagnostics, err, false)
+			return
+		}
+		addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
+		defer func() {
+			if err := c.Client.Stop(); err != nil {
+				resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
+			}
+		}()
+
+		// Call the read JSON-RPC method
+		var err error
+		response, err = c.Read(ctx, request)
+		if err != nil {
+			addCallError(
+				&resp.Diagnostics,
+				"Failed to read data",
+				fmt.Sprintf("Could not read data from Deno script: %s", err.Error()),
+				err,
+			)
+			return
 		}
-	}()
-
-	// Call the read JSON-RPC method
-	response, err := c.Read(ctx, &deno.ReadRequest{Props: dynamic.FromDynamic(state.Props)})
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to read data",
-			fmt.Sprintf("Could not read data from Deno script: %s", err.Error()),
-		)
 	}
 
 	// Handle diagnostics - allows the script to add warnings or errors
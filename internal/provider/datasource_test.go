@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
@@ -57,6 +58,61 @@ func TestDataSource(t *testing.T) {
 	})
 }
 
+func TestDataSourceCacheTTL(t *testing.T) {
+	t.Setenv("TF_ACC", "1")
+	t.Setenv("TF_LOG", "DEBUG")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+					data "denobridge_datasource" "test" {
+						path      = "./datasource_test.ts"
+						cache_ttl = "5m"
+						props = {
+							value = "Hello World"
+						}
+					}
+
+					data "denobridge_datasource" "cached" {
+						path      = "./datasource_test.ts"
+						cache_ttl = "5m"
+						props = {
+							value = "Hello World"
+						}
+					}
+				`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.denobridge_datasource.cached",
+						tfjsonpath.New("result").AtMapKey("hashedValue"),
+						knownvalue.StringExact("a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.denobridge_datasource.cached",
+						tfjsonpath.New("sensitive_result").AtMapKey("secret"),
+						knownvalue.StringExact("datasource-secret"),
+					),
+				},
+			},
+			{
+				Config: `
+					data "denobridge_datasource" "test" {
+						path      = "./datasource_test.ts"
+						cache_ttl = "soon"
+						props = {
+							value = "Hello World"
+						}
+					}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid cache TTL`),
+			},
+		},
+	})
+}
+
 func TestDatasourceWithZod(t *testing.T) {
 	t.Setenv("TF_ACC", "1")
 	t.Setenv("TF_LOG", "DEBUG")
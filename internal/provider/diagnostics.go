@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/deno"
+	"github.com/brad-jones/terraform-provider-denobridge/internal/dynamic"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// addCallError translates an error returned from a Deno script call into a diagnostic.
+//
+// Errors where the script asked for manual intervention are framed so the user knows
+// the operation was deliberately halted and needs their attention, rather than
+// being yet another generic failure. Validation errors are attached to the offending
+// attributes, so the user is pointed at exactly what to fix. Errors whose data describes a
+// diagnostic, see deno.ScriptErrorDiagnostic, are added with its severity and attribute.
+//
+// The call failed, so there is no response to carry on with. A diagnostic the script
+// reported as a warning is added alongside an error that fails the operation.
+func addCallError(diags *diag.Diagnostics, summary, detail string, err error) {
+	addCallErrorDiagnostics(diags, summary, detail, err, false)
+}
+
+// addDeleteCallError is addCallError for delete, where a diagnostic the script reported as a
+// warning does not fail the operation, eg: a script may report a resource that is already gone
+// as a warning, so it is still removed from the state.
+func addDeleteCallError(diags *diag.Diagnostics, summary, detail string, err error) {
+	addCallErrorDiagnostics(diags, summary, detail, err, true)
+}
+
+// addCallErrorDiagnostics does the work of addCallError & addDeleteCallError.
+func addCallErrorDiagnostics(diags *diag.Diagnostics, summary, detail string, err error, warningSucceeds bool) {
+	if validationErrors := deno.ValidationErrors(err); len(validationErrors) > 0 {
+		for _, validationErr := range validationErrors {
+			diags.AddAttributeError(dynamic.PropPathToPath(&validationErr.Path), summary, validationErr.Message)
+		}
+		return
+	}
+	if errors.Is(err, deno.ErrManualIntervention) {
+		diags.AddError(
+			"Manual intervention required",
+			fmt.Sprintf(
+				"%s: the Deno script halted the operation because it detected a condition that must be resolved manually. "+
+					"The operation was not retried. Resolve the issue below and run Terraform again.\n\n%s",
+				summary, err.Error(),
+			),
+		)
+		return
+	}
+	var scriptErr *deno.ScriptError
+	if errors.As(err, &scriptErr) {
+		if diagnostic := scriptErr.Diagnostic(); diagnostic != nil {
+			addScriptErrorDiagnostic(diags, summary, diagnostic)
+			if diagnostic.Severity == "warning" && !warningSucceeds {
+				diags.AddError(summary, detail)
+			}
+			return
+		}
+	}
+	diags.AddError(summary, detail)
+}
+
+// addScriptErrorDiagnostic adds the diagnostic a script described in the data of an error,
+// falling back to the summary of the failed operation when the script gave none.
+func addScriptErrorDiagnostic(diags *diag.Diagnostics, summary string, diagnostic *deno.ScriptErrorDiagnostic) {
+	if diagnostic.Summary != "" {
+		summary = diagnostic.Summary
+	}
+	switch {
+	case diagnostic.Severity == "warning" && diagnostic.PropPath != nil:
+		diags.AddAttributeWarning(dynamic.PropPathToPath(diagnostic.PropPath), summary, diagnostic.Detail)
+	case diagnostic.Severity == "warning":
+		diags.AddWarning(summary, diagnostic.Detail)
+	case diagnostic.PropPath != nil:
+		diags.AddAttributeError(dynamic.PropPathToPath(diagnostic.PropPath), summary, diagnostic.Detail)
+	default:
+		diags.AddError(summary, diagnostic.Detail)
+	}
+}
+
+// addPlanExplanation surfaces the explanation a Deno script gave for its plan.
+//
+// Terraform has no informational diagnostic severity, so the explanation is added as a
+// warning, which is the only way to get free form text in front of the user during a plan.
+func addPlanExplanation(diags *diag.Diagnostics, explanation string) {
+	if strings.TrimSpace(explanation) == "" {
+		return
+	}
+	diags.AddWarning("Plan explanation", explanation)
+}
+
+// addStartError translates an error returned when starting Deno into a diagnostic.
+//
+// A missing or unusable Deno binary, see deno.ErrDenoNotFound & deno.ErrDenoNotExecutable, or a missing
+// script, see deno.ErrScriptNotFound, gets a diagnostic saying how to fix it, rather than the generic one.
+//
+// A dry run, see deno.ErrDryRun, is not a failure. It is reported as a warning describing the
+// command that would have run, and the operation is a no-op. Except for operations that change a
+// resource, which Terraform would record as done even though nothing happened, so they fail.
+func addStartError(diags *diag.Diagnostics, err error, changesResource bool) {
+	switch {
+	case errors.Is(err, deno.ErrDenoNotFound):
+		diags.AddError("Deno binary not found", fmt.Sprintf("Set deno_binary_path in the provider configuration to the path "+
+			"of an existing Deno binary, or unset it to have the provider download Deno.\n\n%s", err.Error()))
+		return
+	case errors.Is(err, deno.ErrDenoNotExecutable):
+		diags.AddError("Deno binary not executable", fmt.Sprintf("Check deno_binary_path in the provider configuration points "+
+			"at the Deno binary itself, that it is executable, eg: chmod +x, and that it was built for this OS and architecture."+
+			"\n\n%s", err.Error()))
+		return
+	case errors.Is(err, deno.ErrScriptNotFound):
+		diags.AddError("Deno script not found", fmt.Sprintf("Check the path attribute points at an existing script. "+
+			"A relative path is resolved against the directory Terraform runs in.\n\n%s", err.Error()))
+		return
+	case !errors.Is(err, deno.ErrDryRun):
+		diags.AddError("Failed to start Deno", err.Error())
+		return
+	}
+	if changesResource {
+		diags.AddError("Dry run", fmt.Sprintf("The provider is configured with dry_run, so nothing was changed. "+
+			"Unset dry_run to apply changes.\n\n%s", err.Error()))
+		return
+	}
+	diags.AddWarning("Dry run", err.Error())
+}
+
+// addHealthWarnings surfaces the advisories a Deno script reported while starting up,
+// eg: a deprecated config or a soon to expire credential. They never fail the operation.
+func addHealthWarnings(diags *diag.Diagnostics, warnings []string) {
+	for _, warning := range warnings {
+		diags.AddWarning("Deno script reported a warning", warning)
+	}
+}
+
+// addReplaceExplanation tells the user which attributes forced the resource to be replaced,
+// eg: "forces replacement because region changed".
+func addReplaceExplanation(diags *diag.Diagnostics, triggers []string) {
+	diags.AddWarning(
+		"Plan explanation",
+		fmt.Sprintf("forces replacement because %s changed", strings.Join(triggers, ", ")),
+	)
+}
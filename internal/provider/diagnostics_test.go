@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/brad-jones/terraform-provider-denobridge/internal/deno"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestAddCallError_ValidationErrors(t *testing.T) {
+	rpcErr := &jsonrpc2.Error{Code: deno.CodeValidationFailed, Message: "invalid props"}
+	rpcErr.SetError([]deno.ValidationError{
+		{Path: []string{"props", "name"}, Message: "name is already taken"},
+		{Path: []string{"props", "tags", "1"}, Message: "tags must be lowercase"},
+	})
+	err := fmt.Errorf("create: %w", rpcErr)
+
+	var diags diag.Diagnostics
+	addCallError(&diags, "Failed to create resource", err.Error(), err)
+
+	assert.Equal(t, 2, diags.ErrorsCount())
+	for i, expected := range []struct {
+		path   path.Path
+		detail string
+	}{
+		{path.Root("props").AtMapKey("name"), "name is already taken"},
+		{path.Root("props").AtMapKey("tags").AtListIndex(1), "tags must be lowercase"},
+	} {
+		withPath, ok := diags[i].(diag.DiagnosticWithPath)
+		assert.True(t, ok)
+		assert.True(t, expected.path.Equal(withPath.Path()))
+		assert.Equal(t, "Failed to create resource", diags[i].Summary())
+		assert.Equal(t, expected.detail, diags[i].Detail())
+	}
+}
+
+func TestAddCallError_PlainError(t *testing.T) {
+	err := errors.New("connection reset")
+
+	var diags diag.Diagnostics
+	addCallError(&diags, "Failed to create resource", "Could not create resource: connection reset", err)
+
+	assert.Equal(t, 1, diags.ErrorsCount())
+	_, ok := diags[0].(diag.DiagnosticWithPath)
+	assert.False(t, ok)
+	assert.Equal(t, "Could not create resource: connection reset", diags[0].Detail())
+}
+
+func TestAddCallError_ScriptErrorDiagnostic(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		warning  bool
+		summary  string
+		detail   string
+		withPath bool
+	}{
+		{"error", `{"summary":"Bucket name taken","detail":"pick another name"}`, false, "Bucket name taken", "pick another name", false},
+		{"default summary and detail", `{"severity":"error"}`, false, "Failed to create resource", "bucket exists", false},
+		{"warning", `{"severity":"warning","detail":"already gone"}`, true, "Failed to create resource", "already gone", false},
+		{"attribute warning", `{"severity":"warning","propPath":["props","name"]}`, true, "Failed to create resource", "bucket exists", true},
+		{"attribute error", `{"propPath":["props","name"]}`, false, "Failed to create resource", "bucket exists", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := json.RawMessage(tt.data)
+			err := fmt.Errorf("create: %w", &deno.ScriptError{Method: "create", Code: 1, Message: "bucket exists", Data: &data})
+
+			var diags diag.Diagnostics
+			addCallError(&diags, "Failed to create resource", err.Error(), err)
+
+			assert.True(t, diags.HasError())
+			assert.Equal(t, tt.summary, diags[0].Summary())
+			assert.Equal(t, tt.detail, diags[0].Detail())
+			withPath, ok := diags[0].(diag.DiagnosticWithPath)
+			assert.Equal(t, tt.withPath, ok)
+			if ok {
+				assert.True(t, path.Root("props").AtMapKey("name").Equal(withPath.Path()))
+			}
+
+			// A warning does not make up for the missing response, so the operation still fails
+			if tt.warning {
+				assert.Equal(t, 2, len(diags))
+				assert.Equal(t, 1, diags.WarningsCount())
+				assert.Equal(t, "Failed to create resource", diags[1].Summary())
+				assert.Equal(t, err.Error(), diags[1].Detail())
+			} else {
+				assert.Equal(t, 1, len(diags))
+			}
+		})
+	}
+}
+
+func TestAddDeleteCallError_ScriptWarning(t *testing.T) {
+	data := json.RawMessage(`{"severity":"warning","detail":"already gone"}`)
+	err := fmt.Errorf("delete: %w", &deno.ScriptError{Method: "delete", Code: 1, Message: "not found", Data: &data})
+
+	var diags diag.Diagnostics
+	addDeleteCallError(&diags, "Failed to delete resource", err.Error(), err)
+
+	// The resource is still removed from the state
+	assert.False(t, diags.HasError())
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.Equal(t, "already gone", diags[0].Detail())
+
+	// Errors fail the delete as usual
+	data = json.RawMessage(`{"detail":"permission denied"}`)
+	err = &deno.ScriptError{Method: "delete", Code: 1, Message: "forbidden", Data: &data}
+	diags = nil
+	addDeleteCallError(&diags, "Failed to delete resource", err.Error(), err)
+	assert.Equal(t, 1, diags.ErrorsCount())
+}
+
+func TestAddCallError_ScriptErrorWithoutDiagnostic(t *testing.T) {
+	// Data that does not describe a diagnostic falls back to a plain error
+	data := json.RawMessage(`{"retryAfterMs":5000}`)
+	err := &deno.ScriptError{Method: "create", Code: 1, Message: "bucket exists", Data: &data}
+
+	var diags diag.Diagnostics
+	addCallError(&diags, "Failed to create resource", "Could not create resource: bucket exists", err)
+
+	assert.Equal(t, 1, diags.ErrorsCount())
+	assert.Equal(t, "Could not create resource: bucket exists", diags[0].Detail())
+}
+
+func TestAddPlanExplanation(t *testing.T) {
+	var diags diag.Diagnostics
+	addPlanExplanation(&diags, "The region cannot be changed in place")
+
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.False(t, diags.HasError())
+	assert.Equal(t, "Plan explanation", diags[0].Summary())
+	assert.Equal(t, "The region cannot be changed in place", diags[0].Detail())
+}
+
+func TestAddPlanExplanation_Blank(t *testing.T) {
+	var diags diag.Diagnostics
+	addPlanExplanation(&diags, "  ")
+
+	assert.Equal(t, 0, len(diags))
+}
+
+func TestAddHealthWarnings(t *testing.T) {
+	var diags diag.Diagnostics
+	addHealthWarnings(&diags, []string{"config option foo is deprecated", "API token expires in 3 days"})
+
+	assert.Equal(t, 2, diags.WarningsCount())
+	assert.False(t, diags.HasError())
+	assert.Equal(t, "config option foo is deprecated", diags[0].Detail())
+	assert.Equal(t, "API token expires in 3 days", diags[1].Detail())
+}
+
+func TestAddReplaceExplanation(t *testing.T) {
+	var diags diag.Diagnostics
+	addReplaceExplanation(&diags, []string{"region", "network.cidr"})
+
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.Equal(t, "Plan explanation", diags[0].Summary())
+	assert.Equal(t, "forces replacement because region, network.cidr changed", diags[0].Detail())
+}
+
+func TestAddStartError(t *testing.T) {
+	dryRun := fmt.Errorf("%w, it would have executed deno run main.ts in /work", deno.ErrDryRun)
+
+	var diags diag.Diagnostics
+	addStartError(&diags, errors.New("boom"), false)
+	assert.Equal(t, 1, diags.ErrorsCount())
+	assert.Equal(t, "Failed to start Deno", diags[0].Summary())
+
+	for sentinel, summary := range map[error]string{
+		deno.ErrDenoNotFound:      "Deno binary not found",
+		deno.ErrDenoNotExecutable: "Deno binary not executable",
+		deno.ErrScriptNotFound:    "Deno script not found",
+	} {
+		diags = nil
+		addStartError(&diags, fmt.Errorf("failed to start Deno process: %w at /opt/deno", sentinel), true)
+		assert.Equal(t, 1, diags.ErrorsCount())
+		assert.Equal(t, summary, diags[0].Summary())
+		assert.Contains(t, diags[0].Detail(), "/opt/deno")
+	}
+
+	diags = nil
+	addStartError(&diags, dryRun, false)
+	assert.False(t, diags.HasError())
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.Equal(t, "Dry run", diags[0].Summary())
+	assert.Equal(t, dryRun.Error(), diags[0].Detail())
+
+	diags = nil
+	addStartError(&diags, dryRun, true)
+	assert.Equal(t, 1, diags.ErrorsCount())
+	assert.Equal(t, "Dry run", diags[0].Summary())
+	assert.Contains(t, diags[0].Detail(), "deno run main.ts")
+}
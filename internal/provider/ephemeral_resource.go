@@ -128,10 +128,12 @@ func (r *denoBridgeEphemeralResource) Open(ctx context.Context, req ephemeral.Op
 		data.ConfigFile.ValueString(),
 		data.Permissions.MapToDenoPermissions(),
 	)
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -141,10 +143,13 @@ func (r *denoBridgeEphemeralResource) Open(ctx context.Context, req ephemeral.Op
 	// Call the open endpoint
 	response, err := c.Open(ctx, &deno.OpenRequest{Props: dynamic.FromDynamic(data.Props)})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to open data",
 			fmt.Sprintf("Could not open data from Deno script: %s", err.Error()),
+			err,
 		)
+		return
 	}
 
 	// Handle diagnostics - allows the script to add warnings or errors
@@ -259,10 +264,12 @@ func (r *denoBridgeEphemeralResource) Renew(ctx context.Context, req ephemeral.R
 		privateConfig.DenoConfigPath,
 		privateConfig.DenoPermissions,
 	)
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -272,9 +279,11 @@ func (r *denoBridgeEphemeralResource) Renew(ctx context.Context, req ephemeral.R
 	// Call the renew endpoint
 	response, err := c.Renew(ctx, &deno.RenewRequest{Private: privateData})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to renew",
 			fmt.Sprintf("Could not renew data from Deno script: %s", err.Error()),
+			err,
 		)
 		return
 	}
@@ -370,10 +379,12 @@ func (r *denoBridgeEphemeralResource) Close(ctx context.Context, req ephemeral.C
 		privateConfig.DenoConfigPath,
 		privateConfig.DenoPermissions,
 	)
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -383,9 +394,11 @@ func (r *denoBridgeEphemeralResource) Close(ctx context.Context, req ephemeral.C
 	// Call the close endpoint
 	response, err := c.Close(ctx, &deno.CloseRequest{Private: privateData})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to close",
 			fmt.Sprintf("Could not close data from Deno script: %s", err.Error()),
+			err,
 		)
 		return
 	}
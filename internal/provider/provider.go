@@ -39,11 +39,18 @@ type DenoBridgeProvider struct {
 type denoBridgeProviderModel struct {
 	DenoBinaryPath types.String `tfsdk:"deno_binary_path"`
 	DenoVersion    types.String `tfsdk:"deno_version"`
+	RefreshOnly    types.Bool   `tfsdk:"refresh_only"`
+	ShareProcesses types.Bool   `tfsdk:"share_processes"`
+	DryRun         types.Bool   `tfsdk:"dry_run"`
 }
 
 // ProviderConfig holds the resolved provider configuration.
 type ProviderConfig struct {
-	DenoBinaryPath string
+	DenoBinaryPath  string
+	RefreshOnly     bool
+	ProcessPool     *deno.ProcessPool
+	DryRun          bool
+	DatasourceCache *deno.DatasourceCache
 }
 
 // Metadata returns the provider type name.
@@ -65,6 +72,18 @@ func (p *DenoBridgeProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 				MarkdownDescription: "Deno version to auto-download (e.g., 'v2.1.4', 'v2.0.0-rc.1'). Defaults to 'latest' which downloads the latest stable GA release.",
 				Optional:            true,
 			},
+			"refresh_only": schema.BoolAttribute{
+				MarkdownDescription: "Tells resource scripts that reads must not make any changes (eg: lazily repairing drift) and only report the current state. Terraform does not tell providers when it runs in `-refresh-only` mode, so set this when running `terraform apply -refresh-only`.",
+				Optional:            true,
+			},
+			"share_processes": schema.BoolAttribute{
+				MarkdownDescription: "Lets resources with the same script, config file and permissions share one Deno process across concurrent operations, instead of starting a process for each. The process is stopped once no operation is using it. Only enable this for scripts that do not keep state between calls.",
+				Optional:            true,
+			},
+			"dry_run": schema.BoolAttribute{
+				MarkdownDescription: "Logs the `deno run` command each operation would execute, and reports it as a warning, without starting Deno. Reads and plans succeed without calling the scripts, so this is meant for debugging a misconfigured `terraform plan`. Creating, updating or deleting a resource fails.",
+				Optional:            true,
+			},
 		},
 	}
 }
@@ -108,7 +127,13 @@ func (p *DenoBridgeProvider) Configure(ctx context.Context, req provider.Configu
 
 	// Create provider config
 	providerConfig := &ProviderConfig{
-		DenoBinaryPath: denoBinaryPath,
+		DenoBinaryPath:  denoBinaryPath,
+		RefreshOnly:     config.RefreshOnly.ValueBool(),
+		DryRun:          config.DryRun.ValueBool(),
+		DatasourceCache: deno.NewDatasourceCache(),
+	}
+	if config.ShareProcesses.ValueBool() {
+		providerConfig.ProcessPool = deno.NewProcessPool()
 	}
 
 	// Make available to resources and data sources
@@ -0,0 +1,20 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/brad-jones/terraform-provider-denobridge/internal/dynamic"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// replaceTriggerPaths maps the dot separated prop paths a Deno script returned as
+// replaceTriggers to paths within the props attribute, eg: "network.cidr" becomes
+// props["network"]["cidr"] and "subnets.0" becomes props["subnets"][0].
+func replaceTriggerPaths(triggers []string) path.Paths {
+	paths := make(path.Paths, 0, len(triggers))
+	for _, trigger := range triggers {
+		propPath := append([]string{"props"}, strings.Split(trigger, ".")...)
+		paths = append(paths, dynamic.PropPathToPath(&propPath))
+	}
+	return paths
+}
@@ -0,0 +1,22 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+func TestReplaceTriggerPaths(t *testing.T) {
+	paths := replaceTriggerPaths([]string{"region", "network.cidr", "subnets.0"})
+
+	assert.Equal(t, 3, len(paths))
+	assert.True(t, paths.Contains(path.Root("props").AtMapKey("region")))
+	assert.True(t, paths.Contains(path.Root("props").AtMapKey("network").AtMapKey("cidr")))
+	assert.True(t, paths.Contains(path.Root("props").AtMapKey("subnets").AtListIndex(0)))
+
+	// Only the named attributes force replacement, not their siblings or the whole resource
+	assert.False(t, paths.Contains(path.Root("props")))
+	assert.False(t, paths.Contains(path.Root("props").AtMapKey("name")))
+	assert.False(t, paths.Contains(path.Root("props").AtMapKey("network")))
+}
@@ -6,6 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/brad-jones/terraform-provider-denobridge/internal/deno"
 	"github.com/brad-jones/terraform-provider-denobridge/internal/dynamic"
@@ -15,6 +19,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
@@ -44,6 +49,10 @@ type denoBridgeResourceModel struct {
 	SensitiveState        types.Dynamic       `tfsdk:"sensitive_state"`
 	ConfigFile            types.String        `tfsdk:"config_file"`
 	Permissions           *deno.PermissionsTF `tfsdk:"permissions"`
+	EffectivePermissions  *deno.PermissionsTF `tfsdk:"effective_permissions"`
+	CompressState         types.Bool          `tfsdk:"compress_state"`
+	ExportBeforeDestroy   types.Bool          `tfsdk:"export_before_destroy"`
+	ExportPath            types.String        `tfsdk:"export_path"`
 	WriteOnlyProps        types.Dynamic       `tfsdk:"write_only_props"`
 	WriteOnlyPropsVersion types.Int64         `tfsdk:"write_only_props_version"`
 }
@@ -95,6 +104,18 @@ func (r *denoBridgeResource) Schema(_ context.Context, _ resource.SchemaRequest,
 				Description: "File path to a deno config file to use with the deno script. Useful for import maps, etc...",
 				Optional:    true,
 			},
+			"compress_state": schema.BoolAttribute{
+				Description: "Gzip large state blobs returned by the Deno script before storing them in the Terraform state, to reduce the state file size. This is transparent to the script, however the `state` attribute will hold an opaque compressed value that can no longer be referenced by other resources.",
+				Optional:    true,
+			},
+			"export_before_destroy": schema.BoolAttribute{
+				Description: "Ask the Deno script to export a backup of the resource before it is destroyed, including when it is replaced, and write it to `export_path`. The resource is not destroyed if the backup fails. Scripts that do not implement `exportState` are destroyed without a backup.",
+				Optional:    true,
+			},
+			"export_path": schema.StringAttribute{
+				Description: "Directory the backups taken by `export_before_destroy` are written to, one file per destroyed resource. Defaults to the directory Terraform runs in.",
+				Optional:    true,
+			},
 			"permissions": schema.SingleNestedAttribute{
 				Description: "Deno runtime permissions for the script.",
 				Optional:    true,
@@ -115,6 +136,26 @@ func (r *denoBridgeResource) Schema(_ context.Context, _ resource.SchemaRequest,
 					},
 				},
 			},
+			"effective_permissions": schema.SingleNestedAttribute{
+				Description: "The Deno runtime permissions the script actually ran with during the last create, read or update, which may differ from the configured permissions.",
+				Computed:    true,
+				Attributes: map[string]schema.Attribute{
+					"all": schema.BoolAttribute{
+						Description: "Whether all permissions were granted.",
+						Computed:    true,
+					},
+					"allow": schema.ListAttribute{
+						Description: "List of permissions that were allowed.",
+						ElementType: types.StringType,
+						Computed:    true,
+					},
+					"deny": schema.ListAttribute{
+						Description: "List of permissions that were denied.",
+						ElementType: types.StringType,
+						Computed:    true,
+					},
+				},
+			},
 		},
 	}
 }
@@ -177,11 +218,20 @@ func (r *denoBridgeResource) Create(ctx context.Context, req resource.CreateRequ
 		plan.Path.ValueString(),
 		plan.ConfigFile.ValueString(),
 		plan.Permissions.MapToDenoPermissions(),
+		deno.WithProcessPool(r.providerConfig.ProcessPool),
 	)
+	if plan.CompressState.ValueBool() {
+		c.StateCompressionThreshold = deno.DefaultStateCompressionThreshold
+	}
+	c.OnCreateProgress = func(ctx context.Context, progress *deno.CreateProgress) {
+		tflog.Info(ctx, fmt.Sprintf("Creating %s: %s", plan.Path.ValueString(), progress))
+	}
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, true)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -194,10 +244,24 @@ func (r *denoBridgeResource) Create(ctx context.Context, req resource.CreateRequ
 		WriteOnlyProps: writeOnlyProps,
 	})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to create resource",
 			fmt.Sprintf("Could not create resource via Deno script: %s", err.Error()),
+			err,
 		)
+		// Save whatever the script created before it failed, Terraform taints it so the next apply replaces it
+		if response != nil {
+			resp.Diagnostics.AddWarning(
+				"Partially created resource saved to state",
+				fmt.Sprintf("The Deno script failed after creating part of the resource %s. It was saved to state as tainted, so the next apply deletes and recreates it.", response.ID),
+			)
+			plan.ID = types.StringValue(response.ID)
+			plan.EffectivePermissions = c.Client.EffectivePermissions().MapToDenoPermissionsTF()
+			plan.State = dynamic.ToDynamic(response.State)
+			plan.SensitiveState = dynamic.ToDynamic(response.SensitiveState)
+			resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+		}
 		return
 	}
 
@@ -226,8 +290,15 @@ func (r *denoBridgeResource) Create(ctx context.Context, req resource.CreateRequ
 		}
 	}
 
+	// Remember the script's state checksum so later reads can be verified against it
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, "state_checksum", stateChecksumKey(response.StateChecksum))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Set state
 	plan.ID = types.StringValue(response.ID)
+	plan.EffectivePermissions = c.Client.EffectivePermissions().MapToDenoPermissionsTF()
 	plan.State = dynamic.ToDynamic(response.State)
 	plan.SensitiveState = dynamic.ToDynamic(response.SensitiveState)
 	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
@@ -249,23 +320,55 @@ func (r *denoBridgeResource) Read(ctx context.Context, req resource.ReadRequest,
 		state.Path.ValueString(),
 		state.ConfigFile.ValueString(),
 		state.Permissions.MapToDenoPermissions(),
+		deno.WithProcessPool(r.providerConfig.ProcessPool),
 	)
+	if state.CompressState.ValueBool() {
+		c.StateCompressionThreshold = deno.DefaultStateCompressionThreshold
+	}
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
 		}
 	}()
 
+	// Get the state checksum stored by the last create or update, if the script returned one
+	checksumBytes, diags := req.Private.GetKey(ctx, "state_checksum")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var checksumWrapper struct {
+		Checksum string `json:"checksum"`
+	}
+	if len(checksumBytes) > 0 {
+		if err := json.Unmarshal(checksumBytes, &checksumWrapper); err != nil {
+			resp.Diagnostics.AddError(
+				"Failed to read state checksum",
+				fmt.Sprintf("Could not parse checksum from private state: %s", err.Error()),
+			)
+			return
+		}
+	}
+
 	// Call the read endpoint
-	response, err := c.Read(ctx, &deno.CreateReadRequest{ID: state.ID.ValueString(), Props: dynamic.FromDynamic(state.Props)})
+	response, err := c.Read(ctx, &deno.CreateReadRequest{
+		ID:            state.ID.ValueString(),
+		Props:         dynamic.FromDynamic(state.Props),
+		RefreshOnly:   r.providerConfig.RefreshOnly,
+		StateChecksum: checksumWrapper.Checksum,
+	})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to read resource",
 			fmt.Sprintf("Could not read resource via Deno script: %s", err.Error()),
+			err,
 		)
 		return
 	}
@@ -302,6 +405,7 @@ func (r *denoBridgeResource) Read(ctx context.Context, req resource.ReadRequest,
 
 	// Set refreshed state
 	state.Props = dynamic.ToDynamic(response.Props)
+	state.EffectivePermissions = c.Client.EffectivePermissions().MapToDenoPermissionsTF()
 	state.State = dynamic.ToDynamic(response.State)
 	state.SensitiveState = dynamic.ToDynamic(response.SensitiveState)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
@@ -381,11 +485,17 @@ func (r *denoBridgeResource) Update(ctx context.Context, req resource.UpdateRequ
 		plan.Path.ValueString(),
 		plan.ConfigFile.ValueString(),
 		plan.Permissions.MapToDenoPermissions(),
+		deno.WithProcessPool(r.providerConfig.ProcessPool),
 	)
+	if plan.CompressState.ValueBool() {
+		c.StateCompressionThreshold = deno.DefaultStateCompressionThreshold
+	}
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, true)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -402,9 +512,11 @@ func (r *denoBridgeResource) Update(ctx context.Context, req resource.UpdateRequ
 		CurrentSensitiveState: dynamic.FromDynamic(state.SensitiveState),
 	})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addCallError(
+			&resp.Diagnostics,
 			"Failed to update resource",
 			fmt.Sprintf("Could not update resource via Deno script: %s", err.Error()),
+			err,
 		)
 		return
 	}
@@ -434,8 +546,15 @@ func (r *denoBridgeResource) Update(ctx context.Context, req resource.UpdateRequ
 		}
 	}
 
+	// Replace the stored state checksum, an update without one drops it
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, "state_checksum", stateChecksumKey(response.StateChecksum))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Keep the same ID
 	plan.ID = state.ID
+	plan.EffectivePermissions = c.Client.EffectivePermissions().MapToDenoPermissionsTF()
 
 	// Set updated state
 	plan.State = dynamic.ToDynamic(response.State)
@@ -459,11 +578,31 @@ func (r *denoBridgeResource) Delete(ctx context.Context, req resource.DeleteRequ
 		state.Path.ValueString(),
 		state.ConfigFile.ValueString(),
 		state.Permissions.MapToDenoPermissions(),
+		deno.WithProcessPool(r.providerConfig.ProcessPool),
 	)
+	if state.CompressState.ValueBool() {
+		c.StateCompressionThreshold = deno.DefaultStateCompressionThreshold
+	}
+	c.OnDeleteProgress = func(ctx context.Context, progress *deno.DeleteProgress) {
+		tflog.Info(ctx, fmt.Sprintf("Deleting %s: %s", state.Path.ValueString(), progress))
+	}
+	if state.ExportBeforeDestroy.ValueBool() {
+		c.ExportBeforeDestroy = true
+		c.OnStateExported = func(ctx context.Context, id string, snapshot []byte) error {
+			file, err := writeStateBackup(state.ExportPath.ValueString(), id, snapshot)
+			if err != nil {
+				return err
+			}
+			tflog.Info(ctx, fmt.Sprintf("Exported a backup of resource %s to %s before destroying it", id, file))
+			return nil
+		}
+	}
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, true)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -478,9 +617,11 @@ func (r *denoBridgeResource) Delete(ctx context.Context, req resource.DeleteRequ
 		SensitiveState: dynamic.FromDynamic(state.SensitiveState),
 	})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addDeleteCallError(
+			&resp.Diagnostics,
 			"Failed to delete resource",
 			fmt.Sprintf("Could not delete resource via Deno script: %s", err.Error()),
+			err,
 		)
 		return
 	}
@@ -553,15 +694,18 @@ func (r *denoBridgeResource) ModifyPlan(ctx context.Context, req resource.Modify
 	var denoScriptPath string
 	var denoConfigPath string
 	var denoPermissions *deno.PermissionsTF
+	var compressState bool
 	if plan != nil {
 		denoScriptPath = plan.Path.ValueString()
 		denoConfigPath = plan.ConfigFile.ValueString()
 		denoPermissions = plan.Permissions
+		compressState = plan.CompressState.ValueBool()
 	} else {
 		if state != nil {
 			denoScriptPath = state.Path.ValueString()
 			denoConfigPath = state.ConfigFile.ValueString()
 			denoPermissions = state.Permissions
+			compressState = state.CompressState.ValueBool()
 		}
 	}
 
@@ -577,11 +721,17 @@ func (r *denoBridgeResource) ModifyPlan(ctx context.Context, req resource.Modify
 		denoScriptPath,
 		denoConfigPath,
 		denoPermissions.MapToDenoPermissions(),
+		deno.WithProcessPool(r.providerConfig.ProcessPool),
 	)
+	if compressState {
+		c.StateCompressionThreshold = deno.DefaultStateCompressionThreshold
+	}
+	c.Client.DryRun = r.providerConfig.DryRun
 	if err := c.Client.Start(ctx); err != nil {
-		resp.Diagnostics.AddError("Failed to start Deno", err.Error())
+		addStartError(&resp.Diagnostics, err, false)
 		return
 	}
+	addHealthWarnings(&resp.Diagnostics, c.Client.HealthWarnings())
 	defer func() {
 		if err := c.Client.Stop(); err != nil {
 			resp.Diagnostics.AddWarning("Failed to stop Deno", err.Error())
@@ -625,12 +775,21 @@ func (r *denoBridgeResource) ModifyPlan(ctx context.Context, req resource.Modify
 		CurrentSensitiveState: currentSensitiveState,
 	})
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to modify the plan", err.Error())
+		addCallError(&resp.Diagnostics, "Failed to modify the plan", err.Error(), err)
 		return
 	}
 
+	if response == nil {
+		return
+	}
+
+	// Surface the script's reasoning behind the plan, regardless of what else it decided
+	if response.Explanation != nil {
+		addPlanExplanation(&resp.Diagnostics, *response.Explanation)
+	}
+
 	// Bail out if there is nothing to modify
-	if response == nil || response.NoChanges != nil && *response.NoChanges {
+	if response.NoChanges != nil && *response.NoChanges {
 		return
 	}
 
@@ -640,6 +799,13 @@ func (r *denoBridgeResource) ModifyPlan(ctx context.Context, req resource.Modify
 		return
 	}
 
+	// Handle replaceTriggers - like requiresReplacement but scoped to the named attributes
+	if response.ReplaceTriggers != nil && len(*response.ReplaceTriggers) > 0 {
+		resp.RequiresReplace = append(resp.RequiresReplace, replaceTriggerPaths(*response.ReplaceTriggers)...)
+		addReplaceExplanation(&resp.Diagnostics, *response.ReplaceTriggers)
+		return
+	}
+
 	// Handle modified props - allows the script to modify the planned properties
 	if response.ModifiedProps != nil {
 		plan.Props = dynamic.ToDynamic(response.ModifiedProps)
@@ -708,6 +874,34 @@ func (r *denoBridgeResource) ImportState(ctx context.Context, req resource.Impor
 	})...)
 }
 
+// stateChecksumKey encodes a script's state checksum for private state.
+// Returns nil for an empty checksum, which removes the key.
+func stateChecksumKey(checksum string) []byte {
+	if checksum == "" {
+		return nil
+	}
+	// A string map always marshals, the checksum comes from the script so it may need escaping
+	data, _ := json.Marshal(map[string]string{"checksum": checksum})
+	return data
+}
+
+// writeStateBackup writes a backup exported before destroying a resource to a new file in dir, named after
+// the resource id and the time of the backup, so backups of a resource that is replaced repeatedly are all kept.
+// Returns the path of the file.
+func writeStateBackup(dir, id string, snapshot []byte) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create the backup directory: %w", err)
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%s.%d.snapshot", url.PathEscape(id), time.Now().UnixNano()))
+	if err := os.WriteFile(file, snapshot, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write the backup: %w", err)
+	}
+	return file, nil
+}
+
 // hashWriteOnlyProps creates a SHA256 hash of the write-only properties for change detection.
 // Returns an empty string if props is nil.
 func hashWriteOnlyProps(props any) string {
@@ -0,0 +1,43 @@
+// deno-lint-ignore-file require-await
+
+import { ResourceProvider } from "@brad-jones/terraform-provider-denobridge";
+
+interface Props {
+  path: string;
+  content: string;
+}
+
+interface State {
+  mtime: number;
+}
+
+new ResourceProvider<Props, State>({
+  async create({ path, content }) {
+    await Deno.writeTextFile(path, content);
+    return { id: path, state: { mtime: (await Deno.stat(path)).mtime!.getTime() } };
+  },
+  async read(id) {
+    try {
+      const content = await Deno.readTextFile(id);
+      return { props: { path: id, content }, state: { mtime: (await Deno.stat(id)).mtime!.getTime() } };
+    } catch (e) {
+      if (e instanceof Deno.errors.NotFound) return { exists: false };
+      throw e;
+    }
+  },
+  async update(id, nextProps) {
+    await Deno.writeTextFile(id, nextProps.content);
+    return { mtime: (await Deno.stat(id)).mtime!.getTime() };
+  },
+  async delete(id) {
+    await Deno.remove(id);
+  },
+  async exportState(id) {
+    // The backup is the content of the file, read before it is deleted
+    return await Deno.readFile(id);
+  },
+  async modifyPlan(_id, planType, nextProps, currentProps) {
+    if (planType !== "update") return;
+    return { requiresReplacement: currentProps?.path !== nextProps?.path };
+  },
+});
@@ -1,12 +1,17 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/alecthomas/assert/v2"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
 	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
 	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
 )
 
@@ -72,6 +77,11 @@ func TestResource(t *testing.T) {
 						"denobridge_resource.test",
 						tfjsonpath.New("sensitive_state"),
 					),
+					statecheck.ExpectKnownValue(
+						"denobridge_resource.test",
+						tfjsonpath.New("effective_permissions").AtMapKey("all"),
+						knownvalue.Bool(true),
+					),
 				},
 			},
 			// Update in place test
@@ -611,3 +621,104 @@ func TestStatelessResourceWithZod(t *testing.T) {
 		},
 	})
 }
+
+func TestStateChecksumKey(t *testing.T) {
+	assert.Zero(t, stateChecksumKey(""))
+
+	// The checksum comes from the script, so it may contain characters that must be escaped
+	for _, checksum := range []string{"sha256:abc123", `weird"checksum\`} {
+		var decoded struct {
+			Checksum string `json:"checksum"`
+		}
+		assert.NoError(t, json.Unmarshal(stateChecksumKey(checksum), &decoded))
+		assert.Equal(t, checksum, decoded.Checksum)
+	}
+}
+
+func TestResourceExportBeforeDestroy(t *testing.T) {
+	t.Setenv("TF_ACC", "1")
+	t.Setenv("TF_LOG", "DEBUG")
+
+	exportPath := t.TempDir()
+	config := func(path, content string) string {
+		return fmt.Sprintf(`
+			resource "denobridge_resource" "test" {
+				path  = "./resource_export_test.ts"
+				props = {
+					path    = %q
+					content = %q
+				}
+				export_before_destroy = true
+				export_path           = %q
+				permissions = {
+					all = true
+				}
+			}
+		`, path, content, exportPath)
+	}
+
+	// backups returns the content of every backup written so far
+	backups := func() ([]string, error) {
+		files, err := filepath.Glob(filepath.Join(exportPath, "*.snapshot"))
+		if err != nil {
+			return nil, err
+		}
+		var contents []string
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, string(content))
+		}
+		return contents, nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("./export1.txt", "Hello World"),
+				Check: func(*terraform.State) error {
+					if contents, err := backups(); err != nil || len(contents) != 0 {
+						return fmt.Errorf("expected no backups before anything was destroyed, got %v (%v)", contents, err)
+					}
+					return nil
+				},
+			},
+			// Replacing the resource destroys the old file, which is backed up first
+			{
+				Config: config("./export2.txt", "Hello Again"),
+				Check: func(*terraform.State) error {
+					contents, err := backups()
+					if err != nil {
+						return err
+					}
+					if len(contents) != 1 || contents[0] != "Hello World" {
+						return fmt.Errorf("expected a backup of the replaced resource, got %v", contents)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestWriteStateBackup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	first, err := writeStateBackup(dir, "./test.txt", []byte("first"))
+	assert.NoError(t, err)
+	second, err := writeStateBackup(dir, "./test.txt", []byte("second"))
+	assert.NoError(t, err)
+
+	// Backups of the same resource never overwrite each other, and the id can not escape dir
+	assert.NotEqual(t, first, second)
+	for file, expected := range map[string]string{first: "first", second: "second"} {
+		assert.Equal(t, dir, filepath.Dir(file))
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+}
@@ -1,3 +1,4 @@
+import { AsyncLocalStorage } from "node:async_hooks";
 import { TextLineStream } from "@std/streams";
 import { JSONRPCClient, type JSONRPCMethods, JSONRPCServer } from "@yieldray/json-rpc-ts";
 
@@ -49,6 +50,33 @@ export interface Writeable {
   writable: WritableStream<Uint8Array<ArrayBufferLike>>;
 }
 
+/**
+ * The JSON-RPC notification the remote party sends to cancel one of its requests, eg: when Terraform is interrupted.
+ */
+export const CANCEL_REQUEST_METHOD = "$/cancelRequest";
+
+/** Holds the abort signal of the request currently being handled. */
+const requestSignal = new AsyncLocalStorage<AbortSignal>();
+
+/**
+ * Returns the abort signal of the JSON-RPC request currently being handled, or undefined outside of one.
+ *
+ * The signal is aborted when the remote party cancels the request with a `$/cancelRequest`
+ * notification, eg: when the user interrupts Terraform. Pass it to fetch or check it between
+ * steps of long running work to stop early instead of leaving half finished changes behind.
+ *
+ * @example
+ * ```ts
+ * async create(props) {
+ *   const res = await fetch(url, { method: "POST", body: JSON.stringify(props), signal: cancelSignal() });
+ *   ...
+ * }
+ * ```
+ */
+export function cancelSignal(): AbortSignal | undefined {
+  return requestSignal.getStore();
+}
+
 export interface JSocketOptions {
   /**
    * If enabled, verbose logs will be output on STDERR.
@@ -231,6 +259,13 @@ export class JSocket<ClientMethods extends JSONRPCMethods, ServerMethods extends
    */
   readonly #clientResponseWaiters = new Map<number, { resolve: (response: string) => void; timeout: number }>();
 
+  /**
+   * Aborts the requests from the remote party that are being handled, keyed by JSON-RPC message ID.
+   *
+   * @internal
+   */
+  readonly #serverRequestAborts = new Map<string | number, AbortController>();
+
   /**
    * Queue of pending write operations to serialize access to the writer.
    * This prevents concurrent getWriter() calls which would throw on some platforms.
@@ -455,8 +490,18 @@ export class JSocket<ClientMethods extends JSONRPCMethods, ServerMethods extends
         return;
       }
 
-      // Let JSONRPCServer route the request
-      const response = await this.server.handleRequest(line);
+      // Abort the matching request being handled, cancellations are never answered
+      if (message.method === CANCEL_REQUEST_METHOD) {
+        this.#serverRequestAborts.get(message.params?.id)?.abort();
+        return;
+      }
+
+      // Let JSONRPCServer route the request, with a signal the remote party can abort
+      const abort = new AbortController();
+      const id = message.id;
+      if (id !== undefined && id !== null) this.#serverRequestAborts.set(id, abort);
+      const response = await requestSignal.run(abort.signal, () => this.server.handleRequest(line))
+        .finally(() => this.#serverRequestAborts.delete(id));
 
       // Send any response back
       // Notifications won't have a response
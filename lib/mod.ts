@@ -1,6 +1,23 @@
+export { cancelSignal } from "./jsocket.ts";
 export * from "./providers/action.ts";
+export {
+  addHealthWarning,
+  type BackendHealth,
+  cpuHint,
+  grantedPermissions,
+  type GrantedPermissions,
+  MANUAL_INTERVENTION_ERROR_CODE,
+  ManualInterventionError,
+  PROTOCOL_VERSION,
+  setBackendHealthCheck,
+  VALIDATION_FAILED_ERROR_CODE,
+  ValidationFailedError,
+  type ValidationIssue,
+} from "./providers/base.ts";
 export * from "./providers/datasource.ts";
 export * from "./providers/ephemeral_resource.ts";
 export * from "./providers/resource.ts";
+export type * as rpc from "./providers/rpc_types.d.ts";
+export { stateChecksum, type StateChecksum } from "./providers/state_checksum.ts";
 
 export const DENOBRIDGE_VERSION = "0.4.1";
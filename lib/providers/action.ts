@@ -2,6 +2,18 @@ import type { z } from "@zod/zod";
 import { BaseJsonRpcProvider } from "./base.ts";
 import { type Diagnostics, isDiagnostics } from "./diagnostics.ts";
 
+/**
+ * Optional details reported alongside an action's progress message.
+ */
+export interface ActionProgress {
+  /** Completion between 0 and 100, values outside are clamped. */
+  percent?: number;
+  /** How many units of work are done, shown when total is set. */
+  current?: number;
+  /** How many units of work there are in all. */
+  total?: number;
+}
+
 /**
  * Defines the methods that must be implemented by an action provider.
  *
@@ -12,10 +24,13 @@ export type ActionProviderMethods<TProps> = {
    * Invokes the action with the provided properties.
    *
    * @param props - The properties for the action invocation.
-   * @param progressCallback - A callback function to report progress messages during action execution.
+   * @param progressCallback - A callback function to report progress messages, and optionally a percentage and counts, during action execution.
    * @returns A promise that resolves when the action completes.
    */
-  invoke(props: TProps, progressCallback: (message: string) => Promise<void>): Promise<Diagnostics | void>;
+  invoke(
+    props: TProps,
+    progressCallback: (message: string, progress?: ActionProgress) => Promise<void>,
+  ): Promise<Diagnostics | void>;
 };
 
 /**
@@ -25,9 +40,9 @@ type RemoteMethods = {
   /**
    * Notifies the remote client of progress during action invocation.
    *
-   * @param params - Object containing the progress message.
+   * @param params - Object containing the progress message and optional percentage and counts.
    */
-  invokeProgress(params: { message: string }): void;
+  invokeProgress(params: { message: string } & ActionProgress): void;
 };
 
 /**
@@ -46,7 +61,7 @@ export class ActionProvider<TProps> extends BaseJsonRpcProvider<RemoteMethods> {
       async invoke(params: { props: Record<string, unknown> }) {
         const result = await providerMethods.invoke(
           params.props as TProps,
-          (message: string) => client.notify("invokeProgress", { message }),
+          (message: string, progress?: ActionProgress) => client.notify("invokeProgress", { message, ...progress }),
         );
         if (isDiagnostics(result)) return result;
         return { done: true };
@@ -1,6 +1,187 @@
 import { type JSONRPCClient, JSONRPCError, type JSONRPCMethod, type JSONRPCMethods } from "@yieldray/json-rpc-ts";
 import { createJSocket } from "../jsocket.ts";
 
+/** The JSON-RPC error code that halts an operation pending manual intervention. */
+export const MANUAL_INTERVENTION_ERROR_CODE = -32002;
+
+/**
+ * Throw from any provider method to halt the operation because a condition was detected that
+ * a human must resolve, eg: ambiguous state or external tampering. The provider renders this
+ * as a "Manual intervention required" error and never retries the operation automatically.
+ *
+ * @example
+ * ```ts
+ * async delete(id, props, state) {
+ *   const remote = await api.get(id);
+ *   if (remote.etag !== state.etag) {
+ *     throw new ManualInterventionError(`Resource ${id} was modified outside of Terraform`);
+ *   }
+ *   await api.delete(id);
+ * }
+ * ```
+ */
+export class ManualInterventionError extends JSONRPCError {
+  /** @param message - What needs to be resolved before running Terraform again. */
+  constructor(message: string) {
+    super(message, MANUAL_INTERVENTION_ERROR_CODE);
+  }
+}
+
+/** The JSON-RPC error code that rejects the input of an operation, see ValidationFailedError. */
+export const VALIDATION_FAILED_ERROR_CODE = -32003;
+
+/** Describes why a single prop was rejected. */
+export interface ValidationIssue {
+  /** The path to the offending prop, in the same form as a diagnostic's propPath, eg: `["props", "name"]`. */
+  path: string[];
+  /** Why the prop was rejected. */
+  message: string;
+}
+
+/**
+ * Throw from any provider method to reject its input at runtime, eg: a name that turns out to be
+ * taken during create. The provider attaches each issue to the offending attribute, just like a
+ * diagnostic with a propPath, so the user is pointed at exactly what to fix.
+ *
+ * @example
+ * ```ts
+ * async create(props) {
+ *   if (await api.exists(props.name)) {
+ *     throw new ValidationFailedError([{ path: ["props", "name"], message: `${props.name} is already taken` }]);
+ *   }
+ *   // ...
+ * }
+ * ```
+ */
+export class ValidationFailedError extends JSONRPCError {
+  /**
+   * @param issues - The offending props and why each was rejected.
+   * @param message - A summary of the failure, used when the provider can not attach the issues.
+   */
+  constructor(issues: ValidationIssue[], message = "Validation failed") {
+    super(message, VALIDATION_FAILED_ERROR_CODE, issues);
+  }
+}
+
+/**
+ * The effective Deno permissions the provider granted to this process.
+ * Sent by the provider as part of the startup `health` handshake.
+ */
+export interface GrantedPermissions {
+  /** Whether all permissions were granted (ie: `--allow-all`). */
+  all: boolean;
+  /** The permissions that were granted, eg: `read`, `net=example.com`. */
+  allow: string[];
+  /** The permissions that were explicitly denied. */
+  deny: string[];
+}
+
+let resolveGrantedPermissions: (permissions: GrantedPermissions) => void;
+const grantedPermissionsPromise = new Promise<GrantedPermissions>((resolve) => {
+  resolveGrantedPermissions = resolve;
+});
+
+/**
+ * Returns the permissions the provider granted to this process.
+ *
+ * Resolves once the startup handshake has been received, which always happens before
+ * any provider method is called. Use this to branch dynamic imports so a script can
+ * degrade gracefully instead of crashing on an import that needs a missing permission.
+ *
+ * @example
+ * ```ts
+ * const { allow, all } = await grantedPermissions();
+ * if (all || allow.includes("ffi")) {
+ *   const { native } = await import("./native.ts");
+ * }
+ * ```
+ */
+export function grantedPermissions(): Promise<GrantedPermissions> {
+  return grantedPermissionsPromise;
+}
+
+let resolveCpuHint: (cpus: number | undefined) => void;
+const cpuHintPromise = new Promise<number | undefined>((resolve) => {
+  resolveCpuHint = resolve;
+});
+
+/**
+ * Returns the number of CPUs the provider says this process may use, or undefined if it did not say.
+ *
+ * Resolves once the startup handshake has been received. In containers Deno can not always detect
+ * the real CPU limit, so use this to size the concurrency of CPU bound work instead.
+ *
+ * @example
+ * ```ts
+ * const workers = (await cpuHint()) ?? navigator.hardwareConcurrency;
+ * ```
+ */
+export function cpuHint(): Promise<number | undefined> {
+  return cpuHintPromise;
+}
+
+/** Whether a script can reach the backend it manages, reported as part of the `health` handshake. */
+export interface BackendHealth {
+  /** Whether the backend is reachable. */
+  ok: boolean;
+  /** Optionally explains why the backend is unreachable. */
+  message?: string;
+}
+
+let backendHealthCheck: (() => BackendHealth | Promise<BackendHealth>) | undefined;
+
+/**
+ * Registers a check that reports whether the script can reach the backend it manages.
+ *
+ * The check runs during the startup `health` handshake, so connectivity problems such as an
+ * API being down or invalid credentials surface when the provider starts the script rather
+ * than at the first CRUD call. A check that throws is reported as an unreachable backend.
+ *
+ * @example
+ * ```ts
+ * setBackendHealthCheck(async () => {
+ *   const res = await fetch("https://api.example.com/ping");
+ *   return { ok: res.ok, message: res.statusText };
+ * });
+ * ```
+ */
+export function setBackendHealthCheck(check: () => BackendHealth | Promise<BackendHealth>): void {
+  backendHealthCheck = check;
+}
+
+const healthWarnings: string[] = [];
+
+/**
+ * The version of the bridge protocol this library speaks, reported in the `health` handshake.
+ * The provider refuses to talk to a script that speaks a different major version.
+ */
+export const PROTOCOL_VERSION = "1.0.0";
+
+/**
+ * Returns the optional methods that are implemented by the given provider methods, reported as
+ * capabilities in the `health` handshake so the provider never calls one that is missing.
+ */
+export function optionalCapabilities(providerMethods: object, optionalMethods: string[]): string[] {
+  return optionalMethods.filter((name) => typeof (providerMethods as Record<string, unknown>)[name] === "function");
+}
+
+/**
+ * The optional protocol features this library supports. The provider offers the features it
+ * supports in the `health` handshake and only uses those answered with.
+ */
+const SUPPORTED_FEATURES = ["batch", "cancelRequest"];
+
+/**
+ * Adds an advisory that is reported to the provider as part of the `health` handshake,
+ * eg: a deprecated config option or a soon to expire credential.
+ *
+ * Warnings are shown to the user as warning diagnostics but never stop the provider.
+ * Call this before or while the script starts up, warnings added after the handshake are not reported.
+ */
+export function addHealthWarning(message: string): void {
+  healthWarnings.push(message);
+}
+
 /**
  * Base class for all JSON-RPC provider implementations in the denobridge Terraform provider.
  * Handles the JSON-RPC communication layer over stdin/stdout and provides common functionality
@@ -15,8 +196,13 @@ export class BaseJsonRpcProvider<RemoteMethods extends JSONRPCMethods = JSONRPCM
    * @param providerMethods - A function that receives a JSON-RPC client and returns an object
    *                          containing the provider's method implementations. The client can be
    *                          used to make calls or send notifications to the remote side.
+   * @param capabilities - The optional methods the provider implements, reported in the `health`
+   *                       handshake. When omitted the provider may call any method.
    */
-  constructor(providerMethods: (client: JSONRPCClient<RemoteMethods>) => Record<string, unknown>) {
+  constructor(
+    providerMethods: (client: JSONRPCClient<RemoteMethods>) => Record<string, unknown>,
+    capabilities?: string[],
+  ) {
     console.error(
       "This is a JSON-RPC 2.0 server for the denobridge terraform provider. see: https://github.com/brad-jones/terraform-provider-denobridge",
     );
@@ -28,18 +214,46 @@ export class BaseJsonRpcProvider<RemoteMethods extends JSONRPCMethods = JSONRPCM
       // swallow exception due to no permissions to read env vars
     }
 
-    const socket = createJSocket<RemoteMethods>(Deno.stdin, Deno.stdout, { debugLogging })(
-      (client) =>
-        wrapMethods({
+    const socketOptions = { debugLogging };
+    const socket = createJSocket<RemoteMethods>(Deno.stdin, Deno.stdout, socketOptions)(
+      (client) => {
+        const methods: JSONRPCMethods = wrapMethods({
           ...providerMethods(client),
-          health() {
-            return { ok: true };
+          async health(params?: { permissions?: GrantedPermissions; cpuHint?: number; features?: string[] }) {
+            resolveGrantedPermissions(params?.permissions ?? { all: false, allow: [], deny: [] });
+            resolveCpuHint(params?.cpuHint);
+            const warnings = healthWarnings.length > 0 ? [...healthWarnings] : undefined;
+            const features = (params?.features ?? []).filter((f) => SUPPORTED_FEATURES.includes(f));
+            const handshake = { ok: true, warnings, features, capabilities, protocolVersion: PROTOCOL_VERSION };
+            if (!backendHealthCheck) return handshake;
+            try {
+              return { ...handshake, backend: await backendHealthCheck() };
+            } catch (e) {
+              return {
+                ...handshake,
+                backend: { ok: false, message: e instanceof Error ? e.message : String(e) },
+              };
+            }
+          },
+          setLogLevel(params: { level: "trace" | "debug" | "info" | "warn" | "error" | "off" }) {
+            socketOptions.debugLogging = params.level === "trace" || params.level === "debug";
+          },
+          ping() {
+            return {};
           },
           shutdown() {
             console.error("Shutting down gracefully...");
             socket[Symbol.asyncDispose]();
           },
-        }),
+        });
+        // Lets the provider check that every method it calls is implemented before calling any of them
+        methods["rpc.discover"] = () => ({
+          openrpc: "1.3.2",
+          info: { title: "denobridge script", version: "1.0.0" },
+          methods: Object.keys(methods).map((name) => ({ name, params: [] })),
+        });
+        return methods;
+      },
     );
   }
 }
@@ -17,8 +17,31 @@ export interface DatasourceProviderMethods<TProps, TResult> {
    * @returns A promise that resolves to the data fetched from the datasource.
    */
   read(props: TProps): Promise<Diagnostics | TResult>;
+
+  /**
+   * Optionally reads data progressively, for datasources that enumerate large collections.
+   * Called instead of read when the provider asks for a streamed read, each chunk is sent
+   * to the provider as soon as it is emitted rather than buffered into one response.
+   *
+   * @param props - The properties/configuration for the datasource read operation.
+   * @param emit - Sends the next chunk of the result to the provider.
+   * @returns A promise that resolves once every chunk was emitted.
+   */
+  readStream?(props: TProps, emit: (chunk: unknown) => Promise<void>): Promise<Diagnostics | void>;
 }
 
+/**
+ * Internal type defining the remote methods available to the JSON-RPC client.
+ */
+type RemoteMethods = {
+  /**
+   * Delivers the next chunk of a streamed read to the provider.
+   *
+   * @param params - The stream the chunk belongs to, its sequence number from 0 and the chunk.
+   */
+  readChunk(params: { streamId: string; seq: number; chunk: unknown }): void;
+};
+
 /**
  * Base class for implementing Terraform datasource providers with JSON-RPC communication.
  * Datasources are read-only and used to fetch data from external sources during Terraform operations.
@@ -26,14 +49,24 @@ export interface DatasourceProviderMethods<TProps, TResult> {
  * @template TProps - The type of the properties/configuration for the datasource.
  * @template TResult - The type of the data returned by the datasource.
  */
-export class DatasourceProvider<TProps, TResult> extends BaseJsonRpcProvider {
+export class DatasourceProvider<TProps, TResult> extends BaseJsonRpcProvider<RemoteMethods> {
   /**
    * Creates a new DatasourceProvider instance.
    * @param providerMethods - The implementation of the datasource provider methods.
    */
   constructor(providerMethods: DatasourceProviderMethods<TProps, TResult>) {
-    super(() => ({
-      async read(params: { props: unknown }) {
+    super((client) => ({
+      async read(params: { props: unknown; streamId?: string }) {
+        const { streamId } = params;
+        if (streamId && providerMethods.readStream) {
+          let seq = 0;
+          const result = await providerMethods.readStream(
+            params.props as TProps,
+            (chunk) => client.notify("readChunk", { streamId, seq: seq++, chunk }),
+          );
+          return { ...(isDiagnostics(result) ? result : {}), chunks: seq };
+        }
+
         const result = await providerMethods.read(params.props as TProps);
         if (isDiagnostics(result)) return result;
 
@@ -1,6 +1,6 @@
 import { JSONRPCMethodNotFoundError } from "@yieldray/json-rpc-ts";
 import type { z } from "@zod/zod";
-import { BaseJsonRpcProvider } from "./base.ts";
+import { BaseJsonRpcProvider, optionalCapabilities } from "./base.ts";
 import { type Diagnostics, isDiagnostics } from "./diagnostics.ts";
 
 /**
@@ -65,12 +65,18 @@ export type EphemeralResourceProviderMethods<TProps, TResult, TPrivateData = nev
  * @template TResult - The type of the data returned when the resource is opened.
  * @template TPrivateData - The type of private data maintained between lifecycle operations.
  */
+/** The optional methods of an ephemeral resource, reported as capabilities when implemented. */
+const OPTIONAL_METHODS = ["renew", "close"];
+
 export class EphemeralResourceProvider<TProps, TResult, TPrivateData = never> extends BaseJsonRpcProvider {
   /**
    * Creates a new EphemeralResourceProvider instance.
    * @param providerMethods - The implementation of the ephemeral resource provider methods.
    */
   constructor(providerMethods: EphemeralResourceProviderMethods<TProps, TResult, TPrivateData>) {
+    // Renewals in flight, close waits for them to settle so a renewal can not complete after the close
+    const renewals = new Set<Promise<unknown>>();
+
     super(() => ({
       async open(params: { props: Record<string, unknown> }) {
         const result = await providerMethods.open(params.props as TProps);
@@ -90,14 +96,22 @@ export class EphemeralResourceProvider<TProps, TResult, TPrivateData = never> ex
       },
       async renew(params: { privateData: TPrivateData }) {
         if (!providerMethods.renew) throw new JSONRPCMethodNotFoundError();
-        return await providerMethods.renew(params.privateData);
+        const renewal = providerMethods.renew(params.privateData);
+        renewals.add(renewal);
+        try {
+          return await renewal;
+        } finally {
+          renewals.delete(renewal);
+        }
       },
       async close(params: { privateData: TPrivateData }) {
         if (!providerMethods.close) throw new JSONRPCMethodNotFoundError();
+        // The provider cancels a renewal in flight before closing, see cancelSignal
+        await Promise.allSettled(renewals);
         const result = await providerMethods.close(params.privateData);
         if (isDiagnostics(result)) return result;
       },
-    }));
+    }), optionalCapabilities(providerMethods, OPTIONAL_METHODS));
   }
 }
 
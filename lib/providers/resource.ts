@@ -1,21 +1,207 @@
 // deno-lint-ignore-file no-explicit-any
 
-import { JSONRPCMethodNotFoundError } from "@yieldray/json-rpc-ts";
+import { JSONRPCError, JSONRPCMethodNotFoundError } from "@yieldray/json-rpc-ts";
 import type { z } from "@zod/zod";
-import { BaseJsonRpcProvider } from "./base.ts";
+import { BaseJsonRpcProvider, optionalCapabilities } from "./base.ts";
 import { type Diagnostics, isDiagnostics } from "./diagnostics.ts";
+import { isStateChecksum, type StateChecksum } from "./state_checksum.ts";
+
+/**
+ * The JSON-RPC error code that signals a resource can not be deleted right now.
+ * The provider retries a busy delete with backoff instead of failing immediately.
+ */
+export const RESOURCE_BUSY_ERROR_CODE = -32001;
+
+/**
+ * Throw from `delete` when the resource can not be deleted yet, eg: it still has dependents
+ * or an async backend operation has not finished. Unlike any other error, the provider will
+ * retry the delete with backoff, up to a bound, before failing.
+ *
+ * @example
+ * ```ts
+ * async delete(id) {
+ *   const { status } = await api.get(id);
+ *   if (status === "provisioning") throw new ResourceBusyError("Still provisioning", 5000);
+ *   await api.delete(id);
+ * }
+ * ```
+ */
+export class ResourceBusyError extends JSONRPCError {
+  /**
+   * @param message - Why the resource is busy.
+   * @param retryAfterMs - An optional hint for how long the provider should wait before retrying.
+   */
+  constructor(message = "Resource is busy", retryAfterMs?: number) {
+    super(message, RESOURCE_BUSY_ERROR_CODE, retryAfterMs === undefined ? undefined : { retryAfterMs });
+  }
+}
+
+/**
+ * Throw from `create` when it failed midway, after it already created part of the resource, eg: some of
+ * its sub-resources. The provider saves the partial id & state to the Terraform state, marked as tainted,
+ * so the next apply deletes and recreates the resource rather than orphaning what was created.
+ *
+ * @example
+ * ```ts
+ * async create(props) {
+ *   const network = await api.createNetwork(props);
+ *   try {
+ *     const server = await api.createServer(network.id, props);
+ *     return { id: server.id, state: { networkId: network.id } };
+ *   } catch (e) {
+ *     throw new PartialCreateError(`Failed to create server: ${e}`, network.id, { networkId: network.id });
+ *   }
+ * }
+ * ```
+ */
+export class PartialCreateError<TState = unknown, TID = string> extends JSONRPCError {
+  /**
+   * @param message - Why the create failed.
+   * @param id - The id to save for what was created, so the resource can be read & deleted later.
+   * @param state - The state of what was created, its `sensitive` key is stored as sensitive state.
+   * @param code - An optional JSON-RPC error code.
+   */
+  constructor(message: string, id: TID, state?: TState, code = -32000) {
+    // Like the state returned by create, any sensitive values are stored apart from the rest
+    const { sensitive: sensitiveState, ...rest } = (state ?? {}) as any;
+    super(message, code, { partialState: { id, state: state === undefined ? undefined : rest, sensitiveState } });
+  }
+}
+
+/** Additional options given to the read method. */
+export interface ReadOptions {
+  /**
+   * When true the read must not make any changes, eg: lazily repairing drift, it must only
+   * report the current state. Set when the provider is configured with `refresh_only = true`.
+   */
+  refreshOnly: boolean;
+}
+
+/** Additional options given to the create method. */
+export interface CreateOptions {
+  /**
+   * Identifies the logical create, it is the same for every retry of it. When a create is retried after it
+   * already created the object, eg: because the response was lost, the script should return the state of the
+   * existing object rather than creating a duplicate.
+   */
+  idempotencyKey?: string;
+  /**
+   * Set when the provider reserved a slot for a pending create, so a PendingCreate may be returned.
+   * Otherwise the create should finish before returning.
+   */
+  pendingAsync?: boolean;
+}
+
+/** Fields that may be returned alongside any modifyPlan result. */
+interface PlanExplanation {
+  /**
+   * A human readable explanation of why the plan looks the way it does.
+   * Shown to the user as a note in the plan output.
+   */
+  explanation?: string;
+}
+
+/**
+ * Returned from create to start a long running create. The provider then polls
+ * createStatus with the id until the create completes or fails.
+ */
+export interface PendingCreate<TID = string> {
+  /** Identifies the pending create, passed to createStatus. */
+  id: TID;
+  pending: true;
+  /**
+   * Optionally how often, in milliseconds, the provider should poll createStatus,
+   * eg: longer for a slow backend. The provider bounds it to its own floor & ceiling.
+   */
+  pollIntervalMs?: number;
+}
+
+/** Describes how far along a pending create is, shown to the user while they wait. */
+export interface CreateProgress {
+  /** A human readable description of the current step. */
+  message: string;
+  /** Optional completion between 0 and 100. */
+  percent?: number;
+}
+
+/** The result of polling a pending create. */
+export type CreateStatus<TState = void, TID = string> =
+  | {
+    status: "pending";
+    progress?: CreateProgress;
+    /** Optionally changes how often, in milliseconds, the provider polls createStatus from now on. */
+    pollIntervalMs?: number;
+  }
+  | {
+    status: "complete";
+    progress?: CreateProgress;
+    /** Optionally replaces the id returned by the pending create. */
+    id?: TID;
+    state?: TState;
+  }
+  | {
+    status: "failed";
+    /** Describes why the create failed. */
+    error: string;
+  };
+
+/** Describes how far along a pending delete is, shown to the user while they wait. */
+export interface DeleteProgress {
+  /** A human readable description of the current step. */
+  message: string;
+  /** Optional completion between 0 and 100. */
+  percent?: number;
+}
+
+/**
+ * Returned from delete to start a long running delete, eg: draining or cascading. The provider
+ * reports progress while it waits for complete to settle, a rejection fails the delete.
+ */
+export interface PendingDelete {
+  pending: true;
+  /**
+   * Finishes the delete in the background.
+   *
+   * @param progress - Reports how far along the delete is.
+   * @returns A promise that resolves when the resource is deleted.
+   */
+  complete(progress: (progress: DeleteProgress) => Promise<void>): Promise<Diagnostics | void>;
+}
+
+/** Checks if the result of delete is a PendingDelete. */
+function isPendingDelete(value: unknown): value is PendingDelete {
+  return typeof value === "object" && value !== null && "pending" in value && value.pending === true &&
+    "complete" in value && typeof value.complete === "function";
+}
+
+/**
+ * Internal type defining the remote methods available to the JSON-RPC client.
+ */
+type RemoteMethods = {
+  /** Notifies the provider of progress during a pending delete. */
+  deleteProgress(params: { id: unknown } & DeleteProgress): void;
+  /** Notifies the provider that a pending delete has finished, successfully or not. */
+  deleteComplete(params: { id: unknown; done?: boolean; error?: string } & Partial<Diagnostics>): void;
+};
 
 /** The return type for the modifyPlan method. */
 type ModifyPlanReturn<TProps> = Promise<
-  | {
+  | (PlanExplanation & {
     /** Modified properties to use instead of the originally planned properties. */
     modifiedProps?: TProps;
-  }
-  | {
+  })
+  | (PlanExplanation & {
     /** Whether the resource must be replaced (destroyed and recreated) instead of updated. */
     requiresReplacement: boolean;
-  }
-  | Diagnostics
+  })
+  | (PlanExplanation & {
+    /**
+     * Paths of the props whose change forces the resource to be replaced, eg: ["region", "network.cidr"].
+     * Terraform shows each of these attributes as forcing replacement, rather than the whole resource.
+     */
+    replaceTriggers: string[];
+  })
+  | (PlanExplanation & Diagnostics)
   | undefined
 >;
 
@@ -32,9 +218,28 @@ type StatefulResourceProviderMethods<TProps, TState, TID = string> = {
    * Creates a new resource with the provided properties.
    *
    * @param props - The properties/configuration for the new resource.
-   * @returns A promise that resolves to an object containing the resource ID and initial state.
+   * @param id - The id the provider generated for the new resource, only set when the provider generates ids.
+   * @param options - Additional create options.
+   * @param options.idempotencyKey - The same for every retry of this create, to deduplicate retries with.
+   * @param options.pendingAsync - Set when a PendingCreate may be returned.
+   * @returns A promise that resolves to an object containing the resource ID and initial state,
+   *          optionally with warnings to display alongside them and a checksum of the state,
+   *          or a PendingCreate for long running creates that are completed by createStatus.
+   */
+  create(
+    props: TProps,
+    id?: TID,
+    options?: CreateOptions,
+  ): Promise<Diagnostics | ({ id: TID; state: TState } & Diagnostics & StateChecksum) | PendingCreate<TID>>;
+
+  /**
+   * Reports the status of a pending create. This method is optional and only called
+   * when create returned a PendingCreate.
+   *
+   * @param id - The identifier returned by the pending create.
+   * @returns A promise that resolves to the status, including the initial state once complete.
    */
-  create(props: TProps): Promise<Diagnostics | { id: TID; state: TState }>;
+  createStatus?(id: TID): Promise<CreateStatus<TState, TID>>;
 
   /**
    * Reads an existing resource by its ID and validates it against the expected properties.
@@ -43,10 +248,44 @@ type StatefulResourceProviderMethods<TProps, TState, TID = string> = {
    * @param props - The expected properties/configuration of the resource.
    *                Props may not always exist, for example when importing resource,
    *                they are given on a best effort basis.
+   * @param options - Additional read options.
+   * @param options.refreshOnly - When true the read must not make any changes, eg: lazily repairing drift,
+   *                              it must only report the current state.
    * @returns A promise that resolves to the current properties and state if the resource exists,
    *          or an object with exists: false if the resource no longer exists.
    */
-  read(id: TID, props: TProps | null): Promise<Diagnostics | { props: TProps; state: TState } | { exists: false }>;
+  read(
+    id: TID,
+    props: TProps | null,
+    options?: ReadOptions,
+  ): Promise<Diagnostics | { props: TProps; state: TState } | { exists: false }>;
+
+  /**
+   * Hydrates a resource from a snapshot instead of looking it up in its live backend. This method is optional,
+   * it supports rebuilding Terraform state when the backend is unavailable but a known good snapshot exists.
+   *
+   * @param snapshot - The raw snapshot data.
+   * @returns A promise that resolves to the properties and state of the resource described by the snapshot.
+   */
+  importSnapshot?(snapshot: Uint8Array): Promise<Diagnostics | { props: TProps; state: TState }>;
+
+  /**
+   * Produces a portable backup of a resource, called before it is destroyed when the provider is configured
+   * to export state first. This method is optional, the backup can later be restored with importSnapshot.
+   *
+   * @param id - The identifier of the resource to export.
+   * @returns A promise that resolves to the raw snapshot data.
+   */
+  exportState?(id: TID): Promise<Uint8Array>;
+
+  /**
+   * Adopts an existing external object into Terraform. This method is optional, unlike read
+   * it is given nothing but the id, so it must describe the object from scratch.
+   *
+   * @param id - The identifier of the existing object to import.
+   * @returns A promise that resolves to the properties and state of the object.
+   */
+  import?(id: TID): Promise<Diagnostics | { props: TProps; state: TState }>;
 
   /**
    * Updates an existing resource with new properties.
@@ -55,14 +294,15 @@ type StatefulResourceProviderMethods<TProps, TState, TID = string> = {
    * @param nextProps - The new properties/configuration to apply.
    * @param currentProps - The current properties/configuration before the update.
    * @param currentState - The current state before the update.
-   * @returns A promise that resolves to the updated state.
+   * @returns A promise that resolves to the updated state, or to `{ state, diagnostics, stateChecksum }`
+   *          to display warnings or return a checksum alongside the updated state.
    */
   update(
     id: TID,
     nextProps: TProps,
     currentProps: TProps,
     currentState: TState,
-  ): Promise<Diagnostics | TState>;
+  ): Promise<Diagnostics | TState | ({ state: TState } & Diagnostics & StateChecksum)>;
 
   /**
    * Deletes an existing resource.
@@ -70,9 +310,10 @@ type StatefulResourceProviderMethods<TProps, TState, TID = string> = {
    * @param id - The identifier of the resource to delete.
    * @param props - The current properties/configuration of the resource.
    * @param state - The current state of the resource.
-   * @returns A promise that resolves when the resource is deleted.
+   * @returns A promise that resolves when the resource is deleted,
+   *          or a PendingDelete for long running deletes that report their progress.
    */
-  delete(id: TID, props: TProps, state: TState): Promise<Diagnostics | void>;
+  delete(id: TID, props: TProps, state: TState): Promise<Diagnostics | void | PendingDelete>;
 
   /**
    * Modifies a Terraform plan before execution. This method is optional and allows customizing
@@ -107,9 +348,28 @@ type StatelessResourceProviderMethods<TProps, TID = string> = {
    * Creates a new resource with the provided properties.
    *
    * @param props - The properties/configuration for the new resource.
-   * @returns A promise that resolves to an object containing the resource ID.
+   * @param id - The id the provider generated for the new resource, only set when the provider generates ids.
+   * @param options - Additional create options.
+   * @param options.idempotencyKey - The same for every retry of this create, to deduplicate retries with.
+   * @param options.pendingAsync - Set when a PendingCreate may be returned.
+   * @returns A promise that resolves to an object containing the resource ID,
+   *          optionally with warnings to display alongside it,
+   *          or a PendingCreate for long running creates that are completed by createStatus.
+   */
+  create(
+    props: TProps,
+    id?: TID,
+    options?: CreateOptions,
+  ): Promise<Diagnostics | ({ id: TID } & Diagnostics) | PendingCreate<TID>>;
+
+  /**
+   * Reports the status of a pending create. This method is optional and only called
+   * when create returned a PendingCreate.
+   *
+   * @param id - The identifier returned by the pending create.
+   * @returns A promise that resolves to the status of the create.
    */
-  create(props: TProps): Promise<Diagnostics | { id: TID }>;
+  createStatus?(id: TID): Promise<CreateStatus<void, TID>>;
 
   /**
    * Reads an existing resource by its ID and validates it against the expected properties.
@@ -118,10 +378,40 @@ type StatelessResourceProviderMethods<TProps, TID = string> = {
    * @param props - The expected properties/configuration of the resource.
    *                Props may not always exist, for example when importing resource,
    *                they are given on a best effort basis.
+   * @param options - Additional read options.
+   * @param options.refreshOnly - When true the read must not make any changes, eg: lazily repairing drift,
+   *                              it must only report the current state.
    * @returns A promise that resolves to the current properties if the resource exists,
    *          or an object with exists: false if the resource no longer exists.
    */
-  read(id: TID, props: TProps | null): Promise<Diagnostics | { props: TProps } | { exists: false }>;
+  read(id: TID, props: TProps | null, options?: ReadOptions): Promise<Diagnostics | { props: TProps } | { exists: false }>;
+
+  /**
+   * Hydrates a resource from a snapshot instead of looking it up in its live backend. This method is optional,
+   * it supports rebuilding Terraform state when the backend is unavailable but a known good snapshot exists.
+   *
+   * @param snapshot - The raw snapshot data.
+   * @returns A promise that resolves to the properties of the resource described by the snapshot.
+   */
+  importSnapshot?(snapshot: Uint8Array): Promise<Diagnostics | { props: TProps }>;
+
+  /**
+   * Produces a portable backup of a resource, called before it is destroyed when the provider is configured
+   * to export state first. This method is optional, the backup can later be restored with importSnapshot.
+   *
+   * @param id - The identifier of the resource to export.
+   * @returns A promise that resolves to the raw snapshot data.
+   */
+  exportState?(id: TID): Promise<Uint8Array>;
+
+  /**
+   * Adopts an existing external object into Terraform. This method is optional, unlike read
+   * it is given nothing but the id, so it must describe the object from scratch.
+   *
+   * @param id - The identifier of the existing object to import.
+   * @returns A promise that resolves to the properties of the object.
+   */
+  import?(id: TID): Promise<Diagnostics | { props: TProps }>;
 
   /**
    * Updates an existing resource with new properties.
@@ -142,9 +432,10 @@ type StatelessResourceProviderMethods<TProps, TID = string> = {
    *
    * @param id - The identifier of the resource to delete.
    * @param props - The current properties/configuration of the resource.
-   * @returns A promise that resolves when the resource is deleted.
+   * @returns A promise that resolves when the resource is deleted,
+   *          or a PendingDelete for long running deletes that report their progress.
    */
-  delete(id: TID, props: TProps): Promise<Diagnostics | void>;
+  delete(id: TID, props: TProps): Promise<Diagnostics | void | PendingDelete>;
 
   /**
    * Modifies a Terraform plan before execution. This method is optional and allows customizing
@@ -193,15 +484,88 @@ export type ResourceProviderMethods<TProps, TState = void, TID = string> = [TSta
  * @template TState - The type of the runtime state maintained by the resource (defaults to void for stateless resources).
  * @template TID - The type of the resource identifier (defaults to string).
  */
-export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJsonRpcProvider {
+/** The optional methods of a resource, reported as capabilities when implemented. */
+const OPTIONAL_METHODS = ["createStatus", "import", "importSnapshot", "exportState", "modifyPlan"];
+
+export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJsonRpcProvider<RemoteMethods> {
   /**
    * Creates a new ResourceProvider instance.
    * @param providerMethods - The implementation of the resource provider methods.
    */
   constructor(providerMethods: ResourceProviderMethods<TProps, TState, TID>) {
-    super(() => ({
-      async create(params: { props: Record<string, unknown>; writeOnlyProps?: Record<string, unknown> }) {
-        const result = await providerMethods.create({ ...params.props, writeOnly: params.writeOnlyProps } as TProps);
+    super((client) => ({
+      async create(
+        params: {
+          props: Record<string, unknown>;
+          writeOnlyProps?: Record<string, unknown>;
+          id?: TID;
+          idempotencyKey?: string;
+          pendingAsync?: boolean;
+        },
+      ) {
+        const result = await providerMethods.create(
+          { ...params.props, writeOnly: params.writeOnlyProps } as TProps,
+          params.id,
+          { idempotencyKey: params.idempotencyKey, pendingAsync: params.pendingAsync },
+        );
+
+        // Diagnostics without an id failed the create, otherwise they are displayed alongside the new resource
+        if (isDiagnostics(result) && !("id" in result)) return result;
+
+        if ("pending" in result) return { id: result.id, pending: true, pollIntervalMs: result.pollIntervalMs };
+
+        const sensitiveState = (result as any).state?.sensitive;
+
+        const state = (result as any).state;
+        if (state && typeof state === "object" && "sensitive" in state) {
+          delete state["sensitive"];
+        }
+
+        return {
+          id: result.id,
+          state,
+          sensitiveState,
+          diagnostics: (result as Diagnostics).diagnostics,
+          stateChecksum: (result as StateChecksum).stateChecksum,
+        };
+      },
+      async createStatus(params: { id: TID }) {
+        if (!providerMethods.createStatus) throw new JSONRPCMethodNotFoundError();
+
+        const result = await providerMethods.createStatus(params.id);
+        if (result.status !== "complete") return result;
+
+        const sensitiveState = (result as any).state?.sensitive;
+
+        const state = (result as any).state;
+        if (state && typeof state === "object" && "sensitive" in state) {
+          delete state["sensitive"];
+        }
+
+        return { ...result, state, sensitiveState };
+      },
+      async import(params: { id: TID }) {
+        if (!providerMethods.import) throw new JSONRPCMethodNotFoundError();
+
+        const result = await providerMethods.import(params.id);
+
+        if (isDiagnostics(result)) return result;
+
+        const sensitiveState = (result as any).state?.sensitive;
+
+        const state = (result as any).state;
+        if (state && typeof state === "object" && "sensitive" in state) {
+          delete state["sensitive"];
+        }
+
+        return { props: result.props, state, sensitiveState };
+      },
+      async importSnapshot(params: { snapshot: string }) {
+        if (!providerMethods.importSnapshot) throw new JSONRPCMethodNotFoundError();
+
+        // The snapshot is base64 encoded over JSON-RPC
+        const snapshot = Uint8Array.from(atob(params.snapshot), (c) => c.charCodeAt(0));
+        const result = await providerMethods.importSnapshot(snapshot);
 
         if (isDiagnostics(result)) return result;
 
@@ -212,10 +576,19 @@ export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJ
           delete state["sensitive"];
         }
 
-        return { id: result.id, state, sensitiveState };
+        return { props: result.props, state, sensitiveState };
+      },
+      async exportState(params: { id: TID }) {
+        if (!providerMethods.exportState) throw new JSONRPCMethodNotFoundError();
+
+        // The snapshot is base64 encoded over JSON-RPC
+        const snapshot = await providerMethods.exportState(params.id);
+        return { snapshot: btoa(Array.from(snapshot, (b) => String.fromCharCode(b)).join("")) };
       },
-      async read(params: { id: TID; props: Record<string, unknown> | null }) {
-        const result = await providerMethods.read(params.id, params.props as TProps | null);
+      async read(params: { id: TID; props: Record<string, unknown> | null; refreshOnly?: boolean }) {
+        const result = await providerMethods.read(params.id, params.props as TProps | null, {
+          refreshOnly: params.refreshOnly ?? false,
+        });
 
         if ("exists" in result) return result;
 
@@ -247,16 +620,20 @@ export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJ
           { ...params.currentState, sensitive: params.currentSensitiveState } as TState,
         );
 
-        if (isDiagnostics(result)) return result;
+        // Diagnostics without a state failed the update, otherwise they are displayed alongside the new state
+        if (isDiagnostics(result) && !("state" in result)) return result;
+        const wrapped = isDiagnostics(result) || isStateChecksum(result);
+        const diagnostics = isDiagnostics(result) ? result.diagnostics : undefined;
+        const checksum = isStateChecksum(result) ? result.stateChecksum : undefined;
 
-        const sensitiveState = (result as any)?.sensitive;
+        const state = wrapped ? (result as any).state : result as any;
 
-        const state = result as any;
+        const sensitiveState = state?.sensitive;
         if (state && typeof state === "object" && "sensitive" in state) {
           delete state["sensitive"];
         }
 
-        return { state: result, sensitiveState };
+        return { state, sensitiveState, diagnostics, stateChecksum: checksum };
       },
       async delete(
         params: {
@@ -264,16 +641,79 @@ export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJ
           props: Record<string, unknown>;
           state: Record<string, unknown>;
           sensitiveState?: Record<string, unknown>;
+          pendingAsync?: boolean;
         },
       ) {
-        const result = await providerMethods.delete(
+        let result = await providerMethods.delete(
           params.id,
           params.props as TProps,
           { ...params.state, sensitive: params.sensitiveState } as TState,
         );
+        // Without a slot reserved by the provider, the pending delete is awaited before answering
+        if (isPendingDelete(result) && !params.pendingAsync) {
+          result = await result.complete((progress) => client.notify("deleteProgress", { id: params.id, ...progress }));
+        }
+        if (isPendingDelete(result)) {
+          // Finish in the background, the provider waits for the deleteComplete notification
+          result.complete((progress) => client.notify("deleteProgress", { id: params.id, ...progress })).then(
+            (completed) =>
+              client.notify("deleteComplete", isDiagnostics(completed) ? { id: params.id, ...completed } : {
+                id: params.id,
+                done: true,
+              }),
+            (e) => client.notify("deleteComplete", { id: params.id, error: e instanceof Error ? e.message : String(e) }),
+          );
+          return { pending: true };
+        }
         if (isDiagnostics(result)) return result;
         return { done: true };
       },
+      async deleteBatch(
+        params: {
+          items: {
+            id: TID;
+            props: Record<string, unknown>;
+            state: Record<string, unknown>;
+            sensitiveState?: Record<string, unknown>;
+            pendingAsync?: boolean;
+          }[];
+        },
+      ) {
+        // Each item succeeds or fails on its own, so one bad delete does not fail the whole batch
+        const settled = await Promise.allSettled(params.items.map(async (item) => {
+          let result = await providerMethods.delete(
+            item.id,
+            item.props as TProps,
+            { ...item.state, sensitive: item.sensitiveState } as TState,
+          );
+          // Without a slot reserved by the provider, the pending delete is awaited without progress
+          if (isPendingDelete(result) && !item.pendingAsync) result = await result.complete(async () => {});
+          if (isPendingDelete(result)) {
+            // Finish in the background, the provider waits for the deleteComplete notification
+            result.complete((progress) => client.notify("deleteProgress", { id: item.id, ...progress })).then(
+              (completed) =>
+                client.notify("deleteComplete", isDiagnostics(completed) ? { id: item.id, ...completed } : {
+                  id: item.id,
+                  done: true,
+                }),
+              (e) =>
+                client.notify("deleteComplete", { id: item.id, error: e instanceof Error ? e.message : String(e) }),
+            );
+            return { pending: true };
+          }
+          if (isDiagnostics(result)) return result;
+          return { done: true };
+        }));
+
+        return {
+          results: settled.map((outcome) => {
+            if (outcome.status === "fulfilled") return outcome.value;
+            const e = outcome.reason;
+            if (e instanceof JSONRPCError) return { error: { code: e.code, message: e.message, data: e.data } };
+            return { error: { code: -32603, message: e instanceof Error ? e.message : String(e) } };
+          }),
+        };
+      },
       async modifyPlan(
         params: {
           id?: TID;
@@ -302,7 +742,7 @@ export class ResourceProvider<TProps, TState = void, TID = string> extends BaseJ
 
         return { noChanges: true };
       },
-    }));
+    }), optionalCapabilities(providerMethods, OPTIONAL_METHODS));
   }
 }
 
@@ -349,7 +789,7 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
       : args[0];
 
     const validatedMethods = {
-      async create(props: any) {
+      async create(props: any, id?: TID, options?: CreateOptions) {
         // Validate props
         const propsParsed = propsSchema.safeParse(props);
         if (!propsParsed.success) {
@@ -364,10 +804,13 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
         }
 
         // Call the method with validated props
-        const result = await providerMethods.create(propsParsed.data);
+        const result = await providerMethods.create(propsParsed.data, id, options);
 
-        // Catch any diagnostics and return them early
-        if (isDiagnostics(result)) return result;
+        // Catch any diagnostics that failed the create and return them early
+        if (isDiagnostics(result) && !("id" in result)) return result;
+
+        // The state of a pending create is validated by createStatus once complete
+        if ("pending" in result) return result;
 
         // Validate the state
         if (stateSchema) {
@@ -383,12 +826,17 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
             };
           }
 
-          return { id: result.id, state: stateParsed.data };
+          return {
+            id: result.id,
+            state: stateParsed.data,
+            diagnostics: (result as Diagnostics).diagnostics,
+            stateChecksum: (result as StateChecksum).stateChecksum,
+          };
         }
 
-        return { id: result.id };
+        return { id: result.id, diagnostics: (result as Diagnostics).diagnostics };
       },
-      async read(id: TID, props: any) {
+      async read(id: TID, props: any, options?: ReadOptions) {
         // Validate props
         const propsParsed = props ? propsSchema.safeParse(props) : undefined;
         if (propsParsed?.success === false) {
@@ -403,7 +851,7 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
         }
 
         // Call the method with validated props
-        const result = await providerMethods.read(id, propsParsed?.data ?? null);
+        const result = await providerMethods.read(id, propsParsed?.data ?? null, options);
 
         // Catch any diagnostics and return them early
         if (isDiagnostics(result)) return result;
@@ -510,12 +958,13 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
           currentStateParsed ? currentStateParsed.data as any : undefined,
         );
 
-        // Catch any diagnostics and return them early
-        if (isDiagnostics(result)) return result;
+        // Catch any diagnostics that failed the update and return them early
+        if (isDiagnostics(result) && !("state" in result)) return result;
 
         // Validate the state
         if (stateSchema) {
-          const stateParsed = stateSchema.safeParse(result);
+          const wrapped = isDiagnostics(result) || isStateChecksum(result);
+          const stateParsed = stateSchema.safeParse(wrapped ? (result as any).state : result);
           if (!stateParsed.success) {
             return {
               diagnostics: stateParsed.error.issues.map((i) => ({
@@ -526,6 +975,13 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
               })),
             };
           }
+          if (wrapped) {
+            return {
+              state: stateParsed.data,
+              diagnostics: (result as Diagnostics).diagnostics,
+              stateChecksum: (result as StateChecksum).stateChecksum,
+            };
+          }
           return stateParsed.data;
         }
       },
@@ -559,10 +1015,73 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
         // Call the method with validated props
         const result = await providerMethods.delete(id, propsParsed.data, stateParsed?.data as any);
 
-        // Catch any diagnostics and return them early
-        if (isDiagnostics(result)) return result;
+        // Catch any diagnostics and pending deletes and return them early
+        if (isDiagnostics(result) || isPendingDelete(result)) return result;
       },
     };
+    if (providerMethods.createStatus) {
+      (validatedMethods as any)["createStatus"] = async (id: TID) => {
+        const result = await providerMethods.createStatus!(id);
+
+        // Validate the state once the create is complete
+        if (result.status === "complete" && stateSchema) {
+          const stateParsed = stateSchema.safeParse((result as any).state);
+          if (!stateParsed.success) {
+            return {
+              status: "failed",
+              error: stateParsed.error.issues.map((i) => `Zod Validation Issue: ${i.message}`).join("\n"),
+            };
+          }
+          return { ...result, state: stateParsed.data };
+        }
+
+        return result;
+      };
+    }
+    // Validates the props & state of an imported resource, just like a read
+    const validateImported = (result: Diagnostics | { props: any; state?: any }) => {
+      // Catch any diagnostics and return them early
+      if (isDiagnostics(result)) return result;
+
+      const resultPropsParsed = propsSchema.safeParse(result.props);
+      const resultStateParsed = stateSchema ? stateSchema.safeParse((result as any).state) : undefined;
+      if (!resultPropsParsed.success || resultStateParsed?.success === false) {
+        return {
+          diagnostics: [
+            ...(!resultPropsParsed.success
+              ? resultPropsParsed.error.issues.map((i) => ({
+                severity: "error",
+                summary: "Zod Validation Issue",
+                detail: i.message,
+                propPath: i.path.length > 0 ? ["props", ...i.path.map((_) => String(_))] : undefined,
+              }))
+              : []),
+            ...(resultStateParsed?.success === false
+              ? resultStateParsed.error.issues.map((i) => ({
+                severity: "error",
+                summary: "Zod Validation Issue",
+                detail: i.message,
+                propPath: i.path.length > 0 ? ["state", ...i.path.map((_) => String(_))] : undefined,
+              }))
+              : []),
+          ],
+        } as Diagnostics;
+      }
+
+      return resultStateParsed
+        ? { props: resultPropsParsed.data, state: resultStateParsed.data }
+        : { props: resultPropsParsed.data };
+    };
+    if (providerMethods.import) {
+      (validatedMethods as any)["import"] = async (id: TID) => validateImported(await providerMethods.import!(id));
+    }
+    if (providerMethods.importSnapshot) {
+      (validatedMethods as any)["importSnapshot"] = async (snapshot: Uint8Array) =>
+        validateImported(await providerMethods.importSnapshot!(snapshot));
+    }
+    if (providerMethods.exportState) {
+      (validatedMethods as any)["exportState"] = providerMethods.exportState;
+    }
     if (providerMethods.modifyPlan) {
       (validatedMethods as any)["modifyPlan"] = async (
         id: TID,
@@ -624,8 +1143,8 @@ export class ZodResourceProvider<TProps extends z.ZodType, TState extends z.ZodT
         // Catch any diagnostics and return them early
         if (isDiagnostics(result)) return result;
 
-        // Catch the requiresReplacement case
-        if ("requiresReplacement" in result) return result;
+        // Catch the requiresReplacement & replaceTriggers cases
+        if ("requiresReplacement" in result || "replaceTriggers" in result) return result;
 
         // Validate the modified props
         const modifiedPropsParsed = result.modifiedProps ? propsSchema.safeParse(result.modifiedProps) : undefined;
@@ -0,0 +1,312 @@
+// Code generated by internal/deno/gendts. DO NOT EDIT.
+
+export interface HealthRequest {
+  permissions: {
+    all: boolean;
+    allow: string[];
+    deny: string[];
+    allowUnknown?: boolean;
+  };
+  cpuHint?: number;
+  features: string[];
+  encodings: string[];
+}
+
+export interface HealthResponse {
+  ok: boolean;
+  backend?: {
+    ok: boolean;
+    message?: string;
+  } | null;
+  warnings?: string[];
+  features?: string[];
+  encodings?: string[];
+  capabilities?: string[];
+  protocolVersion?: string;
+}
+
+export interface CreateRequest {
+  props: unknown;
+  writeOnlyProps?: unknown;
+  id?: string;
+  idempotencyKey?: string;
+  pendingAsync?: boolean;
+}
+
+export interface CreateResponse {
+  id: string;
+  state: unknown;
+  sensitiveState: unknown;
+  pending?: boolean;
+  pollIntervalMs?: number;
+  stateChecksum?: string;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface PartialCreateState {
+  partialState: CreateResponse | null;
+}
+
+export interface CreateStatusRequest {
+  id: string;
+}
+
+export interface CreateProgress {
+  message: string;
+  percent?: number | null;
+}
+
+export interface CreateStatusResponse {
+  status: string;
+  progress?: CreateProgress | null;
+  pollIntervalMs?: number;
+  id?: string;
+  state: unknown;
+  sensitiveState: unknown;
+  error?: string;
+}
+
+export interface CreateReadRequest {
+  id: string;
+  props: unknown;
+  refreshOnly: boolean;
+}
+
+export interface CreateReadResponse {
+  props: unknown;
+  state: unknown;
+  sensitiveState: unknown;
+  exists: boolean | null;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface UpdateRequest {
+  id: string;
+  nextProps: unknown;
+  nextWriteOnlyProps?: unknown;
+  currentProps: unknown;
+  currentState: unknown;
+  currentSensitiveState: unknown;
+}
+
+export interface UpdateResponse {
+  state: unknown;
+  sensitiveState: unknown;
+  stateChecksum?: string;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface DeleteRequest {
+  id: string;
+  props: unknown;
+  state: unknown;
+  sensitiveState: unknown;
+  pendingAsync?: boolean;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface DeleteResponse {
+  done: boolean;
+  pending?: boolean;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface DeleteProgress {
+  id: string;
+  message: string;
+  percent?: number | null;
+}
+
+export interface DeleteCompleteRequest {
+  id: string;
+  done: boolean;
+  error?: string;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface DeleteBatchRequest {
+  items: (DeleteRequest | null)[];
+}
+
+export interface DeleteBatchResponse {
+  results: {
+    done: boolean;
+    pending?: boolean;
+    diagnostics?: {
+      severity: string;
+      summary: string;
+      detail: string;
+      propPath?: string[] | null;
+    }[] | null;
+    error?: {
+      code: number;
+      message: string;
+      data?: unknown;
+    } | null;
+  }[];
+}
+
+export interface ModifyPlanRequest {
+  id?: string | null;
+  planType: string;
+  nextProps: unknown;
+  currentProps?: unknown;
+  currentState?: unknown;
+  currentSensitiveState?: unknown;
+}
+
+export interface ModifyPlanResponse {
+  noChanges?: boolean | null;
+  modifiedProps?: unknown;
+  requiresReplacement?: boolean | null;
+  replaceTriggers?: string[] | null;
+  explanation?: string | null;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface ImportRequest {
+  id: string;
+}
+
+export interface ImportResponse {
+  props: unknown;
+  state: unknown;
+  sensitiveState: unknown;
+}
+
+export interface ImportSnapshotRequest {
+  snapshot: string;
+}
+
+export interface ExportStateRequest {
+  id: string;
+}
+
+export interface ExportStateResponse {
+  snapshot: string;
+}
+
+export interface OpenRequest {
+  props: unknown;
+}
+
+export interface OpenResponse {
+  result: unknown;
+  sensitiveResult: unknown;
+  renewAt?: number | null;
+  privateData?: unknown;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface RenewRequest {
+  privateData?: unknown;
+}
+
+export interface RenewResponse {
+  renewAt?: number | null;
+  privateData?: unknown;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface CloseRequest {
+  privateData?: unknown;
+}
+
+export interface CloseResponse {
+  done: boolean;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface InvokeRequest {
+  props: unknown;
+}
+
+export interface InvokeResponse {
+  done: boolean;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface InvokeProgressRequest {
+  message: string;
+  percent?: number | null;
+  current?: number;
+  total?: number;
+}
+
+export interface ReadRequest {
+  props: unknown;
+  streamId?: string;
+}
+
+export interface ReadResponse {
+  result: unknown;
+  sensitiveResult: unknown;
+  chunks?: number;
+  diagnostics?: {
+    severity: string;
+    summary: string;
+    detail: string;
+    propPath?: string[] | null;
+  }[] | null;
+}
+
+export interface ReadChunkRequest {
+  streamId: string;
+  seq: number;
+  chunk: unknown;
+}
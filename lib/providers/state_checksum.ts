@@ -0,0 +1,40 @@
+/** Optionally returned alongside a resource's state to opt in to state integrity checks. */
+export interface StateChecksum {
+  /**
+   * The checksum of the returned state as computed by stateChecksum. The provider stores it
+   * and warns if a later read returns a state that no longer matches it.
+   */
+  stateChecksum?: string;
+}
+
+// deno-lint-ignore no-explicit-any
+export function isStateChecksum(value: any): value is StateChecksum {
+  return value && typeof value === "object" && "stateChecksum" in value;
+}
+
+/**
+ * Computes the checksum of a resource's state, the hex encoded sha256 of its JSON with object keys sorted.
+ *
+ * Checksum the state without its `sensitive` property, that part is stored separately and is not verified.
+ *
+ * @example
+ * ```ts
+ * const state = { arn: bucket.arn };
+ * return { id: bucket.name, state, stateChecksum: await stateChecksum(state) };
+ * ```
+ */
+export async function stateChecksum(state: unknown): Promise<string> {
+  const data = new TextEncoder().encode(JSON.stringify(sortKeys(state)));
+  const hash = await crypto.subtle.digest("SHA-256", data);
+  return Array.from(new Uint8Array(hash), (b) => b.toString(16).padStart(2, "0")).join("");
+}
+
+function sortKeys(value: unknown): unknown {
+  if (Array.isArray(value)) return value.map(sortKeys);
+  if (value && typeof value === "object") {
+    return Object.fromEntries(
+      Object.keys(value).sort().map((k) => [k, sortKeys((value as Record<string, unknown>)[k])]),
+    );
+  }
+  return value;
+}